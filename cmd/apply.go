@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"reflect"
+
+	"github.com/dathan/go-vault-dump/pkg/diff"
+	"github.com/dathan/go-vault-dump/pkg/vault"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	applyDryRun bool
+	applyYes    bool
+)
+
+func init() {
+	Cmd := &cobra.Command{
+		Use:   "apply [flags] <patch.json>",
+		Short: "Apply a JSON Patch written by diff to Vault",
+		Args:  cobra.ExactArgs(1),
+		RunE:  doApply,
+	}
+	Cmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "check the patch against Vault without writing")
+	Cmd.Flags().BoolVarP(&applyYes, "yes", "y", false, "apply without confirming the summary of changes")
+	rootCmd.AddCommand(Cmd)
+}
+
+func doApply(cmd *cobra.Command, args []string) (err error) {
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	patches, err := diff.ParsePatch(data)
+	if err != nil {
+		return fmt.Errorf("error: %s: %w", args[0], err)
+	}
+	if len(patches) == 0 {
+		log.Println("Nothing to apply")
+		return nil
+	}
+	if !applyDryRun {
+		if err := checkWrites(viper.GetString(vaFlag)); err != nil {
+			return err
+		}
+	}
+
+	trace, err := tracer()
+	if err != nil {
+		return err
+	}
+	pathTokens, err := vault.ParsePathTokens(viper.GetStringSlice(pathTokenFlag))
+	if err != nil {
+		return err
+	}
+	vc, err := vault.NewClient(&vault.Config{
+		Auth:         auth(),
+		TLS:          tlsConfig(),
+		Namespace:    viper.GetString(namespaceFlag),
+		Retries:      viper.GetInt(retriesFlag),
+		RetryBackoff: viper.GetDuration(retryBackoffFlag),
+		MaxRetryWait: viper.GetDuration(maxRetryWaitFlag),
+		Timeout:      viper.GetDuration(requestTimeoutFlag),
+		RateLimit:    viper.GetFloat64(rateLimitFlag),
+		Context:      runContext(),
+		Address:      viper.GetString(vaFlag),
+		ReadOnly:     applyDryRun || readOnly(false),
+		Token:        vaultToken(),
+		Trace:        trace,
+		Usage:        requests,
+		Ignore:       &vault.Ignore{},
+		PathTokens:   pathTokens,
+	})
+	if err != nil {
+		return err
+	}
+	if !applyDryRun && !applyYes {
+		creates, overwrites, deletes := planPatches(vc, patches)
+		if err := confirmChanges(vc, "apply", creates, overwrites, deletes); err != nil {
+			return err
+		}
+	}
+
+	written, failures := []string{}, map[string]string{}
+	if !applyDryRun {
+		var r *run
+		if r, err = startRun("apply", viper.GetString(vaFlag)); err != nil {
+			return err
+		}
+		defer func() {
+			r.finish(written, failures, err)
+		}()
+	}
+
+	written, failures = applyPatches(vc, patches)
+	if len(failures) > 0 {
+		return fmt.Errorf("error: %d of %d secrets failed to apply", len(failures), len(patches))
+	}
+	return nil
+}
+
+// applyPatches applies the patch of each secret, the others are still
+// applied when one fails, and returns the paths applied and why the others
+// failed
+func applyPatches(vc *vault.Config, patches []diff.SecretPatch) ([]string, map[string]string) {
+	written, failures := []string{}, map[string]string{}
+	for _, p := range patches {
+		if err := applySecret(vc, p); err != nil {
+			log.Printf("Failed to apply %s: %s", p.Path, err)
+			failures[p.Path] = err.Error()
+			continue
+		}
+		written = append(written, p.Path)
+	}
+	return written, failures
+}
+
+// planPatches counts the secrets the patches create, overwrite and delete,
+// patches that can not be applied are left out and fail when applied
+func planPatches(vc *vault.Config, patches []diff.SecretPatch) (creates, overwrites, deletes int) {
+	for _, p := range patches {
+		data, _, err := vc.ReadSecret(p.Path)
+		if err != nil {
+			log.Printf("Warning: failed to read %s: %s", p.Path, err)
+			continue
+		}
+		live, err := asDumped(data)
+		if err != nil {
+			log.Printf("Warning: %s: %s", p.Path, err)
+			continue
+		}
+		result, err := p.Apply(live)
+		if err != nil {
+			log.Printf("Warning: %s: %s", p.Path, err)
+			continue
+		}
+		switch {
+		case reflect.DeepEqual(result, live):
+		case live == nil:
+			creates++
+		case result == nil:
+			deletes++
+		default:
+			overwrites++
+		}
+	}
+	return creates, overwrites, deletes
+}
+
+// applySecret applies the patch of a secret to its current version in Vault.
+// Writes use check-and-set against the version the patch was checked
+// against, so secrets changed in the meantime fail instead of being
+// overwritten.
+func applySecret(vc *vault.Config, p diff.SecretPatch) error {
+	data, version, err := vc.ReadSecret(p.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read: %w", err)
+	}
+	live, err := asDumped(data)
+	if err != nil {
+		return err
+	}
+	result, err := p.Apply(live)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case reflect.DeepEqual(result, live):
+		log.Printf("%s is up to date", p.Path)
+		return nil
+	case applyDryRun:
+		log.Printf("%s would be %s", p.Path, applyAction(live, result))
+		return nil
+	case result == nil:
+		// deletes can not check-and-set, the version is checked right before
+		if _, current, err := vc.ReadSecret(p.Path); err != nil {
+			return fmt.Errorf("failed to read: %w", err)
+		} else if current != version {
+			return fmt.Errorf("changed in Vault from version %d to %d", version, current)
+		}
+		resolved, err := vc.ResolveMountPath(p.Path, "data")
+		if err != nil {
+			return err
+		}
+		if err := vc.DeleteSecret(resolved); err != nil {
+			return err
+		}
+	default:
+		cas, err := vc.WriteSecretCAS(p.Path, result, version)
+		if err != nil {
+			return err
+		}
+		if !cas {
+			log.Printf("Warning: %s is not on a KV version 2 mount, it was written without check-and-set", p.Path)
+		}
+	}
+	log.Printf("%s %s", p.Path, applyAction(live, result))
+	return nil
+}
+
+func applyAction(live, result map[string]interface{}) string {
+	switch {
+	case live == nil:
+		return "created"
+	case result == nil:
+		return "deleted"
+	}
+	return "updated"
+}
@@ -1,55 +1,262 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/exec"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/dathan/go-vault-dump/pkg/aws"
+	"github.com/dathan/go-vault-dump/pkg/fault"
+	"github.com/dathan/go-vault-dump/pkg/feature"
+	"github.com/dathan/go-vault-dump/pkg/print"
+	"github.com/dathan/go-vault-dump/pkg/vault"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 const (
+	auditSyslogFlag = "audit-syslog"
+	faultInjectFlag = "fault-inject"
 	ignoreKeysFlag  = "ignore-keys"
 	ignorePathsFlag = "ignore-paths"
+	namespaceFlag   = "namespace"
+	noOverwriteFlag = "no-overwrite"
+	s3AccelFlag     = "s3-accelerate"
+	s3ClassFlag     = "s3-storage-class"
+	pathTokenFlag   = "path-token"
+	rateLimitFlag   = "rate-limit"
+	readOnlyFlag    = "read-only"
+	remoteCfgFlag   = "remote-config"
+	retriesFlag     = "retries"
+	traceFlag       = "trace"
+	unwrapTokenFlag = "unwrap-token"
 	vaFlag          = "vault-addr"
 	vtFlag          = "vault-token"
+
+	maxRetryWaitFlag   = "max-retry-wait"
+	requestTimeoutFlag = "request-timeout"
+	retryBackoffFlag   = "retry-backoff"
+
+	caCertFlag        = "ca-cert"
+	caPathFlag        = "ca-path"
+	clientCertFlag    = "client-cert"
+	clientKeyFlag     = "client-key"
+	tlsServerNameFlag = "tls-server-name"
+	tlsSkipVerifyFlag = "tls-skip-verify"
+
+	cloudWatchNamespaceFlag = "cloudwatch-namespace"
+	eventBridgeBusFlag      = "eventbridge-bus"
+	healthcheckURLFlag      = "healthcheck-url"
+	historyFileFlag         = "history-file"
+
+	awsIMDSv2OnlyFlag           = "aws-imds-v2-only"
+	awsProfileFlag              = "aws-profile"
+	awsRoleARNFlag              = "aws-role-arn"
+	awsWebIdentityTokenFileFlag = "aws-web-identity-token-file"
+
+	appRoleMountFlag           = "approle-mount"
+	appRoleRoleIDFlag          = "approle-role-id"
+	appRoleSecretIDFlag        = "approle-secret-id"
+	appRoleWrappedSecretIDFlag = "approle-wrapped-secret-id"
+
+	authMethodFlag      = "auth-method"
+	awsAuthMountFlag    = "aws-auth-mount"
+	awsAuthRoleFlag     = "aws-auth-role"
+	awsAuthServerIDFlag = "aws-auth-server-id"
+	k8sMountFlag        = "k8s-mount"
+	k8sRoleFlag         = "k8s-role"
+	k8sTokenFileFlag    = "k8s-token-file"
+	oidcCallbackFlag    = "oidc-callback-addr"
+	oidcMountFlag       = "oidc-mount"
+	oidcRoleFlag        = "oidc-role"
+	oidcSkipBrowserFlag = "oidc-skip-browser"
+
+	smtpAddrFlag     = "smtp-addr"
+	smtpBodyFlag     = "smtp-body"
+	smtpFromFlag     = "smtp-from"
+	smtpPasswordFlag = "smtp-password"
+	smtpSubjectFlag  = "smtp-subject"
+	smtpToFlag       = "smtp-to"
+	smtpUsernameFlag = "smtp-username"
+
+	compactFlag       = "compact"
+	escapeHTMLFlag    = "escape-html"
+	formatFlag        = "format"
+	prettyFlag        = "pretty"
+	yamlIndentFlag    = "yaml-indent"
+	yamlLineWidthFlag = "yaml-line-width"
+	yamlQuoteFlag     = "yaml-quote"
+)
+
+// formats of --format, the human readable text or JSON for automation
+const (
+	formatText = "text"
+	formatJSON = "json"
 )
 
 var (
 	cfgFile string
-	rootCmd *cobra.Command
+	// rootCmd is created before any init function runs, the subcommands add
+	// themselves to it from the init functions of their files
+	rootCmd = &cobra.Command{
+		Use: "vault-tools <subcommand> [flags]",
+	}
 	version = "dev" // https://goreleaser.com/environment/#using-the-mainversion
+	commit  = "none"
+	date    = "unknown"
 	Verbose bool
 )
 
+// exitSkipped is the exit status of a dump written without some secrets,
+// with --fail-on-skipped, distinct from 1 for a command that failed
+const exitSkipped = 3
+
+// skippedError is returned by a dump written without some secrets, see
+// exitSkipped
+type skippedError struct {
+	skipped int
+}
+
+func (e *skippedError) Error() string {
+	return fmt.Sprintf("%d secrets could not be dumped and were skipped", e.skipped)
+}
+
 func exitErr(e error) {
 	log.SetOutput(os.Stderr)
 	log.Println(e)
+	var skipped *skippedError
+	if errors.As(e, &skipped) {
+		os.Exit(exitSkipped)
+	}
 	os.Exit(1)
 }
 
 func init() {
-	rootCmd = &cobra.Command{
-		Use: "vault-tools <subcommand> [flags]",
-	}
 	rootCmd.Version = version
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := checkAuth(); err != nil {
+			return err
+		}
+		if err := loadRemoteConfig(); err != nil {
+			return err
+		}
+		return configureAWS(cmd, args)
+	}
 
 	logSetup()
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.vault-dump/config.yaml)")
+	rootCmd.PersistentFlags().String(remoteCfgFlag, "", "KV path of a secret whose fields are merged into the config file, read from Vault at startup")
 	rootCmd.PersistentFlags().String(vaFlag, "https://127.0.0.1:8200", "vault url")
-	rootCmd.PersistentFlags().String(vtFlag, "", "vault token")
+	rootCmd.PersistentFlags().String(vtFlag, "", "vault token (default the token of the vault CLI)")
+	rootCmd.PersistentFlags().Bool(unwrapTokenFlag, false, "the vault token is a response wrapping token, unwrap it and use the token it wraps")
+	rootCmd.PersistentFlags().String(namespaceFlag, "", "Vault Enterprise namespace, sent as X-Vault-Namespace (default $VAULT_NAMESPACE)")
+	rootCmd.PersistentFlags().Int(retriesFlag, 5, "retries of a failed request to Vault, and of a failed write")
+	rootCmd.PersistentFlags().Duration(retryBackoffFlag, time.Second, "wait before the first retry, doubled for every retry")
+	rootCmd.PersistentFlags().Duration(maxRetryWaitFlag, 30*time.Second, "longest wait between retries, including the Retry-After of Vault")
+	rootCmd.PersistentFlags().Duration(requestTimeoutFlag, 60*time.Second, "timeout of each request to Vault")
+	rootCmd.PersistentFlags().Float64(rateLimitFlag, 0, "most requests per second to Vault, retries included, 0 for no limit")
+	rootCmd.PersistentFlags().String(caCertFlag, "", "PEM file of the CAs to verify Vault with (default $VAULT_CACERT)")
+	rootCmd.PersistentFlags().String(caPathFlag, "", "directory of PEM files of the CAs to verify Vault with (default $VAULT_CAPATH)")
+	rootCmd.PersistentFlags().String(clientCertFlag, "", "PEM file of the client certificate for Vault (default $VAULT_CLIENT_CERT)")
+	rootCmd.PersistentFlags().String(clientKeyFlag, "", "PEM file of the key of --client-cert (default $VAULT_CLIENT_KEY)")
+	rootCmd.PersistentFlags().String(tlsServerNameFlag, "", "name to verify the certificate of Vault against (default $VAULT_TLS_SERVER_NAME)")
+	rootCmd.PersistentFlags().Bool(tlsSkipVerifyFlag, false, "do not verify the certificate of Vault, insecure (default $VAULT_SKIP_VERIFY)")
+	rootCmd.PersistentFlags().String(appRoleRoleIDFlag, "", "log in with this AppRole role ID instead of --vault-token, the secret ID is read from VAULT_DUMP_APPROLE_SECRET_ID or, wrapped, VAULT_DUMP_APPROLE_WRAPPED_SECRET_ID")
+	rootCmd.PersistentFlags().String(appRoleMountFlag, "approle", "path of the AppRole auth method")
+	rootCmd.PersistentFlags().String(authMethodFlag, "", "how to log in to Vault, [token, approle, aws, kubernetes, oidc] (default approle with --approle-role-id, else token)")
+	rootCmd.PersistentFlags().String(awsAuthRoleFlag, "", "Vault role to log in as with the AWS auth method")
+	rootCmd.PersistentFlags().String(awsAuthMountFlag, "aws", "path of the AWS auth method")
+	rootCmd.PersistentFlags().String(awsAuthServerIDFlag, "", "value of the X-Vault-AWS-IAM-Server-ID header the AWS auth method requires, if any")
+	rootCmd.PersistentFlags().String(k8sRoleFlag, "", "Vault role to log in as with the kubernetes auth method")
+	rootCmd.PersistentFlags().String(k8sMountFlag, "kubernetes", "path of the kubernetes auth method")
+	rootCmd.PersistentFlags().String(k8sTokenFileFlag, vault.ServiceAccountTokenFile, "service account token to log in with the kubernetes auth method")
+	rootCmd.PersistentFlags().String(oidcRoleFlag, "", "Vault role to log in as with the OIDC auth method (default the role of the mount)")
+	rootCmd.PersistentFlags().String(oidcMountFlag, "oidc", "path of the OIDC auth method")
+	rootCmd.PersistentFlags().String(oidcCallbackFlag, "localhost:8250", "address to receive the OIDC callback on, must match a redirect URI of the role")
+	rootCmd.PersistentFlags().Bool(oidcSkipBrowserFlag, false, "only print the OIDC login URL instead of opening it in a browser")
+	rootCmd.PersistentFlags().StringSlice(pathTokenFlag, []string{}, "vault token for the paths below a prefix, prefix=token, may be repeated")
 	rootCmd.PersistentFlags().StringSlice(ignoreKeysFlag, []string{}, "comma separated list of key names to ignore")
 	rootCmd.PersistentFlags().StringSlice(ignorePathsFlag, []string{}, "comma separated list of paths to ignore")
+	rootCmd.PersistentFlags().String(auditSyslogFlag, "", "send CEF audit events to this syslog collector, udp://host:port, tcp://host:port or local")
+	rootCmd.PersistentFlags().String(awsProfileFlag, "", "AWS shared config profile")
+	rootCmd.PersistentFlags().String(awsRoleARNFlag, "", "AWS role to assume with the default credentials, or with --aws-web-identity-token-file")
+	rootCmd.PersistentFlags().String(awsWebIdentityTokenFileFlag, "", "OIDC token file to assume --aws-role-arn with")
+	rootCmd.PersistentFlags().Bool(awsIMDSv2OnlyFlag, false, "never fall back to IMDSv1 for EC2 instance role credentials")
+	rootCmd.PersistentFlags().String(cloudWatchNamespaceFlag, "", "publish run metrics to this CloudWatch namespace")
+	rootCmd.PersistentFlags().String(eventBridgeBusFlag, "", "publish a completion event for each run to this EventBridge bus")
+	rootCmd.PersistentFlags().String(healthcheckURLFlag, "", "ping this URL at the start and end of each run, healthchecks.io style")
+	rootCmd.PersistentFlags().String(historyFileFlag, "", "record the results of recent runs to this file, for status")
+	rootCmd.PersistentFlags().String(smtpAddrFlag, "", "SMTP server host:port to email failed runs through")
+	rootCmd.PersistentFlags().String(smtpFromFlag, "", "sender of failure emails")
+	rootCmd.PersistentFlags().StringSlice(smtpToFlag, []string{}, "recipients of failure emails")
+	rootCmd.PersistentFlags().String(smtpUsernameFlag, "", "SMTP username, the password is read from VAULT_DUMP_SMTP_PASSWORD")
+	rootCmd.PersistentFlags().String(smtpSubjectFlag, "", "template of the subject of failure emails")
+	rootCmd.PersistentFlags().String(smtpBodyFlag, "", "template of the body of failure emails")
+	rootCmd.PersistentFlags().String(formatFlag, formatText, "output format of list, status, whoami and doctor on stdout, [text, json], diff and stats have their own")
+	rootCmd.PersistentFlags().Bool(prettyFlag, false, "indent json output by 2 spaces")
+	rootCmd.PersistentFlags().Bool(compactFlag, false, "write json output on a single line, the default, overrides --pretty")
+	rootCmd.PersistentFlags().Bool(escapeHTMLFlag, true, "write <, > and & in json output as \\u003c, \\u003e and \\u0026")
+	rootCmd.PersistentFlags().Int(yamlIndentFlag, 0, "spaces per level of yaml output, 2 to 9 (default 2)")
+	rootCmd.PersistentFlags().String(yamlQuoteFlag, "", fmt.Sprintf("quotes of yaml strings that could be read as another type, %v, always quotes every string (default single)", print.QuoteStyles))
+	rootCmd.PersistentFlags().Int(yamlLineWidthFlag, 0, "fold yaml strings at this line width, 0 never folds")
+	rootCmd.PersistentFlags().Bool(noOverwriteFlag, false, "fail S3 uploads of objects that already exist instead of replacing them")
+	rootCmd.PersistentFlags().String(s3ClassFlag, "", "storage class of uploaded objects, e.g. STANDARD_IA or GLACIER_IR")
+	rootCmd.PersistentFlags().Bool(s3AccelFlag, false, "upload through S3 Transfer Acceleration")
+	rootCmd.PersistentFlags().Bool(readOnlyFlag, false, "refuse every write to Vault (default true for dump)")
+	rootCmd.PersistentFlags().String(traceFlag, "", "append the method, path, status, latency and retry count of every Vault request to this file")
+	rootCmd.PersistentFlags().String(faultInjectFlag, "", "inject faults, error=rate,slow=rate,delay=duration,upload=rate")
+	rootCmd.PersistentFlags().MarkHidden(faultInjectFlag)
 	rootCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "v", false, "verbose output")
 
+	viper.BindPFlag(remoteCfgFlag, rootCmd.PersistentFlags().Lookup(remoteCfgFlag))
 	viper.BindPFlag(ignorePathsFlag, rootCmd.PersistentFlags().Lookup(ignorePathsFlag))
 	viper.BindPFlag(ignoreKeysFlag, rootCmd.PersistentFlags().Lookup(ignoreKeysFlag))
 	viper.BindPFlag(vaFlag, rootCmd.PersistentFlags().Lookup(vaFlag))
 	viper.BindPFlag(vtFlag, rootCmd.PersistentFlags().Lookup(vtFlag))
+	viper.BindPFlag(unwrapTokenFlag, rootCmd.PersistentFlags().Lookup(unwrapTokenFlag))
+	viper.BindPFlag(namespaceFlag, rootCmd.PersistentFlags().Lookup(namespaceFlag))
+	for _, f := range []string{retriesFlag, retryBackoffFlag, maxRetryWaitFlag, requestTimeoutFlag, rateLimitFlag} {
+		viper.BindPFlag(f, rootCmd.PersistentFlags().Lookup(f))
+	}
+	for _, f := range []string{caCertFlag, caPathFlag, clientCertFlag, clientKeyFlag, tlsServerNameFlag, tlsSkipVerifyFlag} {
+		viper.BindPFlag(f, rootCmd.PersistentFlags().Lookup(f))
+	}
+	viper.BindPFlag(pathTokenFlag, rootCmd.PersistentFlags().Lookup(pathTokenFlag))
+	viper.BindPFlag(appRoleRoleIDFlag, rootCmd.PersistentFlags().Lookup(appRoleRoleIDFlag))
+	viper.BindPFlag(appRoleMountFlag, rootCmd.PersistentFlags().Lookup(appRoleMountFlag))
+	for _, f := range []string{authMethodFlag, awsAuthRoleFlag, awsAuthMountFlag, awsAuthServerIDFlag, k8sRoleFlag, k8sMountFlag, k8sTokenFileFlag, oidcRoleFlag, oidcMountFlag, oidcCallbackFlag, oidcSkipBrowserFlag} {
+		viper.BindPFlag(f, rootCmd.PersistentFlags().Lookup(f))
+	}
+	viper.BindPFlag(auditSyslogFlag, rootCmd.PersistentFlags().Lookup(auditSyslogFlag))
+	for _, f := range []string{awsProfileFlag, awsRoleARNFlag, awsWebIdentityTokenFileFlag, awsIMDSv2OnlyFlag} {
+		viper.BindPFlag(f, rootCmd.PersistentFlags().Lookup(f))
+	}
+	viper.BindPFlag(cloudWatchNamespaceFlag, rootCmd.PersistentFlags().Lookup(cloudWatchNamespaceFlag))
+	viper.BindPFlag(eventBridgeBusFlag, rootCmd.PersistentFlags().Lookup(eventBridgeBusFlag))
+	viper.BindPFlag(healthcheckURLFlag, rootCmd.PersistentFlags().Lookup(healthcheckURLFlag))
+	viper.BindPFlag(historyFileFlag, rootCmd.PersistentFlags().Lookup(historyFileFlag))
+	for _, f := range []string{smtpAddrFlag, smtpFromFlag, smtpToFlag, smtpUsernameFlag, smtpSubjectFlag, smtpBodyFlag} {
+		viper.BindPFlag(f, rootCmd.PersistentFlags().Lookup(f))
+	}
+	for _, f := range []string{formatFlag, prettyFlag, compactFlag, escapeHTMLFlag, yamlIndentFlag, yamlQuoteFlag, yamlLineWidthFlag} {
+		viper.BindPFlag(f, rootCmd.PersistentFlags().Lookup(f))
+	}
+	viper.BindPFlag(noOverwriteFlag, rootCmd.PersistentFlags().Lookup(noOverwriteFlag))
+	viper.BindPFlag(s3ClassFlag, rootCmd.PersistentFlags().Lookup(s3ClassFlag))
+	viper.BindPFlag(s3AccelFlag, rootCmd.PersistentFlags().Lookup(s3AccelFlag))
+	viper.BindPFlag(readOnlyFlag, rootCmd.PersistentFlags().Lookup(readOnlyFlag))
+	viper.BindPFlag(traceFlag, rootCmd.PersistentFlags().Lookup(traceFlag))
+	viper.BindPFlag(faultInjectFlag, rootCmd.PersistentFlags().Lookup(faultInjectFlag))
 }
 
 func initConfig() {
@@ -73,6 +280,314 @@ func initConfig() {
 	viper.AutomaticEnv()
 }
 
+// configureAWS applies the AWS credential flags, the default credential
+// chain is kept when none is set
+func configureAWS(cmd *cobra.Command, args []string) error {
+	c := aws.Credentials{
+		Profile:              viper.GetString(awsProfileFlag),
+		RoleARN:              viper.GetString(awsRoleARNFlag),
+		WebIdentityTokenFile: viper.GetString(awsWebIdentityTokenFileFlag),
+		IMDSv2Only:           viper.GetBool(awsIMDSv2OnlyFlag),
+	}
+	if c == (aws.Credentials{}) {
+		return nil
+	}
+	if err := aws.Configure(c); err != nil {
+		return fmt.Errorf("error: failed to configure AWS credentials: %w", err)
+	}
+	return nil
+}
+
+// uploads applies the S3 upload flags
+func uploads() error {
+	class := viper.GetString(s3ClassFlag)
+	if err := aws.ValidateStorageClass(class); err != nil {
+		return fmt.Errorf("error: %w", err)
+	}
+	aws.StorageClass = class
+	aws.Accelerate = viper.GetBool(s3AccelFlag)
+	aws.NoOverwrite = viper.GetBool(noOverwriteFlag)
+	return nil
+}
+
+// faults parses --fault-inject and enables it for uploads, Vault clients
+// enable it through vault.Config
+func faults() (*fault.Config, error) {
+	f, err := fault.Parse(viper.GetString(faultInjectFlag))
+	if err != nil {
+		return nil, err
+	}
+	if f != nil {
+		log.Println("Warning: fault injection is enabled")
+	}
+	aws.Faults = f
+	return f, nil
+}
+
+var (
+	traceMu   sync.Mutex
+	traceFile *os.File
+)
+
+// tracer opens --trace for appending, the file is shared by every Vault
+// client of the run, nil is returned when tracing is off
+func tracer() (io.Writer, error) {
+	path := viper.GetString(traceFlag)
+	if path == "" {
+		return nil, nil
+	}
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	if traceFile == nil {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("error: failed to open the trace file: %w", err)
+		}
+		traceFile = f
+	}
+	return traceFile, nil
+}
+
+var (
+	authMu sync.Mutex
+	login  vault.Login
+
+	tokenOnce    sync.Once
+	defaultToken string
+)
+
+// vaultToken returns --vault-token, or with token auth the token the vault
+// CLI would use when it is not set, so a `vault login` is picked up
+func vaultToken() string {
+	if token := viper.GetString(vtFlag); token != "" || authMethod() != "token" {
+		return token
+	}
+	tokenOnce.Do(func() {
+		var err error
+		if defaultToken, err = vault.DefaultToken(); err != nil {
+			log.Printf("Warning: failed to read the token of the vault CLI, %s", err)
+		}
+	})
+	return defaultToken
+}
+
+// authMethod returns the auth method of --auth-method, approle when only
+// --approle-role-id is set
+func authMethod() string {
+	method := viper.GetString(authMethodFlag)
+	if method == "" && viper.GetString(appRoleRoleIDFlag) != "" {
+		return "approle"
+	}
+	if method == "" {
+		return "token"
+	}
+	return method
+}
+
+// authMethods are the values of --auth-method
+var authMethods = []string{"token", "approle", "aws", "kubernetes", "oidc"}
+
+// checkAuth fails early on an unknown auth method or one missing its role
+func checkAuth() error {
+	if viper.GetBool(unwrapTokenFlag) && authMethod() != "token" {
+		return fmt.Errorf("error: --%s only applies to --%s=token", unwrapTokenFlag, authMethodFlag)
+	}
+	switch authMethod() {
+	case "token", "oidc":
+	case "approle":
+		if viper.GetString(appRoleRoleIDFlag) == "" {
+			return fmt.Errorf("error: --%s is required with --%s=approle", appRoleRoleIDFlag, authMethodFlag)
+		}
+	case "aws":
+		if err := feature.Require(feature.AWS); err != nil {
+			return fmt.Errorf("error: --%s=aws: %w", authMethodFlag, err)
+		}
+		if viper.GetString(awsAuthRoleFlag) == "" {
+			return fmt.Errorf("error: --%s is required with --%s=aws", awsAuthRoleFlag, authMethodFlag)
+		}
+	case "kubernetes":
+		if viper.GetString(k8sRoleFlag) == "" {
+			return fmt.Errorf("error: --%s is required with --%s=kubernetes", k8sRoleFlag, authMethodFlag)
+		}
+	default:
+		return fmt.Errorf("error: invalid --%s %q, expected one of [%s]", authMethodFlag, viper.GetString(authMethodFlag), strings.Join(authMethods, ", "))
+	}
+	return nil
+}
+
+// auth returns the login of the auth method, or nil to use --vault-token as is.
+// It is shared by every client of the run, so a wrapped secret ID is only
+// unwrapped once.
+func auth() vault.Login {
+	authMu.Lock()
+	defer authMu.Unlock()
+	if login != nil {
+		return login
+	}
+	switch authMethod() {
+	case "approle":
+		login = &vault.AppRole{
+			Mount:           viper.GetString(appRoleMountFlag),
+			RoleID:          viper.GetString(appRoleRoleIDFlag),
+			SecretID:        viper.GetString(appRoleSecretIDFlag),
+			WrappedSecretID: viper.GetString(appRoleWrappedSecretIDFlag),
+		}
+	case "aws":
+		login = &vault.AWS{
+			Mount:    viper.GetString(awsAuthMountFlag),
+			Role:     viper.GetString(awsAuthRoleFlag),
+			ServerID: viper.GetString(awsAuthServerIDFlag),
+			Sign:     aws.SignedCallerIdentity,
+		}
+	case "kubernetes":
+		login = &vault.Kubernetes{
+			Mount:     viper.GetString(k8sMountFlag),
+			Role:      viper.GetString(k8sRoleFlag),
+			TokenFile: viper.GetString(k8sTokenFileFlag),
+		}
+	case "oidc":
+		o := &vault.OIDC{
+			Mount:        viper.GetString(oidcMountFlag),
+			Role:         viper.GetString(oidcRoleFlag),
+			CallbackAddr: viper.GetString(oidcCallbackFlag),
+		}
+		if !viper.GetBool(oidcSkipBrowserFlag) {
+			o.Open = openBrowser
+		}
+		login = o
+	case "token":
+		if !viper.GetBool(unwrapTokenFlag) {
+			return nil
+		}
+		login = &vault.WrappedToken{Token: vaultToken()}
+	default:
+		// a nil *AppRole would not be a nil Login
+		return nil
+	}
+	return login
+}
+
+// openBrowser opens url in the default browser of the operator
+func openBrowser(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	default:
+		return exec.Command("xdg-open", url).Start()
+	}
+}
+
+// tlsConfig returns the TLS flags, nil when none is set so the VAULT_*
+// environment variables apply
+func tlsConfig() *vault.TLS {
+	t := vault.TLS{
+		CACert:     viper.GetString(caCertFlag),
+		CAPath:     viper.GetString(caPathFlag),
+		ClientCert: viper.GetString(clientCertFlag),
+		ClientKey:  viper.GetString(clientKeyFlag),
+		ServerName: viper.GetString(tlsServerNameFlag),
+		SkipVerify: viper.GetBool(tlsSkipVerifyFlag),
+	}
+	if t == (vault.TLS{}) {
+		return nil
+	}
+	return &t
+}
+
+// yamlOptions returns the options of the yaml encoder, nil when none is set to
+// write yaml as before
+func yamlOptions() (*print.YAMLOptions, error) {
+	o := print.YAMLOptions{
+		Indent:    viper.GetInt(yamlIndentFlag),
+		Quote:     viper.GetString(yamlQuoteFlag),
+		LineWidth: viper.GetInt(yamlLineWidthFlag),
+	}
+	if o == (print.YAMLOptions{}) {
+		return nil, nil
+	}
+	if err := o.Validate(); err != nil {
+		return nil, fmt.Errorf("error: %w", err)
+	}
+	return &o, nil
+}
+
+// jsonOptions returns the options of the json encoder, nil when none is set to
+// write compact json as before
+func jsonOptions() *print.JSONOptions {
+	o := print.JSONOptions{NoEscapeHTML: !viper.GetBool(escapeHTMLFlag)}
+	if viper.GetBool(prettyFlag) && !viper.GetBool(compactFlag) {
+		o.Indent = 2
+	}
+	if o == (print.JSONOptions{}) {
+		return nil
+	}
+	return &o
+}
+
+// toJSON encodes v as json with the json options
+func toJSON(v interface{}) (string, error) {
+	if o := jsonOptions(); o != nil {
+		return print.ToJSONWith(v, *o)
+	}
+	return print.ToJSON(v)
+}
+
+// jsonFormat reports whether --format asks for JSON output. diff and stats
+// have a --format of their own, which takes json as well.
+func jsonFormat() (bool, error) {
+	switch f := viper.GetString(formatFlag); f {
+	case formatText, "":
+		return false, nil
+	case formatJSON:
+		return true, nil
+	default:
+		return false, fmt.Errorf("error: invalid --format %q, expected %s or %s", f, formatText, formatJSON)
+	}
+}
+
+// ownFormat returns format, the --format of a command with formats of its
+// own, or json when it was not given and --format json is set in the config
+// file or environment
+func ownFormat(cmd *cobra.Command, format string) string {
+	if !cmd.Flags().Changed(formatFlag) && viper.GetString(formatFlag) == formatJSON {
+		return formatJSON
+	}
+	return format
+}
+
+// printJSON prints v to stdout as json with the json options
+func printJSON(v interface{}) error {
+	out, err := toJSON(v)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
+
+// toYaml encodes v as yaml with the yaml options
+func toYaml(v interface{}) (string, error) {
+	o, err := yamlOptions()
+	if err != nil {
+		return "", err
+	}
+	if o == nil {
+		return print.ToYaml(v)
+	}
+	return print.ToYamlWith(v, *o)
+}
+
+// readOnly reports whether writes to Vault are refused, def is the default of
+// the command unless --read-only is set
+func readOnly(def bool) bool {
+	if viper.IsSet(readOnlyFlag) {
+		return viper.GetBool(readOnlyFlag)
+	}
+	return def
+}
+
 func logSetup() {
 	log.SetFlags(0)
 	if Verbose {
@@ -81,7 +596,22 @@ func logSetup() {
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ExecuteContext(context.Background())
+}
+
+// ExecuteContext runs the command line with ctx, which stops dumps and every
+// request to Vault once it is done, see main
+func ExecuteContext(ctx context.Context) {
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		exitErr(err)
 	}
 }
+
+// runContext returns the context of the command line, done once the process
+// is asked to stop
+func runContext() context.Context {
+	if ctx := rootCmd.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
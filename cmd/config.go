@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+	"github.com/spf13/viper"
+)
+
+// localSettings are needed to read the remote config, they are only taken
+// from flags, the environment and the config file
+var localSettings = []string{
+	remoteCfgFlag, vaFlag, vtFlag, unwrapTokenFlag, namespaceFlag, pathTokenFlag,
+	caCertFlag, caPathFlag, clientCertFlag, clientKeyFlag, tlsServerNameFlag, tlsSkipVerifyFlag,
+	authMethodFlag, appRoleMountFlag, appRoleRoleIDFlag, appRoleSecretIDFlag, appRoleWrappedSecretIDFlag,
+	awsAuthMountFlag, awsAuthRoleFlag, awsAuthServerIDFlag, k8sMountFlag, k8sRoleFlag, k8sTokenFileFlag,
+	oidcCallbackFlag, oidcMountFlag, oidcRoleFlag, oidcSkipBrowserFlag,
+}
+
+// loadRemoteConfig merges the fields of the secret at --remote-config into
+// the config file, each field a setting as it is named there. Flags and the
+// environment still take precedence over it.
+func loadRemoteConfig() error {
+	path := viper.GetString(remoteCfgFlag)
+	if path == "" {
+		return nil
+	}
+	trace, err := tracer()
+	if err != nil {
+		return err
+	}
+	vc, err := vault.NewClient(&vault.Config{
+		Auth:         auth(),
+		TLS:          tlsConfig(),
+		Namespace:    viper.GetString(namespaceFlag),
+		Retries:      viper.GetInt(retriesFlag),
+		RetryBackoff: viper.GetDuration(retryBackoffFlag),
+		MaxRetryWait: viper.GetDuration(maxRetryWaitFlag),
+		Timeout:      viper.GetDuration(requestTimeoutFlag),
+		RateLimit:    viper.GetFloat64(rateLimitFlag),
+		Context:      runContext(),
+		Address:      viper.GetString(vaFlag),
+		ReadOnly:     true,
+		Token:        vaultToken(),
+		Trace:        trace,
+		Ignore:       &vault.Ignore{},
+	})
+	if err != nil {
+		return fmt.Errorf("error: failed to read the remote config: %w", err)
+	}
+	defer vc.Close()
+
+	data, _, err := vc.ReadSecret(path)
+	if err != nil {
+		return fmt.Errorf("error: failed to read the remote config %s: %w", path, err)
+	}
+	if data == nil {
+		return fmt.Errorf("error: no remote config at %s", path)
+	}
+	settings, ignored := remoteSettings(data)
+	for _, k := range ignored {
+		log.Printf("Warning: ignoring %s in the remote config, it is only read locally\n", k)
+	}
+	if err := viper.MergeConfigMap(settings); err != nil {
+		return fmt.Errorf("error: invalid remote config %s: %w", path, err)
+	}
+	log.Printf("Read %d settings from the remote config at %s\n", len(settings), path)
+	return nil
+}
+
+// remoteSettings returns the settings of a remote config and the sorted
+// names of the local settings it holds, which are left out
+func remoteSettings(data map[string]interface{}) (map[string]interface{}, []string) {
+	settings := make(map[string]interface{}, len(data))
+	ignored := []string{}
+	for k, v := range data {
+		local := false
+		for _, l := range localSettings {
+			if strings.EqualFold(k, l) {
+				local = true
+				break
+			}
+		}
+		if local {
+			ignored = append(ignored, k)
+			continue
+		}
+		settings[k] = configValue(v)
+	}
+	sort.Strings(ignored)
+	return settings, ignored
+}
+
+// configValue converts the numbers of a value read from Vault, decoded as
+// json.Number, to the types the config file decodes them to
+func configValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return int(n)
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for k, vv := range v {
+			converted[k] = configValue(vv)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, vv := range v {
+			converted[i] = configValue(vv)
+		}
+		return converted
+	}
+	return v
+}
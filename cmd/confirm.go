@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// confirmChanges shows what command is about to change in the cluster vc
+// talks to and has the operator type the name of the cluster, or its address
+// when it has no name, before anything is written
+func confirmChanges(vc *vault.Config, command string, creates, overwrites, deletes int) error {
+	addr := vc.Client.Address()
+	target, expected := addr, addr
+	if cluster, err := vc.Fingerprint(); err != nil {
+		log.Printf("Warning: could not identify the target cluster, %s", err)
+	} else if cluster.Name != "" {
+		target, expected = fmt.Sprintf("cluster %s at %s", cluster, addr), cluster.Name
+	}
+
+	fmt.Fprintf(os.Stderr, "%s to %s:\n", command, target)
+	fmt.Fprintf(os.Stderr, "  %d creates\n  %d overwrites\n  %d deletes\n", creates, overwrites, deletes)
+	if creates+overwrites+deletes == 0 {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Type %s to continue: ", expected)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.TrimSpace(answer) != expected {
+		return fmt.Errorf("error: %s cancelled, pass --yes to skip the confirmation", command)
+	}
+	return nil
+}
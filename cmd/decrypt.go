@@ -1,11 +1,13 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 
 	"github.com/dathan/go-vault-dump/pkg/aws"
+	"github.com/dathan/go-vault-dump/pkg/stream"
 	"github.com/spf13/cobra"
 )
 
@@ -28,11 +30,24 @@ func doDecrypt(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	dd, err := aws.KMSDecrypt(string(data))
-	if err != nil {
-		return err
+	// raft snapshots and other large artifacts are encrypted streams
+	if bytes.HasPrefix(data, []byte(stream.Magic)) {
+		r, err := stream.NewReader(bytes.NewReader(data), func(h stream.Header) ([]byte, error) {
+			return aws.KMSDecryptDataKey(h.Key)
+		})
+		if err != nil {
+			return err
+		}
+		if data, err = ioutil.ReadAll(r); err != nil {
+			return err
+		}
+	} else {
+		dd, err := aws.KMSDecrypt(string(data))
+		if err != nil {
+			return err
+		}
+		data = []byte(dd)
 	}
-	data = []byte(dd)
 
 	if destPath == "" {
 		fmt.Print(string(data))
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/dathan/go-vault-dump/pkg/aws"
+	"github.com/dathan/go-vault-dump/pkg/dump"
+)
+
+// deltaUpload tracks an upload of only the files of a dump that changed
+// since the previous upload to the same location
+type deltaUpload struct {
+	// previous is the delta manifest of the previous upload, nil without one
+	previous  *dump.DeltaManifest
+	next      *dump.DeltaManifest
+	checksums map[string]string
+	// reused is the number of files not uploaded again
+	reused int
+}
+
+// newDeltaUpload reads the latest delta manifest below s3path to compare the
+// files of a dump with checksums against. Without one, or when it can not be
+// read, every file is uploaded.
+func newDeltaUpload(s3path string, checksums map[string]string) *deltaUpload {
+	d := &deltaUpload{next: dump.NewDeltaManifest(), checksums: checksums}
+	location, err := latestDelta(s3path)
+	if err != nil {
+		log.Printf("Warning: failed to find the previous delta manifest below %s, uploading every file, %s\n", s3path, err.Error())
+		return d
+	}
+	if location == "" {
+		log.Printf("No previous delta manifest below %s, uploading every file\n", s3path)
+		return d
+	}
+	ciphertext, err := aws.S3Get(location)
+	if err == nil {
+		var plaintext string
+		if plaintext, err = aws.KMSDecrypt(string(ciphertext)); err == nil {
+			d.previous, err = dump.ParseDeltaManifest([]byte(plaintext))
+		}
+	}
+	if err != nil {
+		log.Printf("Warning: failed to read the previous delta manifest %s, uploading every file, %s\n", location, err.Error())
+		return d
+	}
+	log.Printf("Comparing with the delta manifest %s\n", location)
+	return d
+}
+
+// latestDelta returns the location of the most recently written delta
+// manifest directly below s3path, empty without one
+func latestDelta(s3path string) (string, error) {
+	results, err := aws.S3List(s3path+"/", "."+dump.DeltaExt+"."+cryptExt)
+	if err != nil {
+		return "", err
+	}
+	bucket := strings.Split(s3path[len("s3://"):], "/")[0]
+	prefix := strings.TrimPrefix(s3path[len("s3://"+bucket):], "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	var latest aws.S3ListResult
+	for _, r := range results {
+		if strings.Contains(strings.TrimPrefix(r.Key, prefix), "/") {
+			continue
+		}
+		if latest.Key == "" || r.LastModified.After(latest.LastModified) {
+			latest = r
+		}
+	}
+	if latest.Key == "" {
+		return "", nil
+	}
+	return fmt.Sprintf("s3://%s/%s", bucket, latest.Key), nil
+}
+
+// unchanged records the file of group uploaded to object and reports
+// whether the previous upload holds it already, in which case it is
+// recorded with the object of that upload and must not be uploaded again
+func (d *deltaUpload) unchanged(group, object, key string, indexed bool) bool {
+	f := dump.DeltaFile{
+		Object:   object,
+		Checksum: d.checksums[group],
+		Encoding: encoding,
+		KMSKey:   key,
+		Indexed:  indexed,
+	}
+	previous, ok := d.previous.Unchanged(group, f)
+	if ok {
+		log.Printf("Unchanged since the previous upload, not uploading %s, its content is in %s\n", object, previous)
+		f.Object = previous
+		d.reused++
+	}
+	d.next.Files[group] = f
+	return ok
+}
+
+// write uploads the delta manifest of the files uploaded, encrypted with
+// kmsKey, next to them
+func (d *deltaUpload) write(s3path, outputFilename, kmsKey string) error {
+	if len(d.next.Files) == 0 {
+		return nil
+	}
+	plaintext, err := toJSON(d.next)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := aws.KMSEncrypt(plaintext, kmsKey)
+	if err != nil {
+		return err
+	}
+	log.Printf("Uploaded %d of %d files, %d unchanged\n", len(d.next.Files)-d.reused, len(d.next.Files), d.reused)
+	return aws.S3Put(fmt.Sprintf("%s/%s.%s.%s", s3path, outputFilename, dump.DeltaExt, cryptExt), ciphertext)
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/dathan/go-vault-dump/pkg/guard"
+)
+
+// denyPolicyFile is where the deny policy of the organization is read from,
+// and denyPolicyKey the base64 encoded ed25519 key it must be signed with.
+// Both are set at build time rather than by flags, so that whoever runs a
+// command can not lift the policy, see the Makefile.
+var (
+	denyPolicyFile = "/etc/vault-dump/deny-policy.yaml"
+	denyPolicyKey  = ""
+)
+
+// denyPolicy reads the deny policy, nil without one. A binary built with a
+// key refuses to read secrets without a policy signed by it.
+func denyPolicy() (*guard.DenyPolicy, error) {
+	if _, err := os.Stat(denyPolicyFile); os.IsNotExist(err) && denyPolicyKey == "" {
+		return nil, nil
+	}
+	p, err := guard.ReadDenyPolicy(denyPolicyFile, denyPolicyKey)
+	if err != nil {
+		return nil, fmt.Errorf("error: refusing to read secrets without the deny policy: %w", err)
+	}
+	if denyPolicyKey == "" {
+		log.Printf("Warning: the deny policy %s is enforced but not verified, this binary was built without a key\n", denyPolicyFile)
+	}
+	return p, nil
+}
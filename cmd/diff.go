@@ -0,0 +1,337 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dathan/go-vault-dump/pkg/aws"
+	"github.com/dathan/go-vault-dump/pkg/diff"
+	"github.com/dathan/go-vault-dump/pkg/load"
+	"github.com/dathan/go-vault-dump/pkg/stream"
+	"github.com/dathan/go-vault-dump/pkg/vault"
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	diffBase     string
+	diffMerged   string
+	diffFormat   string
+	diffValues   string
+	diffExitCode bool
+	diffDestPath string
+)
+
+func init() {
+	Cmd := &cobra.Command{
+		Use:   "diff [flags] <old> <new> | --base <base> <edited>",
+		Short: "Show the differences between two dumps, or merge an edited dump with Vault",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if diffBase != "" {
+				return cobra.ExactArgs(1)(cmd, args)
+			}
+			return cobra.ExactArgs(2)(cmd, args)
+		},
+		RunE: doDiff,
+	}
+	Cmd.Flags().StringVar(&diffBase, "base", "", "dump the edited dump was made from, merge the edits with the secrets in Vault")
+	Cmd.Flags().StringVar(&diffMerged, "merged", "", "with --base, write the merged secrets to this file")
+	Cmd.Flags().StringVarP(&diffFormat, "format", "f", "unified", "output format, [unified, json, json-patch, html, changelog]")
+	Cmd.Flags().StringVar(&diffValues, "values", string(diff.Redact), "how to show values, [redact, hash, show]")
+	Cmd.Flags().BoolVar(&diffExitCode, "exit-code", false, "fail when the dumps differ")
+	Cmd.Flags().StringVarP(&diffDestPath, "output", "o", "", "output path")
+	rootCmd.AddCommand(Cmd)
+}
+
+func doDiff(cmd *cobra.Command, args []string) error {
+	diffFormat = ownFormat(cmd, diffFormat)
+	write, ok := diff.Formats[diffFormat]
+	if !ok {
+		formats := make([]string, 0, len(diff.Formats))
+		for f := range diff.Formats {
+			formats = append(formats, f)
+		}
+		sort.Strings(formats)
+		return fmt.Errorf("error: invalid format %q, expected one of %s", diffFormat, strings.Join(formats, ", "))
+	}
+	mode, err := diff.ParseValues(diffValues)
+	if err != nil {
+		return fmt.Errorf("error: %w", err)
+	}
+
+	if diffMerged != "" && diffBase == "" {
+		return errors.New("error: --merged requires --base")
+	}
+
+	var report diff.Report
+	if diffBase != "" {
+		if report, err = mergeWithVault(diffBase, args[0], mode); err != nil {
+			return err
+		}
+	} else {
+		old, err := readVersionedDump(args[0])
+		if err != nil {
+			return err
+		}
+		new, err := readVersionedDump(args[1])
+		if err != nil {
+			return err
+		}
+		report = diff.NewReport(args[0], args[1], old.Secrets, new.Secrets, mode)
+		// changes are attributed to the KV v2 version they were made in
+		// when the dumps include metadata or earlier versions
+		report.Changes = diff.Attribute(report.Changes, dumpVersions(old), dumpVersions(new))
+	}
+
+	var out io.Writer = os.Stdout
+	if diffDestPath != "" {
+		ff, err := os.OpenFile(diffDestPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, UMASK)
+		if err != nil {
+			return err
+		}
+		defer ff.Close()
+		out = ff
+	}
+	if err := write(out, report); err != nil {
+		return err
+	}
+
+	if len(report.Conflicts) > 0 {
+		return fmt.Errorf("%d conflicts between %s and Vault", len(report.Conflicts), args[0])
+	}
+	if diffExitCode && len(report.Changes) > 0 {
+		s := report.Summary
+		return fmt.Errorf("dumps differ: %d added, %d updated, %d deleted", s.Added, s.Updated, s.Deleted)
+	}
+	return nil
+}
+
+// mergeWithVault merges the edits from the base dump to the edited dump with
+// the changes made in Vault since the base dump, reading the secrets of both
+// dumps from Vault, and writes the merged secrets to --merged
+func mergeWithVault(basePath, editedPath string, mode diff.Values) (diff.Report, error) {
+	base, err := readDump(basePath)
+	if err != nil {
+		return diff.Report{}, err
+	}
+	edited, err := readDump(editedPath)
+	if err != nil {
+		return diff.Report{}, err
+	}
+
+	trace, err := tracer()
+	if err != nil {
+		return diff.Report{}, err
+	}
+	pathTokens, err := vault.ParsePathTokens(viper.GetStringSlice(pathTokenFlag))
+	if err != nil {
+		return diff.Report{}, err
+	}
+	vc, err := vault.NewClient(&vault.Config{
+		Auth:         auth(),
+		TLS:          tlsConfig(),
+		Namespace:    viper.GetString(namespaceFlag),
+		Retries:      viper.GetInt(retriesFlag),
+		RetryBackoff: viper.GetDuration(retryBackoffFlag),
+		MaxRetryWait: viper.GetDuration(maxRetryWaitFlag),
+		Timeout:      viper.GetDuration(requestTimeoutFlag),
+		RateLimit:    viper.GetFloat64(rateLimitFlag),
+		Context:      runContext(),
+		Address:      viper.GetString(vaFlag),
+		ReadOnly:     true,
+		Token:        vaultToken(),
+		Trace:        trace,
+		Ignore:       &vault.Ignore{},
+		PathTokens:   pathTokens,
+	})
+	if err != nil {
+		return diff.Report{}, err
+	}
+
+	// Vault is read at every path of either dump
+	paths := make(map[string]bool, len(edited))
+	for path := range base {
+		paths[path] = true
+	}
+	for path := range edited {
+		paths[path] = true
+	}
+	deny, err := denyPolicy()
+	if err != nil {
+		return diff.Report{}, err
+	}
+	live := make(map[string]interface{})
+	for path := range paths {
+		if deny != nil {
+			resolved, err := vc.ResolveMountPath(path, "data")
+			if err != nil {
+				return diff.Report{}, err
+			}
+			if deny.Denied(resolved) {
+				return diff.Report{}, fmt.Errorf("error: %s is denied by the deny policy, it can not be read to merge", path)
+			}
+		}
+		data, _, err := vc.ReadSecret(path)
+		if err != nil {
+			return diff.Report{}, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if data == nil {
+			continue
+		}
+		if live[path], err = asDumped(data); err != nil {
+			return diff.Report{}, err
+		}
+	}
+
+	address := viper.GetString(vaFlag)
+	report, merged := diff.NewMergeReport(basePath, editedPath, address, base, edited, live, mode)
+	if diffMerged != "" {
+		data, err := json.MarshalIndent(merged, "", "  ")
+		if err != nil {
+			return diff.Report{}, err
+		}
+		if err := ioutil.WriteFile(diffMerged, data, UMASK); err != nil {
+			return diff.Report{}, err
+		}
+	}
+	return report, nil
+}
+
+// readDump returns the secrets of a json or yaml dump, a local file or an S3
+// object, see readArtifact
+func readDump(location string) (map[string]interface{}, error) {
+	d, err := readVersionedDump(location)
+	if err != nil {
+		return nil, err
+	}
+	return d.Secrets, nil
+}
+
+// readVersionedDump returns the secrets of a dump like readDump, along with
+// what it records of their versions
+func readVersionedDump(location string) (*load.Dump, error) {
+	data, _, _, err := readArtifact(location)
+	if err != nil {
+		return nil, err
+	}
+	d, err := load.ReadDump(data, location, aws.KMSDecryptDataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", location, err)
+	}
+	return d, nil
+}
+
+// dumpVersions returns the versions of the secrets of d, from their metadata
+// and earlier versions, for the secrets it records either of
+func dumpVersions(d *load.Dump) map[string]diff.Versions {
+	versions := make(map[string]diff.Versions)
+	for p, secret := range d.Secrets {
+		m, history := d.Metadata[p], d.History[p]
+		if m == nil && len(history) == 0 {
+			continue
+		}
+		v := diff.Versions{Created: make(map[int]string), Data: make(map[int]map[string]interface{})}
+		for _, h := range history {
+			v.Created[h.Version] = h.CreatedTime
+			if h.Data != nil {
+				v.Data[h.Version] = h.Data
+			}
+		}
+		if m != nil {
+			v.Current = m.CurrentVersion
+			for n, vm := range m.Versions {
+				if i, err := strconv.Atoi(n); err == nil {
+					v.Created[i] = vm.CreatedTime
+				}
+			}
+		} else {
+			// earlier versions are dumped up to the one before the secret
+			v.Current = history[len(history)-1].Version + 1
+		}
+		if data, ok := secret.(map[string]interface{}); ok && v.Current > 0 {
+			v.Data[v.Current] = data
+		}
+		versions[p] = v
+	}
+	return versions
+}
+
+// readArtifact returns the plaintext of a dump, a local file or an S3 object,
+// and whether it was encrypted. S3 objects, local files ending in .aes and
+// encrypted streams are decrypted in memory, the header of a stream is
+// returned with it.
+func readArtifact(location string) ([]byte, *stream.Header, bool, error) {
+	fromS3 := strings.HasPrefix(location, "s3://")
+	var (
+		data []byte
+		err  error
+	)
+	if fromS3 {
+		data, err = aws.S3Get(location)
+	} else {
+		data, err = ioutil.ReadFile(location)
+	}
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte(stream.Magic)):
+		r, err := stream.NewReader(bytes.NewReader(data), func(h stream.Header) ([]byte, error) {
+			return aws.KMSDecryptDataKey(h.Key)
+		})
+		if err != nil {
+			return nil, nil, true, err
+		}
+		if data, err = ioutil.ReadAll(r); err != nil {
+			return nil, nil, true, err
+		}
+		h := r.Header()
+		return data, &h, true, nil
+	case fromS3 || strings.HasSuffix(location, "."+cryptExt):
+		plaintext, err := aws.KMSDecrypt(string(data))
+		if err != nil {
+			return nil, nil, true, err
+		}
+		return []byte(plaintext), nil, true, nil
+	}
+	return data, nil, false, nil
+}
+
+// parseArtifact parses the plaintext of a dump as it was written, without
+// reading its manifest, and reports whether it is yaml
+func parseArtifact(data []byte) (map[string]interface{}, bool, error) {
+	isYAML := !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+	secrets := make(map[string]interface{})
+	var err error
+	if isYAML {
+		err = yaml.Unmarshal(data, &secrets)
+	} else {
+		err = json.Unmarshal(data, &secrets)
+	}
+	return secrets, isYAML, err
+}
+
+// asDumped returns a secret read from Vault as it is read back from a dump,
+// the API decodes numbers as json.Number
+func asDumped(data map[string]interface{}) (map[string]interface{}, error) {
+	if data == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var decoded map[string]interface{}
+	err = json.Unmarshal(b, &decoded)
+	return decoded, err
+}
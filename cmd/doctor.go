@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/aws"
+	"github.com/dathan/go-vault-dump/pkg/feature"
+	"github.com/dathan/go-vault-dump/pkg/vault"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// maxClockSkew is the most the local clock may be off Vault's before
+	// logins and AWS request signatures start being rejected
+	maxClockSkew = time.Minute
+	// minTokenTTL is the least TTL left of a token that can not be renewed
+	// for a dump to be likely to finish with it
+	minTokenTTL = time.Hour
+	// kmsProbe is what is encrypted and decrypted to check the KMS key
+	kmsProbe = "vault-dump doctor"
+)
+
+var (
+	doctorCmd *cobra.Command
+)
+
+func init() {
+	doctorCmd = &cobra.Command{
+		Use:    "doctor [flags] [/vault/path ...] [s3://bucket/prefix]",
+		Short:  "Check the connection to Vault, the login, the clock and the access to the paths, KMS key and S3 location to use",
+		PreRun: bindDoctorFlags,
+		RunE:   doctor,
+	}
+	doctorCmd.Flags().String(kmsKeyFlag, "", "KMS key ARN to check encrypting and decrypting with")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// bindDoctorFlags binds --kms-key when doctor runs, so the key set for dump
+// in the config file is checked without taking over the flag of dump
+func bindDoctorFlags(c *cobra.Command, args []string) {
+	viper.BindPFlag(kmsKeyFlag, c.Flags().Lookup(kmsKeyFlag))
+}
+
+// diagnosis is the outcome of a check of doctor, fix tells what to do about
+// a failed or warned one
+type diagnosis struct {
+	check  string
+	result string
+	detail string
+	fix    string
+}
+
+// checkResult is a diagnosis shown with --format json
+type checkResult struct {
+	Check  string `json:"check"`
+	Result string `json:"result"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+const (
+	resultOK   = "ok"
+	resultWarn = "warn"
+	resultFail = "FAIL"
+	resultSkip = "skipped"
+)
+
+func doctor(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonFormat()
+	if err != nil {
+		return err
+	}
+	paths, locations := []string{}, []string{}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "s3://") {
+			locations = append(locations, arg)
+			continue
+		}
+		paths = append(paths, strings.Split(arg, ",")...)
+	}
+
+	diagnoses := []diagnosis{}
+	vc, connected := checkVault(&diagnoses)
+	if !connected {
+		diagnoses = append(diagnoses, diagnosis{"auth", resultSkip, "Vault can not be used", ""})
+	} else if checkLogin(vc, &diagnoses) {
+		checkPaths(vc, paths, &diagnoses)
+	}
+	checkKMS(viper.GetString(kmsKeyFlag), &diagnoses)
+	for _, location := range locations {
+		checkS3(location, &diagnoses)
+	}
+
+	failed := 0
+	for _, d := range diagnoses {
+		if d.result == resultFail {
+			failed++
+		}
+	}
+	if asJSON {
+		results := make([]checkResult, len(diagnoses))
+		for i, d := range diagnoses {
+			results[i] = checkResult{Check: d.check, Result: d.result, Detail: d.detail, Fix: d.fix}
+		}
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	} else {
+		printDiagnoses(diagnoses)
+	}
+	if failed > 0 {
+		return fmt.Errorf("error: %d of %d checks failed", failed, len(diagnoses))
+	}
+	return nil
+}
+
+// printDiagnoses prints the diagnoses as a table, followed by the fixes
+func printDiagnoses(diagnoses []diagnosis) {
+	tab := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tab, "Check\tResult\tDetail\t\n")
+	fmt.Fprintf(tab, "---\t---\t---\t\n")
+	for _, d := range diagnoses {
+		fmt.Fprintf(tab, "%s\t%s\t%s\t\n", d.check, d.result, d.detail)
+	}
+	tab.Flush()
+
+	for _, d := range diagnoses {
+		if d.fix != "" {
+			fmt.Printf("\n%s: %s\n", d.check, d.fix)
+		}
+	}
+}
+
+// checkVault connects to Vault without logging in and checks its health and
+// clock, it reports whether Vault can be used
+func checkVault(diagnoses *[]diagnosis) (*vault.Config, bool) {
+	address := viper.GetString(vaFlag)
+	vc, err := vault.NewClient(&vault.Config{
+		TLS:          tlsConfig(),
+		Namespace:    viper.GetString(namespaceFlag),
+		Retries:      1,
+		RetryBackoff: viper.GetDuration(retryBackoffFlag),
+		MaxRetryWait: viper.GetDuration(maxRetryWaitFlag),
+		Timeout:      viper.GetDuration(requestTimeoutFlag),
+		Context:      runContext(),
+		Address:      address,
+		ReadOnly:     true,
+		Token:        vaultToken(),
+		Ignore:       &vault.Ignore{},
+	})
+	if err != nil {
+		*diagnoses = append(*diagnoses, diagnosis{"vault", resultFail, err.Error(),
+			"check --ca-cert, --ca-path, --client-cert and --client-key, and the VAULT_* variables they default to"})
+		return nil, false
+	}
+	health, err := vc.Health()
+	if err != nil {
+		*diagnoses = append(*diagnoses, diagnosis{"vault", resultFail, fmt.Sprintf("can not reach %s: %s", address, err), connectFix(err)})
+		return nil, false
+	}
+
+	d := diagnosis{"vault", resultOK, fmt.Sprintf("%s, Vault %s", health.Cluster, health.Version), ""}
+	switch {
+	case !health.Initialized:
+		d.result, d.detail, d.fix = resultFail, address+" is not initialized", "initialize Vault, or point --vault-addr at the cluster to dump"
+	case health.Sealed:
+		d.result, d.detail, d.fix = resultFail, address+" is sealed", "unseal Vault, or point --vault-addr at an unsealed node"
+	case health.Standby:
+		d.detail += ", standby node"
+	}
+	*diagnoses = append(*diagnoses, d)
+
+	clock := diagnosis{"clock", resultOK, fmt.Sprintf("%s off Vault", health.Skew), ""}
+	if health.Skew > maxClockSkew || health.Skew < -maxClockSkew {
+		clock.result = resultFail
+		clock.fix = "synchronize the local clock, e.g. with NTP; logins and AWS request signatures are rejected when it is too far off"
+	}
+	*diagnoses = append(*diagnoses, clock)
+	return vc, d.result == resultOK
+}
+
+// connectFix returns what to do about a failure to reach Vault
+func connectFix(err error) string {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+	)
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return "the certificate of Vault is signed by an unknown CA, pass it with --ca-cert or --ca-path"
+	case errors.As(err, &hostname):
+		return "the certificate of Vault is not valid for the address, use the name it was issued for or --tls-server-name"
+	case strings.Contains(err.Error(), "server gave HTTP response to HTTPS client"):
+		return "Vault does not serve TLS on this address, use an http:// --vault-addr"
+	}
+	return "check --vault-addr or VAULT_ADDR, and that the network allows reaching it"
+}
+
+// checkLogin logs in with the auth method and checks the token, it reports
+// whether the token can be used
+func checkLogin(vc *vault.Config, diagnoses *[]diagnosis) bool {
+	method := authMethod()
+	if login := auth(); login != nil {
+		token, err := login.Login(vc.Client)
+		if err != nil {
+			*diagnoses = append(*diagnoses, diagnosis{"auth", resultFail, fmt.Sprintf("%s login failed: %s", method, err), loginFix(method)})
+			return false
+		}
+		vc.Client.SetToken(token)
+	}
+	if vc.Client.Token() == "" {
+		*diagnoses = append(*diagnoses, diagnosis{"auth", resultFail, "no token", loginFix(method)})
+		return false
+	}
+	info, err := vc.LookupSelf()
+	if err != nil {
+		*diagnoses = append(*diagnoses, diagnosis{"auth", resultFail, fmt.Sprintf("%s token lookup failed: %s", method, err), loginFix(method)})
+		return false
+	}
+
+	d := diagnosis{"auth", resultOK, fmt.Sprintf("%s login as %s, policies %s", method, info.DisplayName, strings.Join(info.Policies, ", ")), ""}
+	switch {
+	case info.TTL == 0:
+		d.detail += ", never expires"
+	case !info.Renewable && info.TTL < minTokenTTL:
+		d.result = resultWarn
+		d.detail += fmt.Sprintf(", expires in %s and is not renewable", info.TTL.Round(time.Second))
+		d.fix = "a long dump may outlive the token, log in again or use an auth method that logs in for each run"
+	default:
+		d.detail += fmt.Sprintf(", expires in %s", info.TTL.Round(time.Second))
+	}
+	*diagnoses = append(*diagnoses, d)
+	return true
+}
+
+// loginFix returns what to do about a failed login with method
+func loginFix(method string) string {
+	switch method {
+	case "approle":
+		return fmt.Sprintf("check --%s and the secret ID in VAULT_DUMP_APPROLE_SECRET_ID or VAULT_DUMP_APPROLE_WRAPPED_SECRET_ID, and --%s", appRoleRoleIDFlag, appRoleMountFlag)
+	case "aws":
+		return fmt.Sprintf("check that --%s is bound to the AWS identity of this host, and --%s and --%s", awsAuthRoleFlag, awsAuthMountFlag, awsAuthServerIDFlag)
+	case "kubernetes":
+		return fmt.Sprintf("check that --%s is bound to the service account of --%s, and --%s", k8sRoleFlag, k8sTokenFileFlag, k8sMountFlag)
+	case "oidc":
+		return fmt.Sprintf("check --%s and --%s, and that --%s matches a redirect URI of the role", oidcRoleFlag, oidcMountFlag, oidcCallbackFlag)
+	}
+	return fmt.Sprintf("run `vault login`, or set --%s or VAULT_TOKEN to a valid token", vtFlag)
+}
+
+// checkPaths checks that the token may dump paths
+func checkPaths(vc *vault.Config, paths []string, diagnoses *[]diagnosis) {
+	if len(paths) == 0 {
+		return
+	}
+	resolved, err := vc.PolicyPaths(paths)
+	if err != nil {
+		*diagnoses = append(*diagnoses, diagnosis{"paths", resultFail, fmt.Sprintf("failed to look up the mounts: %s", err),
+			"the token needs read on sys/mounts, or check the paths are below a mount"})
+		return
+	}
+	capabilities, err := vc.DumpCapabilities(resolved)
+	if err != nil {
+		*diagnoses = append(*diagnoses, diagnosis{"paths", resultFail, fmt.Sprintf("failed to look up capabilities: %s", err), ""})
+		return
+	}
+	missing := []string{}
+	for _, c := range capabilities {
+		if !c.Allowed() {
+			missing = append(missing, fmt.Sprintf("%s on %s", c.Needed, c.Endpoint))
+		}
+	}
+	if len(missing) > 0 {
+		*diagnoses = append(*diagnoses, diagnosis{"paths", resultFail, "missing " + strings.Join(missing, ", "),
+			"grant the token a policy allowing them, `vault-dump policy` prints one"})
+		return
+	}
+	*diagnoses = append(*diagnoses, diagnosis{"paths", resultOK, fmt.Sprintf("%d endpoints of %s allowed", len(capabilities), strings.Join(paths, ", ")), ""})
+}
+
+// checkKMS encrypts and decrypts a probe with kmsKey, what dumps and imports
+// do with it, without writing anything
+func checkKMS(kmsKey string, diagnoses *[]diagnosis) {
+	if kmsKey == "" {
+		*diagnoses = append(*diagnoses, diagnosis{"kms", resultSkip, fmt.Sprintf("no --%s", kmsKeyFlag), ""})
+		return
+	}
+	if err := feature.Require(feature.AWS); err != nil {
+		*diagnoses = append(*diagnoses, diagnosis{"kms", resultFail, err.Error(), "use a build with AWS support"})
+		return
+	}
+	ciphertext, err := aws.KMSEncrypt(kmsProbe, kmsKey)
+	if err != nil {
+		*diagnoses = append(*diagnoses, diagnosis{"kms", resultFail, fmt.Sprintf("encrypting with %s failed: %s", kmsKey, err), awsFix("kms:GenerateDataKey on the key")})
+		return
+	}
+	plaintext, err := aws.KMSDecrypt(ciphertext)
+	if err != nil {
+		*diagnoses = append(*diagnoses, diagnosis{"kms", resultFail, fmt.Sprintf("decrypting with %s failed: %s", kmsKey, err), awsFix("kms:Decrypt on the key")})
+		return
+	}
+	if plaintext != kmsProbe {
+		*diagnoses = append(*diagnoses, diagnosis{"kms", resultFail, "decrypting returned another plaintext", ""})
+		return
+	}
+	*diagnoses = append(*diagnoses, diagnosis{"kms", resultOK, "encrypted and decrypted with " + kmsKey, ""})
+}
+
+// checkS3 lists location, writing to it is not checked since that can not be
+// done without writing
+func checkS3(location string, diagnoses *[]diagnosis) {
+	if err := feature.Require(feature.AWS); err != nil {
+		*diagnoses = append(*diagnoses, diagnosis{"s3", resultFail, err.Error(), "use a build with AWS support"})
+		return
+	}
+	objects, err := aws.S3List(location, "")
+	if err != nil {
+		*diagnoses = append(*diagnoses, diagnosis{"s3", resultFail, fmt.Sprintf("listing %s failed: %s", location, err), awsFix("s3:ListBucket on the bucket")})
+		return
+	}
+	*diagnoses = append(*diagnoses, diagnosis{"s3", resultOK, fmt.Sprintf("listed %d objects in %s, uploads are not checked", len(objects), location), ""})
+}
+
+// awsFix returns what to do about a failed AWS request that needs permission
+func awsFix(permission string) string {
+	return fmt.Sprintf("grant the AWS identity %s, or pick the identity with --%s or --%s", permission, awsProfileFlag, awsRoleARNFlag)
+}
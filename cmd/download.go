@@ -31,8 +31,12 @@ func init() {
 }
 
 func doDownload(cmd *cobra.Command, args []string) error {
-	srcPath := args[0]
+	return download(args[0], decrypt, destPath)
+}
 
+// download writes the object at srcPath to destPath, or stdout when empty,
+// removing its KMS encryption when decrypt is set
+func download(srcPath string, decrypt bool, destPath string) error {
 	data, err := aws.S3Get(srcPath)
 	if err != nil {
 		return err
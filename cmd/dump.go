@@ -1,24 +1,88 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/dathan/go-vault-dump/pkg/auditlog"
 	"github.com/dathan/go-vault-dump/pkg/aws"
 	"github.com/dathan/go-vault-dump/pkg/dump"
+	"github.com/dathan/go-vault-dump/pkg/fault"
+	"github.com/dathan/go-vault-dump/pkg/feature"
+	"github.com/dathan/go-vault-dump/pkg/file"
+	"github.com/dathan/go-vault-dump/pkg/kafka"
+	"github.com/dathan/go-vault-dump/pkg/load"
+	"github.com/dathan/go-vault-dump/pkg/notify"
+	"github.com/dathan/go-vault-dump/pkg/stream"
 	"github.com/dathan/go-vault-dump/pkg/vault"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 const (
-	cryptExt   = "aes"
-	destFlag   = "dest"
-	fileFlag   = "filename"
-	kmsKeyFlag = "kms-key"
+	allClustersFlag   = "all-clusters"
+	changeWebhookFlag = "change-webhook"
+	checkpointFlag    = "checkpoint"
+	classPolicyFlag   = "classification-policy"
+	clustersKey       = "clusters"
+	concurrencyFlag   = "concurrency"
+	cryptExt          = "aes"
+	deletedFlag       = "deleted"
+	deltaFlag         = "delta"
+	destFlag          = "dest"
+	dryRunFlag        = "dry-run"
+	encryptNamesFlag  = "encrypt-names"
+	encryptValuesFlag = "encrypt-values"
+	excludeKeyFlag    = "exclude-key-glob"
+	excludeMatchFlag  = "exclude-metadata-match"
+	excludePathFlag   = "exclude-path-regex"
+	failOnSkippedFlag = "fail-on-skipped"
+	fileFlag          = "filename"
+	followAuditFlag   = "follow-audit"
+	followDelayFlag   = "follow-delay"
+	incidentAfterFlag = "incident-after"
+	includeKeyFlag    = "include-key-glob"
+	includeMatchFlag  = "include-metadata-match"
+	includeMetaFlag   = "include-metadata"
+	includePathFlag   = "include-path-regex"
+	incrementalFlag   = "incremental"
+	indexFlag         = "index"
+	kafkaBrokersFlag  = "kafka-brokers"
+	kafkaTopicFlag    = "kafka-topic"
+	keysOnlyFlag      = "keys-only"
+	kmsKeyFlag        = "kms-key"
+	maxDepthFlag      = "max-depth"
+	metadataOnlyFlag  = "metadata-only"
+	opsgenieKeyFlag   = "opsgenie-api-key"
+	outputFDFlag      = "output-fd"
+	outputFIFOFlag    = "output-fifo"
+	pagerDutyKeyFlag  = "pagerduty-routing-key"
+	partialFlag       = "partial"
+	postProcessFlag   = "post-process"
+	raftSnapshotFlag  = "raft-snapshot"
+	recurseNSFlag     = "recurse-namespaces"
+	resumeFlag        = "resume"
+	sinceFlag         = "since"
+	splitFlag         = "split"
+	splitPerPathFlag  = "split-per-path"
+	stateFlag         = "state"
+	versionsFlag      = "versions"
+	watchFlag         = "watch"
+
+	externalizeSizeFlag = "externalize-size"
+	maxValueSizeFlag    = "max-value-size"
+	relativePathsFlag   = "relative-paths"
 )
 
 var (
@@ -30,60 +94,581 @@ var (
 
 func init() {
 	dumpCmd = &cobra.Command{
-		Use:   "dump [flags] /vault/path[,...]",
+		Use:   "dump [flags] /vault/path[,...] ...",
 		Short: "Dump secrets from Vault",
-		Args:  cobra.ExactArgs(1),
+		Args:  cobra.ArbitraryArgs,
 		RunE:  dumpVault,
 	}
 
 	dumpCmd.Flags().StringP(fileFlag, "f", "vault-dump", "output filename (.json or .yaml extension will be added)")
 	dumpCmd.Flags().String(kmsKeyFlag, "", "KMS encryption key ARN (required for S3 uploads)")
-	dumpCmd.Flags().StringP(destFlag, "d", "", "output directory or S3 path")
+	dumpCmd.Flags().StringP(destFlag, "d", "", "output directory, file:// URL or S3 path")
 	dumpCmd.Flags().StringVarP(&encoding, "encoding", "e", "json", "encoding type [json, yaml]")
-	dumpCmd.Flags().StringVarP(&output, "output", "o", "file", "output type, [stdout, file, s3]")
+	dumpCmd.Flags().StringVarP(&output, "output", "o", "file", "output type, [stdout, file, s3, kafka]")
 	dumpCmd.Flags().StringVarP(&kubeconfig, "kubeconfig", "k", "", "location of kube config file")
+	dumpCmd.Flags().Int(concurrencyFlag, 0, "most requests to Vault in flight while listing and reading secrets (default the number of CPUs)")
+	dumpCmd.Flags().Int(maxValueSizeFlag, 0, "skip and report values larger than this many bytes (0 for no limit)")
+	dumpCmd.Flags().Int(externalizeSizeFlag, 0, "write values larger than this many bytes to separate files (0 to disable)")
+	dumpCmd.Flags().StringSlice(splitFlag, []string{}, "split output into separately encrypted files, name=prefix[=kms-key|age-recipient]")
+	dumpCmd.Flags().Bool(splitPerPathFlag, false, "write the secrets of each path dumped to a file of its own, named after the path")
+	dumpCmd.Flags().String(deletedFlag, dump.DeletedSkip, "secrets whose latest version is deleted or destroyed, [skip, previous, tombstone]")
+	dumpCmd.Flags().Bool(dryRunFlag, false, "list the secrets that would be dumped, their count and an estimate of the size of the dump without reading values or writing anything")
+	dumpCmd.Flags().Duration(watchFlag, 0, "dump again every interval until interrupted (0 to dump once)")
+	dumpCmd.Flags().StringSlice(changeWebhookFlag, []string{}, "with --watch or --follow-audit, webhook URLs to post the changes between dumps to")
+	dumpCmd.Flags().String(followAuditFlag, "", "experimental, after the dump follow Vault's audit log, a file or tcp://, udp:// or unix:// address of a socket audit device, and dump the secrets written again")
+	dumpCmd.Flags().Duration(followDelayFlag, 2*time.Second, "with --follow-audit, how long to collect writes before reading the secrets written again")
+	dumpCmd.Flags().Int(incidentAfterFlag, 3, "with --watch, open an incident after this many consecutive failed dumps")
+	dumpCmd.Flags().String(pagerDutyKeyFlag, "", "with --watch, PagerDuty Events API v2 routing key to open incidents with")
+	dumpCmd.Flags().String(opsgenieKeyFlag, "", "with --watch, Opsgenie API key to open alerts with")
+	dumpCmd.Flags().Bool(allClustersFlag, false, "dump every cluster listed under clusters in the config file in parallel")
+	dumpCmd.Flags().String(checkpointFlag, "", "record the secrets to this file in plaintext as they are read, so a failed or interrupted dump can be resumed, removed once the dump is written")
+	dumpCmd.Flags().String(resumeFlag, "", "resume the dump recorded in this checkpoint file, reading only the secrets it does not hold")
+	dumpCmd.Flags().Bool(incrementalFlag, false, "only read the KV v2 secrets whose metadata changed since the previous dump recorded in --state, taking the others from that dump")
+	dumpCmd.Flags().String(stateFlag, "", "with --incremental, file recording the metadata of the secrets of the previous dump")
+	dumpCmd.Flags().String(sinceFlag, "", "only dump the KV v2 secrets modified since this long ago, e.g. 72h, or this RFC 3339 time, a delta of the secrets changed")
+	dumpCmd.Flags().String(versionsFlag, "latest", "versions of each KV v2 secret to dump, latest, all or a number of versions, earlier versions are recorded in the manifest")
+	dumpCmd.Flags().Bool(includeMetaFlag, false, "record the custom metadata, settings and version states of each KV v2 secret in the manifest")
+	dumpCmd.Flags().StringSlice(includeMatchFlag, []string{}, "only dump KV v2 secrets whose custom metadata matches key=value, or holds key, may be repeated")
+	dumpCmd.Flags().StringSlice(excludeMatchFlag, []string{}, "do not dump KV v2 secrets whose custom metadata matches key=value, e.g. backup=false, or holds key, may be repeated")
+	dumpCmd.Flags().String(classPolicyFlag, "", "JSON or YAML file of the destinations secrets may be dumped to by the classification in their KV v2 custom metadata, refused secrets are reported as failed")
+	dumpCmd.Flags().Int(maxDepthFlag, 0, "levels below each path to list, 1 for the secrets directly below it (0 for no limit)")
+	dumpCmd.Flags().StringArray(includePathFlag, []string{}, "only dump secrets whose data path matches this regular expression, may be repeated")
+	dumpCmd.Flags().StringArray(excludePathFlag, []string{}, "do not dump secrets, nor list directories followed by a slash, whose data path matches this regular expression, may be repeated")
+	dumpCmd.Flags().StringArray(includeKeyFlag, []string{}, "only dump secrets whose name matches this glob pattern, may be repeated")
+	dumpCmd.Flags().StringArray(excludeKeyFlag, []string{}, "do not dump secrets, nor list directories, whose name matches this glob pattern, may be repeated")
+	dumpCmd.Flags().Bool(deltaFlag, false, "with s3 output, only upload the files whose content changed since the previous upload to the same location, referencing the others in a delta manifest")
+	dumpCmd.Flags().Bool(failOnSkippedFlag, false, "once the dump is written, list the secrets and directories that could not be read or listed and exit with status 3 if there are any")
+	dumpCmd.Flags().Bool(partialFlag, false, "when interrupted, write the secrets read so far as partial output, marked in its file name and manifest")
+	dumpCmd.Flags().Bool(metadataOnlyFlag, false, "dump the paths, versions, timestamps and custom metadata of secrets but no values, an inventory that can not be imported")
+	dumpCmd.Flags().Bool(keysOnlyFlag, false, "dump the paths and key names of secrets but no values, read from the KV v2 subkeys endpoint where possible, a structure that can not be imported")
+	dumpCmd.Flags().Bool(relativePathsFlag, false, "write paths relative to the dumped path instead of including the mount")
+	dumpCmd.Flags().Bool(encryptValuesFlag, false, "with file output, encrypt each value with a data key from --kms-key, or the key of its --split group, leaving the paths readable")
+	dumpCmd.Flags().Bool(encryptNamesFlag, false, "with --encrypt-values, encrypt the key names of secrets as well")
+	dumpCmd.Flags().Bool(indexFlag, false, "write an index of the paths and field names next to each file, for search")
+	dumpCmd.Flags().Bool(raftSnapshotFlag, false, "also download a raft snapshot of the cluster next to the dump, encrypted with --kms-key")
+	dumpCmd.Flags().Bool(recurseNSFlag, false, "also dump the path in every namespace below --namespace, each below its namespace in the output")
+	dumpCmd.Flags().StringSlice(postProcessFlag, []string{}, "programs rewriting every secret before it is encoded, \"program [args]\", run in order")
+	dumpCmd.Flags().StringSlice(kafkaBrokersFlag, []string{}, "Kafka broker addresses for kafka output, host:port")
+	dumpCmd.Flags().String(kafkaTopicFlag, "", "Kafka topic for kafka output")
+	dumpCmd.Flags().Int(outputFDFlag, 0, "write stdout output to this inherited file descriptor instead")
+	dumpCmd.Flags().String(outputFIFOFlag, "", "write stdout output to this existing named pipe instead")
 
 	viper.BindPFlag(fileFlag, dumpCmd.Flags().Lookup(fileFlag))
 	viper.BindPFlag(destFlag, dumpCmd.Flags().Lookup(destFlag))
 	viper.BindPFlag(kmsKeyFlag, dumpCmd.Flags().Lookup(kmsKeyFlag))
+	viper.BindPFlag(splitFlag, dumpCmd.Flags().Lookup(splitFlag))
+	viper.BindPFlag(splitPerPathFlag, dumpCmd.Flags().Lookup(splitPerPathFlag))
+	viper.BindPFlag(concurrencyFlag, dumpCmd.Flags().Lookup(concurrencyFlag))
+	viper.BindPFlag(maxValueSizeFlag, dumpCmd.Flags().Lookup(maxValueSizeFlag))
+	viper.BindPFlag(externalizeSizeFlag, dumpCmd.Flags().Lookup(externalizeSizeFlag))
+	viper.BindPFlag(deletedFlag, dumpCmd.Flags().Lookup(deletedFlag))
+	viper.BindPFlag(dryRunFlag, dumpCmd.Flags().Lookup(dryRunFlag))
+	viper.BindPFlag(watchFlag, dumpCmd.Flags().Lookup(watchFlag))
+	viper.BindPFlag(changeWebhookFlag, dumpCmd.Flags().Lookup(changeWebhookFlag))
+	viper.BindPFlag(followAuditFlag, dumpCmd.Flags().Lookup(followAuditFlag))
+	viper.BindPFlag(followDelayFlag, dumpCmd.Flags().Lookup(followDelayFlag))
+	viper.BindPFlag(allClustersFlag, dumpCmd.Flags().Lookup(allClustersFlag))
+	viper.BindPFlag(incidentAfterFlag, dumpCmd.Flags().Lookup(incidentAfterFlag))
+	viper.BindPFlag(pagerDutyKeyFlag, dumpCmd.Flags().Lookup(pagerDutyKeyFlag))
+	viper.BindPFlag(opsgenieKeyFlag, dumpCmd.Flags().Lookup(opsgenieKeyFlag))
+	viper.BindPFlag(kafkaBrokersFlag, dumpCmd.Flags().Lookup(kafkaBrokersFlag))
+	viper.BindPFlag(kafkaTopicFlag, dumpCmd.Flags().Lookup(kafkaTopicFlag))
+	viper.BindPFlag(metadataOnlyFlag, dumpCmd.Flags().Lookup(metadataOnlyFlag))
+	viper.BindPFlag(keysOnlyFlag, dumpCmd.Flags().Lookup(keysOnlyFlag))
+	viper.BindPFlag(partialFlag, dumpCmd.Flags().Lookup(partialFlag))
+	viper.BindPFlag(failOnSkippedFlag, dumpCmd.Flags().Lookup(failOnSkippedFlag))
+	viper.BindPFlag(checkpointFlag, dumpCmd.Flags().Lookup(checkpointFlag))
+	viper.BindPFlag(incrementalFlag, dumpCmd.Flags().Lookup(incrementalFlag))
+	viper.BindPFlag(stateFlag, dumpCmd.Flags().Lookup(stateFlag))
+	viper.BindPFlag(sinceFlag, dumpCmd.Flags().Lookup(sinceFlag))
+	viper.BindPFlag(encryptValuesFlag, dumpCmd.Flags().Lookup(encryptValuesFlag))
+	viper.BindPFlag(encryptNamesFlag, dumpCmd.Flags().Lookup(encryptNamesFlag))
+	viper.BindPFlag(versionsFlag, dumpCmd.Flags().Lookup(versionsFlag))
+	viper.BindPFlag(includeMetaFlag, dumpCmd.Flags().Lookup(includeMetaFlag))
+	viper.BindPFlag(includeMatchFlag, dumpCmd.Flags().Lookup(includeMatchFlag))
+	viper.BindPFlag(excludeMatchFlag, dumpCmd.Flags().Lookup(excludeMatchFlag))
+	viper.BindPFlag(classPolicyFlag, dumpCmd.Flags().Lookup(classPolicyFlag))
+	viper.BindPFlag(maxDepthFlag, dumpCmd.Flags().Lookup(maxDepthFlag))
+	viper.BindPFlag(includePathFlag, dumpCmd.Flags().Lookup(includePathFlag))
+	viper.BindPFlag(excludePathFlag, dumpCmd.Flags().Lookup(excludePathFlag))
+	viper.BindPFlag(includeKeyFlag, dumpCmd.Flags().Lookup(includeKeyFlag))
+	viper.BindPFlag(excludeKeyFlag, dumpCmd.Flags().Lookup(excludeKeyFlag))
+	viper.BindPFlag(deltaFlag, dumpCmd.Flags().Lookup(deltaFlag))
+	viper.BindPFlag(resumeFlag, dumpCmd.Flags().Lookup(resumeFlag))
+	viper.BindPFlag(relativePathsFlag, dumpCmd.Flags().Lookup(relativePathsFlag))
+	viper.BindPFlag(indexFlag, dumpCmd.Flags().Lookup(indexFlag))
+	viper.BindPFlag(postProcessFlag, dumpCmd.Flags().Lookup(postProcessFlag))
+	viper.BindPFlag(recurseNSFlag, dumpCmd.Flags().Lookup(recurseNSFlag))
+	viper.BindPFlag(raftSnapshotFlag, dumpCmd.Flags().Lookup(raftSnapshotFlag))
+	viper.BindPFlag(outputFDFlag, dumpCmd.Flags().Lookup(outputFDFlag))
+	viper.BindPFlag(outputFIFOFlag, dumpCmd.Flags().Lookup(outputFIFOFlag))
 
 	rootCmd.AddCommand(dumpCmd)
 }
 
 func dumpVault(cmd *cobra.Command, args []string) error {
+	injected, err := faults()
+	if err != nil {
+		return err
+	}
+	if err := uploads(); err != nil {
+		return err
+	}
+
+	watch := viper.GetDuration(watchFlag)
+	follow := viper.GetString(followAuditFlag)
+	redirected := viper.GetInt(outputFDFlag) != 0 || viper.GetString(outputFIFOFlag) != ""
+	if redirected && output != "stdout" {
+		return errors.New("error: --output-fd and --output-fifo require stdout output")
+	}
+	checkpointed := viper.GetString(checkpointFlag) != "" || viper.GetString(resumeFlag) != ""
+	if checkpointed && (watch > 0 || follow != "") {
+		return errors.New("error: --checkpoint and --resume can not be combined with --watch or --follow-audit")
+	}
+	incremental := viper.GetBool(incrementalFlag)
+	if incremental && (watch > 0 || follow != "") {
+		return errors.New("error: --incremental can not be combined with --watch or --follow-audit")
+	}
+	if viper.GetBool(dryRunFlag) {
+		if watch > 0 || follow != "" || viper.GetBool(allClustersFlag) {
+			return errors.New("error: --dry-run can not be combined with --watch, --follow-audit or --all-clusters")
+		}
+		if checkpointed || incremental || viper.GetBool(partialFlag) {
+			return errors.New("error: --dry-run writes nothing, it can not be combined with --checkpoint, --resume, --incremental or --partial")
+		}
+	}
+	if viper.GetBool(allClustersFlag) {
+		if checkpointed {
+			return errors.New("error: --checkpoint and --resume can not be combined with --all-clusters")
+		}
+		if incremental {
+			return errors.New("error: --incremental can not be combined with --all-clusters")
+		}
+		if watch > 0 {
+			return errors.New("error: --watch can not be combined with --all-clusters")
+		}
+		if follow != "" {
+			return errors.New("error: --follow-audit can not be combined with --all-clusters")
+		}
+		if redirected {
+			return errors.New("error: --output-fd and --output-fifo can not be combined with --all-clusters")
+		}
+		return dumpClusters(joinPaths(args), injected)
+	}
+	paths := joinPaths(args)
+	if paths == "" {
+		return errors.New("error: a path to dump is required")
+	}
+
+	c := cluster{
+		Address:    viper.GetString(vaFlag),
+		Token:      vaultToken(),
+		Paths:      paths,
+		Dest:       viper.GetString(destFlag),
+		KMSKey:     viper.GetString(kmsKeyFlag),
+		PathTokens: viper.GetStringSlice(pathTokenFlag),
+	}
+	if watch > 0 {
+		if output == "s3" && aws.NoOverwrite {
+			return errors.New("error: --watch uploads the same S3 objects every interval, it can not be combined with --no-overwrite")
+		}
+		if follow != "" {
+			return errors.New("error: --follow-audit replaces --watch, they can not be combined")
+		}
+		return watchCluster(c, injected, watch)
+	}
+	if follow != "" {
+		if viper.GetDuration(followDelayFlag) <= 0 {
+			return errors.New("error: --follow-delay must be positive")
+		}
+		if viper.GetBool(recurseNSFlag) {
+			return errors.New("error: --follow-audit can not be combined with --recurse-namespaces")
+		}
+		if output == "s3" && aws.NoOverwrite {
+			return errors.New("error: --follow-audit uploads the same S3 objects for every change, it can not be combined with --no-overwrite")
+		}
+	}
+	dumper, err := dumpCluster(c, injected, follow)
+	if err != nil || follow != "" {
+		return err
+	}
+	return skipped(dumper)
+}
+
+// skipped lists the failures of dumper, the secrets and directories left out
+// of the dump, and returns a skippedError when there are any with
+// --fail-on-skipped
+func skipped(dumper *dump.Config) error {
+	if !viper.GetBool(failOnSkippedFlag) || dumper == nil || len(dumper.Failures()) == 0 {
+		return nil
+	}
+	failures := dumper.Failures()
+	paths := make([]string, 0, len(failures))
+	for p := range failures {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	log.Println("Skipped:")
+	for _, p := range paths {
+		log.Printf("  %s [%s], %s\n", p, failures[p].Category, failures[p].Reason)
+	}
+	return &skippedError{skipped: len(paths)}
+}
+
+// ChangeFeed is posted to the change webhooks when secrets changed between
+// two dumps in watch mode, it never holds secret values
+type ChangeFeed struct {
+	Event   string        `json:"event"`
+	Time    string        `json:"time"`
+	Changes []dump.Change `json:"changes"`
+}
+
+// watchCluster dumps the cluster every interval until the process is
+// interrupted, posting the changes between consecutive dumps to the change
+// webhooks. Failed dumps are logged and retried at the next interval, an
+// incident is opened once too many failed in a row.
+func watchCluster(c cluster, injected *fault.Config, interval time.Duration) error {
+	var previous map[string]dump.SecretState
+	first := true
+	escalation := incidents(c)
+	for {
+		dumper, err := dumpCluster(c, injected, "")
+		if errors.Is(err, dump.ErrInterrupted) {
+			return nil
+		}
+		if err != nil {
+			log.Printf("dump failed, %s\n", err.Error())
+			if err := escalation.Failed(err); err != nil {
+				log.Printf("failed to open incident, %s\n", err.Error())
+			}
+		} else {
+			if err := escalation.Succeeded(); err != nil {
+				log.Printf("failed to resolve incident, %s\n", err.Error())
+			}
+			changes, next := dump.Diff(previous, dumper.State())
+			if !first {
+				publishChanges(changes)
+			}
+			previous, first = next, false
+		}
+		select {
+		case <-runContext().Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// incidents returns the escalation of failed dumps of c to the configured
+// pagers
+func incidents(c cluster) *notify.Escalation {
+	source, _ := os.Hostname()
+	e := &notify.Escalation{
+		After:  viper.GetInt(incidentAfterFlag),
+		Key:    fmt.Sprintf("vault-dump %s %s", c.Address, c.Paths),
+		Source: source,
+	}
+	if key := viper.GetString(pagerDutyKeyFlag); key != "" {
+		e.Pagers = append(e.Pagers, &notify.PagerDuty{RoutingKey: key})
+	}
+	if key := viper.GetString(opsgenieKeyFlag); key != "" {
+		e.Pagers = append(e.Pagers, &notify.Opsgenie{APIKey: key})
+	}
+	return e
+}
+
+// followAudit follows Vault's audit log at source once the cluster was
+// dumped, collecting the writes to the namespace of the dump for
+// --follow-delay before refresh reads the secrets written again, and posts
+// the changes to the change webhooks. It returns once the audit log can not
+// be followed anymore, a failed refresh is logged and tried again with the
+// next writes. An interrupted refresh leaves the dump as it was and stops
+// following.
+func followAudit(source string, dumper *dump.Config, refresh func(paths []string) (int, error)) error {
+	ctx, cancel := context.WithCancel(runContext())
+	defer cancel()
+	events := make(chan auditlog.Event, 1000)
+	followed := make(chan error, 1)
+	go func() { followed <- auditlog.Follow(ctx, source, events) }()
+	log.Printf("Following the audit log %s for writes\n", source)
+
+	_, previous := dump.Diff(nil, dumper.State())
+	namespace := strings.Trim(dumper.VaultConfig.Namespace, "/")
+	delay := viper.GetDuration(followDelayFlag)
+	pending := make(map[string]bool)
+	var batch <-chan time.Time
+	for {
+		select {
+		case err := <-followed:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("error: failed to follow the audit log: %w", err)
+		case e := <-events:
+			if e.Namespace != namespace {
+				continue
+			}
+			if batch == nil {
+				batch = time.After(delay)
+			}
+			pending[e.Path] = true
+		case <-batch:
+			batch = nil
+			paths := make([]string, 0, len(pending))
+			for p := range pending {
+				paths = append(paths, p)
+			}
+			sort.Strings(paths)
+			refreshed, err := refresh(paths)
+			if errors.Is(err, dump.ErrInterrupted) {
+				return nil
+			}
+			if err != nil {
+				log.Printf("refresh failed, %s\n", err.Error())
+				batch = time.After(delay)
+				continue
+			}
+			pending = make(map[string]bool)
+			if refreshed == 0 {
+				continue
+			}
+			changes, next := dump.Diff(previous, dumper.State())
+			publishChanges(changes)
+			previous = next
+		}
+	}
+}
+
+// publishChanges posts the changes to every change webhook
+func publishChanges(changes []dump.Change) {
+	log.Printf("%d secrets changed since the previous dump\n", len(changes))
+	if len(changes) == 0 {
+		return
+	}
+	feed := ChangeFeed{
+		Event:   "changes",
+		Time:    time.Now().UTC().Format(time.RFC3339),
+		Changes: changes,
+	}
+	for _, url := range viper.GetStringSlice(changeWebhookFlag) {
+		if err := notify.PostJSON(url, feed); err != nil {
+			log.Printf("failed to post changes to %s, %s\n", url, err.Error())
+		}
+	}
+}
+
+// cluster is a Vault cluster to dump, the clusters of the config file fall
+// back to the flags for the fields they leave empty
+type cluster struct {
+	Name    string `mapstructure:"name"`
+	Address string `mapstructure:"vault-addr"`
+	Token   string `mapstructure:"vault-token"`
+	Paths   string `mapstructure:"paths"`
+	Dest    string `mapstructure:"dest"`
+	KMSKey  string `mapstructure:"kms-key"`
+	// PathTokens are prefix=token pairs, see vault.PathToken
+	PathTokens []string `mapstructure:"path-token"`
+}
+
+// clusterName restricts cluster names to what is safe to use in a path
+var clusterName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// dumpClusters dumps the clusters of the config file in parallel, each to
+// its own destination, and logs a combined summary
+func dumpClusters(paths string, injected *fault.Config) error {
+	clusters := []cluster{}
+	if err := viper.UnmarshalKey(clustersKey, &clusters); err != nil {
+		return err
+	}
+	if len(clusters) == 0 {
+		return fmt.Errorf("error: no %s defined in the config file", clustersKey)
+	}
+
+	seen := make(map[string]bool)
+	for i, c := range clusters {
+		if !clusterName.MatchString(c.Name) {
+			return fmt.Errorf("error: invalid cluster name %q, only letters, digits, '_' and '-' are allowed", c.Name)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("error: duplicate cluster name %q", c.Name)
+		}
+		seen[c.Name] = true
+
+		if c.Address == "" {
+			c.Address = viper.GetString(vaFlag)
+		}
+		if c.Token == "" {
+			c.Token = vaultToken()
+		}
+		if len(c.PathTokens) == 0 {
+			c.PathTokens = viper.GetStringSlice(pathTokenFlag)
+		}
+		if c.Paths == "" {
+			c.Paths = paths
+		}
+		if c.Paths == "" {
+			return fmt.Errorf("error: no paths to dump for cluster %s", c.Name)
+		}
+		if c.Dest == "" {
+			c.Dest = vault.EnsureNoTrailingSlash(dump.GetPathForOutput(viper.GetString(destFlag))) + "/" + c.Name
+		}
+		if c.KMSKey == "" {
+			c.KMSKey = viper.GetString(kmsKeyFlag)
+		}
+		clusters[i] = c
+	}
+
+	type result struct {
+		secrets  int
+		failed   int
+		err      error
+		duration time.Duration
+	}
+	results := make([]result, len(clusters))
+	var wg sync.WaitGroup
+	for i, c := range clusters {
+		wg.Add(1)
+		go func(i int, c cluster) {
+			defer wg.Done()
+			start := time.Now()
+			dumper, err := dumpCluster(c, injected, "")
+			results[i] = result{err: err, duration: time.Since(start)}
+			if dumper != nil {
+				results[i].secrets, results[i].failed = dumper.Stats()
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	failures := 0
+	log.Println("Summary:")
+	for i, c := range clusters {
+		r := results[i]
+		status := "ok"
+		if r.err != nil {
+			status = "error: " + r.err.Error()
+			failures++
+		}
+		log.Printf("  %s (%s): %d secrets, %d failed, %s, %s\n", c.Name, c.Dest, r.secrets, r.failed, r.duration.Round(time.Millisecond), status)
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d of %d cluster dumps failed", failures, len(clusters))
+	}
+	skipped := 0
+	for _, r := range results {
+		skipped += r.failed
+	}
+	if viper.GetBool(failOnSkippedFlag) && skipped > 0 {
+		return &skippedError{skipped: skipped}
+	}
+	return nil
+}
+
+// dumpCluster dumps the paths of a single cluster and returns the finished
+// dumper, with follow it then follows the audit log at follow, see
+// followAudit, and every refresh is a run of its own
+func dumpCluster(c cluster, injected *fault.Config, follow string) (dumper *dump.Config, err error) {
+	paths := c.Paths
+	kind := output
+	command := "dump"
+	if viper.GetBool(dryRunFlag) {
+		command = "dry-run"
+	}
 
-	paths := args[0]
+	r, err := startRun(command, c.Address)
+	if err != nil {
+		return nil, err
+	}
+	// scope limits what a refresh reports to the paths it read again
+	var scope map[string]bool
+	report := func(err error) {
+		accessed, failures := []string{}, map[string]string{}
+		if dumper != nil {
+			for p, s := range dumper.State() {
+				if !s.Unknown && (scope == nil || scope[p]) {
+					accessed = append(accessed, p)
+				}
+			}
+			sort.Strings(accessed)
+			for p, f := range dumper.Failures() {
+				if scope == nil || scope[p] {
+					failures[p] = fmt.Sprintf("%s: %s", f.Category, f.Reason)
+				}
+			}
+		}
+		r.finish(accessed, failures, err)
+		r = nil
+	}
+	defer func() {
+		if r != nil {
+			report(err)
+		}
+	}()
 
+	trace, err := tracer()
+	if err != nil {
+		return nil, err
+	}
+	pathTokens, err := vault.ParsePathTokens(c.PathTokens)
+	if err != nil {
+		return nil, err
+	}
 	vc, err := vault.NewClient(&vault.Config{
-		Address: viper.GetString(vaFlag),
+		Auth:         auth(),
+		TLS:          tlsConfig(),
+		Namespace:    viper.GetString(namespaceFlag),
+		RetryBackoff: viper.GetDuration(retryBackoffFlag),
+		MaxRetryWait: viper.GetDuration(maxRetryWaitFlag),
+		Timeout:      viper.GetDuration(requestTimeoutFlag),
+		RateLimit:    viper.GetFloat64(rateLimitFlag),
+		Context:      runContext(),
+		Address:      c.Address,
+		Faults:       injected,
 		Ignore: &vault.Ignore{
 			Keys:  viper.GetStringSlice(ignoreKeysFlag),
 			Paths: viper.GetStringSlice(ignorePathsFlag),
 		},
-		Retries: 5,
-		Token:   viper.GetString(vtFlag),
+		ReadOnly: readOnly(true),
+		Retries:  viper.GetInt(retriesFlag),
+		Renew:    true,
+		Token:    c.Token,
+		Trace:    trace,
+		Usage:    requests,
+
+		PathTokens: pathTokens,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer vc.Close()
 
-	outputPath := viper.GetString(destFlag)
+	outputPath := c.Dest
+	if strings.HasPrefix(outputPath, "file://") {
+		if outputPath, err = file.FromURL(outputPath); err != nil {
+			return nil, err
+		}
+	}
 	if len(outputPath) > 5 && outputPath[:5] == "s3://" {
-		output = "s3"
+		kind = "s3"
+	}
+	if backend, ok := outputBackends[kind]; ok {
+		if err := feature.Require(backend); err != nil {
+			return nil, fmt.Errorf("error: %s output: %w", kind, err)
+		}
 	}
 
 	s3path := ""
-	kmsKey := viper.GetString(kmsKeyFlag)
-	if output == "s3" {
+	kmsKey := c.KMSKey
+	if kind == "s3" {
 		if kmsKey == "" {
-			return errors.New("error: KMS key must be specified for S3 upload")
+			return nil, errors.New("error: KMS key must be specified for S3 upload")
 		}
 		if outputPath == "" {
-			return errors.New("error: Must specify an output path for S3 upload")
+			return nil, errors.New("error: Must specify an output path for S3 upload")
 		}
 		s3path = vault.EnsureNoTrailingSlash(outputPath)
 		if len(s3path) < 5 || s3path[:5] != "s3://" {
-			return errors.New("error: Output path for S3 upload must begin with s3://")
+			return nil, errors.New("error: Output path for S3 upload must begin with s3://")
 		}
 		outputPath, err = ioutil.TempDir("", "vault-dump-*")
 		if err != nil {
@@ -91,7 +676,7 @@ func dumpVault(cmd *cobra.Command, args []string) error {
 		}
 	}
 	defer func() {
-		if output == "s3" {
+		if kind == "s3" {
 			os.RemoveAll(outputPath)
 		}
 	}()
@@ -100,46 +685,817 @@ func dumpVault(cmd *cobra.Command, args []string) error {
 	outputConfig, err := dump.NewOutput(
 		outputPath,
 		encoding,
-		output,
+		kind,
 	)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	yamlOpts, err := yamlOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	if viper.GetInt(externalizeSizeFlag) > 0 && kind != "file" {
+		return nil, errors.New("error: externalizing values is only supported for file output")
+	}
+
+	groups, err := dump.ParseGroups(viper.GetStringSlice(splitFlag))
+	if err != nil {
+		return nil, err
+	}
+	if viper.GetBool(splitPerPathFlag) {
+		if len(groups) > 0 {
+			return nil, errors.New("error: --split-per-path can not be combined with --split")
+		}
+		if viper.GetBool(recurseNSFlag) {
+			return nil, errors.New("error: --split-per-path can not be combined with --recurse-namespaces")
+		}
+		if groups, err = pathGroups(vc, strings.Split(paths, ",")); err != nil {
+			return nil, err
+		}
+	}
+	if len(groups) > 0 && kind != "file" && kind != "s3" {
+		return nil, errors.New("error: splitting is only supported for file and s3 output")
+	}
+	if len(groups) > 0 && viper.GetInt(externalizeSizeFlag) > 0 {
+		return nil, errors.New("error: externalizing values can not be combined with splitting")
+	}
+
+	if viper.GetBool(indexFlag) && kind != "file" && kind != "s3" {
+		return nil, errors.New("error: an index is only written for file and s3 output")
+	}
+	if viper.GetBool(deltaFlag) && kind != "s3" {
+		return nil, errors.New("error: delta uploads are only written for s3 output")
+	}
+	if viper.GetBool(raftSnapshotFlag) && kind != "file" && kind != "s3" {
+		return nil, errors.New("error: a raft snapshot is only written for file and s3 output")
+	}
+
+	encryptValues := viper.GetBool(encryptValuesFlag)
+	if viper.GetBool(encryptNamesFlag) && !encryptValues {
+		return nil, errors.New("error: --encrypt-names requires --encrypt-values")
+	}
+	if encryptValues && kind != "file" {
+		return nil, errors.New("error: values are only encrypted for file output")
+	}
+	// each file has a data key of its own, encrypted by the key of its group
+	dataKeys := make(map[string]string)
+	for _, g := range append([]dump.Group{{KMSKey: kmsKey}}, groups...) {
+		key := g.KMSKey
+		if key == "" {
+			key = kmsKey
+		}
+		if encryptValues && key == "" {
+			return nil, errors.New("error: --encrypt-values requires --kms-key, or a KMS key for every --split group")
+		}
+		if encryptValues && g.AgeRecipient != "" {
+			return nil, errors.New("error: --encrypt-values encrypts with KMS data keys, it can not be combined with age recipients of --split groups")
+		}
+		dataKeys[g.Name] = key
+	}
+	var dataKey func(string) (*dump.DataKey, error)
+	if encryptValues {
+		dataKey = func(group string) (*dump.DataKey, error) {
+			plainkey, cipherkey, err := aws.KMSDataKey(dataKeys[group])
+			if err != nil {
+				return nil, err
+			}
+			return &dump.DataKey{Plaintext: plainkey, Ciphertext: cipherkey, KMSKey: dataKeys[group]}, nil
+		}
+	}
+
+	checkpoint, resume := viper.GetString(checkpointFlag), viper.GetString(resumeFlag)
+	if resume != "" {
+		if checkpoint != "" && checkpoint != resume {
+			return nil, errors.New("error: --resume keeps recording to the checkpoint it resumes, --checkpoint must be the same file or left out")
+		}
+		checkpoint = resume
+	}
+	// the checkpoint holds the secrets in plaintext, which encrypted output
+	// must never leave on disk
+	if checkpoint != "" {
+		encrypted := kmsKey != "" || encryptValues
+		for _, g := range groups {
+			encrypted = encrypted || g.KMSKey != "" || g.AgeRecipient != ""
+		}
+		if encrypted {
+			return nil, errors.New("error: the checkpoint holds secrets in plaintext, it can not be combined with encrypted output, --kms-key, keys of --split groups or --encrypt-values")
+		}
+	}
+
+	incremental, statePath := viper.GetBool(incrementalFlag), viper.GetString(stateFlag)
+	if incremental && statePath == "" {
+		return nil, errors.New("error: --incremental requires --state")
+	}
+	if incremental && (kind != "file" || len(groups) > 0) {
+		return nil, errors.New("error: incremental dumps are only written for file output without splitting")
+	}
+	var modifiedSince time.Time
+	if since := viper.GetString(sinceFlag); since != "" {
+		if incremental {
+			return nil, errors.New("error: --since can not be combined with --incremental, which already reads only the secrets changed")
+		}
+		t, err := dump.ParseSince(since, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("error: %w", err)
+		}
+		modifiedSince = t
+	}
+
+	versions, err := dump.ParseVersions(viper.GetString(versionsFlag))
+	if err != nil {
+		return nil, fmt.Errorf("error: %w", err)
+	}
+	if versions != dump.VersionsLatest && kind == "kafka" {
+		return nil, errors.New("error: earlier versions are not published to Kafka")
+	}
+	if viper.GetBool(includeMetaFlag) && kind == "kafka" {
+		return nil, errors.New("error: metadata is not published to Kafka")
+	}
+	filter, err := dump.NewFilter(
+		viper.GetStringSlice(includePathFlag),
+		viper.GetStringSlice(excludePathFlag),
+		viper.GetStringSlice(includeKeyFlag),
+		viper.GetStringSlice(excludeKeyFlag),
+		viper.GetStringSlice(includeMatchFlag),
+		viper.GetStringSlice(excludeMatchFlag),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error: %w", err)
+	}
+	var policy *dump.ClassPolicy
+	if fp := viper.GetString(classPolicyFlag); fp != "" {
+		if policy, err = dump.ReadClassPolicy(fp); err != nil {
+			return nil, fmt.Errorf("error: %w", err)
+		}
+	}
+	deny, err := denyPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if deny != nil && viper.GetBool(raftSnapshotFlag) {
+		return nil, errors.New("error: a raft snapshot holds every secret, it can not be taken under a deny policy")
+	}
+
+	partial := viper.GetBool(partialFlag)
+	if partial && kind != "file" && kind != "stdout" {
+		return nil, errors.New("error: partial output is only written for file and stdout output")
+	}
+	if partial && len(groups) > 0 {
+		return nil, errors.New("error: partial output can not be combined with splitting")
+	}
+
+	if viper.GetBool(relativePathsFlag) && strings.Contains(paths, ",") {
+		return nil, errors.New("error: relative paths require a single path to dump")
+	}
+
+	var namespaces []string
+	if viper.GetBool(recurseNSFlag) {
+		if viper.GetBool(relativePathsFlag) {
+			return nil, errors.New("error: relative paths can not be combined with --recurse-namespaces")
+		}
+		children, err := vc.Namespaces()
+		if err != nil {
+			return nil, fmt.Errorf("error: failed to list the namespaces: %w", err)
+		}
+		log.Printf("Found %d namespaces below %q\n", len(children), vc.Namespace)
+		namespaces = append([]string{""}, children...)
+	}
+
+	var publish func(map[string]string) error
+	if kind == "kafka" {
+		brokers := viper.GetStringSlice(kafkaBrokersFlag)
+		topic := viper.GetString(kafkaTopicFlag)
+		if len(brokers) == 0 || topic == "" {
+			return nil, errors.New("error: Kafka output requires --kafka-brokers and --kafka-topic")
+		}
+		publish = func(messages map[string]string) error {
+			return publishKafka(brokers, topic, messages, kmsKey)
+		}
+	}
+
+	var streamOut func([]byte) error
+	if kind == "stdout" {
+		out, err := outputWriter()
+		if err != nil {
+			return nil, err
+		}
+		if kmsKey != "" {
+			streamOut = func(data []byte) error {
+				return streamEncrypted(out, data, kmsKey)
+			}
+		} else if out != os.Stdout {
+			streamOut = func(data []byte) error {
+				_, err := out.Write(append(data, '\n'))
+				return err
+			}
+		}
 	}
 
 	outputFilename := viper.GetString(fileFlag)
-	dumper, err := dump.New(&dump.Config{
+	programs, err := startProcessors(viper.GetStringSlice(postProcessFlag))
+	if err != nil {
+		return nil, err
+	}
+	processors := make([]dump.Processor, len(programs))
+	for i, p := range programs {
+		processors[i] = p
+	}
+	defer func() {
+		for _, p := range programs {
+			if cerr := p.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	}()
+	destination, err := dumpDestination(kind, s3path, outputPath)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		since    *dump.IncrementalState
+		previous map[string]interface{}
+		dumpFile string
+	)
+	if incremental {
+		if dumpFile, err = filepath.Abs(fmt.Sprintf("%s/%s.%s", outputPath, outputFilename, encoding)); err != nil {
+			return nil, err
+		}
+		if since, previous, err = previousDump(statePath, dumpFile); err != nil {
+			return nil, err
+		}
+	}
+	dumper, err = dump.New(&dump.Config{
 		Debug:       Verbose,
 		InputPath:   paths,
 		Filename:    outputFilename,
+		Groups:      groups,
 		Output:      outputConfig,
 		VaultConfig: vc,
+
+		MaxValueSize:    viper.GetInt(maxValueSizeFlag),
+		ExternalizeSize: viper.GetInt(externalizeSizeFlag),
+		RelativePaths:   viper.GetBool(relativePathsFlag),
+		Deleted:         viper.GetString(deletedFlag),
+		Publish:         publish,
+		Stream:          streamOut,
+		Processors:      processors,
+		Namespaces:      namespaces,
+		YAML:            yamlOpts,
+		JSON:            jsonOptions(),
+		Concurrency:     viper.GetInt(concurrencyFlag),
+		MetadataOnly:    viper.GetBool(metadataOnlyFlag),
+		KeysOnly:        viper.GetBool(keysOnlyFlag),
+		Context:         runContext(),
+		Partial:         partial,
+		Checkpoint:      checkpoint,
+		Resume:          resume != "",
+		Incremental:     incremental,
+		Since:           since,
+		Versions:        versions,
+		IncludeMetadata: viper.GetBool(includeMetaFlag),
+		Filter:          filter,
+		MaxDepth:        viper.GetInt(maxDepthFlag),
+		Policy:          policy,
+		Destination:     destination,
+		Deny:            deny,
+		DryRun:          viper.GetBool(dryRunFlag),
+		ModifiedSince:   modifiedSince,
+		EncryptValues:   encryptValues,
+		EncryptNames:    viper.GetBool(encryptNamesFlag),
+		DataKey:         dataKey,
+		Previous:        previous,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if err := dumper.Secrets(); err != nil {
+		return nil, err
+	}
+	if plan := dumper.Plan(); plan != nil {
+		printPlan(plan)
+		return dumper, nil
+	}
+
+	// written uploads, encrypts or indexes the files of the dump once they
+	// are written, files with encrypted values are left readable
+	written := func() error {
+		if kind == "s3" {
+			var delta *deltaUpload
+			if viper.GetBool(deltaFlag) {
+				delta = newDeltaUpload(s3path, dumper.Checksums())
+			}
+			for _, g := range append([]dump.Group{{KMSKey: kmsKey}}, groups...) {
+				if err := uploadGroup(outputPath, s3path, outputFilename, g, kmsKey, delta); err != nil {
+					return err
+				}
+			}
+			if delta != nil {
+				if err := delta.write(s3path, outputFilename, kmsKey); err != nil {
+					return err
+				}
+			}
+			if err := uploadQuarantine(outputPath, s3path, outputFilename, kmsKey); err != nil {
+				return err
+			}
+		} else if kind == "file" && encryptValues {
+			for _, g := range append([]dump.Group{{}}, groups...) {
+				srcPath := fmt.Sprintf("%s/%s.%s", outputPath, dump.GroupFilename(outputFilename, g.Name), encoding)
+				if _, err := os.Stat(srcPath); err != nil || !viper.GetBool(indexFlag) {
+					continue
+				}
+				if _, err := writeIndex(srcPath); err != nil {
+					return err
+				}
+			}
+			if err := encryptQuarantine(outputPath, outputFilename, kmsKey); err != nil {
+				return err
+			}
+		} else if kind == "file" && len(groups) > 0 {
+			for _, g := range append([]dump.Group{{KMSKey: kmsKey}}, groups...) {
+				if err := encryptGroup(outputPath, outputFilename, g, kmsKey); err != nil {
+					return err
+				}
+			}
+			if err := encryptQuarantine(outputPath, outputFilename, kmsKey); err != nil {
+				return err
+			}
+		} else if kind == "file" && viper.GetBool(indexFlag) {
+			if _, err := writeIndex(fmt.Sprintf("%s/%s.%s", outputPath, outputFilename, encoding)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := written(); err != nil {
+		return dumper, err
+	}
+	if incremental {
+		st := dumper.IncrementalState()
+		st.Dump = dumpFile
+		if err := st.Write(statePath); err != nil {
+			return dumper, fmt.Errorf("error: failed to write the incremental state: %w", err)
+		}
+	}
+
+	// the snapshot is taken once the logical dump is written, a failed
+	// snapshot fails the run but leaves the dump in place
+	if viper.GetBool(raftSnapshotFlag) {
+		if runContext().Err() != nil {
+			return dumper, dump.ErrInterrupted
+		}
+		if err := saveSnapshot(vc, outputPath, s3path, outputFilename, kmsKey); err != nil {
+			return dumper, err
+		}
+	}
+
+	if follow == "" {
+		return dumper, nil
+	}
+	report(nil)
+	return dumper, followAudit(follow, dumper, func(paths []string) (int, error) {
+		changed, err := dumper.Changed(paths)
+		if err != nil || len(changed) == 0 {
+			return 0, err
+		}
+		if r, err = startRun("dump", c.Address); err != nil {
+			return 0, err
+		}
+		scope = make(map[string]bool)
+		refreshed, err := dumper.Refresh(changed)
+		for _, p := range refreshed {
+			scope[p] = true
+		}
+		if err == nil {
+			err = written()
+		}
+		report(err)
+		return len(refreshed), err
+	})
+}
+
+// previousDump reads the incremental state at statePath and the secrets of
+// the dump it describes, nothing when there is no state or it describes
+// another file than dumpFile
+func previousDump(statePath, dumpFile string) (*dump.IncrementalState, map[string]interface{}, error) {
+	st, err := dump.ReadIncrementalState(statePath)
+	if os.IsNotExist(err) {
+		log.Printf("No incremental state at %s, reading every secret\n", statePath)
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error: %w", err)
+	}
+	if st.Dump != dumpFile {
+		log.Printf("Warning: the incremental state describes %s, reading every secret\n", st.Dump)
+		return nil, nil, nil
+	}
+	data, err := ioutil.ReadFile(dumpFile)
+	if os.IsNotExist(err) {
+		log.Printf("Warning: the previous dump %s is gone, reading every secret\n", dumpFile)
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	previous, err := load.Secrets(data, dumpFile, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error: failed to read the previous dump: %w", err)
+	}
+	return st, previous, nil
+}
+
+// publishKafka encrypts each message with kmsKey when one is given and
+// publishes them keyed by path
+func publishKafka(brokers []string, topic string, messages map[string]string, kmsKey string) error {
+	if kmsKey != "" {
+		for p, plaintext := range messages {
+			ciphertext, err := aws.KMSEncrypt(plaintext, kmsKey)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt %s: %w", p, err)
+			}
+			messages[p] = ciphertext
+		}
+	}
+	return kafka.Publish(brokers, topic, messages)
+}
+
+// streamEncrypted writes data to out as an encrypted stream whose data key is
+// encrypted with kmsKey, see pkg/stream
+func streamEncrypted(out io.Writer, data []byte, kmsKey string) error {
+	plainkey, cipherkey, err := aws.KMSDataKey(kmsKey)
+	if err != nil {
+		return err
+	}
+	w, err := stream.NewWriter(out, stream.Header{
+		Key:      cipherkey,
+		KMSKey:   kmsKey,
+		Encoding: encoding,
+		Created:  time.Now().UTC().Format(time.RFC3339),
+	}, plainkey)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
 		return err
 	}
+	return w.Close()
+}
+
+var (
+	outputMu   sync.Mutex
+	outputFile *os.File
+)
+
+// outputWriter returns where stdout output is written, the file descriptor
+// of --output-fd, the named pipe of --output-fifo or stdout. It is opened
+// once, so the dumps of --watch follow each other on the same pipe.
+func outputWriter() (*os.File, error) {
+	fd, fifo := viper.GetInt(outputFDFlag), viper.GetString(outputFIFOFlag)
+	if fd == 0 && fifo == "" {
+		return os.Stdout, nil
+	}
+	if fd != 0 && fifo != "" {
+		return nil, errors.New("error: --output-fd can not be combined with --output-fifo")
+	}
+
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	if outputFile != nil {
+		return outputFile, nil
+	}
+	if fd != 0 {
+		if fd < 0 {
+			return nil, fmt.Errorf("error: invalid file descriptor %d", fd)
+		}
+		f := os.NewFile(uintptr(fd), fmt.Sprintf("fd %d", fd))
+		if f == nil {
+			return nil, fmt.Errorf("error: invalid file descriptor %d", fd)
+		}
+		if _, err := f.Stat(); err != nil {
+			return nil, fmt.Errorf("error: file descriptor %d is not open: %w", fd, err)
+		}
+		outputFile = f
+		return outputFile, nil
+	}
+
+	// a regular file would leave the dump on the filesystem
+	info, err := os.Stat(fifo)
+	if err != nil {
+		return nil, fmt.Errorf("error: %w", err)
+	}
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		return nil, fmt.Errorf("error: %s is not a named pipe", fifo)
+	}
+	// blocks until the reading end is opened
+	f, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	outputFile = f
+	return outputFile, nil
+}
+
+// printPlan prints the paths a dry run would dump followed by a summary
+func printPlan(plan *dump.Plan) {
+	for _, p := range plan.Paths {
+		fmt.Println(p)
+	}
+	fmt.Printf("%d secrets with %d key names found, about %d bytes without values", len(plan.Paths), plan.Keys, plan.Size)
+	if len(plan.Failed) > 0 {
+		fmt.Printf(", %d paths failed", len(plan.Failed))
+	}
+	fmt.Println()
+}
 
-	if output == "s3" {
-		srcPath := fmt.Sprintf("%s/%s.%s", outputPath, outputFilename, encoding)
-		dstPath := fmt.Sprintf("%s/%s.%s.%s", s3path, outputFilename, encoding, cryptExt)
-		plaintext, err := ioutil.ReadFile(srcPath)
+// dumpDestination returns where the dump goes as the classification policy
+// matches it, the S3 path, the absolute output directory, kafka://topic or
+// stdout
+func dumpDestination(kind, s3path, outputPath string) (string, error) {
+	switch kind {
+	case "s3":
+		return s3path, nil
+	case "kafka":
+		return "kafka://" + viper.GetString(kafkaTopicFlag), nil
+	case "stdout":
+		return "stdout", nil
+	}
+	return filepath.Abs(outputPath)
+}
+
+// joinPaths returns the paths of every argument as one comma separated list,
+// each argument may hold several paths separated by commas
+func joinPaths(args []string) string {
+	paths := []string{}
+	for _, arg := range args {
+		for _, p := range strings.Split(arg, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				paths = append(paths, p)
+			}
+		}
+	}
+	return strings.Join(paths, ",")
+}
+
+// pathGroups returns a group for each of paths, so that the secrets of each
+// are written to a file of their own
+func pathGroups(vc *vault.Config, paths []string) ([]dump.Group, error) {
+	resolved := make([]string, len(paths))
+	for i, p := range paths {
+		r, err := vc.ResolveMountPath(p, "data")
 		if err != nil {
-			// This is expected if no secrets were dumped
-			log.Println("Nothing to upload")
-			return nil
+			return nil, fmt.Errorf("error: failed to resolve %s: %w", p, err)
 		}
-		ciphertext, err := aws.KMSEncrypt(string(plaintext), kmsKey)
+		resolved[i] = r
+	}
+	groups, err := dump.PathGroups(paths, resolved)
+	if err != nil {
+		return nil, fmt.Errorf("error: %w", err)
+	}
+	return groups, nil
+}
+
+// uploadGroup encrypts the file written for a group with its key, falling
+// back to the default key, and uploads it to S3 unless delta, when given,
+// finds it unchanged since the previous upload
+func uploadGroup(outputPath, s3path, outputFilename string, g dump.Group, defaultKey string, delta *deltaUpload) error {
+	filename := dump.GroupFilename(outputFilename, g.Name)
+	srcPath := fmt.Sprintf("%s/%s.%s", outputPath, filename, encoding)
+	key, ext := groupKey(g, defaultKey)
+	dstPath := fmt.Sprintf("%s/%s.%s.%s", s3path, filename, encoding, ext)
+	plaintext, err := ioutil.ReadFile(srcPath)
+	if err != nil {
+		// This is expected if no secrets were dumped for the group
+		log.Println("Nothing to upload for", filename)
+		return nil
+	}
+	if delta != nil && delta.unchanged(g.Name, dstPath, key, viper.GetBool(indexFlag)) {
+		return nil
+	}
+	ciphertext, err := encryptFor(g, plaintext, key)
+	if err != nil {
+		return err
+	}
+	if err := aws.S3Put(dstPath, ciphertext); err != nil {
+		return err
+	}
+	if !viper.GetBool(indexFlag) {
+		return nil
+	}
+	indexPath, err := writeIndex(srcPath)
+	if err != nil {
+		return err
+	}
+	if plaintext, err = ioutil.ReadFile(indexPath); err != nil {
+		return err
+	}
+	if ciphertext, err = encryptFor(g, plaintext, key); err != nil {
+		return err
+	}
+	return aws.S3Put(indexLocation(dstPath), ciphertext)
+}
+
+// groupKey returns the key the files of a group are encrypted with, its age
+// recipient or its KMS key, falling back to the default key, and the
+// extension of the encrypted files
+func groupKey(g dump.Group, defaultKey string) (string, string) {
+	if g.AgeRecipient != "" {
+		return g.AgeRecipient, dump.AgeExt
+	}
+	if g.KMSKey != "" {
+		return g.KMSKey, cryptExt
+	}
+	return defaultKey, cryptExt
+}
+
+// encryptFor encrypts plaintext for a group with key, as returned by groupKey
+func encryptFor(g dump.Group, plaintext []byte, key string) (string, error) {
+	if g.AgeRecipient != "" {
+		return dump.AgeEncrypt(plaintext, key)
+	}
+	return aws.KMSEncrypt(string(plaintext), key)
+}
+
+// uploadQuarantine encrypts the quarantine file of the dump with kmsKey and
+// uploads it next to the dump, when some secrets could not be dumped
+func uploadQuarantine(outputPath, s3path, outputFilename, kmsKey string) error {
+	filename := dump.QuarantineFilename(outputFilename)
+	plaintext, err := ioutil.ReadFile(fmt.Sprintf("%s/%s", outputPath, filename))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ciphertext, err := aws.KMSEncrypt(string(plaintext), kmsKey)
+	if err != nil {
+		return err
+	}
+	return aws.S3Put(fmt.Sprintf("%s/%s.%s", s3path, filename, cryptExt), ciphertext)
+}
+
+// encryptQuarantine replaces the quarantine file of the dump, when some
+// secrets could not be dumped, with one encrypted by kmsKey, like the files
+// of its groups, and removes the encrypted one of an earlier dump otherwise
+func encryptQuarantine(outputPath, outputFilename, kmsKey string) error {
+	srcPath := fmt.Sprintf("%s/%s", outputPath, dump.QuarantineFilename(outputFilename))
+	plaintext, err := ioutil.ReadFile(srcPath)
+	if os.IsNotExist(err) {
+		// the dump is complete, the quarantine of an earlier one is stale
+		if err := os.Remove(srcPath + "." + cryptExt); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if kmsKey == "" {
+		return nil
+	}
+	ciphertext, err := aws.KMSEncrypt(string(plaintext), kmsKey)
+	if err != nil {
+		return err
+	}
+	if ok := file.WriteFile(srcPath+"."+cryptExt, ciphertext); !ok {
+		return fmt.Errorf("failed to write %s.%s", srcPath, cryptExt)
+	}
+	return os.Remove(srcPath)
+}
+
+// encryptGroup replaces the plaintext file written for a group with one
+// encrypted by the group's key or age recipient, falling back to the default
+// key, and leaves it in plaintext when none is set
+func encryptGroup(outputPath, outputFilename string, g dump.Group, defaultKey string) error {
+	filename := dump.GroupFilename(outputFilename, g.Name)
+	srcPath := fmt.Sprintf("%s/%s.%s", outputPath, filename, encoding)
+	plaintext, err := ioutil.ReadFile(srcPath)
+	if err != nil {
+		log.Println("Nothing to encrypt for", filename)
+		return nil
+	}
+	key, ext := groupKey(g, defaultKey)
+	// the index is encrypted with the file it lists
+	paths := []string{srcPath}
+	if viper.GetBool(indexFlag) {
+		indexPath, err := writeIndex(srcPath)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, indexPath)
+	}
+	if key == "" {
+		log.Printf("Warning: no KMS key for %s, leaving it unencrypted\n", filename)
+		return nil
+	}
+	for _, p := range paths {
+		if plaintext, err = ioutil.ReadFile(p); err != nil {
+			return err
+		}
+		ciphertext, err := encryptFor(g, plaintext, key)
 		if err != nil {
 			return err
 		}
-		err = aws.S3Put(dstPath, ciphertext)
+		if ok := file.WriteFile(p+"."+ext, ciphertext); !ok {
+			return fmt.Errorf("failed to write %s.%s", p, ext)
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveSnapshot downloads a raft snapshot of the cluster next to the dump as
+// <filename>.snap, or as an encrypted stream <filename>.snap.aes when a KMS
+// key is set, and uploads it when s3path is set
+func saveSnapshot(vc *vault.Config, outputPath, s3path, outputFilename, kmsKey string) error {
+	// the deny policy can not be applied to a snapshot
+	if deny, err := denyPolicy(); err != nil {
+		return err
+	} else if deny != nil {
+		return errors.New("error: a raft snapshot holds every secret, it can not be taken under a deny policy")
+	}
+	name := outputFilename + ".snap"
+	if kmsKey != "" {
+		name += "." + cryptExt
+	}
+	dest := file.LocalPath(fmt.Sprintf("%s/%s", outputPath, name))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, UMASK)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var (
+		w  io.Writer = f
+		sw *stream.Writer
+	)
+	if kmsKey != "" {
+		plainkey, cipherkey, err := aws.KMSDataKey(kmsKey)
 		if err != nil {
 			return err
 		}
+		if sw, err = stream.NewWriter(f, stream.Header{
+			Key:      cipherkey,
+			KMSKey:   kmsKey,
+			Encoding: "snap",
+			Created:  time.Now().UTC().Format(time.RFC3339),
+		}, plainkey); err != nil {
+			return err
+		}
+		w = sw
+	}
+	n, err := vc.RaftSnapshot(w)
+	if err == nil && sw != nil {
+		err = sw.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// a partial snapshot must not be mistaken for one
+		os.Remove(dest)
+		return err
 	}
 
+	if s3path != "" {
+		data, err := ioutil.ReadFile(dest)
+		if err != nil {
+			return err
+		}
+		if err := aws.S3Put(s3path+"/"+name, string(data)); err != nil {
+			return err
+		}
+		log.Printf("Uploaded a raft snapshot of %d bytes to %s/%s", n, s3path, name)
+		return nil
+	}
+	log.Printf("Wrote a raft snapshot of %d bytes to %s", n, dest)
 	return nil
 }
+
+// startProcessors starts the programs of --post-process, each is split into
+// the program and its arguments on whitespace
+func startProcessors(commands []string) ([]*dump.ExecProcessor, error) {
+	programs := []*dump.ExecProcessor{}
+	for _, c := range commands {
+		args := strings.Fields(c)
+		if len(args) == 0 {
+			continue
+		}
+		e, err := dump.StartExec(args[0], args[1:]...)
+		if err != nil {
+			for _, p := range programs {
+				p.Close()
+			}
+			return nil, err
+		}
+		programs = append(programs, e)
+	}
+	return programs, nil
+}
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dathan/go-vault-dump/pkg/diff"
+	"github.com/dathan/go-vault-dump/pkg/dump"
+	"github.com/dathan/go-vault-dump/pkg/vault"
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const editHeader = `# Edit the secrets below, lines starting with '#' are ignored. Removing a
+# secret deletes it, secrets can be added below the edited path. The changes
+# are shown before they are written to Vault.
+#
+`
+
+var (
+	editValues string
+	editYes    bool
+)
+
+func init() {
+	Cmd := &cobra.Command{
+		Use:   "edit [flags] <path>",
+		Short: "Edit a secret, or the secrets below a path, in $EDITOR",
+		Args:  cobra.ExactArgs(1),
+		RunE:  doEdit,
+	}
+	Cmd.Flags().StringVar(&editValues, "values", string(diff.Redact), "how to show values in the diff, [redact, hash, show]")
+	Cmd.Flags().BoolVarP(&editYes, "yes", "y", false, "write the changes without asking")
+	rootCmd.AddCommand(Cmd)
+}
+
+func doEdit(cmd *cobra.Command, args []string) (err error) {
+	mode, err := diff.ParseValues(editValues)
+	if err != nil {
+		return fmt.Errorf("error: %w", err)
+	}
+	// checked before editing, so no edit is lost to the guard
+	if err := checkWrites(viper.GetString(vaFlag)); err != nil {
+		return err
+	}
+
+	trace, err := tracer()
+	if err != nil {
+		return err
+	}
+	pathTokens, err := vault.ParsePathTokens(viper.GetStringSlice(pathTokenFlag))
+	if err != nil {
+		return err
+	}
+	vc, err := vault.NewClient(&vault.Config{
+		Auth:         auth(),
+		TLS:          tlsConfig(),
+		Namespace:    viper.GetString(namespaceFlag),
+		Retries:      viper.GetInt(retriesFlag),
+		RetryBackoff: viper.GetDuration(retryBackoffFlag),
+		MaxRetryWait: viper.GetDuration(maxRetryWaitFlag),
+		Timeout:      viper.GetDuration(requestTimeoutFlag),
+		RateLimit:    viper.GetFloat64(rateLimitFlag),
+		Context:      runContext(),
+		Address:      viper.GetString(vaFlag),
+		ReadOnly:     readOnly(false),
+		Token:        vaultToken(),
+		Trace:        trace,
+		Usage:        requests,
+		Ignore:       &vault.Ignore{},
+		PathTokens:   pathTokens,
+	})
+	if err != nil {
+		return err
+	}
+
+	secrets, err := readSubtree(vc, args[0])
+	if err != nil {
+		return err
+	}
+	if len(secrets) == 0 {
+		return fmt.Errorf("error: no secrets at %s", args[0])
+	}
+	prefix, err := vc.ResolveMountPath(args[0], "data")
+	if err != nil {
+		return err
+	}
+
+	// the secrets are only written to a file readable by the owner, removed
+	// when done
+	f, err := ioutil.TempFile("", "vault-dump-edit-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	data, err := yaml.Marshal(secrets)
+	if err == nil {
+		_, err = f.WriteString(editHeader + string(data))
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	var edited map[string]interface{}
+	for {
+		if edited, err = editSecrets(f.Name(), vault.EnsureNoTrailingSlash(prefix)); err == nil {
+			break
+		}
+		fmt.Fprintln(os.Stderr, "error:", err)
+		if !confirm("Edit again?") {
+			return fmt.Errorf("error: edit of %s cancelled", args[0])
+		}
+	}
+
+	report := diff.NewReport(args[0], "edited", secrets, edited, mode)
+	if len(report.Changes) == 0 {
+		log.Println("No changes")
+		return nil
+	}
+	if err := diff.Unified(os.Stdout, report); err != nil {
+		return err
+	}
+	if !editYes && !confirm("Write these changes to Vault?") {
+		log.Println("Changes not written")
+		return nil
+	}
+
+	var b bytes.Buffer
+	if err := diff.JSONPatch(&b, diff.NewReport(args[0], "edited", secrets, edited, diff.Show)); err != nil {
+		return err
+	}
+	patches, err := diff.ParsePatch(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("error: %w", err)
+	}
+
+	r, err := startRun("edit", viper.GetString(vaFlag))
+	if err != nil {
+		return err
+	}
+	written, failures := applyPatches(vc, patches)
+	defer func() {
+		r.finish(written, failures, err)
+	}()
+	if len(failures) > 0 {
+		return fmt.Errorf("error: %d of %d secrets failed to write, edit again to retry", len(failures), len(patches))
+	}
+	return nil
+}
+
+// readSubtree reads a secret, or every secret below a path, as they are read
+// back from a dump
+func readSubtree(vc *vault.Config, path string) (map[string]interface{}, error) {
+	scraper, err := dump.NewSecretScraper(vc)
+	if err != nil {
+		return nil, err
+	}
+	if scraper.Deny, err = denyPolicy(); err != nil {
+		return nil, err
+	}
+	var wg sync.WaitGroup
+	if err := scraper.Run(path, &wg, runtime.NumCPU()); err != nil {
+		return nil, err
+	}
+	wg.Wait()
+	if n := len(scraper.Failed); n > 0 {
+		return nil, fmt.Errorf("error: failed to read %d secrets below %s", n, path)
+	}
+
+	secrets := make(map[string]interface{}, len(scraper.Data))
+	for p, data := range scraper.Data {
+		fields, ok := data.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("error: %s is not a secret of fields", p)
+		}
+		if secrets[p], err = asDumped(fields); err != nil {
+			return nil, err
+		}
+	}
+	return secrets, nil
+}
+
+// editSecrets opens the file in the user's editor and returns the secrets
+// read back, which must be objects of fields at or below prefix
+func editSecrets(filename, prefix string) (map[string]interface{}, error) {
+	editor := editorCommand()
+	c := exec.Command(editor[0], append(editor[1:], filename)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return nil, fmt.Errorf("editor %s: %w", editor[0], err)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var edited map[string]interface{}
+	if err := yaml.Unmarshal(data, &edited); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	paths := make([]string, 0, len(edited))
+	for p := range edited {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if p != prefix && !strings.HasPrefix(p, prefix+"/") {
+			return nil, fmt.Errorf("%s is not below %s", p, prefix)
+		}
+		if _, ok := edited[p].(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("%s must be an object of fields", p)
+		}
+	}
+	return edited, nil
+}
+
+// editorCommand returns the user's editor, $VISUAL or $EDITOR, and its
+// arguments
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.Fields(os.Getenv(env)); len(editor) > 0 {
+			return editor
+		}
+	}
+	return []string{"vi"}
+}
+
+// confirm asks a yes or no question on the terminal, anything but yes is no
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/dathan/go-vault-dump/pkg/aws"
+	"github.com/dathan/go-vault-dump/pkg/dump"
+	"github.com/spf13/cobra"
+)
+
+var (
+	extractKMSKey   string
+	extractDestPath string
+)
+
+func init() {
+	Cmd := &cobra.Command{
+		Use:   "extract [flags] <dump> <path-prefix>",
+		Short: "Extract the secrets below a path from a dump into a new dump",
+		Args:  cobra.ExactArgs(2),
+		RunE:  doExtract,
+	}
+	Cmd.Flags().StringVarP(&extractKMSKey, "kms-key", "k", "", "encrypt the extracted dump with this KMS key, required for encrypted dumps")
+	Cmd.Flags().StringVarP(&extractDestPath, "output", "o", "", "output path, a local file or s3://bucket/key, stdout by default")
+	rootCmd.AddCommand(Cmd)
+}
+
+func doExtract(cmd *cobra.Command, args []string) error {
+	data, header, encrypted, err := readArtifact(args[0])
+	if err != nil {
+		return err
+	}
+	// the slice of an encrypted dump is never written in plaintext
+	if encrypted && extractKMSKey == "" {
+		return fmt.Errorf("error: %s is encrypted, --kms-key is required to encrypt the extracted dump", args[0])
+	}
+
+	secrets, isYAML, err := parseArtifact(data)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", args[0], err)
+	}
+
+	extracted, err := dump.Extract(secrets, args[1])
+	if err != nil {
+		return fmt.Errorf("error: %w", err)
+	}
+	if path, key, ok := externalized(extracted); ok {
+		return fmt.Errorf("error: %s:%s is externalized, dumps with externalized values can not be extracted", path, key)
+	}
+
+	var output string
+	if isYAML {
+		output, err = toYaml(extracted)
+	} else {
+		output, err = toJSON(extracted)
+	}
+	if err != nil {
+		return err
+	}
+
+	if extractKMSKey != "" {
+		if header != nil {
+			var b []byte
+			if b, err = encryptStream([]byte(output), *header, extractKMSKey); err == nil {
+				output = string(b)
+			}
+		} else {
+			output, err = aws.KMSEncrypt(output, extractKMSKey)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	count := len(extracted)
+	if _, ok := extracted[dump.ManifestKey]; ok {
+		count--
+	}
+	if err := writeArtifact(extractDestPath, output); err != nil {
+		return err
+	}
+	log.Printf("Extracted %d secrets below %s", count, args[1])
+	return nil
+}
+
+// externalized returns the first value of a dump with tagged values that
+// references a file written next to the dump
+func externalized(secrets map[string]interface{}) (string, string, bool) {
+	m, _ := secrets[dump.ManifestKey].(map[string]interface{})
+	if m["value_tagging"] != dump.ValueTaggingDollar {
+		return "", "", false
+	}
+	for path, secret := range secrets {
+		values, ok := secret.(map[string]interface{})
+		if !ok || path == dump.ManifestKey {
+			continue
+		}
+		for k, v := range values {
+			if ref, ok := v.(map[string]interface{}); ok && len(ref) == 1 && ref[dump.FileRefKey] != nil {
+				return path, k, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// writeArtifact writes a dump to a local file readable by the owner only, an
+// S3 object, or stdout when dest is empty
+func writeArtifact(dest, output string) error {
+	switch {
+	case dest == "":
+		fmt.Print(output)
+	case strings.HasPrefix(dest, "s3://"):
+		if err := uploads(); err != nil {
+			return err
+		}
+		return aws.S3Put(dest, output)
+	default:
+		return ioutil.WriteFile(dest, []byte(output), UMASK)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/guard"
+	"github.com/spf13/viper"
+)
+
+const (
+	confirmProductionFlag = "confirm-production"
+	productionPatternFlag = "production-pattern"
+	writeWindowFlag       = "write-window"
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringSlice(writeWindowFlag, []string{}, "only write to Vault within these windows, \"[days] HH:MM-HH:MM [zone]\", e.g. \"Mon-Fri 22:00-06:00 UTC\", may be repeated")
+	rootCmd.PersistentFlags().String(productionPatternFlag, "", "regular expression of production Vault addresses, writing to them requires --confirm-production")
+	rootCmd.PersistentFlags().Bool(confirmProductionFlag, false, "confirm writing to a Vault address matching --production-pattern")
+	for _, f := range []string{writeWindowFlag, productionPatternFlag, confirmProductionFlag} {
+		viper.BindPFlag(f, rootCmd.PersistentFlags().Lookup(f))
+	}
+}
+
+// checkWrites refuses to write to the Vault at addr outside of the write
+// windows, or to a production address without --confirm-production
+func checkWrites(addr string) error {
+	if pattern := viper.GetString(productionPatternFlag); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("error: invalid --%s: %w", productionPatternFlag, err)
+		}
+		if re.MatchString(addr) {
+			if !viper.GetBool(confirmProductionFlag) {
+				return fmt.Errorf("error: %s is a production address, pass --%s to write to it", addr, confirmProductionFlag)
+			}
+			log.Printf("Writing to production address %s", addr)
+		}
+	}
+
+	specs := viper.GetStringSlice(writeWindowFlag)
+	if len(specs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, spec := range specs {
+		w, err := guard.ParseWindow(spec)
+		if err != nil {
+			return fmt.Errorf("error: invalid --%s: %w", writeWindowFlag, err)
+		}
+		if w.Contains(now) {
+			return nil
+		}
+	}
+	return fmt.Errorf("error: writes to %s are only allowed within %v, it is %s", addr, specs, now.Format("Mon 15:04 MST"))
+}
@@ -1,81 +1,201 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/dathan/go-vault-dump/pkg/aws"
 	"github.com/dathan/go-vault-dump/pkg/file"
 	"github.com/dathan/go-vault-dump/pkg/load"
+	"github.com/dathan/go-vault-dump/pkg/stream"
 	"github.com/dathan/go-vault-dump/pkg/vault"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+const (
+	rotateDatabaseFlag = "rotate-database"
+	rotateWebhookFlag  = "rotate-webhook"
+)
+
 var (
-	Brute     bool
-	importCmd *cobra.Command
+	Brute            bool
+	allowStale       bool
+	force            bool
+	importYes        bool
+	maxAge           time.Duration
+	restoreDeletions bool
+	restoreHistory   bool
+	restoreMetadata  bool
+	restoreOrder     string
+	target           string
+	importCmd        *cobra.Command
 )
 
 func init() {
 	importCmd = &cobra.Command{
-		Use:   "import [flags] <filename>",
-		Short: "Import secrets to Vault",
-		Args:  cobra.ExactArgs(1),
-		RunE:  importVault,
+		Use:    "import [flags] <filename|s3://bucket/key|->",
+		Short:  "Import secrets to Vault",
+		Args:   cobra.ExactArgs(1),
+		PreRun: bindImportFlags,
+		RunE:   importVault,
 	}
-	importCmd.Flags().BoolVarP(&Brute, "brute", "", false, "retry failed indefinitely")
-	importCmd.Flags().ParseErrorsWhitelist.UnknownFlags = true
+	addImportFlags(importCmd)
+	importCmd.Flags().StringVar(&target, "target", "", "restore below this path instead of the path the dump was taken from")
 	rootCmd.AddCommand(importCmd)
 }
 
+// addImportFlags adds the flags of writing a dump to Vault to c
+func addImportFlags(c *cobra.Command) {
+	c.Flags().BoolVarP(&Brute, "brute", "", false, "retry failed indefinitely")
+	c.Flags().BoolVar(&allowStale, "allow-stale", false, "restore dumps older than --max-age")
+	c.Flags().DurationVar(&maxAge, "max-age", 7*24*time.Hour, "refuse dumps older than this (0 to disable)")
+	c.Flags().BoolVar(&force, "force", false, "restore into a different cluster than the dump was taken from")
+	c.Flags().BoolVar(&restoreDeletions, "restore-deletions", false, "delete or destroy the latest version of secrets recorded as tombstones")
+	c.Flags().BoolVar(&restoreHistory, "restore-history", false, "write the earlier versions recorded in dumps of several versions before each secret")
+	c.Flags().BoolVar(&restoreMetadata, "restore-metadata", false, "replace the custom metadata and settings of secrets with those recorded in dumps including metadata")
+	c.Flags().StringVar(&restoreOrder, "restore-order", "", "JSON or YAML file of rules writing some secrets only after others, everything else is written in parallel")
+	c.Flags().Bool(rotateDatabaseFlag, false, "rotate the root credentials of restored database connections")
+	c.Flags().StringSlice(rotateWebhookFlag, []string{}, "webhook URLs to post the restored paths to for rotation")
+	c.Flags().BoolVarP(&importYes, "yes", "y", false, "write without confirming the summary of changes")
+	c.Flags().ParseErrorsWhitelist.UnknownFlags = true
+}
+
+// bindImportFlags binds the config file keys of the flags added by
+// addImportFlags to the command that runs
+func bindImportFlags(c *cobra.Command, args []string) {
+	viper.BindPFlag(rotateDatabaseFlag, c.Flags().Lookup(rotateDatabaseFlag))
+	viper.BindPFlag(rotateWebhookFlag, c.Flags().Lookup(rotateWebhookFlag))
+}
+
 func importVault(cmd *cobra.Command, args []string) error {
+	_, _, _, err := importDump("import", args[0], target)
+	return err
+}
 
-	retries := 5
-	if Brute {
-		retries = 0
+// importDump writes the dump at location to Vault below target, or the path
+// it was taken from when target is empty, and returns the paths written, why
+// the others failed and why those missing from the dump could not be dumped
+func importDump(command, location, target string) (written []string, failures, quarantined map[string]string, err error) {
+
+	if err := checkWrites(viper.GetString(vaFlag)); err != nil {
+		return nil, nil, nil, err
+	}
+	// a dump read from stdin leaves nothing to answer the confirmation with
+	if location == "-" && !importYes {
+		return nil, nil, nil, fmt.Errorf("error: the dump is read from stdin, pass --yes to %s it without confirmation", command)
+	}
+	injected, err := faults()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var order *load.Order
+	if restoreOrder != "" {
+		if order, err = load.ReadOrder(restoreOrder); err != nil {
+			return nil, nil, nil, fmt.Errorf("error: %w", err)
+		}
+	}
+	trace, err := tracer()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pathTokens, err := vault.ParsePathTokens(viper.GetStringSlice(pathTokenFlag))
+	if err != nil {
+		return nil, nil, nil, err
 	}
 	vc, err := vault.NewClient(&vault.Config{
-		Address: viper.GetString(vaFlag),
-		Retries: retries,
-		Token:   viper.GetString(vtFlag),
+		Auth:         auth(),
+		TLS:          tlsConfig(),
+		Namespace:    viper.GetString(namespaceFlag),
+		RetryBackoff: viper.GetDuration(retryBackoffFlag),
+		MaxRetryWait: viper.GetDuration(maxRetryWaitFlag),
+		Timeout:      viper.GetDuration(requestTimeoutFlag),
+		RateLimit:    viper.GetFloat64(rateLimitFlag),
+		Context:      runContext(),
+		Address:      viper.GetString(vaFlag),
+		Faults:       injected,
+		ReadOnly:     readOnly(false),
+		Renew:        true,
+		Retries:      viper.GetInt(retriesFlag),
+		Brute:        Brute,
+		Token:        vaultToken(),
+		Trace:        trace,
+		Usage:        requests,
 		Ignore: &vault.Ignore{
 			Keys:  viper.GetStringSlice(ignoreKeysFlag),
 			Paths: viper.GetStringSlice(ignorePathsFlag),
 		},
+		PathTokens: pathTokens,
 	})
 
 	if err != nil {
-		return err
+		return nil, nil, nil, err
+	}
+	defer vc.Close()
+
+	r, err := startRun(command, viper.GetString(vaFlag))
+	if err != nil {
+		return nil, nil, nil, err
 	}
+	written, failures = []string{}, map[string]string{}
+	defer func() {
+		r.finish(written, failures, err)
+	}()
 
+	var confirmRestore func(load.Plan) error
+	if !importYes {
+		confirmRestore = func(p load.Plan) error {
+			return confirmChanges(vc, command, p.Creates, p.Overwrites, p.Deletes)
+		}
+	}
 	loader, err := load.New(
 		&load.Config{
 			VaultConfig: vc,
+			Target:      target,
+
+			RestoreDeletions: restoreDeletions,
+			RestoreHistory:   restoreHistory,
+			RestoreMetadata:  restoreMetadata,
+			Force:            force,
+			MaxAge:           maxAge,
+			AllowStale:       allowStale,
+			RotateDatabase:   viper.GetBool(rotateDatabaseFlag),
+			RotateWebhooks:   viper.GetStringSlice(rotateWebhookFlag),
+			Order:            order,
+			Confirm:          confirmRestore,
+			Context:          runContext(),
+			UnwrapKey:        aws.KMSDecryptDataKey,
 		},
 	)
 	if err != nil {
-		return err
+		return written, failures, quarantined, err
 	}
 
-	filepath := args[0]
+	filepath := location
+	if filepath == "-" {
+		err = importStream(loader)
+		written, failures, quarantined = loader.Written(), loader.Failures(), loader.Quarantined()
+		return written, failures, quarantined, err
+	}
 	fromS3 := len(filepath) > 5 && filepath[:5] == "s3://"
 	tmpDir := ""
 
 	if fromS3 {
 		encrypted, err := aws.S3Get(filepath)
 		if err != nil {
-			return err
+			return written, failures, quarantined, err
 		}
 		plaintext, err := aws.KMSDecrypt(string(encrypted))
 		if err != nil {
-			return err
+			return written, failures, quarantined, err
 		}
 		tmpDir, err = ioutil.TempDir("", "vault-dump-*")
 		if err != nil {
-			return err
+			return written, failures, quarantined, err
 		}
 
 		defer os.RemoveAll(tmpDir)
@@ -86,13 +206,31 @@ func importVault(cmd *cobra.Command, args []string) error {
 		ok := file.WriteFile(filepath, plaintext)
 		if !ok {
 			os.RemoveAll(tmpDir)
-			return fmt.Errorf("error writing %s", filepath)
+			return written, failures, quarantined, fmt.Errorf("error writing %s", filepath)
 		}
 	}
 
-	if err := loader.FromFile(filepath); err != nil {
+	err = loader.FromFile(filepath)
+	written, failures, quarantined = loader.Written(), loader.Failures(), loader.Quarantined()
+	return written, failures, quarantined, err
+}
+
+// importStream restores the dump written by dump -o stdout from stdin, an
+// encrypted stream with --kms-key or plain json or yaml without, the
+// plaintext is only held in memory
+func importStream(loader *load.Config) error {
+	in := bufio.NewReader(os.Stdin)
+	if magic, _ := in.Peek(len(stream.Magic)); string(magic) != stream.Magic {
+		return loader.FromReader(in)
+	}
+	r, err := stream.NewReader(in, func(h stream.Header) ([]byte, error) {
+		return aws.KMSDecryptDataKey(h.Key)
+	})
+	if err != nil {
 		return err
 	}
-
-	return nil
+	if e := r.Header().Encoding; e != "" && e != "json" {
+		return fmt.Errorf("error: only json streams can be imported, got %s", e)
+	}
+	return loader.FromReader(r)
 }
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/dathan/go-vault-dump/pkg/dump"
+	"github.com/dathan/go-vault-dump/pkg/feature"
+	"github.com/spf13/cobra"
+)
+
+var (
+	infoCmd *cobra.Command
+	// outputBackends are the backends the dump outputs that need one are
+	// written with
+	outputBackends = map[string]string{"s3": feature.AWS, "kafka": feature.Kafka}
+)
+
+func init() {
+	infoCmd = &cobra.Command{
+		Use:   "info",
+		Short: "Print the build metadata and capabilities of this binary as JSON",
+		Args:  cobra.NoArgs,
+		RunE:  showInfo,
+	}
+	rootCmd.AddCommand(infoCmd)
+}
+
+// Info is what info prints, for automation to check what a deployed binary
+// can do before running it
+type Info struct {
+	Version     string   `json:"version"`
+	Commit      string   `json:"commit"`
+	Date        string   `json:"date"`
+	GoVersion   string   `json:"go_version"`
+	OS          string   `json:"os"`
+	Arch        string   `json:"arch"`
+	Features    []string `json:"features"`
+	Encodings   []string `json:"encodings"`
+	Outputs     []string `json:"outputs"`
+	AuthMethods []string `json:"auth_methods"`
+	// DenyPolicy is where the deny policy is read from, signed when the
+	// binary was built with a key
+	DenyPolicy       string `json:"deny_policy"`
+	DenyPolicySigned bool   `json:"deny_policy_signed"`
+}
+
+func showInfo(cmd *cobra.Command, args []string) error {
+	info := Info{
+		Version:     version,
+		Commit:      commit,
+		Date:        date,
+		GoVersion:   runtime.Version(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		Features:    feature.List(),
+		Encodings:   dump.Encodings,
+		Outputs:     []string{},
+		AuthMethods: []string{},
+
+		DenyPolicy:       denyPolicyFile,
+		DenyPolicySigned: denyPolicyKey != "",
+	}
+	// outputs and auth methods of a backend left out of the build are not
+	// offered
+	for _, kind := range dump.Kinds {
+		if backend, ok := outputBackends[kind]; !ok || feature.Enabled(backend) {
+			info.Outputs = append(info.Outputs, kind)
+		}
+	}
+	for _, method := range authMethods {
+		if method != "aws" || feature.Enabled(feature.AWS) {
+			info.AuthMethods = append(info.AuthMethods, method)
+		}
+	}
+	out, err := toJSON(info)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
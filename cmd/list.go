@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"text/tabwriter"
+	"time"
 
 	"github.com/dathan/go-vault-dump/pkg/aws"
 	"github.com/spf13/cobra"
@@ -25,7 +26,18 @@ func init() {
 	rootCmd.AddCommand(listCmd)
 }
 
+// listedExport is an export listed with --format json
+type listedExport struct {
+	Key          string    `json:"key"`
+	Size         int       `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
 func listExports(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonFormat()
+	if err != nil {
+		return err
+	}
 
 	s3path := args[0]
 	if s3path == "" {
@@ -39,6 +51,13 @@ func listExports(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if asJSON {
+		listed := make([]listedExport, len(results))
+		for i, r := range results {
+			listed[i] = listedExport{Key: r.Key, Size: r.Size, LastModified: r.LastModified}
+		}
+		return printJSON(listed)
+	}
 
 	// We're using tabwriter to align arbitrary-width columns, but it can't handle mixing left- and
 	// right-aligned columns, so the size column, where we can set a reasonable maximum (<1TB), is
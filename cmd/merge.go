@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/dathan/go-vault-dump/pkg/aws"
+	"github.com/dathan/go-vault-dump/pkg/dump"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mergeConflict string
+	mergeEncoding string
+	mergeKMSKey   string
+	mergeDestPath string
+)
+
+func init() {
+	Cmd := &cobra.Command{
+		Use:   "merge [flags] <dump> <dump>...",
+		Short: "Merge several dumps into one",
+		Args:  cobra.MinimumNArgs(2),
+		RunE:  doMerge,
+	}
+	Cmd.Flags().StringVar(&mergeConflict, "on-conflict", dump.ConflictError, fmt.Sprintf("secret to keep for paths in several dumps, %v", dump.ConflictPolicies))
+	Cmd.Flags().StringVarP(&mergeEncoding, "encoding", "e", "json", "encoding of the merged dump, [json, yaml]")
+	Cmd.Flags().StringVarP(&mergeKMSKey, "kms-key", "k", "", "encrypt the merged dump with this KMS key, required when any dump is encrypted")
+	Cmd.Flags().StringVarP(&mergeDestPath, "output", "o", "", "output path, a local file or s3://bucket/key, stdout by default")
+	rootCmd.AddCommand(Cmd)
+}
+
+func doMerge(cmd *cobra.Command, args []string) error {
+	if mergeEncoding != "json" && mergeEncoding != "yaml" {
+		return fmt.Errorf("error: invalid encoding %q, expected json or yaml", mergeEncoding)
+	}
+
+	dumps := make([]map[string]interface{}, len(args))
+	for i, location := range args {
+		data, _, encrypted, err := readArtifact(location)
+		if err != nil {
+			return err
+		}
+		if encrypted && mergeKMSKey == "" {
+			return fmt.Errorf("error: %s is encrypted, --kms-key is required to encrypt the merged dump", location)
+		}
+		if dumps[i], _, err = parseArtifact(data); err != nil {
+			return fmt.Errorf("failed to read %s: %w", location, err)
+		}
+	}
+
+	merged, conflicts, err := dump.Merge(dumps, mergeConflict)
+	if err != nil {
+		return fmt.Errorf("error: %w", err)
+	}
+	for _, c := range conflicts {
+		names := make([]string, len(c.Dumps))
+		for i, d := range c.Dumps {
+			names[i] = args[d]
+		}
+		log.Printf("Conflict: %s differs in %s, kept %s", c.Path, strings.Join(names, ", "), args[c.Kept])
+	}
+
+	var output string
+	if mergeEncoding == "yaml" {
+		output, err = toYaml(merged)
+	} else {
+		output, err = toJSON(merged)
+	}
+	if err != nil {
+		return err
+	}
+	if mergeKMSKey != "" {
+		if output, err = aws.KMSEncrypt(output, mergeKMSKey); err != nil {
+			return err
+		}
+	}
+	if err := writeArtifact(mergeDestPath, output); err != nil {
+		return err
+	}
+	log.Printf("Merged %d secrets from %d dumps with %d conflicts", len(merged)-1, len(args), len(conflicts))
+	return nil
+}
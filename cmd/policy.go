@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	policyMetadata bool
+	policyCmd      *cobra.Command
+)
+
+func init() {
+	policyCmd = &cobra.Command{
+		Use:   "policy [flags] /vault/path[,...] [...]",
+		Short: "Print the least privileged Vault policy needed to dump paths",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  printPolicy,
+	}
+	policyCmd.Flags().BoolVar(&policyMetadata, "metadata", false, "grant reading KV version 2 metadata, needed by dump --deleted previous")
+	rootCmd.AddCommand(policyCmd)
+}
+
+func printPolicy(cmd *cobra.Command, args []string) error {
+	trace, err := tracer()
+	if err != nil {
+		return err
+	}
+	vc, err := vault.NewClient(&vault.Config{
+		Auth:         auth(),
+		TLS:          tlsConfig(),
+		Namespace:    viper.GetString(namespaceFlag),
+		Retries:      viper.GetInt(retriesFlag),
+		RetryBackoff: viper.GetDuration(retryBackoffFlag),
+		MaxRetryWait: viper.GetDuration(maxRetryWaitFlag),
+		Timeout:      viper.GetDuration(requestTimeoutFlag),
+		RateLimit:    viper.GetFloat64(rateLimitFlag),
+		Context:      runContext(),
+		Address:      viper.GetString(vaFlag),
+		ReadOnly:     true,
+		Token:        vaultToken(),
+		Trace:        trace,
+		Ignore:       &vault.Ignore{},
+	})
+	if err != nil {
+		return err
+	}
+
+	paths := []string{}
+	for _, arg := range args {
+		paths = append(paths, strings.Split(arg, ",")...)
+	}
+	resolved, err := vc.PolicyPaths(paths)
+	if err != nil {
+		return fmt.Errorf("error: failed to look up the mounts of the paths: %w", err)
+	}
+	fmt.Print(vault.DumpPolicy(resolved, policyMetadata))
+	return nil
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/dathan/go-vault-dump/pkg/aws"
+	"github.com/dathan/go-vault-dump/pkg/stream"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	rekeyArn string
+)
+
+func init() {
+	Cmd := &cobra.Command{
+		Short: "Re-encrypt vault bundles with a new KMS key",
+		Use:   "rekey [flags] <path|s3://<bucket>/<key>|s3://<bucket>/<prefix>/>...",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  doRekey,
+	}
+	Cmd.Flags().StringVarP(&rekeyArn, "key", "k", "", "new KMS key ARN")
+	rootCmd.AddCommand(Cmd)
+}
+
+func doRekey(cmd *cobra.Command, args []string) error {
+	if rekeyArn == "" {
+		return errors.New("error: KMS key ARN must be specified")
+	}
+	if err := uploads(); err != nil {
+		return err
+	}
+	// artifacts are replaced in place
+	aws.NoOverwrite = false
+
+	paths, err := rekeyPaths(args)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, path := range paths {
+		if err := rekeyArtifact(path); err != nil {
+			log.Printf("Failed to rekey %s, %s\n", path, err.Error())
+			failed++
+			continue
+		}
+		log.Printf("Rekeyed %s\n", path)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to rekey %d of %d artifacts", failed, len(paths))
+	}
+	return nil
+}
+
+// rekeyPaths expands the S3 prefixes, ending in /, in args to the encrypted
+// artifacts below them
+func rekeyPaths(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "s3://") || !strings.HasSuffix(arg, "/") {
+			paths = append(paths, arg)
+			continue
+		}
+		results, err := aws.S3List(arg, "."+cryptExt)
+		if err != nil {
+			return nil, err
+		}
+		bucket := strings.Split(arg[len("s3://"):], "/")[0]
+		for _, r := range results {
+			paths = append(paths, fmt.Sprintf("s3://%s/%s", bucket, r.Key))
+		}
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("error: no artifacts to rekey")
+	}
+	return paths, nil
+}
+
+// rekeyArtifact replaces the artifact at path, a local file or an S3 object,
+// with one encrypted by the new key. S3 objects keep their storage class
+// unless --s3-storage-class is given.
+func rekeyArtifact(path string) error {
+	if !strings.HasPrefix(path, "s3://") {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rekeyed, err := rekey(data, rekeyArn)
+		if err != nil {
+			return err
+		}
+		// written beside the artifact first so a failure never leaves it half written
+		tmp := path + ".rekey"
+		if err := ioutil.WriteFile(tmp, rekeyed, UMASK); err != nil {
+			return err
+		}
+		return os.Rename(tmp, path)
+	}
+
+	data, err := aws.S3Get(path)
+	if err != nil {
+		return err
+	}
+	rekeyed, err := rekey(data, rekeyArn)
+	if err != nil {
+		return err
+	}
+	if viper.GetString(s3ClassFlag) == "" {
+		class, err := aws.S3StorageClass(path)
+		if err != nil {
+			return err
+		}
+		aws.StorageClass = class
+	}
+	return aws.S3Put(path, string(rekeyed))
+}
+
+// rekey decrypts data, a KMS encrypted artifact or stream, and encrypts it
+// again with kmsKey. The result is decrypted once more before it is returned,
+// so an artifact is never replaced by one the new key can not recover.
+func rekey(data []byte, kmsKey string) ([]byte, error) {
+	if bytes.HasPrefix(data, []byte(stream.Magic)) {
+		return rekeyStream(data, kmsKey)
+	}
+	plaintext, err := aws.KMSDecrypt(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt with the old key: %w", err)
+	}
+	ciphertext, err := aws.KMSEncrypt(plaintext, kmsKey)
+	if err != nil {
+		return nil, err
+	}
+	check, err := aws.KMSDecrypt(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt with the new key: %w", err)
+	}
+	if check != plaintext {
+		return nil, errors.New("re-encrypted artifact does not match the original")
+	}
+	return []byte(ciphertext), nil
+}
+
+// rekeyStream re-encrypts an encrypted stream with a new data key from
+// kmsKey, keeping its encoding and creation time
+func rekeyStream(data []byte, kmsKey string) ([]byte, error) {
+	unwrap := func(h stream.Header) ([]byte, error) {
+		return aws.KMSDecryptDataKey(h.Key)
+	}
+	r, err := stream.NewReader(bytes.NewReader(data), unwrap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt with the old key: %w", err)
+	}
+	plaintext, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := encryptStream(plaintext, r.Header(), kmsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err = stream.NewReader(bytes.NewReader(encrypted), unwrap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt with the new key: %w", err)
+	}
+	check, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt with the new key: %w", err)
+	}
+	if !bytes.Equal(check, plaintext) {
+		return nil, errors.New("re-encrypted stream does not match the original")
+	}
+	return encrypted, nil
+}
+
+// encryptStream encrypts plaintext into a stream with the header h and a new
+// data key of kmsKey
+func encryptStream(plaintext []byte, h stream.Header, kmsKey string) ([]byte, error) {
+	plainkey, cipherkey, err := aws.KMSDataKey(kmsKey)
+	if err != nil {
+		return nil, err
+	}
+	h.Key, h.KMSKey = cipherkey, kmsKey
+	var out bytes.Buffer
+	w, err := stream.NewWriter(&out, h, plainkey)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
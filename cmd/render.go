@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/dathan/go-vault-dump/pkg/render"
+	"github.com/spf13/cobra"
+)
+
+var (
+	renderTemplates []string
+	renderValidate  string
+)
+
+func init() {
+	Cmd := &cobra.Command{
+		Use:   "render [flags] <dump>",
+		Short: "Render Go templates with the secrets of a dump",
+		Args:  cobra.ExactArgs(1),
+		RunE:  doRender,
+	}
+	Cmd.Flags().StringSliceVarP(&renderTemplates, "template", "t", []string{}, "template to render as source[:destination], to stdout without a destination")
+	Cmd.Flags().StringVar(&renderValidate, "validate", "auto", "validate rendered files as [auto, none, k8s, helm, terraform], auto goes by the file name")
+	rootCmd.AddCommand(Cmd)
+}
+
+func doRender(cmd *cobra.Command, args []string) error {
+	if len(renderTemplates) == 0 {
+		return errors.New("error: at least one --template is required")
+	}
+	if renderValidate != "auto" && renderValidate != "none" {
+		if _, err := render.Validate(renderValidate, nil); err != nil {
+			return fmt.Errorf("error: %w", err)
+		}
+	}
+	secrets, err := readDump(args[0])
+	if err != nil {
+		return err
+	}
+	r := render.New(secrets)
+
+	// every template is rendered before any is written, so a missing secret
+	// does not leave half of the configs behind
+	rendered := make([][]byte, len(renderTemplates))
+	for i, spec := range renderTemplates {
+		src := strings.SplitN(spec, ":", 2)[0]
+		text, err := ioutil.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		var b bytes.Buffer
+		if err := r.Render(&b, src, string(text)); err != nil {
+			return fmt.Errorf("error: %w", err)
+		}
+		rendered[i] = b.Bytes()
+	}
+
+	// a broken manifest is reported here rather than when it is applied
+	invalid := 0
+	for i, spec := range renderTemplates {
+		parts := strings.SplitN(spec, ":", 2)
+		name := parts[0]
+		if len(parts) == 2 && parts[1] != "" {
+			name = parts[1]
+		}
+		problems, err := validateRendered(name, rendered[i])
+		if err != nil {
+			return err
+		}
+		for _, p := range problems {
+			log.Printf("invalid %s: %s", name, p)
+		}
+		if len(problems) > 0 {
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("error: %d rendered files are invalid, nothing was written", invalid)
+	}
+
+	for i, spec := range renderTemplates {
+		parts := strings.SplitN(spec, ":", 2)
+		if len(parts) == 1 || parts[1] == "" {
+			if _, err := os.Stdout.Write(rendered[i]); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := ioutil.WriteFile(parts[1], rendered[i], UMASK); err != nil {
+			return err
+		}
+		log.Printf("Rendered %s to %s", parts[0], parts[1])
+	}
+	return nil
+}
+
+// validateRendered validates a rendered file as --validate asks, going by its
+// name when it is auto
+func validateRendered(name string, data []byte) ([]render.Problem, error) {
+	format := renderValidate
+	switch format {
+	case "none":
+		return nil, nil
+	case "auto":
+		if format = render.FormatOf(name, data); format == "" {
+			return nil, nil
+		}
+	}
+	return render.Validate(format, data)
+}
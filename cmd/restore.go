@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	Cmd := &cobra.Command{
+		Use:    "restore [flags] <filename|s3://bucket/key|-> <vault-path>",
+		Short:  "Restore a dump to Vault below a path",
+		Args:   cobra.ExactArgs(2),
+		PreRun: bindImportFlags,
+		RunE:   restoreVault,
+	}
+	addImportFlags(Cmd)
+	rootCmd.AddCommand(Cmd)
+}
+
+func restoreVault(cmd *cobra.Command, args []string) error {
+	written, failures, quarantined, err := importDump("restore", args[0], args[1])
+	for _, p := range written {
+		fmt.Printf("restored %s\n", p)
+	}
+	failed := make([]string, 0, len(failures))
+	for p := range failures {
+		failed = append(failed, p)
+	}
+	sort.Strings(failed)
+	for _, p := range failed {
+		fmt.Printf("failed   %s: %s\n", p, failures[p])
+	}
+	missing := make([]string, 0, len(quarantined))
+	for p := range quarantined {
+		missing = append(missing, p)
+	}
+	sort.Strings(missing)
+	for _, p := range missing {
+		fmt.Printf("missing  %s: not in the dump, %s\n", p, quarantined[p])
+	}
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("error: %d of %d secrets failed to restore", len(failed), len(written)+len(failed))
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("error: the dump is incomplete, %d secrets could not be dumped and were not restored", len(missing))
+	}
+	return nil
+}
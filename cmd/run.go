@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/audit"
+	"github.com/dathan/go-vault-dump/pkg/aws"
+	"github.com/dathan/go-vault-dump/pkg/history"
+	"github.com/dathan/go-vault-dump/pkg/notify"
+	"github.com/dathan/go-vault-dump/pkg/usage"
+	"github.com/spf13/viper"
+)
+
+// run is a dump or import of a single cluster, its start and outcome are
+// reported to the audit collector and the configured monitoring
+type run struct {
+	command string
+	addr    string
+	start   time.Time
+	audit   *audit.Logger
+	// requests are the requests counted before the run started
+	requests usage.Counts
+}
+
+// requests counts the requests to Vault and AWS of every run
+var requests = &usage.Counter{}
+
+func init() {
+	aws.Requests = requests
+}
+
+// startRun records the start of command against the cluster at addr
+func startRun(command, addr string) (*run, error) {
+	l, err := audit.Dial(viper.GetString(auditSyslogFlag))
+	if err != nil {
+		return nil, fmt.Errorf("error: audit collector: %w", err)
+	}
+	if l != nil {
+		l.Version = version
+		l.Start(command, addr)
+	}
+	if u := viper.GetString(healthcheckURLFlag); u != "" {
+		if err := notify.Ping(strings.TrimSuffix(u, "/")+"/start", ""); err != nil {
+			log.Println("Warning: failed to ping healthcheck:", err)
+		}
+	}
+	return &run{command: command, addr: addr, start: time.Now(), audit: l, requests: requests.Counts()}, nil
+}
+
+// finish records the paths accessed by the run, why paths failed and the
+// error of the run, failing to report is logged but does not fail the run
+func (r *run) finish(accessed []string, failures map[string]string, err error) {
+	failed := make([]string, 0, len(failures))
+	for p := range failures {
+		failed = append(failed, p)
+	}
+	sort.Strings(failed)
+
+	for _, p := range accessed {
+		r.audit.Access(p, audit.OutcomeSuccess)
+	}
+	for _, p := range failed {
+		r.audit.Access(p, audit.OutcomeFailure)
+	}
+	r.audit.Finish(len(accessed), err)
+	r.audit.Close()
+
+	used := requests.Counts().Since(r.requests)
+	log.Printf("Requests of %s of %s: %s", r.command, r.addr, used)
+	report := aws.RunReport{
+		Command:  r.command,
+		Cluster:  r.addr,
+		Success:  err == nil,
+		Secrets:  len(accessed),
+		Failed:   len(failed),
+		Start:    r.start,
+		Duration: time.Since(r.start),
+
+		VaultRequests: used.Service(usage.Vault),
+		KMSRequests:   used.Service(usage.KMS),
+		S3Requests:    used.Service(usage.S3),
+		Cost:          used.Cost(),
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	if ns := viper.GetString(cloudWatchNamespaceFlag); ns != "" {
+		if err := aws.PutRunMetrics(ns, report); err != nil {
+			log.Println("Warning: failed to publish CloudWatch metrics:", err)
+		}
+	}
+	if bus := viper.GetString(eventBridgeBusFlag); bus != "" {
+		if err := aws.PutRunEvent(bus, report); err != nil {
+			log.Println("Warning: failed to publish EventBridge event:", err)
+		}
+	}
+	if path := viper.GetString(historyFileFlag); path != "" {
+		if err := history.Append(path, history.Run{
+			Command:  report.Command,
+			Cluster:  report.Cluster,
+			Start:    report.Start,
+			Duration: report.Duration,
+			Success:  report.Success,
+			Secrets:  report.Secrets,
+			Failed:   report.Failed,
+			Error:    report.Error,
+		}); err != nil {
+			log.Println("Warning: failed to record run history:", err)
+		}
+	}
+	if u := viper.GetString(healthcheckURLFlag); u != "" {
+		if err := pingResult(u, report); err != nil {
+			log.Println("Warning: failed to ping healthcheck:", err)
+		}
+	}
+	if (err != nil || len(failed) > 0) && viper.GetString(smtpAddrFlag) != "" {
+		if err := emailFailure(report, failures); err != nil {
+			log.Println("Warning: failed to send failure email:", err)
+		}
+	}
+}
+
+// pingResult pings the healthcheck, or its /fail endpoint for a run that
+// failed, with a summary of the run
+func pingResult(u string, report aws.RunReport) error {
+	u = strings.TrimSuffix(u, "/")
+	if !report.Success {
+		u += "/fail"
+	}
+	body := fmt.Sprintf("%s of %s: duration=%s secrets=%d failed=%d",
+		report.Command, report.Cluster, report.Duration.Round(time.Millisecond), report.Secrets, report.Failed)
+	if report.Error != "" {
+		body += " error=" + report.Error
+	}
+	return notify.Ping(u, body)
+}
+
+// emailFailure sends the failure email of a run with the reason of every
+// failed path attached
+func emailFailure(report aws.RunReport, failures map[string]string) error {
+	e := &notify.Email{
+		Addr:     viper.GetString(smtpAddrFlag),
+		Username: viper.GetString(smtpUsernameFlag),
+		Password: viper.GetString(smtpPasswordFlag),
+		From:     viper.GetString(smtpFromFlag),
+		To:       viper.GetStringSlice(smtpToFlag),
+		Subject:  viper.GetString(smtpSubjectFlag),
+		Body:     viper.GetString(smtpBodyFlag),
+	}
+	var attachments []notify.Attachment
+	if len(failures) > 0 {
+		data, err := json.MarshalIndent(failures, "", "  ")
+		if err != nil {
+			return err
+		}
+		attachments = append(attachments, notify.Attachment{
+			Name:        "failures.json",
+			ContentType: "application/json",
+			Data:        data,
+		})
+	}
+	return e.Send(report, attachments...)
+}
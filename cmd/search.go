@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/dathan/go-vault-dump/pkg/dump"
+	"github.com/dathan/go-vault-dump/pkg/file"
+	"github.com/dathan/go-vault-dump/pkg/load"
+	"github.com/dathan/go-vault-dump/pkg/search"
+	"github.com/spf13/cobra"
+)
+
+// indexExt is appended to the name of a dump file for its index
+const indexExt = "index.json"
+
+var (
+	searchPathRegex  string
+	searchKeyRegex   string
+	searchValueRegex string
+	searchShowValues bool
+	searchNoIndex    bool
+)
+
+func init() {
+	Cmd := &cobra.Command{
+		Use:   "search [flags] <dump>",
+		Short: "Find the secrets of a dump whose paths, field names or values match",
+		Args:  cobra.ExactArgs(1),
+		RunE:  doSearch,
+	}
+	Cmd.Flags().StringVar(&searchPathRegex, "path-regex", "", "match secrets whose path matches this regular expression")
+	Cmd.Flags().StringVar(&searchKeyRegex, "key-regex", "", "match fields whose name matches this regular expression")
+	Cmd.Flags().StringVar(&searchValueRegex, "value-regex", "", "match fields whose value matches this regular expression")
+	Cmd.Flags().BoolVar(&searchShowValues, "show-values", false, "print the values of matching fields")
+	Cmd.Flags().BoolVar(&searchNoIndex, "no-index", false, "search the dump even when an index was written next to it")
+	rootCmd.AddCommand(Cmd)
+}
+
+func doSearch(cmd *cobra.Command, args []string) error {
+	var (
+		q   search.Query
+		err error
+	)
+	for _, r := range []struct {
+		flag string
+		expr string
+		re   **regexp.Regexp
+	}{
+		{"path-regex", searchPathRegex, &q.Path},
+		{"key-regex", searchKeyRegex, &q.Key},
+		{"value-regex", searchValueRegex, &q.Value},
+	} {
+		if r.expr == "" {
+			continue
+		}
+		if *r.re, err = regexp.Compile(r.expr); err != nil {
+			return fmt.Errorf("error: invalid --%s: %w", r.flag, err)
+		}
+	}
+	if q.Path == nil && q.Key == nil && q.Value == nil {
+		return errors.New("error: at least one of --path-regex, --key-regex or --value-regex is required")
+	}
+
+	var matches []search.Match
+	// the index holds no values, it answers queries on paths and field names
+	if q.Value == nil && !searchShowValues && !searchNoIndex {
+		if matches, err = searchIndex(args[0], q); err != nil {
+			return err
+		}
+	}
+	if matches == nil {
+		secrets, err := readDump(args[0])
+		if err != nil {
+			return err
+		}
+		matches = search.Search(secrets, q)
+	}
+
+	for _, m := range matches {
+		switch {
+		case m.Key == "":
+			fmt.Println(m.Path)
+		case searchShowValues:
+			value, err := json.Marshal(m.Value)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s:%s=%s\n", m.Path, m.Key, value)
+		default:
+			fmt.Printf("%s:%s\n", m.Path, m.Key)
+		}
+	}
+	log.Printf("%d matches in %s", len(matches), args[0])
+	return nil
+}
+
+// searchIndex searches the index written next to a dump, nil matches mean
+// the dump has to be searched
+func searchIndex(location string, q search.Query) ([]search.Match, error) {
+	data, _, _, err := readArtifact(indexLocation(location))
+	if err != nil {
+		if Verbose {
+			log.Printf("No index for %s, searching the dump: %s", location, err)
+		}
+		return nil, nil
+	}
+	idx, err := search.ParseIndex(data)
+	if err != nil {
+		return nil, fmt.Errorf("error: %s: %w", indexLocation(location), err)
+	}
+	return idx.Search(q)
+}
+
+// indexLocation returns where the index of a dump is written, encrypted
+// dumps have an encrypted index
+func indexLocation(location string) string {
+	for _, ext := range []string{cryptExt, dump.AgeExt} {
+		if strings.HasSuffix(location, "."+ext) {
+			return strings.TrimSuffix(location, "."+ext) + "." + indexExt + "." + ext
+		}
+	}
+	return location + "." + indexExt
+}
+
+// writeIndex writes the index of the plaintext dump file at srcPath next to
+// it and returns its path
+func writeIndex(srcPath string) (string, error) {
+	data, err := ioutil.ReadFile(srcPath)
+	if err != nil {
+		return "", err
+	}
+	raw, _, err := parseArtifact(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to index %s: %w", srcPath, err)
+	}
+	created := ""
+	if m, err := dump.ExtractManifest(raw); err == nil && m != nil {
+		created = m.Created
+	}
+	secrets, err := load.Secrets(data, srcPath, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to index %s: %w", srcPath, err)
+	}
+	b, err := json.Marshal(search.NewIndex(secrets, created))
+	if err != nil {
+		return "", err
+	}
+	indexPath := indexLocation(srcPath)
+	if ok := file.WriteFile(indexPath, string(b)); !ok {
+		return "", fmt.Errorf("failed to write %v", indexPath)
+	}
+	return indexPath, nil
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/dathan/go-vault-dump/pkg/update"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	updateMirrorFlag = "update-mirror"
+)
+
+var (
+	selfUpdateCmd *cobra.Command
+	// updateKey is the base64 encoded ed25519 key the checksums of releases
+	// must be signed with, set at build time, see the Makefile
+	updateKey     = ""
+	updateRelease string
+	updateCheck   bool
+)
+
+func init() {
+	selfUpdateCmd = &cobra.Command{
+		Use:    "self-update",
+		Short:  "Replace this binary with the latest release from a mirror, once its signature and checksum are verified",
+		Args:   cobra.NoArgs,
+		PreRun: bindSelfUpdateFlags,
+		RunE:   selfUpdate,
+	}
+	selfUpdateCmd.Flags().String(updateMirrorFlag, "", "URL of the mirror of the releases")
+	selfUpdateCmd.Flags().StringVar(&updateRelease, "release", "", "version to install rather than the latest, older versions included")
+	selfUpdateCmd.Flags().BoolVar(&updateCheck, "check", false, "only print whether a newer release is available")
+	rootCmd.AddCommand(selfUpdateCmd)
+}
+
+// bindSelfUpdateFlags binds --update-mirror, so the mirror can be set once in
+// the config file
+func bindSelfUpdateFlags(c *cobra.Command, args []string) {
+	viper.BindPFlag(updateMirrorFlag, c.Flags().Lookup(updateMirrorFlag))
+}
+
+func selfUpdate(cmd *cobra.Command, args []string) error {
+	mirror := viper.GetString(updateMirrorFlag)
+	if mirror == "" {
+		return errors.New("error: set --update-mirror to the URL of the mirror of the releases")
+	}
+	if updateKey == "" {
+		return errors.New("error: this binary was built without a key to verify releases with, it can not update itself")
+	}
+
+	release := updateRelease
+	if release == "" {
+		latest, err := update.Latest(mirror)
+		if err != nil {
+			return fmt.Errorf("error: failed to find the latest release: %w", err)
+		}
+		release = latest
+	}
+	if release == version {
+		fmt.Printf("Already up to date, %s\n", version)
+		return nil
+	}
+	// a mirror serving an older latest must not roll the binary back
+	if updateRelease == "" && update.Older(release, version) {
+		if updateCheck {
+			fmt.Printf("Running %s, newer than the latest release %s\n", version, release)
+			return nil
+		}
+		return fmt.Errorf("error: the latest release %s is older than %s, pass --release %s to downgrade", release, version, release)
+	}
+	if updateCheck {
+		fmt.Printf("%s is available, running %s\n", release, version)
+		return nil
+	}
+
+	r, err := update.Find(mirror, release, update.AssetName(runtime.GOOS, runtime.GOARCH), updateKey)
+	if err != nil {
+		return fmt.Errorf("error: refusing to update: %w", err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	if err := update.Install(mirror, r, exe); err != nil {
+		return fmt.Errorf("error: failed to update %s: %w", exe, err)
+	}
+	fmt.Printf("Updated %s from %s to %s\n", exe, version, release)
+	return nil
+}
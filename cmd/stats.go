@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/dump"
+	"github.com/dathan/go-vault-dump/pkg/stats"
+	"github.com/dathan/go-vault-dump/pkg/vault"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	statsFormat   string
+	statsDestPath string
+)
+
+func init() {
+	Cmd := &cobra.Command{
+		Use:   "stats [flags] /path[,path,...]",
+		Short: "Export statistics of the secrets below paths, without paths or values",
+		Args:  cobra.ExactArgs(1),
+		RunE:  doStats,
+	}
+	Cmd.Flags().StringVar(&statsFormat, "format", stats.Prometheus, "output format, [prometheus, json]")
+	Cmd.Flags().StringVarP(&statsDestPath, "output", "o", "", "output file, replaced atomically for the node exporter textfile collector, stdout by default")
+	rootCmd.AddCommand(Cmd)
+}
+
+func doStats(cmd *cobra.Command, args []string) (err error) {
+	statsFormat = ownFormat(cmd, statsFormat)
+	valid := false
+	for _, f := range stats.Formats {
+		valid = valid || f == statsFormat
+	}
+	if !valid {
+		return fmt.Errorf("error: invalid --format %q, expected one of %v", statsFormat, stats.Formats)
+	}
+
+	trace, err := tracer()
+	if err != nil {
+		return err
+	}
+	pathTokens, err := vault.ParsePathTokens(viper.GetStringSlice(pathTokenFlag))
+	if err != nil {
+		return err
+	}
+	vc, err := vault.NewClient(&vault.Config{
+		Auth:         auth(),
+		TLS:          tlsConfig(),
+		Namespace:    viper.GetString(namespaceFlag),
+		RetryBackoff: viper.GetDuration(retryBackoffFlag),
+		MaxRetryWait: viper.GetDuration(maxRetryWaitFlag),
+		Timeout:      viper.GetDuration(requestTimeoutFlag),
+		RateLimit:    viper.GetFloat64(rateLimitFlag),
+		Context:      runContext(),
+		Address:      viper.GetString(vaFlag),
+		Ignore: &vault.Ignore{
+			Keys:  viper.GetStringSlice(ignoreKeysFlag),
+			Paths: viper.GetStringSlice(ignorePathsFlag),
+		},
+		ReadOnly: readOnly(true),
+		Renew:    true,
+		Retries:  viper.GetInt(retriesFlag),
+		Token:    vaultToken(),
+		Trace:    trace,
+		Usage:    requests,
+
+		PathTokens: pathTokens,
+	})
+	if err != nil {
+		return err
+	}
+	defer vc.Close()
+
+	var (
+		r         *run
+		accessed  []string
+		failures  = map[string]string{}
+		collected = stats.New(time.Now())
+	)
+	if r, err = startRun("stats", viper.GetString(vaFlag)); err != nil {
+		return err
+	}
+	defer func() {
+		r.finish(accessed, failures, err)
+	}()
+
+	scraper, err := dump.NewSecretScraper(vc)
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	if err = scraper.Run(args[0], &wg, runtime.NumCPU()); err != nil {
+		return err
+	}
+	wg.Wait()
+	for p, data := range scraper.Data {
+		_, kv2 := scraper.Versions[p]
+		collected.Add(p, data, kv2, scraper.Updated[p])
+		accessed = append(accessed, p)
+	}
+	sort.Strings(accessed)
+	for p, f := range scraper.Failed {
+		collected.Fail(p)
+		failures[p] = fmt.Sprintf("%s: %s", f.Category, f.Reason)
+	}
+
+	var b bytes.Buffer
+	if err = collected.Write(&b, statsFormat); err != nil {
+		return err
+	}
+	if statsDestPath == "" {
+		_, err = os.Stdout.Write(b.Bytes())
+		return err
+	}
+	if err = replaceFile(statsDestPath, b.Bytes()); err != nil {
+		return err
+	}
+	log.Printf("Wrote statistics of %d secrets to %s", len(accessed), statsDestPath)
+	return nil
+}
+
+// replaceFile writes data to a temporary file next to dest and renames it
+// over dest, so readers never see a partial file. The statistics hold nothing
+// secret and are left readable by other users such as the node exporter.
+func replaceFile(dest string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(dest), "."+filepath.Base(dest)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.Write(data); err == nil {
+		err = f.Chmod(0644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), dest)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/history"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	statusCmd *cobra.Command
+)
+
+func init() {
+	statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show the health of recent runs",
+		Args:  cobra.NoArgs,
+		RunE:  showStatus,
+	}
+	rootCmd.AddCommand(statusCmd)
+}
+
+// runStatus is the status of a command and cluster shown with --format json,
+// its runs as recorded in the history file and its recent durations in seconds
+type runStatus struct {
+	Cluster         string       `json:"cluster"`
+	Command         string       `json:"command"`
+	Runs            int          `json:"runs"`
+	LastRun         history.Run  `json:"last_run"`
+	LastSuccess     *history.Run `json:"last_success,omitempty"`
+	FailureStreak   int          `json:"failure_streak"`
+	LongestStreak   int          `json:"longest_streak"`
+	RecentDurations []float64    `json:"recent_durations"`
+}
+
+func showStatus(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonFormat()
+	if err != nil {
+		return err
+	}
+	path := viper.GetString(historyFileFlag)
+	if path == "" {
+		return errors.New("error: run history is disabled, set --history-file")
+	}
+	runs, err := history.Load(path)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		statuses := []runStatus{}
+		for _, s := range history.Summarize(runs) {
+			durations := make([]float64, len(s.Durations))
+			for i, d := range s.Durations {
+				durations[i] = d.Seconds()
+			}
+			statuses = append(statuses, runStatus{
+				Cluster:         s.Cluster,
+				Command:         s.Command,
+				Runs:            s.Runs,
+				LastRun:         s.Last,
+				LastSuccess:     s.LastSuccess,
+				FailureStreak:   s.Streak,
+				LongestStreak:   s.LongestStreak,
+				RecentDurations: durations,
+			})
+		}
+		return printJSON(statuses)
+	}
+	if len(runs) == 0 {
+		fmt.Printf("No runs recorded in %s\n", path)
+		return nil
+	}
+
+	tab := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tab, "Cluster\tCommand\tRuns\tLast run\tLast success\tFailure streak\tLongest streak\tRecent durations\t\n")
+	fmt.Fprintf(tab, "---\t---\t---\t---\t---\t---\t---\t---\t\n")
+	statuses := history.Summarize(runs)
+	for _, s := range statuses {
+		last := fmt.Sprintf("%s (%s)", s.Last.Start.Local().Format(time.RFC3339), outcome(s.Last))
+		success := "never"
+		if s.LastSuccess != nil {
+			success = fmt.Sprintf("%s ago", time.Since(s.LastSuccess.Start).Round(time.Minute))
+		}
+		durations := []string{}
+		for _, d := range s.Durations {
+			durations = append(durations, d.Round(time.Second).String())
+		}
+		fmt.Fprintf(tab, "%s\t%s\t%d\t%s\t%s\t%d\t%d\t%s\t\n",
+			s.Cluster, s.Command, s.Runs, last, success, s.Streak, s.LongestStreak, strings.Join(durations, " "))
+	}
+	tab.Flush()
+
+	for _, s := range statuses {
+		if s.Streak > 0 && s.Last.Error != "" {
+			fmt.Printf("\n%s %s failed the last %d runs: %s\n", s.Cluster, s.Command, s.Streak, s.Last.Error)
+		}
+	}
+	return nil
+}
+
+func outcome(r history.Run) string {
+	if r.Success {
+		return "ok"
+	}
+	return "failed"
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/aws"
+	"github.com/spf13/cobra"
+)
+
+var (
+	thawDays     int32
+	thawTier     string
+	thawPoll     time.Duration
+	thawTimeout  time.Duration
+	thawDecrypt  bool
+	thawDestPath string
+)
+
+func init() {
+	Cmd := &cobra.Command{
+		Short: "Restore an archived vault bundle from Glacier and download it",
+		Use:   "thaw [flags] s3://<bucket>/<key>",
+		Args:  cobra.ExactArgs(1),
+		RunE:  doThaw,
+	}
+	Cmd.Flags().Int32Var(&thawDays, "days", 1, "days to keep the restored copy")
+	Cmd.Flags().StringVar(&thawTier, "tier", "Standard", "restore tier, [Expedited, Standard, Bulk]")
+	Cmd.Flags().DurationVar(&thawPoll, "poll", time.Minute, "interval to check whether the restore finished")
+	Cmd.Flags().DurationVar(&thawTimeout, "timeout", 48*time.Hour, "give up waiting for the restore after this long")
+	Cmd.Flags().BoolVarP(&thawDecrypt, "decrypt", "d", false, "remove KMS encryption")
+	Cmd.Flags().StringVarP(&thawDestPath, "output", "o", "", "output path")
+	rootCmd.AddCommand(Cmd)
+}
+
+func doThaw(cmd *cobra.Command, args []string) error {
+	srcPath := args[0]
+	if err := aws.ValidateRestoreTier(thawTier); err != nil {
+		return fmt.Errorf("error: %w", err)
+	}
+	if thawDays < 1 {
+		return errors.New("error: --days must be at least 1")
+	}
+	if thawPoll <= 0 {
+		return errors.New("error: --poll must be positive")
+	}
+
+	state, err := aws.S3RestoreState(srcPath)
+	if err != nil {
+		return err
+	}
+	if state.Archived && !state.Ongoing && state.Expiry == "" {
+		days := thawDays
+		if state.Tiered {
+			// intelligent tiering moves the object back instead of copying it
+			days = 0
+		}
+		if err := aws.S3Restore(srcPath, days, thawTier); err != nil {
+			return err
+		}
+		log.Printf("Requested %s restore of %s\n", thawTier, srcPath)
+		state.Ongoing = true
+	}
+
+	deadline := time.Now().Add(thawTimeout)
+	for !state.Readable() {
+		if thawTimeout > 0 && time.Now().After(deadline) {
+			return fmt.Errorf("%s is still being restored after %s, run thaw again to keep waiting", srcPath, thawTimeout)
+		}
+		log.Printf("Waiting for the restore of %s\n", srcPath)
+		time.Sleep(thawPoll)
+		if state, err = aws.S3RestoreState(srcPath); err != nil {
+			return err
+		}
+	}
+	if state.Expiry != "" {
+		log.Printf("%s is restored until %s\n", srcPath, state.Expiry)
+	}
+
+	return download(srcPath, thawDecrypt, thawDestPath)
+}
@@ -2,34 +2,52 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 
+	"github.com/dathan/go-vault-dump/pkg/dump"
 	"github.com/dathan/go-vault-dump/pkg/transform"
+	"github.com/dathan/go-vault-dump/pkg/vault"
+	"github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
 )
 
 var (
 	applyPath    string
+	remapSpecs   []string
+	remapPath    string
 	transformCmd *cobra.Command
 )
 
 func init() {
 	transformCmd = &cobra.Command{
-		Use:   "transform --apply <transform> <filename>",
+		Use:   "transform [--apply <transform>] [--remap from=to] [--remap-file <mapping>] <filename>",
 		Short: "Apply transforms to a vault dump",
 		Args:  cobra.ExactArgs(1),
 		RunE:  doTransform,
 	}
-	transformCmd.Flags().StringVarP(&applyPath, "apply", "a", "", "path to transform definition")
+	transformCmd.Flags().StringVarP(&applyPath, "apply", "a", "", "path or https URL of transform definition")
+	transformCmd.Flags().StringSliceVar(&remapSpecs, "remap", nil, "replace an environment marker in paths and values, from=to, may be repeated")
+	transformCmd.Flags().StringVar(&remapPath, "remap-file", "", "path to a JSON or YAML object of environment markers to replace, from: to")
 	transformCmd.Flags().StringVarP(&destPath, "output", "o", "", "output path")
 	rootCmd.AddCommand(transformCmd)
+
+	testCmd := &cobra.Command{
+		Use:   "test <transform>...",
+		Short: "Run the test cases of transform definitions",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  doTransformTest,
+	}
+	transformCmd.AddCommand(testCmd)
 }
 
 func doTransform(cmd *cobra.Command, args []string) error {
-
-	transforms, err := loadJson(applyPath)
+	if applyPath == "" && len(remapSpecs) == 0 && remapPath == "" {
+		return errors.New("error: one of --apply, --remap or --remap-file must be specified")
+	}
+	remap, err := loadRemap()
 	if err != nil {
 		return err
 	}
@@ -40,9 +58,44 @@ func doTransform(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	data, err := transform.Transform(transforms, secrets)
-	if err != nil {
-		return err
+	// the manifest is carried over untouched, transforms only see secrets
+	manifest, hasManifest := secrets[dump.ManifestKey]
+	delete(secrets, dump.ManifestKey)
+	if hasManifest {
+		m, err := dump.ExtractManifest(map[string]interface{}{dump.ManifestKey: manifest})
+		if err != nil {
+			return err
+		}
+		// encrypted values are bound to their paths, which transforms change
+		if m != nil && m.Encryption != nil {
+			return fmt.Errorf("error: the values of %s are encrypted, dumps written with --encrypt-values can not be transformed", secretsPath)
+		}
+	}
+
+	// transforms match against normalized paths
+	normalized := make(map[string]interface{}, len(secrets))
+	for k, v := range secrets {
+		normalized[vault.NormalizePath(k)] = v
+	}
+	secrets = normalized
+
+	data := secrets
+	if applyPath != "" {
+		transforms, err := transform.Load(applyPath)
+		if err != nil {
+			return err
+		}
+		if data, err = transform.Transform(transforms, data); err != nil {
+			return err
+		}
+	}
+	if remap != nil {
+		if data, err = remap.Apply(data); err != nil {
+			return err
+		}
+	}
+	if hasManifest {
+		data[dump.ManifestKey] = manifest
 	}
 
 	output, err := json.Marshal(data)
@@ -74,6 +127,65 @@ func doTransform(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// doTransformTest runs the tests of every transform definition and fails if
+// any case fails
+func doTransformTest(cmd *cobra.Command, args []string) error {
+	failed, total := 0, 0
+	for _, path := range args {
+		definition, err := transform.Load(path)
+		if err != nil {
+			return err
+		}
+		results, err := transform.RunTests(definition)
+		if err != nil {
+			return fmt.Errorf("error: %s: %w", path, err)
+		}
+		for _, r := range results {
+			total++
+			if r.Passed {
+				fmt.Printf("PASS %s: %s\n", path, r.Name)
+				continue
+			}
+			failed++
+			fmt.Printf("FAIL %s: %s\n", path, r.Name)
+			for _, f := range r.Failures {
+				fmt.Printf("    %s\n", f)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d transform tests failed", failed, total)
+	}
+	fmt.Printf("%d transform tests passed\n", total)
+	return nil
+}
+
+// loadRemap returns the remap of --remap-file and --remap, markers given with
+// --remap take precedence, or nil when neither is given
+func loadRemap() (*transform.Remap, error) {
+	if remapPath == "" && len(remapSpecs) == 0 {
+		return nil, nil
+	}
+	mapping := make(map[string]string)
+	if remapPath != "" {
+		data, err := ioutil.ReadFile(remapPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &mapping); err != nil {
+			return nil, fmt.Errorf("error: invalid remap file %s: %w", remapPath, err)
+		}
+	}
+	specs, err := transform.ParseRemap(remapSpecs)
+	if err != nil {
+		return nil, fmt.Errorf("error: %w", err)
+	}
+	for from, to := range specs {
+		mapping[from] = to
+	}
+	return transform.NewRemap(mapping)
+}
+
 func loadJson(filepath string) (map[string]interface{}, error) {
 	data, err := ioutil.ReadFile(filepath)
 	if err != nil {
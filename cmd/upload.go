@@ -31,6 +31,10 @@ func doUpload(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if err := uploads(); err != nil {
+		return err
+	}
+
 	err = aws.S3Put(destPath, string(data))
 	if err != nil {
 		return err
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	whoamiCmd *cobra.Command
+)
+
+func init() {
+	whoamiCmd = &cobra.Command{
+		Use:   "whoami [flags] [/vault/path[,...] ...]",
+		Short: "Show the token's policies, TTL and capabilities on the paths to dump",
+		RunE:  whoami,
+	}
+	rootCmd.AddCommand(whoamiCmd)
+}
+
+// tokenIdentity is what whoami shows with --format json, the TTL in seconds
+type tokenIdentity struct {
+	Accessor     string             `json:"accessor"`
+	DisplayName  string             `json:"display_name"`
+	Policies     []string           `json:"policies"`
+	TTL          int64              `json:"ttl"`
+	ExpireTime   string             `json:"expire_time,omitempty"`
+	Renewable    bool               `json:"renewable"`
+	Capabilities []pathCapabilities `json:"capabilities,omitempty"`
+}
+
+// pathCapabilities are the capabilities of the token on an endpoint a path
+// to dump is read from
+type pathCapabilities struct {
+	Path         string   `json:"path"`
+	Endpoint     string   `json:"endpoint"`
+	Needed       string   `json:"needed"`
+	Capabilities []string `json:"capabilities"`
+	Allowed      bool     `json:"allowed"`
+}
+
+func whoami(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonFormat()
+	if err != nil {
+		return err
+	}
+	trace, err := tracer()
+	if err != nil {
+		return err
+	}
+	vc, err := vault.NewClient(&vault.Config{
+		Auth:         auth(),
+		TLS:          tlsConfig(),
+		Namespace:    viper.GetString(namespaceFlag),
+		Retries:      viper.GetInt(retriesFlag),
+		RetryBackoff: viper.GetDuration(retryBackoffFlag),
+		MaxRetryWait: viper.GetDuration(maxRetryWaitFlag),
+		Timeout:      viper.GetDuration(requestTimeoutFlag),
+		RateLimit:    viper.GetFloat64(rateLimitFlag),
+		Context:      runContext(),
+		Address:      viper.GetString(vaFlag),
+		ReadOnly:     true,
+		Token:        vaultToken(),
+		Trace:        trace,
+		Ignore:       &vault.Ignore{},
+	})
+	if err != nil {
+		return err
+	}
+
+	info, err := vc.LookupSelf()
+	if err != nil {
+		return fmt.Errorf("error: failed to look up the token: %w", err)
+	}
+	paths := []string{}
+	for _, arg := range args {
+		paths = append(paths, strings.Split(arg, ",")...)
+	}
+	var capabilities []vault.Capability
+	if len(paths) > 0 {
+		resolved, err := vc.PolicyPaths(paths)
+		if err != nil {
+			return fmt.Errorf("error: failed to look up the mounts of the paths: %w", err)
+		}
+		if capabilities, err = vc.DumpCapabilities(resolved); err != nil {
+			return fmt.Errorf("error: failed to look up capabilities: %w", err)
+		}
+	}
+
+	if asJSON {
+		identity := tokenIdentity{
+			Accessor:    info.Accessor,
+			DisplayName: info.DisplayName,
+			Policies:    info.Policies,
+			TTL:         int64(info.TTL.Seconds()),
+			ExpireTime:  info.ExpireTime,
+			Renewable:   info.Renewable,
+		}
+		for _, c := range capabilities {
+			identity.Capabilities = append(identity.Capabilities, pathCapabilities{
+				Path:         c.Path,
+				Endpoint:     c.Endpoint,
+				Needed:       c.Needed,
+				Capabilities: c.Capabilities,
+				Allowed:      c.Allowed(),
+			})
+		}
+		return printJSON(identity)
+	}
+
+	expires := "never"
+	if info.TTL > 0 {
+		expires = fmt.Sprintf("in %s (%s)", info.TTL, info.ExpireTime)
+	}
+	fmt.Printf("Accessor:     %s\n", info.Accessor)
+	fmt.Printf("Display name: %s\n", info.DisplayName)
+	fmt.Printf("Policies:     %s\n", strings.Join(info.Policies, ", "))
+	fmt.Printf("Expires:      %s\n", expires)
+	fmt.Printf("Renewable:    %t\n", info.Renewable)
+	if len(capabilities) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	tab := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tab, "Path\tEndpoint\tNeeded\tCapabilities\t\n")
+	fmt.Fprintf(tab, "---\t---\t---\t---\t\n")
+	for _, c := range capabilities {
+		needed := c.Needed
+		if !c.Allowed() {
+			needed += " (missing)"
+		}
+		fmt.Fprintf(tab, "%s\t%s\t%s\t%s\t\n", c.Path, c.Endpoint, needed, strings.Join(c.Capabilities, ", "))
+	}
+	tab.Flush()
+	return nil
+}
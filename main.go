@@ -1,9 +1,23 @@
 package main
 
 import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/dathan/go-vault-dump/cmd"
 )
 
 func main() {
-	cmd.Execute()
+	// the first SIGINT or SIGTERM stops the command gracefully, a second one
+	// exits at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+		log.Println("Interrupted, stopping, interrupt again to exit at once")
+	}()
+	cmd.ExecuteContext(ctx)
 }
@@ -0,0 +1,194 @@
+package audit
+
+// audit emits CEF events over syslog for every bulk access to secrets, so a
+// SIEM records dumps and imports independently of Vault's audit log
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	vendor  = "go-vault-dump"
+	product = "vault-dump"
+)
+
+// Outcomes of an audited access
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Logger writes audit events for a single run, a nil Logger writes nothing
+type Logger struct {
+	// Version is reported as the device version of every event
+	Version string
+
+	mu      sync.Mutex
+	w       writer
+	run     string
+	command string
+	start   time.Time
+}
+
+// Dial connects to the collector at addr, given as udp://host:port,
+// tcp://host:port or local for the local syslog daemon, an empty addr
+// returns nil
+func Dial(addr string) (*Logger, error) {
+	if addr == "" {
+		return nil, nil
+	}
+
+	network, raddr := "", ""
+	if addr != "local" {
+		u, err := url.Parse(addr)
+		if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") || u.Host == "" {
+			return nil, fmt.Errorf("invalid audit collector %q, expected udp://host:port, tcp://host:port or local", addr)
+		}
+		network, raddr = u.Scheme, u.Host
+	}
+	w, err := dial(network, raddr)
+	if err != nil {
+		return nil, err
+	}
+	return &Logger{w: w}, nil
+}
+
+// writer sends messages to syslog at the priority of the method
+type writer interface {
+	Info(m string) error
+	Warning(m string) error
+	Err(m string) error
+	Close() error
+}
+
+// Start records the start of command on cluster, every later event of the
+// run carries the same run ID
+func (l *Logger) Start(command, cluster string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.command = command
+	l.start = time.Now()
+	l.run = fmt.Sprintf("%d-%d", os.Getpid(), l.start.UnixNano())
+	l.mu.Unlock()
+
+	l.emit("run-start", "Run started", 3, map[string]string{"dhost": cluster})
+}
+
+// Access records that path was read or written during the run
+func (l *Logger) Access(path, outcome string) {
+	if l == nil {
+		return
+	}
+	severity := 3
+	if outcome != OutcomeSuccess {
+		severity = 6
+	}
+	l.emit("secret-access", "Secret accessed", severity, map[string]string{
+		"filePath": path,
+		"outcome":  outcome,
+	})
+}
+
+// Finish records the end of the run with the number of paths accessed and
+// the error the run failed with, if any
+func (l *Logger) Finish(count int, err error) {
+	if l == nil {
+		return
+	}
+	ext := map[string]string{
+		"cnt":     fmt.Sprint(count),
+		"outcome": OutcomeSuccess,
+	}
+	severity := 3
+	if err != nil {
+		ext["outcome"] = OutcomeFailure
+		ext["reason"] = err.Error()
+		severity = 7
+	}
+	l.mu.Lock()
+	ext["cs2"] = time.Since(l.start).Round(time.Millisecond).String()
+	ext["cs2Label"] = "duration"
+	l.mu.Unlock()
+
+	l.emit("run-finish", "Run finished", severity, ext)
+}
+
+// Close closes the connection to the collector
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.w.Close()
+}
+
+func (l *Logger) emit(signature, name string, severity int, ext map[string]string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ext["act"] = l.command
+	ext["cs1"] = l.run
+	ext["cs1Label"] = "run"
+	ext["rt"] = fmt.Sprint(time.Now().UnixNano() / int64(time.Millisecond))
+	ext["suser"] = currentUser()
+
+	msg := Format(l.Version, signature, name, severity, ext)
+	var err error
+	if severity >= 7 {
+		err = l.w.Err(msg)
+	} else if severity >= 6 {
+		err = l.w.Warning(msg)
+	} else {
+		err = l.w.Info(msg)
+	}
+	if err != nil {
+		log.Println("failed to send audit event:", err)
+	}
+}
+
+// Format returns a CEF event, extension keys are written in sorted order
+func Format(version, signature, name string, severity int, ext map[string]string) string {
+	if version == "" {
+		version = "dev"
+	}
+	keys := make([]string, 0, len(ext))
+	for k := range ext {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+extEscaper.Replace(ext[k]))
+	}
+	return fmt.Sprintf("CEF:0|%s|%s|%s|%s|%s|%d|%s",
+		headerEscaper.Replace(vendor),
+		headerEscaper.Replace(product),
+		headerEscaper.Replace(version),
+		headerEscaper.Replace(signature),
+		headerEscaper.Replace(name),
+		severity,
+		strings.Join(pairs, " "),
+	)
+}
+
+var (
+	headerEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	extEscaper    = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+func currentUser() string {
+	for _, env := range []string{"USER", "LOGNAME"} {
+		if u := os.Getenv(env); u != "" {
+			return u
+		}
+	}
+	return fmt.Sprint(os.Getuid())
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"testing"
+)
+
+func TestSuiteFormat(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			signature   string
+			ext         map[string]string
+			normOutput  string
+		}{
+			{"Event", "run-start", map[string]string{"dhost": "prod", "act": "dump"}, `CEF:0|go-vault-dump|vault-dump|1.0|run-start|Name|3|act=dump dhost=prod`},
+			{"Escaped header", "a|b\\c", map[string]string{}, `CEF:0|go-vault-dump|vault-dump|1.0|a\|b\\c|Name|3|`},
+			{"Escaped extension", "secret-access", map[string]string{"filePath": "secret/a=b\\c\nd"}, `CEF:0|go-vault-dump|vault-dump|1.0|secret-access|Name|3|filePath=secret/a\=b\\c\nd`},
+		}
+	)
+	for _, test := range tests {
+		norm := Format("1.0", test.signature, "Name", 3, test.ext)
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteDial(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			addr        string
+			isSuccess   bool
+		}{
+			{"Disabled", "", true},
+			{"UDP collector", "udp://127.0.0.1:514", true},
+			{"Missing host", "udp://", false},
+			{"Unsupported scheme", "http://127.0.0.1:514", false},
+		}
+	)
+	for _, test := range tests {
+		l, err := Dial(test.addr)
+		success := (err == nil)
+		if success == test.isSuccess {
+			tt.Logf("PASS %s", test.description)
+		} else {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		}
+		l.Close()
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package audit
+
+import (
+	"log/syslog"
+)
+
+func dial(network, raddr string) (writer, error) {
+	return syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_AUTH, product)
+}
@@ -0,0 +1,66 @@
+//go:build windows
+// +build windows
+
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// priorities of the messages, facility auth with the severity of the method,
+// as log/syslog sends them
+const (
+	facilityAuth    = 4 << 3
+	severityErr     = 3
+	severityWarning = 4
+	severityInfo    = 6
+)
+
+// dial connects to a remote collector, log/syslog is not available on
+// Windows and there is no local syslog daemon to fall back to
+func dial(network, raddr string) (writer, error) {
+	if network == "" {
+		return nil, errors.New("the local syslog daemon is not supported on Windows, use udp:// or tcp://")
+	}
+	conn, err := net.Dial(network, raddr)
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "localhost"
+	}
+	return &netWriter{conn: conn, hostname: hostname}, nil
+}
+
+// netWriter writes messages in the format of log/syslog to a collector
+type netWriter struct {
+	mu       sync.Mutex
+	conn     net.Conn
+	hostname string
+}
+
+func (w *netWriter) Info(m string) error    { return w.write(severityInfo, m) }
+func (w *netWriter) Warning(m string) error { return w.write(severityWarning, m) }
+func (w *netWriter) Err(m string) error     { return w.write(severityErr, m) }
+
+func (w *netWriter) Close() error {
+	return w.conn.Close()
+}
+
+func (w *netWriter) write(severity int, m string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	nl := ""
+	if !strings.HasSuffix(m, "\n") {
+		nl = "\n"
+	}
+	_, err := fmt.Fprintf(w.conn, "<%d>%s %s %s[%d]: %s%s", facilityAuth|severity, time.Now().Format(time.RFC3339), w.hostname, product, os.Getpid(), m, nl)
+	return err
+}
@@ -0,0 +1,294 @@
+package auditlog
+
+// auditlog follows the audit log Vault writes through a file or socket audit
+// device and reports the writes it records, so that only the secrets that
+// changed have to be read again. Only the json format is understood. Vault
+// logs request paths in the clear and values as HMACs, values are never
+// looked at.
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pollInterval is how often a followed file is checked for new entries and
+// for being rotated
+var pollInterval = 250 * time.Millisecond
+
+// maxDatagram is the largest entry received over udp
+const maxDatagram = 64 * 1024
+
+// Event is a write Vault completed
+type Event struct {
+	Time time.Time
+	// Operation is create, update, patch or delete
+	Operation string
+	// Path is the request path without leading or trailing slashes, e.g.
+	// secret/data/foo or secret/destroy/foo
+	Path string
+	// Namespace is the path of the namespace of the request without
+	// trailing slash, "" for the root namespace
+	Namespace string
+}
+
+// entry is the part of an audit log entry that is read
+type entry struct {
+	Time    string `json:"time"`
+	Type    string `json:"type"`
+	Error   string `json:"error"`
+	Request struct {
+		Operation string `json:"operation"`
+		Path      string `json:"path"`
+		MountType string `json:"mount_type"`
+		Namespace struct {
+			Path string `json:"path"`
+		} `json:"namespace"`
+	} `json:"request"`
+}
+
+// ParseEntry returns the write recorded by a line of the audit log. Requests
+// are logged before they are handled, so only responses without an error are
+// writes that happened, and only those to kv mounts when the mount type is
+// logged.
+func ParseEntry(line []byte) (Event, bool) {
+	// a prefix configured on the audit device precedes the entry
+	start := bytes.IndexByte(line, '{')
+	if start < 0 {
+		return Event{}, false
+	}
+	var e entry
+	if err := json.Unmarshal(line[start:], &e); err != nil {
+		return Event{}, false
+	}
+	if e.Type != "response" || e.Error != "" {
+		return Event{}, false
+	}
+	switch e.Request.Operation {
+	case "create", "update", "patch", "delete":
+	default:
+		return Event{}, false
+	}
+	switch e.Request.MountType {
+	case "", "kv", "generic":
+	default:
+		return Event{}, false
+	}
+	path := strings.Trim(e.Request.Path, "/")
+	if path == "" {
+		return Event{}, false
+	}
+	t, _ := time.Parse(time.RFC3339Nano, e.Time)
+	return Event{
+		Time:      t,
+		Operation: e.Request.Operation,
+		Path:      path,
+		Namespace: strings.Trim(e.Request.Namespace.Path, "/"),
+	}, true
+}
+
+// Follow sends the writes logged to source from now on to events until ctx
+// is done. source is the file of a file audit device, which is followed
+// across rotation and truncation, or tcp://host:port, udp://host:port or
+// unix:///path to listen on for a socket audit device.
+func Follow(ctx context.Context, source string, events chan<- Event) error {
+	if !strings.Contains(source, "://") {
+		return followFile(ctx, source, events)
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return fmt.Errorf("invalid audit log %q, expected a file, tcp://host:port, udp://host:port or unix:///path", source)
+	}
+	switch {
+	case (u.Scheme == "tcp" || u.Scheme == "udp") && u.Host != "":
+		if u.Scheme == "udp" {
+			return followPackets(ctx, u.Host, events)
+		}
+		return followSocket(ctx, u.Scheme, u.Host, events)
+	case u.Scheme == "unix" && u.Path != "":
+		return followSocket(ctx, u.Scheme, u.Path, events)
+	}
+	return fmt.Errorf("invalid audit log %q, expected a file, tcp://host:port, udp://host:port or unix:///path", source)
+}
+
+// send sends the write of line to events, false once ctx is done
+func send(ctx context.Context, line []byte, events chan<- Event) bool {
+	e, ok := ParseEntry(line)
+	if !ok {
+		return ctx.Err() == nil
+	}
+	select {
+	case events <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// followFile reads the entries appended to name, reopening it once it was
+// replaced by a new file and starting over once it was truncated
+func followFile(ctx context.Context, name string, events chan<- Event) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	var partial []byte
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		line, err := r.ReadBytes('\n')
+		offset += int64(len(line))
+		partial = append(partial, line...)
+		if err == nil {
+			if !send(ctx, partial, events) {
+				return nil
+			}
+			partial = nil
+			continue
+		}
+		if err != io.EOF {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		current, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		latest, err := os.Stat(name)
+		switch {
+		case err != nil:
+			// between the rename and the creation of the new file
+			if !os.IsNotExist(err) {
+				return err
+			}
+		case !os.SameFile(current, latest):
+			// rotated, the rest of the old file was read above
+			next, err := os.Open(name)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return err
+			}
+			f.Close()
+			f, offset, partial = next, 0, nil
+			r.Reset(f)
+		case latest.Size() < offset:
+			// truncated in place
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			offset, partial = 0, nil
+			r.Reset(f)
+		}
+	}
+}
+
+// followSocket accepts the connections of socket audit devices and reads the
+// entries each of them sends, one per line
+func followSocket(ctx context.Context, network, addr string, events chan<- Event) error {
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	// connections are closed once the listener fails as well
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			readConn(ctx, conn, events)
+		}()
+	}
+}
+
+// readConn reads the entries of a single connection until it is closed
+func readConn(ctx context.Context, conn net.Conn, events chan<- Event) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 && !send(ctx, line, events) {
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// followPackets reads the entries of a udp socket audit device, every
+// datagram holding whole lines
+func followPackets(ctx context.Context, addr string, events chan<- Event) error {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		pc.Close()
+	}()
+
+	buf := make([]byte, maxDatagram)
+	for {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+			if len(bytes.TrimSpace(line)) > 0 && !send(ctx, line, events) {
+				return nil
+			}
+		}
+	}
+}
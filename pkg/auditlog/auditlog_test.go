@@ -0,0 +1,196 @@
+package auditlog
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// logged returns an audit log entry of a request to path
+func logged(kind, operation, path, mountType, namespace, errMsg string) string {
+	return fmt.Sprintf(`{"time":"2021-01-01T00:00:00.5Z","type":"%s","auth":{"client_token":"hmac-sha256:abc"},`+
+		`"request":{"id":"1","operation":"%s","mount_type":"%s","path":"%s","namespace":{"id":"ns","path":"%s"},`+
+		`"data":{"data":{"password":"hmac-sha256:def"}}},"error":"%s"}`, kind, operation, mountType, path, namespace, errMsg)
+}
+
+func TestSuiteParseEntry(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			line        string
+			normOutput  string
+		}{
+			{"Write", logged("response", "create", "secret/data/foo", "kv", "", ""), "create secret/data/foo in \"\" at 2021-01-01T00:00:00.5Z"},
+			{"Namespace", logged("response", "update", "kv/foo/", "kv", "team-a/", ""), "update kv/foo in \"team-a\" at 2021-01-01T00:00:00.5Z"},
+			{"Destroy", logged("response", "update", "secret/destroy/foo", "kv", "", ""), "update secret/destroy/foo in \"\" at 2021-01-01T00:00:00.5Z"},
+			{"Delete", logged("response", "delete", "secret/metadata/foo", "kv", "", ""), "delete secret/metadata/foo in \"\" at 2021-01-01T00:00:00.5Z"},
+			{"Mount type not logged", logged("response", "patch", "secret/data/foo", "", "", ""), "patch secret/data/foo in \"\" at 2021-01-01T00:00:00.5Z"},
+			{"Prefixed", "vault: " + logged("response", "update", "secret/data/foo", "kv", "", ""), "update secret/data/foo in \"\" at 2021-01-01T00:00:00.5Z"},
+			{"Request", logged("request", "update", "secret/data/foo", "kv", "", ""), "none"},
+			{"Failed", logged("response", "update", "secret/data/foo", "kv", "", "permission denied"), "none"},
+			{"Read", logged("response", "read", "secret/data/foo", "kv", "", ""), "none"},
+			{"List", logged("response", "list", "secret/metadata/", "kv", "", ""), "none"},
+			{"Other mount", logged("response", "update", "auth/token/create", "token", "", ""), "none"},
+			{"Not json", "hello", "none"},
+			{"Empty", "", "none"},
+		}
+	)
+	for _, test := range tests {
+		norm := "none"
+		if e, ok := ParseEntry([]byte(test.line)); ok {
+			norm = fmt.Sprintf("%s %s in %q at %s", e.Operation, e.Path, e.Namespace, e.Time.Format(time.RFC3339Nano))
+		}
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+// next returns the path of the next event, or none when there is none for a while
+func next(events <-chan Event) string {
+	select {
+	case e := <-events:
+		return e.Path
+	case <-time.After(2 * time.Second):
+		return "none"
+	}
+}
+
+func TestSuiteFollowFile(tt *testing.T) {
+	pollInterval = 10 * time.Millisecond
+	dir, err := ioutil.TempDir("", "auditlog")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "audit.log")
+	write := func(flag int, lines ...string) {
+		f, err := os.OpenFile(name, flag|os.O_WRONLY, 0600)
+		if err != nil {
+			tt.Fatal(err)
+		}
+		for _, l := range lines {
+			fmt.Fprint(f, l)
+		}
+		f.Close()
+	}
+	write(os.O_CREATE, logged("response", "update", "secret/data/before", "kv", "", "")+"\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan Event)
+	followed := make(chan error, 1)
+	go func() { followed <- Follow(ctx, name, events) }()
+	// give Follow the time to open the file at its end
+	time.Sleep(50 * time.Millisecond)
+
+	var (
+		tests = []struct {
+			description string
+			action      func()
+			normOutput  string
+		}{
+			{"Appended", func() {
+				write(os.O_APPEND, logged("response", "update", "secret/data/a", "kv", "", "")+"\n")
+			}, "secret/data/a"},
+			{"Written in parts", func() {
+				line := logged("response", "update", "secret/data/b", "kv", "", "") + "\n"
+				write(os.O_APPEND, line[:20])
+				time.Sleep(50 * time.Millisecond)
+				write(os.O_APPEND, line[20:])
+			}, "secret/data/b"},
+			{"Rotated", func() {
+				if err := os.Rename(name, name+".1"); err != nil {
+					tt.Fatal(err)
+				}
+				write(os.O_CREATE, logged("response", "update", "secret/data/c", "kv", "", "")+"\n")
+			}, "secret/data/c"},
+			{"Truncated", func() {
+				write(os.O_TRUNC)
+				time.Sleep(50 * time.Millisecond)
+				write(os.O_APPEND, logged("response", "delete", "secret/data/d", "kv", "", "")+"\n")
+			}, "secret/data/d"},
+			{"Ignored", func() {
+				write(os.O_APPEND, logged("request", "update", "secret/data/e", "kv", "", "")+"\n")
+			}, "none"},
+		}
+	)
+	for _, test := range tests {
+		test.action()
+		if norm := next(events); norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+
+	cancel()
+	if err := <-followed; err != nil {
+		tt.Errorf("FAIL Stopped: expected no error got '%s'", err)
+	} else {
+		tt.Logf("PASS Stopped")
+	}
+}
+
+func TestSuiteFollowSocket(tt *testing.T) {
+	dir, err := ioutil.TempDir("", "auditlog")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "audit.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan Event)
+	followed := make(chan error, 1)
+	go func() { followed <- Follow(ctx, "unix://"+sock, events) }()
+
+	var conn net.Conn
+	for i := 0; i < 100 && conn == nil; i++ {
+		if conn, err = net.Dial("unix", sock); err != nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if conn == nil {
+		tt.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintln(conn, logged("request", "update", "secret/data/a", "kv", "", ""))
+	fmt.Fprintln(conn, logged("response", "update", "secret/data/a", "kv", "", ""))
+	if norm := next(events); norm != "secret/data/a" {
+		tt.Errorf("FAIL Socket: expected 'secret/data/a' got '%s'", norm)
+	} else {
+		tt.Logf("PASS Socket")
+	}
+
+	var (
+		tests = []struct {
+			description string
+			source      string
+		}{
+			{"Missing file", filepath.Join(dir, "missing.log")},
+			{"Unknown scheme", "http://localhost:9090"},
+			{"No address", "tcp://"},
+		}
+	)
+	for _, test := range tests {
+		if err := Follow(ctx, test.source, events); err == nil {
+			tt.Errorf("FAIL %s: expected an error got none", test.description)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+
+	cancel()
+	if err := <-followed; err != nil {
+		tt.Errorf("FAIL Stopped: expected no error got '%s'", err)
+	} else {
+		tt.Logf("PASS Stopped")
+	}
+}
@@ -1,14 +1,18 @@
+//go:build !noaws
+// +build !noaws
+
 package aws
 
 import (
-	"context"
 	"log"
 	"os"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/dathan/go-vault-dump/pkg/feature"
 )
 
 const (
@@ -23,12 +27,22 @@ var (
 
 func init() {
 	var err error
+	feature.Register(feature.AWS)
 
 	AWSRegion = os.Getenv("AWS_REGION")
 
 	AWSEndpoint = os.Getenv("AWS_ENDPOINT")
 
-	resolver := aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
+	AWSConfig, err = loadConfig(Credentials{})
+	if err != nil {
+		log.Fatalf("Error initializing AWS client: %s", err)
+	}
+
+}
+
+// endpointResolver sends every request to AWSEndpoint when it is set
+func endpointResolver() aws.EndpointResolver {
+	return aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
 		if AWSEndpoint != "" {
 			return aws.Endpoint{
 				PartitionID:   "aws",
@@ -38,15 +52,6 @@ func init() {
 		}
 		return aws.Endpoint{}, &aws.EndpointNotFoundError{}
 	})
-	AWSConfig, err = config.LoadDefaultConfig(
-		context.TODO(),
-		config.WithRegion(AWSRegion),
-		config.WithEndpointResolver(resolver),
-	)
-	if err != nil {
-		log.Fatalf("Error initializing AWS client: %s", err)
-	}
-
 }
 
 func NewKMSClient() *kms.Client {
@@ -59,3 +64,11 @@ func NewS3Client() *s3.Client {
 	})
 
 }
+
+func NewCloudWatchClient() *cloudwatch.Client {
+	return cloudwatch.NewFromConfig(AWSConfig)
+}
+
+func NewEventBridgeClient() *eventbridge.Client {
+	return eventbridge.NewFromConfig(AWSConfig)
+}
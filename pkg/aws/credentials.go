@@ -0,0 +1,93 @@
+//go:build !noaws
+// +build !noaws
+
+package aws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// sessionName names the sessions of assumed roles in CloudTrail
+const sessionName = "vault-dump"
+
+// Configure replaces the AWS config used by every client with one loading
+// credentials as c selects
+func Configure(c Credentials) error {
+	cfg, err := loadConfig(c)
+	if err != nil {
+		return err
+	}
+	AWSConfig = cfg
+	return nil
+}
+
+func loadConfig(c Credentials) (aws.Config, error) {
+	if c.WebIdentityTokenFile != "" && c.RoleARN == "" {
+		return aws.Config{}, errors.New("a role ARN is required to use a web identity token")
+	}
+
+	opts := []func(*config.LoadOptions) error{
+		config.WithRegion(AWSRegion),
+		config.WithEndpointResolver(endpointResolver()),
+	}
+	if c.Profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(c.Profile))
+	}
+	if c.IMDSv2Only {
+		opts = append(opts, config.WithEC2RoleCredentialOptions(func(o *ec2rolecreds.Options) {
+			o.Client = imds.New(imds.Options{APIOptions: []func(*middleware.Stack) error{requireIMDSToken}})
+		}))
+	}
+	cfg, err := config.LoadDefaultConfig(context.TODO(), opts...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	cfg.APIOptions = append(cfg.APIOptions, countRequests)
+
+	if c.WebIdentityTokenFile != "" {
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(
+			sts.NewFromConfig(cfg), c.RoleARN, stscreds.IdentityTokenFile(c.WebIdentityTokenFile),
+			func(o *stscreds.WebIdentityRoleOptions) { o.RoleSessionName = sessionName },
+		))
+	} else if c.RoleARN != "" {
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(
+			sts.NewFromConfig(cfg), c.RoleARN,
+			func(o *stscreds.AssumeRoleOptions) { o.RoleSessionName = sessionName },
+		))
+	}
+	return cfg, nil
+}
+
+// requireIMDSToken fails instance metadata requests sent without an IMDSv2
+// session token, the SDK sends them when the token can not be retrieved
+func requireIMDSToken(stack *middleware.Stack) error {
+	return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("RequireIMDSv2",
+		func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			req, ok := in.Request.(*smithyhttp.Request)
+			if ok && req.URL.Path != "/latest/api/token" && req.Header.Get("X-Aws-Ec2-Metadata-Token") == "" {
+				return middleware.FinalizeOutput{}, middleware.Metadata{}, errors.New("no IMDSv2 token could be retrieved and IMDSv1 is disabled")
+			}
+			return next.HandleFinalize(ctx, in)
+		}), middleware.After)
+}
+
+// countRequests counts every attempt of a request in Requests, retries are
+// charged like any other request
+func countRequests(stack *middleware.Stack) error {
+	return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("CountRequests",
+		func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			Requests.Add(awsmiddleware.GetServiceID(ctx), awsmiddleware.GetOperationName(ctx))
+			return next.HandleFinalize(ctx, in)
+		}), middleware.After)
+}
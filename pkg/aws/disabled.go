@@ -0,0 +1,76 @@
+//go:build noaws
+// +build noaws
+
+package aws
+
+// disabled.go replaces the requests to AWS in binaries built with the noaws
+// tag, every one of them fails with ErrDisabled
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrDisabled is returned by every request to AWS of a binary built without
+// AWS support
+var ErrDisabled = errors.New("AWS support is not compiled into this binary, it was built with the noaws tag")
+
+func Configure(c Credentials) error {
+	return ErrDisabled
+}
+
+func ValidateRestoreTier(tier string) error {
+	return ErrDisabled
+}
+
+func S3Put(s3path string, body string) error {
+	return ErrDisabled
+}
+
+func S3List(s3path string, ext string) ([]S3ListResult, error) {
+	return nil, ErrDisabled
+}
+
+func S3Get(s3path string) ([]byte, error) {
+	return nil, ErrDisabled
+}
+
+func S3StorageClass(s3path string) (string, error) {
+	return "", ErrDisabled
+}
+
+func S3RestoreState(s3path string) (RestoreState, error) {
+	return RestoreState{}, ErrDisabled
+}
+
+func S3Restore(s3path string, days int32, tier string) error {
+	return ErrDisabled
+}
+
+func KMSEncrypt(plaintext string, kmsKey string) (string, error) {
+	return "", ErrDisabled
+}
+
+func KMSDecrypt(ciphertext string) (string, error) {
+	return "", ErrDisabled
+}
+
+func KMSDataKey(kmsKey string) ([]byte, []byte, error) {
+	return nil, nil, ErrDisabled
+}
+
+func KMSDecryptDataKey(cipherkey []byte) ([]byte, error) {
+	return nil, ErrDisabled
+}
+
+func PutRunMetrics(namespace string, r RunReport) error {
+	return ErrDisabled
+}
+
+func PutRunEvent(bus string, r RunReport) error {
+	return ErrDisabled
+}
+
+func SignedCallerIdentity(serverID string) (*http.Request, []byte, error) {
+	return nil, nil, ErrDisabled
+}
@@ -0,0 +1,110 @@
+//go:build !noaws
+// +build !noaws
+
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithy "github.com/aws/smithy-go"
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// Restore tiers, from fastest to cheapest
+var restoreTiers = []string{string(types.TierExpedited), string(types.TierStandard), string(types.TierBulk)}
+
+// ValidateRestoreTier returns an error for unknown restore tiers
+func ValidateRestoreTier(tier string) error {
+	for _, t := range restoreTiers {
+		if tier == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid restore tier %q, expected one of %s", tier, strings.Join(restoreTiers, ", "))
+}
+
+// S3RestoreState returns the archive state of the object at s3path
+func S3RestoreState(s3path string) (RestoreState, error) {
+	s3bucket := strings.Split(s3path[len("s3://"):], "/")[0]
+	s3key := vault.EnsureNoLeadingSlash(s3path[len("s3://"+s3bucket):])
+
+	client := NewS3Client()
+	head, err := client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: &s3bucket,
+		Key:    &s3key,
+	})
+	if err != nil {
+		return RestoreState{}, err
+	}
+
+	var state RestoreState
+	switch {
+	case head.StorageClass == types.StorageClassGlacier, head.StorageClass == types.StorageClassDeepArchive:
+		state.Archived = true
+	case head.ArchiveStatus != "":
+		state.Archived, state.Tiered = true, true
+	}
+	state.Ongoing, state.Expiry = parseRestore(aws.ToString(head.Restore))
+	return state, nil
+}
+
+// S3Restore requests a temporary copy of the archived object at s3path for
+// days, days must be 0 for intelligent tiering objects which are moved back
+// to the frequent access tier instead. A restore already in progress is not
+// an error
+func S3Restore(s3path string, days int32, tier string) error {
+	s3bucket := strings.Split(s3path[len("s3://"):], "/")[0]
+	s3key := vault.EnsureNoLeadingSlash(s3path[len("s3://"+s3bucket):])
+
+	request := &types.RestoreRequest{
+		Days:                 days,
+		GlacierJobParameters: &types.GlacierJobParameters{Tier: types.Tier(tier)},
+	}
+
+	client := NewS3Client()
+	_, err := client.RestoreObject(context.TODO(), &s3.RestoreObjectInput{
+		Bucket:         &s3bucket,
+		Key:            &s3key,
+		RestoreRequest: request,
+	})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "RestoreAlreadyInProgress" {
+		return nil
+	}
+	return err
+}
+
+// parseRestore parses the x-amz-restore header, e.g.
+// ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"
+func parseRestore(header string) (bool, string) {
+	var (
+		ongoing bool
+		expiry  string
+	)
+	for header != "" {
+		eq := strings.Index(header, `="`)
+		if eq < 0 {
+			break
+		}
+		name := strings.TrimSpace(strings.TrimLeft(header[:eq], ", "))
+		rest := header[eq+2:]
+		end := strings.Index(rest, `"`)
+		if end < 0 {
+			break
+		}
+		switch name {
+		case "ongoing-request":
+			ongoing = rest[:end] == "true"
+		case "expiry-date":
+			expiry = rest[:end]
+		}
+		header = rest[end+1:]
+	}
+	return ongoing, expiry
+}
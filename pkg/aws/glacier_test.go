@@ -0,0 +1,30 @@
+//go:build !noaws
+// +build !noaws
+
+package aws
+
+import "testing"
+
+func TestSuiteRestore(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			header      string
+			ongoing     bool
+			expiry      string
+		}{
+			{"Not restored", "", false, ""},
+			{"Ongoing", `ongoing-request="true"`, true, ""},
+			{"Restored", `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`, false, "Fri, 21 Dec 2012 00:00:00 GMT"},
+			{"Malformed", `ongoing-request="true`, false, ""},
+		}
+	)
+	for _, test := range tests {
+		ongoing, expiry := parseRestore(test.header)
+		if ongoing != test.ongoing || expiry != test.expiry {
+			tt.Errorf("FAIL %s: expected %t %q got %t %q", test.description, test.ongoing, test.expiry, ongoing, expiry)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
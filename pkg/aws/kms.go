@@ -1,3 +1,6 @@
+//go:build !noaws
+// +build !noaws
+
 package aws
 
 /*
@@ -146,3 +149,27 @@ func KMSDecrypt(ciphertext string) (string, error) {
 
 	return string(data[:len(data)-int(padding)]), nil
 }
+
+// KMSDataKey returns a new 256 bit data key in plaintext and encrypted by
+// kmsKey
+func KMSDataKey(kmsKey string) ([]byte, []byte, error) {
+	resp, err := NewKMSClient().GenerateDataKey(context.TODO(), &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(kmsKey),
+		KeySpec: kmsCipher,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Plaintext, resp.CiphertextBlob, nil
+}
+
+// KMSDecryptDataKey returns the plaintext of a data key from KMSDataKey
+func KMSDecryptDataKey(cipherkey []byte) ([]byte, error) {
+	resp, err := NewKMSClient().Decrypt(context.TODO(), &kms.DecryptInput{
+		CiphertextBlob: cipherkey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
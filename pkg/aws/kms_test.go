@@ -1,3 +1,6 @@
+//go:build !noaws
+// +build !noaws
+
 package aws
 
 import (
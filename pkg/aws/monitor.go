@@ -0,0 +1,87 @@
+//go:build !noaws
+// +build !noaws
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+)
+
+// PutRunMetrics publishes the Success, Failure, Secrets, FailedSecrets,
+// Duration, VaultRequests, KMSRequests, S3Requests and EstimatedCost metrics
+// of a run to namespace, by command and cluster
+func PutRunMetrics(namespace string, r RunReport) error {
+	dimensions := []cwtypes.Dimension{
+		{Name: aws.String("Command"), Value: aws.String(r.Command)},
+		{Name: aws.String("Cluster"), Value: aws.String(r.Cluster)},
+	}
+	success, failure := 1.0, 0.0
+	if !r.Success {
+		success, failure = 0, 1
+	}
+	now := time.Now()
+	datum := func(name string, value float64, unit cwtypes.StandardUnit) cwtypes.MetricDatum {
+		return cwtypes.MetricDatum{
+			MetricName: aws.String(name),
+			Dimensions: dimensions,
+			Timestamp:  &now,
+			Unit:       unit,
+			Value:      aws.Float64(value),
+		}
+	}
+
+	client := NewCloudWatchClient()
+	_, err := client.PutMetricData(context.TODO(), &cloudwatch.PutMetricDataInput{
+		Namespace: aws.String(namespace),
+		MetricData: []cwtypes.MetricDatum{
+			datum("Success", success, cwtypes.StandardUnitCount),
+			datum("Failure", failure, cwtypes.StandardUnitCount),
+			datum("Secrets", float64(r.Secrets), cwtypes.StandardUnitCount),
+			datum("FailedSecrets", float64(r.Failed), cwtypes.StandardUnitCount),
+			datum("Duration", r.Duration.Seconds(), cwtypes.StandardUnitSeconds),
+			datum("VaultRequests", float64(r.VaultRequests), cwtypes.StandardUnitCount),
+			datum("KMSRequests", float64(r.KMSRequests), cwtypes.StandardUnitCount),
+			datum("S3Requests", float64(r.S3Requests), cwtypes.StandardUnitCount),
+			datum("EstimatedCost", r.Cost, cwtypes.StandardUnitNone),
+		},
+	})
+	return err
+}
+
+// PutRunEvent publishes the completion event of a run to bus
+func PutRunEvent(bus string, r RunReport) error {
+	detail, err := json.Marshal(struct {
+		RunReport
+		DurationSeconds float64 `json:"duration_seconds"`
+	}{r, r.Duration.Seconds()})
+	if err != nil {
+		return err
+	}
+
+	client := NewEventBridgeClient()
+	out, err := client.PutEvents(context.TODO(), &eventbridge.PutEventsInput{
+		Entries: []ebtypes.PutEventsRequestEntry{{
+			EventBusName: aws.String(bus),
+			Source:       aws.String(EventSource),
+			DetailType:   aws.String(EventDetailType),
+			Detail:       aws.String(string(detail)),
+			Time:         aws.Time(time.Now()),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if out.FailedEntryCount > 0 && len(out.Entries) > 0 {
+		return fmt.Errorf("event rejected: %s", aws.ToString(out.Entries[0].ErrorMessage))
+	}
+	return nil
+}
@@ -1,22 +1,29 @@
+//go:build !noaws
+// +build !noaws
+
 package aws
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithy "github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/dathan/go-vault-dump/pkg/vault"
 )
 
-type S3ListResult struct {
-	Key  string
-	Size int
-}
-
 func S3Put(s3path string, body string) error {
+	if err := Faults.Upload(s3path); err != nil {
+		return err
+	}
+
 	s3bucket := strings.Split(s3path[len("s3://"):], "/")[0]
 	s3key := s3path[len("s3://"+s3bucket+"/"):]
 
@@ -26,8 +33,27 @@ func S3Put(s3path string, body string) error {
 		Key:    &s3key,
 		Body:   strings.NewReader(body),
 	}
+	if StorageClass != "" {
+		params.StorageClass = types.StorageClass(StorageClass)
+	}
 
-	_, err := client.PutObject(context.TODO(), params)
+	_, err := client.PutObject(context.TODO(), params, func(o *s3.Options) {
+		if Accelerate {
+			// acceleration endpoints only support virtual hosted buckets
+			o.UseAccelerate, o.UsePathStyle = true, false
+		}
+		if NoOverwrite {
+			o.APIOptions = append(o.APIOptions, smithyhttp.SetHeaderValue("If-None-Match", "*"))
+		}
+	})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		// a concurrent upload of the same key fails with a conflict
+		switch apiErr.ErrorCode() {
+		case "PreconditionFailed", "ConditionalRequestConflict":
+			return fmt.Errorf("refusing to overwrite %s: %w", s3path, ErrExists)
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -52,8 +78,8 @@ func S3List(s3path string, ext string) ([]S3ListResult, error) {
 	results := make([]S3ListResult, 0)
 	for _, vv := range output.Contents {
 		keyStr := aws.ToString(vv.Key)
-		if keyStr[len(keyStr)-len(ext):] == ext {
-			results = append(results, S3ListResult{Key: keyStr, Size: int(vv.Size)})
+		if strings.HasSuffix(keyStr, ext) {
+			results = append(results, S3ListResult{Key: keyStr, Size: int(vv.Size), LastModified: aws.ToTime(vv.LastModified)})
 		}
 	}
 
@@ -69,6 +95,10 @@ func S3Get(s3path string) ([]byte, error) {
 		Bucket: &s3bucket,
 		Key:    &s3key,
 	})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidObjectState" {
+		return []byte(""), fmt.Errorf("%s is archived, restore it with thaw first: %w", s3path, err)
+	}
 	if err != nil {
 		return []byte(""), err
 	}
@@ -80,3 +110,20 @@ func S3Get(s3path string) ([]byte, error) {
 
 	return data, nil
 }
+
+// S3StorageClass returns the storage class of the object at s3path, empty for
+// STANDARD
+func S3StorageClass(s3path string) (string, error) {
+	s3bucket := strings.Split(s3path[len("s3://"):], "/")[0]
+	s3key := vault.EnsureNoLeadingSlash(s3path[len("s3://"+s3bucket):])
+
+	client := NewS3Client()
+	head, err := client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: &s3bucket,
+		Key:    &s3key,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(head.StorageClass), nil
+}
@@ -1,3 +1,6 @@
+//go:build !noaws
+// +build !noaws
+
 package aws
 
 import (
@@ -56,3 +59,28 @@ func TestSuiteS3(tt *testing.T) {
 		}
 	}
 }
+
+func TestSuiteStorageClass(tt *testing.T) {
+	var (
+		success bool
+		tests   = []struct {
+			description string
+			class       string
+			isSuccess   bool
+		}{
+			{"Default", "", true},
+			{"Infrequent access", "STANDARD_IA", true},
+			{"Glacier instant retrieval", "GLACIER_IR", true},
+			{"Lower case", "standard_ia", false},
+			{"Unknown", "COLD", false},
+		}
+	)
+	for _, test := range tests {
+		success = (ValidateStorageClass(test.class) == nil)
+		if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
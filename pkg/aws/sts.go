@@ -0,0 +1,53 @@
+//go:build !noaws
+// +build !noaws
+
+package aws
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+const (
+	// stsEndpoint is the global STS endpoint, which Vault verifies login
+	// requests against unless configured otherwise
+	stsEndpoint = "https://sts.amazonaws.com/"
+	stsRegion   = "us-east-1"
+	// callerIdentityBody is the body of an sts:GetCallerIdentity request
+	callerIdentityBody = "Action=GetCallerIdentity&Version=2011-06-15"
+	// serverIDHeader binds a login request to one Vault server
+	serverIDHeader = "X-Vault-AWS-IAM-Server-ID"
+)
+
+// SignedCallerIdentity returns an sts:GetCallerIdentity request and its
+// body, signed with the configured credentials but not sent, for the AWS
+// auth method of Vault to send and learn who signed it. serverID is signed
+// along when set, for Vault servers requiring the server ID header.
+func SignedCallerIdentity(serverID string) (*http.Request, []byte, error) {
+	body := []byte(callerIdentityBody)
+	req, err := http.NewRequest(http.MethodPost, stsEndpoint, strings.NewReader(callerIdentityBody))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if serverID != "" {
+		req.Header.Set(serverIDHeader, serverID)
+	}
+
+	ctx := context.TODO()
+	creds, err := AWSConfig.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	hash := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "sts", stsRegion, time.Now()); err != nil {
+		return nil, nil, err
+	}
+	return req, body, nil
+}
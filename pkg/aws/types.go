@@ -0,0 +1,112 @@
+package aws
+
+// The settings and types of this file are shared by binaries built with and
+// without AWS support, see disabled.go
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/fault"
+	"github.com/dathan/go-vault-dump/pkg/usage"
+)
+
+var (
+	// Faults injects failures into uploads, see fault.Config
+	Faults *fault.Config
+	// Requests counts every request to AWS by service and operation
+	Requests *usage.Counter
+)
+
+// Credentials selects how AWS credentials are obtained. The zero value uses
+// the default chain of the SDK: environment variables, the shared config
+// and credentials files including SSO, web identity such as EKS IAM roles
+// for service accounts, ECS task roles and EC2 instance roles.
+type Credentials struct {
+	// Profile is the shared config profile to use
+	Profile string
+	// RoleARN is assumed with the credentials of the chain, or with the
+	// token of WebIdentityTokenFile when set
+	RoleARN              string
+	WebIdentityTokenFile string
+	// IMDSv2Only refuses to fall back to IMDSv1 for instance role credentials
+	IMDSv2Only bool
+}
+
+// NoOverwrite sends the uploads of S3Put with If-None-Match so an existing
+// backup is never clobbered, by default existing objects are replaced
+var NoOverwrite bool
+
+// ErrExists is returned by S3Put for objects that already exist
+var ErrExists = errors.New("object already exists")
+
+// StorageClass is the storage class of uploaded objects, the bucket's default
+// when empty
+var StorageClass string
+
+// Accelerate sends uploads through S3 Transfer Acceleration, it must be
+// enabled on the bucket
+var Accelerate bool
+
+// storageClasses are the storage classes objects can be uploaded to
+var storageClasses = []string{"STANDARD", "STANDARD_IA", "ONEZONE_IA", "INTELLIGENT_TIERING", "GLACIER_IR", "GLACIER", "DEEP_ARCHIVE"}
+
+// ValidateStorageClass returns an error for unknown storage classes
+func ValidateStorageClass(class string) error {
+	if class == "" {
+		return nil
+	}
+	for _, c := range storageClasses {
+		if class == c {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid storage class %q, expected one of %s", class, strings.Join(storageClasses, ", "))
+}
+
+type S3ListResult struct {
+	Key          string
+	Size         int
+	LastModified time.Time
+}
+
+// RestoreState is the archive state of an S3 object
+type RestoreState struct {
+	Archived bool   // the object must be restored before it can be read
+	Tiered   bool   // archived by intelligent tiering, restores move it back
+	Ongoing  bool   // a restore was requested and is not finished
+	Expiry   string // when the restored copy is removed again, if restored
+}
+
+// Readable reports whether the object can be downloaded
+func (s RestoreState) Readable() bool {
+	return !s.Archived || (!s.Ongoing && s.Expiry != "")
+}
+
+const (
+	// EventSource is the source of the events published to EventBridge
+	EventSource = "vault-dump"
+	// EventDetailType is the detail type of the completion event of a run
+	EventDetailType = "Vault Dump Run Completed"
+)
+
+// RunReport describes the outcome of a dump or import, it never holds
+// secret values
+type RunReport struct {
+	Command  string        `json:"command"`
+	Cluster  string        `json:"cluster"`
+	Success  bool          `json:"success"`
+	Secrets  int           `json:"secrets"`
+	Failed   int           `json:"failed"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"-"`
+	Error    string        `json:"error,omitempty"`
+	// VaultRequests, KMSRequests and S3Requests were sent during the run,
+	// Cost is the estimated cost of its AWS requests in USD
+	VaultRequests int64   `json:"vault_requests"`
+	KMSRequests   int64   `json:"kms_requests"`
+	S3Requests    int64   `json:"s3_requests"`
+	Cost          float64 `json:"cost"`
+}
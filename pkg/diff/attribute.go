@@ -0,0 +1,107 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Versions is what a dump records of the KV version 2 versions of a secret,
+// from its metadata and the earlier versions dumped
+type Versions struct {
+	// Current is the version of the secret dumped, 0 when unknown
+	Current int
+	// Created holds when each version was written
+	Created map[int]string
+	// Data holds the values of the versions dumped, the current one
+	// included, deleted and destroyed versions have none
+	Data map[int]map[string]interface{}
+}
+
+// Attribute returns a copy of the changes from the dump whose secrets have
+// the versions old to the one whose secrets have the versions new, with each
+// change attributed to the version of the secret it was made in, when the
+// versions recorded tell. That is the version from which on the field, or
+// the secret, is as in new, provided the version before it is known to
+// differ. Secrets added are attributed to their first version, changes of
+// fields only when every version between the two dumps was dumped, or when
+// a single version was written between them.
+func Attribute(changes []Change, old, new map[string]Versions) []Change {
+	out := make([]Change, len(changes))
+	for i, c := range changes {
+		// secrets deleted are not in new, their last version is unknown
+		n, ok := new[c.Path]
+		if !ok {
+			out[i] = c
+			continue
+		}
+		known := make(map[int]map[string]interface{}, len(n.Data)+1)
+		for v, data := range n.Data {
+			known[v] = data
+		}
+		if o, ok := old[c.Path]; ok && o.Current > 0 && o.Current < n.Current {
+			if _, dumped := known[o.Current]; !dumped {
+				known[o.Current] = o.Data[o.Current]
+			}
+		}
+		v := 0
+		if c.Key != "" || c.Type != Added {
+			v = changedIn(known, n.Current, c.Key)
+		} else if n.Current == 1 || n.Created[1] != "" {
+			v = 1
+		}
+		if v > 0 {
+			c.Version, c.Changed = v, n.Created[v]
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// changedIn returns the version from which on the field key of the secret,
+// or the whole secret when key is empty, is as in version current, or 0 when
+// the versions known can not tell
+func changedIn(known map[int]map[string]interface{}, current int, key string) int {
+	latest, ok := known[current]
+	if current < 1 || !ok || latest == nil {
+		return 0
+	}
+	field := func(data map[string]interface{}) (interface{}, bool) {
+		v, exists := data[key]
+		return v, exists
+	}
+	if key == "" {
+		field = func(data map[string]interface{}) (interface{}, bool) {
+			return data, true
+		}
+	}
+	target, targetExists := field(latest)
+	v := current
+	for ; v > 1; v-- {
+		data, ok := known[v-1]
+		if !ok || data == nil {
+			break
+		}
+		value, exists := field(data)
+		if exists != targetExists || !reflect.DeepEqual(value, target) {
+			return v
+		}
+	}
+	// a secret is added in its first version, a field was as it is since
+	// then
+	if v == 1 {
+		return 1
+	}
+	return 0
+}
+
+// versionString describes the version c is attributed to, empty when none
+func versionString(c Change) string {
+	if c.Version == 0 {
+		return ""
+	}
+	s := fmt.Sprintf(" in v%d", c.Version)
+	if len(c.Changed) >= len("2006-01-02") {
+		s += " on " + c.Changed[:len("2006-01-02")]
+	}
+	return s
+}
@@ -0,0 +1,95 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestSuiteAttribute(tt *testing.T) {
+	created := map[int]string{
+		1: "2024-04-01T10:00:00.5Z",
+		2: "2024-04-20T10:00:00.5Z",
+		3: "2024-05-02T10:00:00.5Z",
+		4: "2024-05-03T10:00:00.5Z",
+	}
+	oldSecret := map[string]interface{}{"user": "app", "password": "old"}
+	newSecret := map[string]interface{}{"user": "app", "password": "new", "host": "db"}
+	// versions of secret/db holding newSecret, and how the earlier versions
+	// dumped changed into it
+	history := map[int]map[string]interface{}{
+		1: oldSecret,
+		2: {"user": "app", "password": "new"},
+		3: newSecret,
+		4: newSecret,
+	}
+	var (
+		tests = []struct {
+			description string
+			old         map[string]Versions
+			new         map[string]Versions
+			normOutput  string
+		}{
+			{
+				"Earlier versions dumped",
+				nil,
+				map[string]Versions{"secret/db": {Current: 4, Created: created, Data: history}},
+				"secret/db: key host added in v3 on 2024-05-02\nsecret/db: key password changed in v2 on 2024-04-20\nsecret/new: added\n",
+			},
+			{
+				"Single version between the dumps",
+				map[string]Versions{"secret/db": {Current: 3, Data: map[int]map[string]interface{}{3: oldSecret}}},
+				map[string]Versions{"secret/db": {Current: 4, Created: created, Data: map[int]map[string]interface{}{4: newSecret}}},
+				"secret/db: key host added in v4 on 2024-05-03\nsecret/db: key password changed in v4 on 2024-05-03\nsecret/new: added\n",
+			},
+			{
+				"Several versions between the dumps",
+				map[string]Versions{"secret/db": {Current: 1, Data: map[int]map[string]interface{}{1: oldSecret}}},
+				map[string]Versions{"secret/db": {Current: 4, Created: created, Data: map[int]map[string]interface{}{4: newSecret}}},
+				"secret/db: key host added\nsecret/db: key password changed\nsecret/new: added\n",
+			},
+			{
+				"Added secret",
+				nil,
+				map[string]Versions{"secret/new": {Current: 2, Created: created}},
+				"secret/db: key host added\nsecret/db: key password changed\nsecret/new: added in v1 on 2024-04-01\n",
+			},
+			{
+				"No metadata",
+				nil,
+				nil,
+				"secret/db: key host added\nsecret/db: key password changed\nsecret/new: added\n",
+			},
+		}
+	)
+	for _, test := range tests {
+		old := map[string]interface{}{"secret/db": oldSecret}
+		new := map[string]interface{}{"secret/db": newSecret, "secret/new": map[string]interface{}{"k": "v"}}
+		r := NewReport("old.json", "new.json", old, new, Redact)
+		r.Changes = Attribute(r.Changes, test.old, test.new)
+		var b bytes.Buffer
+		if err := Changelog(&b, r); err != nil {
+			tt.Fatal(err)
+		}
+		norm := b.String()
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+
+	// the version a change is attributed to is kept in JSON
+	r := NewReport("old.json", "new.json", map[string]interface{}{}, map[string]interface{}{"secret/new": map[string]interface{}{"k": "v"}}, Redact)
+	r.Changes = Attribute(r.Changes, nil, map[string]Versions{"secret/new": {Current: 1, Created: created}})
+	var b bytes.Buffer
+	if err := JSON(&b, r); err != nil {
+		tt.Fatal(err)
+	}
+	if expected := fmt.Sprintf(`"version": 1,
+      "changed": "%s"`, created[1]); !bytes.Contains(b.Bytes(), []byte(expected)) {
+		tt.Errorf("FAIL JSON: expected '%s' in '%s'", expected, b.String())
+	} else {
+		tt.Logf("PASS JSON")
+	}
+}
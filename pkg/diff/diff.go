@@ -0,0 +1,175 @@
+package diff
+
+// diff compares the secrets of two dumps field by field and renders the
+// changes for terminals, automation and review, with values redacted unless
+// asked otherwise.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Kinds of change, the same as those of the dump change feed
+const (
+	Added   = "added"
+	Updated = "updated"
+	Deleted = "deleted"
+)
+
+// Values controls how values are rendered
+type Values string
+
+const (
+	// Redact hides values, only which fields changed is shown
+	Redact Values = "redact"
+	// Hash shows a short SHA-256 of each value, so equal values can be told
+	// apart without showing them. Short or guessable values can be brute
+	// forced from their hash.
+	Hash Values = "hash"
+	// Show shows values as they are
+	Show Values = "show"
+)
+
+// redacted replaces values with Redact
+const redacted = "(redacted)"
+
+// ParseValues parses a Values mode
+func ParseValues(s string) (Values, error) {
+	switch v := Values(s); v {
+	case Redact, Hash, Show:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid value mode %q, expected one of redact, hash, show", s)
+}
+
+// Change is a change of a field, or of a whole secret when Key is empty
+type Change struct {
+	Path string      `json:"path"`
+	Key  string      `json:"key,omitempty"`
+	Type string      `json:"type"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+	// Version is the KV version 2 version the change was made in and
+	// Changed when it was written, see Attribute
+	Version int    `json:"version,omitempty"`
+	Changed string `json:"changed,omitempty"`
+}
+
+// Compare returns the changes from old to new, sorted by path and key.
+// Secrets that only exist on one side are a single change, the fields of
+// secrets on both sides are compared one by one.
+func Compare(old, new map[string]interface{}) []Change {
+	changes := []Change{}
+	for path, o := range old {
+		if _, exists := new[path]; !exists {
+			changes = append(changes, Change{Path: path, Type: Deleted, Old: o})
+		}
+	}
+	for path, n := range new {
+		o, exists := old[path]
+		if !exists {
+			changes = append(changes, Change{Path: path, Type: Added, New: n})
+			continue
+		}
+		of, oIsObject := o.(map[string]interface{})
+		nf, nIsObject := n.(map[string]interface{})
+		if !oIsObject || !nIsObject {
+			if !reflect.DeepEqual(o, n) {
+				changes = append(changes, Change{Path: path, Type: Updated, Old: o, New: n})
+			}
+			continue
+		}
+		for k, ov := range of {
+			nv, exists := nf[k]
+			switch {
+			case !exists:
+				changes = append(changes, Change{Path: path, Key: k, Type: Deleted, Old: ov})
+			case !reflect.DeepEqual(ov, nv):
+				changes = append(changes, Change{Path: path, Key: k, Type: Updated, Old: ov, New: nv})
+			}
+		}
+		for k, nv := range nf {
+			if _, exists := of[k]; !exists {
+				changes = append(changes, Change{Path: path, Key: k, Type: Added, New: nv})
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Path != changes[j].Path {
+			return changes[i].Path < changes[j].Path
+		}
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// Summary counts changes by kind
+type Summary struct {
+	Added   int `json:"added"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+}
+
+// Summarize counts the changes by kind
+func Summarize(changes []Change) Summary {
+	var s Summary
+	for _, c := range changes {
+		switch c.Type {
+		case Added:
+			s.Added++
+		case Updated:
+			s.Updated++
+		case Deleted:
+			s.Deleted++
+		}
+	}
+	return s
+}
+
+// Redacted returns a copy of the changes with values rendered by mode
+func Redacted(changes []Change, mode Values) []Change {
+	out := make([]Change, len(changes))
+	for i, c := range changes {
+		if c.Old != nil {
+			c.Old = render(c.Old, c.Key == "", mode)
+		}
+		if c.New != nil {
+			c.New = render(c.New, c.Key == "", mode)
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// render returns v as rendered by mode, the fields of a whole secret are
+// rendered one by one so their names stay visible
+func render(v interface{}, secret bool, mode Values) interface{} {
+	if mode == Show {
+		return v
+	}
+	if fields, ok := v.(map[string]interface{}); ok && secret {
+		out := make(map[string]interface{}, len(fields))
+		for k, f := range fields {
+			out[k] = render(f, false, mode)
+		}
+		return out
+	}
+	if mode == Hash {
+		return hash(v)
+	}
+	return redacted
+}
+
+// hash returns a short SHA-256 of the JSON encoding of v
+func hash(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return redacted
+	}
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])[:12]
+}
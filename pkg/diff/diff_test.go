@@ -0,0 +1,142 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var (
+	oldDump = map[string]interface{}{
+		"secret/app/db":  map[string]interface{}{"user": "app", "password": "old", "port": float64(5432)},
+		"secret/app/api": map[string]interface{}{"token": "t"},
+		"secret/a~b/c":   map[string]interface{}{"k": "v"},
+	}
+	newDump = map[string]interface{}{
+		"secret/app/db":  map[string]interface{}{"user": "app", "password": "new", "host": "db"},
+		"secret/app/web": map[string]interface{}{"cert": "<pem>"},
+		"secret/a~b/c":   map[string]interface{}{"k": "v"},
+	}
+)
+
+func TestSuiteCompare(tt *testing.T) {
+	expected := []Change{
+		{Path: "secret/app/api", Type: Deleted, Old: map[string]interface{}{"token": "t"}},
+		{Path: "secret/app/db", Key: "host", Type: Added, New: "db"},
+		{Path: "secret/app/db", Key: "password", Type: Updated, Old: "old", New: "new"},
+		{Path: "secret/app/db", Key: "port", Type: Deleted, Old: float64(5432)},
+		{Path: "secret/app/web", Type: Added, New: map[string]interface{}{"cert": "<pem>"}},
+	}
+	changes := Compare(oldDump, newDump)
+	if !reflect.DeepEqual(changes, expected) {
+		tt.Errorf("FAIL Compare: expected %v got %v", expected, changes)
+	} else {
+		tt.Logf("PASS Compare")
+	}
+	if s := Summarize(changes); s != (Summary{Added: 2, Updated: 1, Deleted: 2}) {
+		tt.Errorf("FAIL Summarize: got %+v", s)
+	} else {
+		tt.Logf("PASS Summarize")
+	}
+	if changes := Compare(oldDump, oldDump); len(changes) != 0 {
+		tt.Errorf("FAIL Compare identical: got %v", changes)
+	} else {
+		tt.Logf("PASS Compare identical")
+	}
+}
+
+func TestSuiteFormats(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			format      string
+			mode        Values
+			contains    []string
+			excludes    []string
+		}{
+			{
+				"Unified redacted", "unified", Redact,
+				[]string{"--- old.json\n+++ new.json\n", "@@ secret/app/api (deleted) @@\n-token: (redacted)\n", "-password: (redacted)\n+password: (redacted)\n", "@@ secret/app/web (added) @@\n+cert: (redacted)\n"},
+				[]string{"old\"", "new\"", "<pem>"},
+			},
+			{
+				"Unified hashed", "unified", Hash,
+				[]string{"-password: sha256:", "+host: sha256:"},
+				[]string{"\"new\""},
+			},
+			{
+				"Unified shown", "unified", Show,
+				[]string{"-password: \"old\"\n+password: \"new\"\n", "-port: 5432\n"},
+				nil,
+			},
+			{
+				"JSON redacted", "json", Redact,
+				[]string{`"added": 2`, `"values": "redact"`, `"old": "(redacted)"`},
+				[]string{`"old": "old"`},
+			},
+			{
+				"JSON Patch shown", "json-patch", Show,
+				[]string{`"op": "test",`, `"path": "/secret~1app~1db/password"`, `"op": "replace"`, `"op": "remove"`},
+				nil,
+			},
+			{
+				"JSON Patch redacted has no tests", "json-patch", Redact,
+				[]string{`"op": "replace"`},
+				[]string{`"op": "test"`},
+			},
+			{
+				"HTML escaped", "html", Show,
+				[]string{"<tr class=\"added\">", "&lt;pem&gt;", "1 updated, 2 deleted"},
+				[]string{"\"<pem>\""},
+			},
+		}
+	)
+	for _, test := range tests {
+		var b bytes.Buffer
+		r := NewReport("old.json", "new.json", oldDump, newDump, test.mode)
+		if err := Formats[test.format](&b, r); err != nil {
+			tt.Errorf("FAIL %s: %s", test.description, err)
+			continue
+		}
+		out, success := b.String(), true
+		for _, c := range test.contains {
+			if !strings.Contains(out, c) {
+				tt.Errorf("FAIL %s: expected %q in\n%s", test.description, c, out)
+				success = false
+			}
+		}
+		for _, c := range test.excludes {
+			if strings.Contains(out, c) {
+				tt.Errorf("FAIL %s: unexpected %q in\n%s", test.description, c, out)
+				success = false
+			}
+		}
+		if success {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteJSONPatchApplies(tt *testing.T) {
+	var b bytes.Buffer
+	if err := JSONPatch(&b, NewReport("old", "new", oldDump, newDump, Show)); err != nil {
+		tt.Fatalf("FAIL %s", err)
+	}
+	var ops []PatchOp
+	if err := json.Unmarshal(b.Bytes(), &ops); err != nil {
+		tt.Fatalf("FAIL %s", err)
+	}
+	tokens := strings.Split(ops[0].Path, "/")
+	if len(tokens) != 2 || tokens[1] != "secret~1app~1api" {
+		tt.Errorf("FAIL pointer: got %s", ops[0].Path)
+	} else {
+		tt.Logf("PASS pointer")
+	}
+	for _, op := range ops {
+		if op.Path == "/secret~1a~0b~1c" {
+			tt.Errorf("FAIL unchanged secret in patch")
+		}
+	}
+}
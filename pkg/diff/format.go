@@ -0,0 +1,325 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report is a comparison of two dumps ready to be written, its changes are
+// already rendered with Redacted
+type Report struct {
+	Old     string   `json:"old"`
+	New     string   `json:"new"`
+	Base    string   `json:"base,omitempty"`
+	Values  Values   `json:"values"`
+	Summary Summary  `json:"summary"`
+	Changes []Change `json:"changes"`
+	// Conflicts of a three-way merge, the changes leave them out
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+}
+
+// NewReport compares old and new, named oldName and newName, and renders the
+// values of the changes by mode
+func NewReport(oldName, newName string, old, new map[string]interface{}, mode Values) Report {
+	changes := Compare(old, new)
+	return Report{
+		Old:     oldName,
+		New:     newName,
+		Values:  mode,
+		Summary: Summarize(changes),
+		Changes: Redacted(changes, mode),
+	}
+}
+
+// NewMergeReport merges the changes from base to ours and from base to
+// theirs, see Merge3. The report shows the changes from theirs to the merged
+// secrets, leaving conflicts as they are in theirs, and the conflicts.
+func NewMergeReport(baseName, oursName, theirsName string, base, ours, theirs map[string]interface{}, mode Values) (Report, map[string]interface{}) {
+	merged, conflicts := Merge3(base, ours, theirs)
+	resolved := make(map[string]interface{}, len(merged))
+	for path, secret := range merged {
+		resolved[path] = secret
+	}
+	for _, c := range conflicts {
+		if c.Key == "" {
+			if c.Theirs == nil {
+				delete(resolved, c.Path)
+			} else {
+				resolved[c.Path] = c.Theirs
+			}
+			continue
+		}
+		fields := make(map[string]interface{})
+		for k, v := range resolved[c.Path].(map[string]interface{}) {
+			fields[k] = v
+		}
+		if c.Theirs == nil {
+			delete(fields, c.Key)
+		} else {
+			fields[c.Key] = c.Theirs
+		}
+		resolved[c.Path] = fields
+	}
+
+	changes := Compare(theirs, resolved)
+	rendered := make([]Conflict, len(conflicts))
+	for i, c := range conflicts {
+		for _, v := range []*interface{}{&c.Base, &c.Ours, &c.Theirs} {
+			if *v != nil {
+				*v = render(*v, c.Key == "", mode)
+			}
+		}
+		rendered[i] = c
+	}
+	return Report{
+		Old:       theirsName,
+		New:       oursName,
+		Base:      baseName,
+		Values:    mode,
+		Summary:   Summarize(changes),
+		Changes:   Redacted(changes, mode),
+		Conflicts: rendered,
+	}, merged
+}
+
+// Formats the report can be written in
+var Formats = map[string]func(io.Writer, Report) error{
+	"unified":    Unified,
+	"json":       JSON,
+	"json-patch": JSONPatch,
+	"html":       HTML,
+	"changelog":  Changelog,
+}
+
+// Unified writes the report as a unified diff of the fields of each secret
+func Unified(w io.Writer, r Report) error {
+	if len(r.Changes) == 0 && len(r.Conflicts) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	if r.Base != "" {
+		fmt.Fprintf(&b, "--- %s\n+++ %s merged with %s\n", r.Old, r.New, r.Base)
+	} else {
+		fmt.Fprintf(&b, "--- %s\n+++ %s\n", r.Old, r.New)
+	}
+	path := ""
+	for i, c := range r.Changes {
+		if i == 0 || c.Path != path {
+			path = c.Path
+			if c.Key == "" && c.Type != Updated {
+				fmt.Fprintf(&b, "@@ %s (%s) @@\n", c.Path, c.Type)
+			} else {
+				fmt.Fprintf(&b, "@@ %s @@\n", c.Path)
+			}
+		}
+		if c.Old != nil {
+			writeLines(&b, "-", c.Key, c.Old, r.Values)
+		}
+		if c.New != nil {
+			writeLines(&b, "+", c.Key, c.New, r.Values)
+		}
+	}
+	// conflicts are marked like git does, ours first
+	for _, c := range r.Conflicts {
+		fmt.Fprintf(&b, "@@ %s (conflict) @@\n", c.Path)
+		fmt.Fprintf(&b, "<<<<<<< %s\n", r.New)
+		if c.Ours != nil {
+			writeLines(&b, "", c.Key, c.Ours, r.Values)
+		}
+		fmt.Fprintf(&b, "||||||| %s\n", r.Base)
+		if c.Base != nil {
+			writeLines(&b, "", c.Key, c.Base, r.Values)
+		}
+		b.WriteString("=======\n")
+		if c.Theirs != nil {
+			writeLines(&b, "", c.Key, c.Theirs, r.Values)
+		}
+		fmt.Fprintf(&b, ">>>>>>> %s\n", r.Old)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Changelog writes the report as a line per change, without values, with the
+// version each change was made in when it is attributed, see Attribute
+func Changelog(w io.Writer, r Report) error {
+	var b strings.Builder
+	for _, c := range r.Changes {
+		what := c.Type
+		if what == Updated {
+			what = "changed"
+		}
+		if c.Key == "" {
+			fmt.Fprintf(&b, "%s: %s%s\n", c.Path, what, versionString(c))
+		} else {
+			fmt.Fprintf(&b, "%s: key %s %s%s\n", c.Path, c.Key, what, versionString(c))
+		}
+	}
+	for _, c := range r.Conflicts {
+		if c.Key == "" {
+			fmt.Fprintf(&b, "%s: conflict\n", c.Path)
+		} else {
+			fmt.Fprintf(&b, "%s: key %s conflict\n", c.Path, c.Key)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeLines writes a field, or every field of a whole secret
+func writeLines(b *strings.Builder, prefix, key string, v interface{}, mode Values) {
+	fields, ok := v.(map[string]interface{})
+	if key != "" || !ok {
+		if key == "" {
+			fmt.Fprintf(b, "%s%s\n", prefix, valueString(v, mode))
+		} else {
+			fmt.Fprintf(b, "%s%s: %s\n", prefix, key, valueString(v, mode))
+		}
+		return
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s%s: %s\n", prefix, k, valueString(fields[k], mode))
+	}
+}
+
+// valueString renders a value on a single line, shown values are JSON
+// encoded so strings with newlines can not fake diff lines
+func valueString(v interface{}, mode Values) string {
+	if s, ok := v.(string); ok && mode != Show {
+		return s
+	}
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return redacted
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// JSON writes the report as JSON
+func JSON(w io.Writer, r Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// JSONPatch writes the report as an RFC 6902 JSON Patch turning the old dump,
+// an object of paths to secrets, into the new one. With values shown every
+// replace and remove is preceded by a test of the old value, so the patch
+// only applies to the dump it was made from. Patches with redacted values
+// are for review only.
+func JSONPatch(w io.Writer, r Report) error {
+	if len(r.Conflicts) > 0 {
+		return fmt.Errorf("can not write a patch with %d conflicts", len(r.Conflicts))
+	}
+	ops := []PatchOp{}
+	for _, c := range r.Changes {
+		pointer := "/" + escapePointer(c.Path)
+		if c.Key != "" {
+			pointer += "/" + escapePointer(c.Key)
+		}
+		if r.Values == Show && c.Type != Added {
+			ops = append(ops, PatchOp{Op: "test", Path: pointer, Value: c.Old})
+		}
+		switch c.Type {
+		case Added:
+			ops = append(ops, PatchOp{Op: "add", Path: pointer, Value: c.New})
+		case Updated:
+			ops = append(ops, PatchOp{Op: "replace", Path: pointer, Value: c.New})
+		case Deleted:
+			ops = append(ops, PatchOp{Op: "remove", Path: pointer})
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ops)
+}
+
+// escapePointer escapes a JSON Pointer reference token, RFC 6901
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+// htmlRow is a line of the HTML report
+type htmlRow struct {
+	Path string
+	Key  string
+	Type string
+	Old  string
+	New  string
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>vault-dump diff {{.Old}} {{.New}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+td.value { font-family: monospace; white-space: pre-wrap; word-break: break-all; }
+tr.added { background: #e6ffec; }
+tr.updated { background: #fff8c5; }
+tr.deleted { background: #ffebe9; }
+</style>
+</head>
+<body>
+<h1>Secrets diff</h1>
+<p>From <code>{{.Old}}</code> to <code>{{.New}}</code>, generated {{.Generated}}, values {{.Values}}.</p>
+<p>{{.Summary.Added}} added, {{.Summary.Updated}} updated, {{.Summary.Deleted}} deleted.</p>
+{{if .Rows}}<table>
+<tr><th>Path</th><th>Key</th><th>Change</th><th>Old</th><th>New</th></tr>
+{{range .Rows}}<tr class="{{.Type}}"><td>{{.Path}}</td><td>{{.Key}}</td><td>{{.Type}}</td><td class="value">{{.Old}}</td><td class="value">{{.New}}</td></tr>
+{{end}}</table>{{else}}<p>No differences.</p>{{end}}
+{{if .ConflictRows}}<h2>Conflicts</h2>
+<p>Changed differently in <code>{{.New}}</code> and <code>{{.Old}}</code> since <code>{{.Base}}</code>.</p>
+<table>
+<tr><th>Path</th><th>Key</th><th>Base</th><th>Ours</th><th>Theirs</th></tr>
+{{range .ConflictRows}}<tr class="updated"><td>{{.Path}}</td><td>{{.Key}}</td><td class="value">{{.Base}}</td><td class="value">{{.Ours}}</td><td class="value">{{.Theirs}}</td></tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
+
+// HTML writes the report as a standalone HTML page
+func HTML(w io.Writer, r Report) error {
+	rows := make([]htmlRow, 0, len(r.Changes))
+	for _, c := range r.Changes {
+		row := htmlRow{Path: c.Path, Key: c.Key, Type: c.Type}
+		if c.Old != nil {
+			row.Old = valueString(c.Old, r.Values)
+		}
+		if c.New != nil {
+			row.New = valueString(c.New, r.Values)
+		}
+		rows = append(rows, row)
+	}
+	conflicts := make([]map[string]string, 0, len(r.Conflicts))
+	for _, c := range r.Conflicts {
+		row := map[string]string{"Path": c.Path, "Key": c.Key}
+		for name, v := range map[string]interface{}{"Base": c.Base, "Ours": c.Ours, "Theirs": c.Theirs} {
+			if v != nil {
+				row[name] = valueString(v, r.Values)
+			}
+		}
+		conflicts = append(conflicts, row)
+	}
+	return htmlReport.Execute(w, struct {
+		Report
+		Generated    string
+		Rows         []htmlRow
+		ConflictRows []map[string]string
+	}{r, time.Now().UTC().Format(time.RFC3339), rows, conflicts})
+}
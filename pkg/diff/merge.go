@@ -0,0 +1,150 @@
+package diff
+
+import (
+	"reflect"
+	"sort"
+)
+
+// ConflictKey tags a value that was changed differently on both sides of a
+// three-way merge, its value holds the base, ours and theirs versions that
+// exist
+const ConflictKey = "$conflict"
+
+// Conflict is a field, or a whole secret when Key is empty, changed
+// differently on both sides of a three-way merge. Versions missing on a side
+// are nil.
+type Conflict struct {
+	Path   string      `json:"path"`
+	Key    string      `json:"key,omitempty"`
+	Base   interface{} `json:"base,omitempty"`
+	Ours   interface{} `json:"ours,omitempty"`
+	Theirs interface{} `json:"theirs,omitempty"`
+}
+
+// side is a version of a value in a three-way merge
+type side struct {
+	v      interface{}
+	exists bool
+}
+
+func (s side) equal(o side) bool {
+	return s.exists == o.exists && (!s.exists || reflect.DeepEqual(s.v, o.v))
+}
+
+// pick returns the merged version of a value, the side that changed it from
+// base, and false when both sides changed it differently
+func pick(base, ours, theirs side) (side, bool) {
+	switch {
+	case ours.equal(theirs), theirs.equal(base):
+		return ours, true
+	case ours.equal(base):
+		return theirs, true
+	}
+	return side{}, false
+}
+
+// Merge3 merges the changes from base to ours and from base to theirs.
+// Secrets changed on both sides are merged field by field, and every field
+// or secret changed differently on both sides is a conflict, which is tagged
+// with ConflictKey in the merged secrets.
+func Merge3(base, ours, theirs map[string]interface{}) (map[string]interface{}, []Conflict) {
+	merged := make(map[string]interface{})
+	conflicts := []Conflict{}
+	for _, path := range union(base, ours, theirs) {
+		b, o, t := lookup(base, path), lookup(ours, path), lookup(theirs, path)
+		if m, ok := pick(b, o, t); ok {
+			if m.exists {
+				merged[path] = m.v
+			}
+			continue
+		}
+
+		of, oIsObject := o.v.(map[string]interface{})
+		tf, tIsObject := t.v.(map[string]interface{})
+		bf, bIsObject := b.v.(map[string]interface{})
+		if !oIsObject || !tIsObject || (b.exists && !bIsObject) {
+			c := Conflict{Path: path, Base: b.v, Ours: o.v, Theirs: t.v}
+			conflicts = append(conflicts, c)
+			merged[path] = conflictValue(c)
+			continue
+		}
+
+		fields := make(map[string]interface{})
+		for _, k := range union(bf, of, tf) {
+			fb, fo, ft := lookup(bf, k), lookup(of, k), lookup(tf, k)
+			m, ok := pick(fb, fo, ft)
+			if !ok {
+				c := Conflict{Path: path, Key: k, Base: fb.v, Ours: fo.v, Theirs: ft.v}
+				conflicts = append(conflicts, c)
+				fields[k] = conflictValue(c)
+			} else if m.exists {
+				fields[k] = m.v
+			}
+		}
+		merged[path] = fields
+	}
+	return merged, conflicts
+}
+
+// HasConflicts reports whether any secret, or field of a secret, is tagged
+// with ConflictKey
+func HasConflicts(secrets map[string]interface{}) bool {
+	for _, secret := range secrets {
+		if isConflict(secret) {
+			return true
+		}
+		if fields, ok := secret.(map[string]interface{}); ok {
+			for _, v := range fields {
+				if isConflict(v) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func isConflict(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return false
+	}
+	_, ok = m[ConflictKey]
+	return ok
+}
+
+// conflictValue tags the versions of a conflict that exist
+func conflictValue(c Conflict) map[string]interface{} {
+	versions := make(map[string]interface{})
+	if c.Base != nil {
+		versions["base"] = c.Base
+	}
+	if c.Ours != nil {
+		versions["ours"] = c.Ours
+	}
+	if c.Theirs != nil {
+		versions["theirs"] = c.Theirs
+	}
+	return map[string]interface{}{ConflictKey: versions}
+}
+
+func lookup(m map[string]interface{}, k string) side {
+	v, exists := m[k]
+	return side{v: v, exists: exists}
+}
+
+// union returns the sorted keys of all maps
+func union(maps ...map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	keys := []string{}
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
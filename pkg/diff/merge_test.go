@@ -0,0 +1,132 @@
+package diff
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSuiteMerge3(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			base        map[string]interface{}
+			ours        map[string]interface{}
+			theirs      map[string]interface{}
+			merged      map[string]interface{}
+			conflicts   int
+		}{
+			{
+				"Only ours changed",
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "1"}},
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "2"}},
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "1"}},
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "2"}},
+				0,
+			},
+			{
+				"Only theirs changed",
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "1"}},
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "1"}},
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "3"}, "s/b": map[string]interface{}{"x": "y"}},
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "3"}, "s/b": map[string]interface{}{"x": "y"}},
+				0,
+			},
+			{
+				"Different fields changed on both sides",
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "1", "l": "1", "m": "1"}},
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "2", "l": "1"}},
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "1", "l": "3", "m": "1", "n": "new"}},
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "2", "l": "3", "n": "new"}},
+				0,
+			},
+			{
+				"Same change on both sides",
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "1"}},
+				map[string]interface{}{},
+				map[string]interface{}{},
+				map[string]interface{}{},
+				0,
+			},
+			{
+				"Field conflict",
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "1", "l": "1"}},
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "2", "l": "1"}},
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "3", "l": "4"}},
+				map[string]interface{}{"s/a": map[string]interface{}{
+					"k": map[string]interface{}{ConflictKey: map[string]interface{}{"base": "1", "ours": "2", "theirs": "3"}},
+					"l": "4",
+				}},
+				1,
+			},
+			{
+				"Deleted by us and changed by them",
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "1"}},
+				map[string]interface{}{},
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "3"}},
+				map[string]interface{}{"s/a": map[string]interface{}{ConflictKey: map[string]interface{}{
+					"base": map[string]interface{}{"k": "1"}, "theirs": map[string]interface{}{"k": "3"},
+				}}},
+				1,
+			},
+			{
+				"Added differently on both sides",
+				map[string]interface{}{},
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "1", "l": "1"}},
+				map[string]interface{}{"s/a": map[string]interface{}{"k": "2", "l": "1"}},
+				map[string]interface{}{"s/a": map[string]interface{}{
+					"k": map[string]interface{}{ConflictKey: map[string]interface{}{"ours": "1", "theirs": "2"}},
+					"l": "1",
+				}},
+				1,
+			},
+		}
+	)
+	for _, test := range tests {
+		merged, conflicts := Merge3(test.base, test.ours, test.theirs)
+		if !reflect.DeepEqual(merged, test.merged) || len(conflicts) != test.conflicts || HasConflicts(merged) != (test.conflicts > 0) {
+			tt.Errorf("FAIL %s: expected %v with %d conflicts got %v with %v", test.description, test.merged, test.conflicts, merged, conflicts)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteMergeReport(tt *testing.T) {
+	base := map[string]interface{}{"s/a": map[string]interface{}{"k": "1", "l": "1"}}
+	ours := map[string]interface{}{"s/a": map[string]interface{}{"k": "2", "l": "2"}}
+	theirs := map[string]interface{}{"s/a": map[string]interface{}{"k": "3", "l": "1"}}
+	r, _ := NewMergeReport("base.json", "ours.json", "vault", base, ours, theirs, Show)
+
+	var (
+		tests = []struct {
+			description string
+			check       func() bool
+		}{
+			{"Conflicts left out of changes", func() bool {
+				return reflect.DeepEqual(r.Changes, []Change{{Path: "s/a", Key: "l", Type: Updated, Old: "1", New: "2"}})
+			}},
+			{"Conflict markers", func() bool {
+				var b bytes.Buffer
+				Unified(&b, r)
+				return strings.Contains(b.String(), "@@ s/a (conflict) @@\n<<<<<<< ours.json\nk: \"2\"\n||||||| base.json\nk: \"1\"\n=======\nk: \"3\"\n>>>>>>> vault\n")
+			}},
+			{"No patch with conflicts", func() bool {
+				var b bytes.Buffer
+				return JSONPatch(&b, r) != nil
+			}},
+			{"Redacted conflicts", func() bool {
+				r, _ := NewMergeReport("base.json", "ours.json", "vault", base, ours, theirs, Redact)
+				return r.Conflicts[0].Ours == redacted && r.Conflicts[0].Theirs == redacted
+			}},
+		}
+	)
+	for _, test := range tests {
+		if !test.check() {
+			tt.Errorf("FAIL %s: %+v", test.description, r)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
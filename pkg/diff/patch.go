@@ -0,0 +1,199 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// PatchOp is an RFC 6902 JSON Patch operation on a dump, an object of paths
+// to secrets
+type PatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// SecretPatch is the operations of a patch on one secret, in order
+type SecretPatch struct {
+	Path string
+	Ops  []PatchOp
+}
+
+// hashed matches values rendered with Hash
+var hashed = regexp.MustCompile(`^sha256:[0-9a-f]{12}$`)
+
+// ParsePatch parses a JSON Patch written by JSONPatch and groups its
+// operations by secret, in the order each secret first appears. Only add,
+// remove, replace and test are supported, and patches with redacted or
+// hashed values are refused.
+func ParsePatch(data []byte) ([]SecretPatch, error) {
+	var ops []PatchOp
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+	index := make(map[string]int)
+	patches := []SecretPatch{}
+	for i, op := range ops {
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("operation %d: %s needs a value", i+1, op.Op)
+			}
+			if isRendered(op.Value) {
+				return nil, fmt.Errorf("operation %d: %s has redacted values, write the patch with --values show", i+1, op.Path)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("operation %d: unsupported op %q", i+1, op.Op)
+		}
+		path, _, err := SplitPointer(op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i+1, err)
+		}
+		n, seen := index[path]
+		if !seen {
+			n = len(patches)
+			index[path] = n
+			patches = append(patches, SecretPatch{Path: path})
+		}
+		patches[n].Ops = append(patches[n].Ops, op)
+	}
+	return patches, nil
+}
+
+// isRendered reports whether v, or any field of v, was rendered by Redact or
+// Hash
+func isRendered(v interface{}) bool {
+	switch vv := v.(type) {
+	case string:
+		return vv == redacted || hashed.MatchString(vv)
+	case map[string]interface{}:
+		for _, f := range vv {
+			if s, ok := f.(string); ok && isRendered(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SplitPointer splits a JSON Pointer into the secret path and the field, which
+// is empty for pointers to whole secrets
+func SplitPointer(pointer string) (string, string, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return "", "", fmt.Errorf("invalid pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	if len(tokens) > 2 || tokens[0] == "" {
+		return "", "", fmt.Errorf("pointer %q must address a secret or a field of a secret", pointer)
+	}
+	for i, t := range tokens {
+		tokens[i] = unescapePointer(t)
+	}
+	if len(tokens) == 1 {
+		return tokens[0], "", nil
+	}
+	return tokens[0], tokens[1], nil
+}
+
+// unescapePointer reverses escapePointer
+func unescapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+}
+
+// Apply applies the operations of p to a secret, nil when it does not exist,
+// and returns the result, nil when the secret is removed. Operations are
+// checked strictly so the patch only applies to the secret it was made from:
+// adds fail on values that already exist with a different value, removes
+// and replaces fail on values that do not exist, and tests fail on values
+// that are different.
+func (p SecretPatch) Apply(secret map[string]interface{}) (map[string]interface{}, error) {
+	var current map[string]interface{}
+	if secret != nil {
+		current = make(map[string]interface{}, len(secret))
+		for k, v := range secret {
+			current[k] = v
+		}
+	}
+	for _, op := range p.Ops {
+		_, key, err := SplitPointer(op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			if current, err = applyToSecret(current, op); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", op.Op, p.Path, err)
+			}
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("%s %s:%s: secret does not exist", op.Op, p.Path, key)
+		}
+		if err := applyToField(current, key, op); err != nil {
+			return nil, fmt.Errorf("%s %s:%s: %w", op.Op, p.Path, key, err)
+		}
+	}
+	return current, nil
+}
+
+func applyToSecret(current map[string]interface{}, op PatchOp) (map[string]interface{}, error) {
+	if op.Op == "remove" {
+		if current == nil {
+			return nil, fmt.Errorf("secret does not exist")
+		}
+		return nil, nil
+	}
+	value, ok := op.Value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value must be an object")
+	}
+	switch op.Op {
+	case "add":
+		if current != nil && !reflect.DeepEqual(current, value) {
+			return nil, fmt.Errorf("secret already exists")
+		}
+	case "replace":
+		if current == nil {
+			return nil, fmt.Errorf("secret does not exist")
+		}
+	case "test":
+		if !reflect.DeepEqual(current, value) {
+			return nil, fmt.Errorf("secret changed")
+		}
+		return current, nil
+	}
+	copied := make(map[string]interface{}, len(value))
+	for k, v := range value {
+		copied[k] = v
+	}
+	return copied, nil
+}
+
+func applyToField(current map[string]interface{}, key string, op PatchOp) error {
+	v, exists := current[key]
+	switch op.Op {
+	case "add":
+		if exists && !reflect.DeepEqual(v, op.Value) {
+			return fmt.Errorf("field already exists")
+		}
+		current[key] = op.Value
+	case "replace":
+		if !exists {
+			return fmt.Errorf("field does not exist")
+		}
+		current[key] = op.Value
+	case "remove":
+		if !exists {
+			return fmt.Errorf("field does not exist")
+		}
+		delete(current, key)
+	case "test":
+		if !exists || !reflect.DeepEqual(v, op.Value) {
+			return fmt.Errorf("field changed")
+		}
+	}
+	return nil
+}
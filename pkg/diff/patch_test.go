@@ -0,0 +1,180 @@
+package diff
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSuiteParsePatch(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			patch       string
+			paths       []string
+			fail        bool
+		}{
+			{"Grouped by secret", `[
+				{"op": "test", "path": "/secret~1a/k", "value": "1"},
+				{"op": "replace", "path": "/secret~1a/k", "value": "2"},
+				{"op": "add", "path": "/secret~1b", "value": {"x": "y"}},
+				{"op": "remove", "path": "/secret~1a/l"}
+			]`, []string{"secret/a", "secret/b"}, false},
+			{"Empty patch", `[]`, []string{}, false},
+			{"Unsupported op", `[{"op": "move", "from": "/a", "path": "/b"}]`, nil, true},
+			{"Missing value", `[{"op": "add", "path": "/a/k"}]`, nil, true},
+			{"Redacted value", `[{"op": "replace", "path": "/a/k", "value": "(redacted)"}]`, nil, true},
+			{"Hashed field", `[{"op": "add", "path": "/a", "value": {"k": "sha256:0123456789ab"}}]`, nil, true},
+			{"Pointer too deep", `[{"op": "remove", "path": "/a/k/nested"}]`, nil, true},
+			{"Root pointer", `[{"op": "remove", "path": ""}]`, nil, true},
+			{"Not a patch", `{"op": "remove"}`, nil, true},
+		}
+	)
+	for _, test := range tests {
+		patches, err := ParsePatch([]byte(test.patch))
+		if test.fail {
+			if err == nil {
+				tt.Errorf("FAIL %s: expected an error", test.description)
+			} else {
+				tt.Logf("PASS %s", test.description)
+			}
+			continue
+		}
+		if err != nil {
+			tt.Errorf("FAIL %s: %s", test.description, err)
+			continue
+		}
+		paths := []string{}
+		for _, p := range patches {
+			paths = append(paths, p.Path)
+		}
+		if !reflect.DeepEqual(paths, test.paths) {
+			tt.Errorf("FAIL %s: expected %v got %v", test.description, test.paths, paths)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteSecretPatchApply(tt *testing.T) {
+	live := map[string]interface{}{"k": "1", "l": "1"}
+	var (
+		tests = []struct {
+			description string
+			secret      map[string]interface{}
+			ops         []PatchOp
+			expected    map[string]interface{}
+			fail        bool
+		}{
+			{
+				"Tested replace", live,
+				[]PatchOp{{Op: "test", Path: "/s/k", Value: "1"}, {Op: "replace", Path: "/s/k", Value: "2"}},
+				map[string]interface{}{"k": "2", "l": "1"}, false,
+			},
+			{
+				"Changed since the patch", map[string]interface{}{"k": "3", "l": "1"},
+				[]PatchOp{{Op: "test", Path: "/s/k", Value: "1"}, {Op: "replace", Path: "/s/k", Value: "2"}},
+				nil, true,
+			},
+			{
+				"Add and remove fields", live,
+				[]PatchOp{{Op: "add", Path: "/s/m", Value: "new"}, {Op: "remove", Path: "/s/l"}},
+				map[string]interface{}{"k": "1", "m": "new"}, false,
+			},
+			{
+				"Add existing field", live,
+				[]PatchOp{{Op: "add", Path: "/s/k", Value: "2"}},
+				nil, true,
+			},
+			{
+				"Add already applied", live,
+				[]PatchOp{{Op: "add", Path: "/s/k", Value: "1"}},
+				live, false,
+			},
+			{
+				"Remove missing field", live,
+				[]PatchOp{{Op: "remove", Path: "/s/m"}},
+				nil, true,
+			},
+			{
+				"Create secret", nil,
+				[]PatchOp{{Op: "add", Path: "/s", Value: map[string]interface{}{"x": "y"}}},
+				map[string]interface{}{"x": "y"}, false,
+			},
+			{
+				"Create existing secret", live,
+				[]PatchOp{{Op: "add", Path: "/s", Value: map[string]interface{}{"x": "y"}}},
+				nil, true,
+			},
+			{
+				"Delete secret", live,
+				[]PatchOp{{Op: "test", Path: "/s", Value: map[string]interface{}{"k": "1", "l": "1"}}, {Op: "remove", Path: "/s"}},
+				nil, false,
+			},
+			{
+				"Field of missing secret", nil,
+				[]PatchOp{{Op: "add", Path: "/s/k", Value: "1"}},
+				nil, true,
+			},
+		}
+	)
+	for _, test := range tests {
+		result, err := SecretPatch{Path: "s", Ops: test.ops}.Apply(test.secret)
+		if test.fail {
+			if err == nil {
+				tt.Errorf("FAIL %s: expected an error got %v", test.description, result)
+			} else {
+				tt.Logf("PASS %s", test.description)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(result, test.expected) {
+			tt.Errorf("FAIL %s: expected %v got %v, %v", test.description, test.expected, result, err)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+	if !reflect.DeepEqual(live, map[string]interface{}{"k": "1", "l": "1"}) {
+		tt.Errorf("FAIL Apply changed its input: %v", live)
+	}
+}
+
+func TestSuitePatchRoundTrip(tt *testing.T) {
+	var b bytes.Buffer
+	if err := JSONPatch(&b, NewReport("old", "new", oldDump, newDump, Show)); err != nil {
+		tt.Fatalf("FAIL %s", err)
+	}
+	patches, err := ParsePatch(b.Bytes())
+	if err != nil {
+		tt.Fatalf("FAIL %s", err)
+	}
+	result := make(map[string]interface{})
+	for path, secret := range oldDump {
+		result[path] = secret
+	}
+	for _, p := range patches {
+		current, _ := result[p.Path].(map[string]interface{})
+		applied, err := p.Apply(current)
+		if err != nil {
+			tt.Fatalf("FAIL %s: %s", p.Path, err)
+		}
+		if applied == nil {
+			delete(result, p.Path)
+		} else {
+			result[p.Path] = applied
+		}
+	}
+	if !reflect.DeepEqual(result, newDump) {
+		tt.Errorf("FAIL round trip: expected %v got %v", newDump, result)
+	} else {
+		tt.Logf("PASS round trip")
+	}
+
+	b.Reset()
+	JSONPatch(&b, NewReport("old", "new", oldDump, newDump, Redact))
+	if _, err := ParsePatch(b.Bytes()); err == nil {
+		tt.Errorf("FAIL redacted patch parsed")
+	} else {
+		tt.Logf("PASS redacted patch refused")
+	}
+}
@@ -0,0 +1,45 @@
+package dump
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// AgeExt is the extension of the files of groups encrypted to an age
+// recipient, decrypted with age -d
+const AgeExt = "age"
+
+// isAgeRecipient reports whether key names an age recipient rather than a
+// KMS key
+func isAgeRecipient(key string) bool {
+	return strings.HasPrefix(key, "age1")
+}
+
+// AgeEncrypt encrypts plaintext to the age recipient, armored so that it is
+// written and uploaded like the files encrypted with KMS
+func AgeEncrypt(plaintext []byte, recipient string) (string, error) {
+	r, err := age.ParseX25519Recipient(recipient)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	a := armor.NewWriter(&b)
+	w, err := age.Encrypt(a, r)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	if err := a.Close(); err != nil {
+		return "", fmt.Errorf("failed to armor: %w", err)
+	}
+	return b.String(), nil
+}
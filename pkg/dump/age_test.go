@@ -0,0 +1,51 @@
+package dump
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+func TestSuiteAgeEncrypt(tt *testing.T) {
+	team, err := age.GenerateX25519Identity()
+	if err != nil {
+		tt.Fatal(err)
+	}
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		tt.Fatal(err)
+	}
+	var (
+		tests = []struct {
+			description string
+			recipient   string
+			identity    *age.X25519Identity
+			normOutput  string
+		}{
+			{"Team decrypts", team.Recipient().String(), team, `{"secret/team/a":{"k":"v"}}`},
+			{"Other team can not decrypt", team.Recipient().String(), other, "error"},
+			{"Invalid recipient", "age1xyz", team, "error"},
+		}
+	)
+	for _, test := range tests {
+		norm := "error"
+		if ciphertext, err := AgeEncrypt([]byte(`{"secret/team/a":{"k":"v"}}`), test.recipient); err == nil {
+			if strings.Contains(ciphertext, "secret/team") {
+				tt.Errorf("FAIL %s: plaintext left in %s", test.description, ciphertext)
+			}
+			if r, err := age.Decrypt(armor.NewReader(strings.NewReader(ciphertext)), test.identity); err == nil {
+				if b, err := ioutil.ReadAll(r); err == nil {
+					norm = string(b)
+				}
+			}
+		}
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package dump
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+)
+
+const (
+	// ChangeAdded is a secret that did not exist in the previous dump
+	ChangeAdded = "added"
+	// ChangeUpdated is a secret whose contents or version changed
+	ChangeUpdated = "updated"
+	// ChangeDeleted is a secret that no longer exists
+	ChangeDeleted = "deleted"
+)
+
+// SecretState is what is compared between dumps to find changes, a hash of
+// the secret and its KV v2 version, never its value. Secrets that failed to
+// dump have an unknown state.
+type SecretState struct {
+	Hash    string
+	Version int
+	Unknown bool
+}
+
+// Change is an entry of the change feed, versions are omitted for secrets
+// without them
+type Change struct {
+	Path       string `json:"path"`
+	Type       string `json:"type"`
+	OldVersion int    `json:"old_version,omitempty"`
+	NewVersion int    `json:"new_version,omitempty"`
+}
+
+// hashSecret returns the hash of a secret as it is encoded in the dump
+func hashSecret(secret interface{}) string {
+	b, err := json.Marshal(secret)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Diff returns the changes from old to new sorted by path, and the state to
+// compare the next dump against, in which secrets with an unknown state keep
+// their old state so a failed read is not reported as a deletion
+func Diff(old, new map[string]SecretState) ([]Change, map[string]SecretState) {
+	changes := []Change{}
+	next := make(map[string]SecretState, len(new))
+	for path, n := range new {
+		o, existed := old[path]
+		if n.Unknown {
+			if existed {
+				next[path] = o
+			}
+			continue
+		}
+		next[path] = n
+		switch {
+		case !existed:
+			changes = append(changes, Change{Path: path, Type: ChangeAdded, NewVersion: n.Version})
+		case o.Hash != n.Hash || o.Version != n.Version:
+			changes = append(changes, Change{Path: path, Type: ChangeUpdated, OldVersion: o.Version, NewVersion: n.Version})
+		}
+	}
+	for path, o := range old {
+		if _, ok := new[path]; !ok {
+			changes = append(changes, Change{Path: path, Type: ChangeDeleted, OldVersion: o.Version})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, next
+}
@@ -0,0 +1,50 @@
+package dump
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSuiteDiff(tt *testing.T) {
+	old := map[string]SecretState{
+		"secret/a": {Hash: "a", Version: 1},
+		"secret/b": {Hash: "b", Version: 1},
+		"secret/c": {Hash: "c"},
+		"secret/d": {Hash: "d", Version: 3},
+	}
+	new := map[string]SecretState{
+		"secret/a": {Hash: "a", Version: 1},
+		"secret/b": {Hash: "b2", Version: 2},
+		"secret/d": {Unknown: true},
+		"secret/e": {Hash: "e", Version: 1},
+	}
+
+	var (
+		tests = []struct {
+			description string
+			action      string
+			normOutput  string
+		}{
+			{"Changes", "Changes", "secret/b updated 1 2,secret/c deleted 0 0,secret/e added 0 1"},
+			{"Unknown keeps old state", "Next", "secret/d 3"},
+		}
+	)
+	changes, next := Diff(old, new)
+	for _, test := range tests {
+		out := []string{}
+		switch test.action {
+		case "Changes":
+			for _, c := range changes {
+				out = append(out, fmt.Sprintf("%s %s %d %d", c.Path, c.Type, c.OldVersion, c.NewVersion))
+			}
+		case "Next":
+			out = append(out, fmt.Sprintf("secret/d %d", next["secret/d"].Version))
+		}
+		if norm := strings.Join(out, ","); norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
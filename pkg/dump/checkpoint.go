@@ -0,0 +1,220 @@
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// checkpointVersion is the format version of checkpoint files
+const checkpointVersion = 1
+
+// checkpointHeader is the first line of a checkpoint file, identifying the
+// dump it belongs to
+type checkpointHeader struct {
+	Version      int            `json:"version"`
+	Created      string         `json:"created"`
+	InputPath    string         `json:"input_path"`
+	Namespace    string         `json:"namespace,omitempty"`
+	Namespaces   []string       `json:"namespaces,omitempty"`
+	Deleted      string         `json:"deleted"`
+	MetadataOnly bool           `json:"metadata_only,omitempty"`
+	KeysOnly     bool           `json:"keys_only,omitempty"`
+	Cluster      *vault.Cluster `json:"cluster,omitempty"`
+}
+
+// checkpointEntry is a line of a checkpoint file recording a path that was
+// read, its secret or the tombstone recorded in its place
+type checkpointEntry struct {
+	Namespace string              `json:"namespace,omitempty"`
+	Path      string              `json:"path"`
+	Data      interface{}         `json:"data,omitempty"`
+	Version   int                 `json:"version,omitempty"`
+	Updated   string              `json:"updated,omitempty"`
+	Tombstone *vault.VersionState `json:"tombstone,omitempty"`
+}
+
+// checkpoint records the paths of a dump as they are read to a file, one
+// JSON line each, so that a failed or interrupted dump can be resumed without
+// reading them again. Failed paths are not recorded and are read again.
+type checkpoint struct {
+	path    string
+	file    *os.File
+	entries map[[2]string]checkpointEntry
+	mu      sync.Mutex
+	err     error
+}
+
+// openCheckpoint creates the checkpoint file at path for the dump described
+// by header, or with resume reads the paths recorded by an earlier dump of
+// the same paths and keeps recording to it
+func openCheckpoint(path string, resume bool, header checkpointHeader) (*checkpoint, error) {
+	cp := &checkpoint{path: path, entries: make(map[[2]string]checkpointEntry)}
+	if !resume {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("checkpoint %s exists, resume it or remove it", path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		cp.file = f
+		header.Version = checkpointVersion
+		header.Created = time.Now().UTC().Format(time.RFC3339)
+		if err := cp.writeLine(header); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return cp, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	cp.file = f
+	recorded, end, err := cp.read()
+	if err == nil {
+		err = header.resumes(recorded)
+	}
+	// a line cut short by a crash is dropped and written again
+	if err == nil {
+		err = f.Truncate(end)
+	}
+	if err == nil {
+		_, err = f.Seek(end, 0)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to resume checkpoint %s: %w", path, err)
+	}
+	log.Printf("Resuming the dump of %s from %s, %d secrets were read already\n", recorded.InputPath, path, len(cp.entries))
+	return cp, nil
+}
+
+// read reads the header and entries of the checkpoint file and returns the
+// offset after the last complete line
+func (cp *checkpoint) read() (checkpointHeader, int64, error) {
+	var (
+		header checkpointHeader
+		end    int64
+	)
+	r := bufio.NewReader(cp.file)
+	for first := true; ; first = false {
+		line, err := r.ReadBytes('\n')
+		if err != nil && len(line) > 0 {
+			log.Printf("Warning: dropping the incomplete last line of checkpoint %s\n", cp.path)
+		}
+		if err != nil {
+			break
+		}
+		end += int64(len(line))
+		d := json.NewDecoder(bytes.NewReader(line))
+		d.UseNumber()
+		if first {
+			if err := d.Decode(&header); err != nil {
+				return header, 0, fmt.Errorf("not a checkpoint: %w", err)
+			}
+			continue
+		}
+		var e checkpointEntry
+		if err := d.Decode(&e); err != nil {
+			return header, 0, err
+		}
+		cp.entries[[2]string{e.Namespace, e.Path}] = e
+	}
+	if header.Version == 0 {
+		return header, 0, errors.New("not a checkpoint")
+	}
+	if header.Version > checkpointVersion {
+		return header, 0, fmt.Errorf("unsupported checkpoint version %d", header.Version)
+	}
+	return header, end, nil
+}
+
+// resumes returns why the dump described by h can not resume the dump
+// recorded in the checkpoint, nil when it can
+func (h checkpointHeader) resumes(recorded checkpointHeader) error {
+	switch {
+	case h.InputPath != recorded.InputPath:
+		return fmt.Errorf("it records a dump of %s, not %s", recorded.InputPath, h.InputPath)
+	case h.Namespace != recorded.Namespace || strings.Join(h.Namespaces, ",") != strings.Join(recorded.Namespaces, ","):
+		return errors.New("it records a dump of different namespaces")
+	case h.Deleted != recorded.Deleted || h.MetadataOnly != recorded.MetadataOnly || h.KeysOnly != recorded.KeysOnly:
+		return errors.New("it records a dump with different options")
+	case h.Cluster != nil && recorded.Cluster != nil && !recorded.Cluster.Same(*h.Cluster):
+		return fmt.Errorf("it records a dump of cluster %s, not %s", *recorded.Cluster, *h.Cluster)
+	}
+	return nil
+}
+
+// lookup returns what was recorded for path in the namespace ns
+func (cp *checkpoint) lookup(ns, path string) (checkpointEntry, bool) {
+	if cp == nil {
+		return checkpointEntry{}, false
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	e, ok := cp.entries[[2]string{ns, path}]
+	return e, ok
+}
+
+// record appends e to the checkpoint file, a failed write is logged once and
+// stops recording but not the dump
+func (cp *checkpoint) record(e checkpointEntry) {
+	if cp == nil {
+		return
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	key := [2]string{e.Namespace, e.Path}
+	if _, ok := cp.entries[key]; ok || cp.err != nil {
+		return
+	}
+	if err := cp.writeLine(e); err != nil {
+		cp.err = err
+		log.Printf("Warning: failed to write checkpoint %s, %s\n", cp.path, err.Error())
+		return
+	}
+	cp.entries[key] = e
+}
+
+// writeLine appends v as a line of JSON
+func (cp *checkpoint) writeLine(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = cp.file.Write(append(b, '\n'))
+	return err
+}
+
+// close closes the checkpoint file and keeps it to be resumed
+func (cp *checkpoint) close() error {
+	if cp == nil {
+		return nil
+	}
+	if err := cp.file.Close(); err != nil {
+		return err
+	}
+	log.Printf("Checkpoint %s kept to resume the dump from\n", cp.path)
+	return nil
+}
+
+// remove closes and removes the checkpoint file once the dump is written
+func (cp *checkpoint) remove() error {
+	if cp == nil {
+		return nil
+	}
+	cp.file.Close()
+	return os.Remove(cp.path)
+}
@@ -0,0 +1,93 @@
+package dump
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+func TestSuiteCheckpoint(tt *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint-*")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dump.checkpoint")
+	header := checkpointHeader{InputPath: "kv/app", Deleted: DeletedTombstone, Cluster: &vault.Cluster{ID: "a"}}
+
+	cp, err := openCheckpoint(path, false, header)
+	if err != nil {
+		tt.Fatal(err)
+	}
+	cp.record(checkpointEntry{Path: "kv/data/app/a", Data: map[string]interface{}{"value": "1"}, Version: 2})
+	cp.record(checkpointEntry{Path: "kv/data/app/b", Tombstone: &vault.VersionState{Version: 3}})
+	cp.record(checkpointEntry{Namespace: "team", Path: "kv/data/app/a", Data: map[string]interface{}{"value": "2"}})
+	if err := cp.close(); err != nil {
+		tt.Fatal(err)
+	}
+	// a line cut short by a crash
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		tt.Fatal(err)
+	}
+	f.WriteString(`{"path": "kv/data/app/c", "da`)
+	f.Close()
+
+	var (
+		tests = []struct {
+			description string
+			header      checkpointHeader
+			normOutput  string
+		}{
+			{"Same dump", header, "3 kv/data/app/a=map[value:1] v2, kv/data/app/b tombstone 3, team kv/data/app/a=map[value:2]"},
+			{"Other path", checkpointHeader{InputPath: "kv/other", Deleted: DeletedTombstone}, "failed to resume checkpoint " + path + ": it records a dump of kv/app, not kv/other"},
+			{"Other options", checkpointHeader{InputPath: "kv/app", Deleted: DeletedSkip}, "failed to resume checkpoint " + path + ": it records a dump with different options"},
+			{"Other cluster", checkpointHeader{InputPath: "kv/app", Deleted: DeletedTombstone, Cluster: &vault.Cluster{ID: "b"}}, "failed to resume checkpoint " + path + ": it records a dump of cluster  (a), not  (b)"},
+			{"Cluster unknown", checkpointHeader{InputPath: "kv/app", Deleted: DeletedTombstone}, "3 kv/data/app/a=map[value:1] v2, kv/data/app/b tombstone 3, team kv/data/app/a=map[value:2]"},
+		}
+	)
+	for _, test := range tests {
+		var norm string
+		cp, err := openCheckpoint(path, true, test.header)
+		if err != nil {
+			norm = err.Error()
+		} else {
+			a, _ := cp.lookup("", "kv/data/app/a")
+			b, _ := cp.lookup("", "kv/data/app/b")
+			team, _ := cp.lookup("team", "kv/data/app/a")
+			_, c := cp.lookup("", "kv/data/app/c")
+			norm = fmt.Sprintf("%d %s=%v v%d, %s tombstone %d, %s %s=%v", len(cp.entries), a.Path, a.Data, a.Version, b.Path, b.Tombstone.Version, team.Namespace, team.Path, team.Data)
+			if c {
+				norm += ", kv/data/app/c"
+			}
+			cp.close()
+		}
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+
+	if _, err := openCheckpoint(path, false, header); err == nil {
+		tt.Errorf("FAIL Existing checkpoint: expected an error got none")
+	} else {
+		tt.Logf("PASS Existing checkpoint")
+	}
+	cp, err = openCheckpoint(path, true, header)
+	if err != nil {
+		tt.Fatal(err)
+	}
+	if err := cp.remove(); err != nil {
+		tt.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		tt.Errorf("FAIL Removed: expected the checkpoint to be removed got '%v'", err)
+	} else {
+		tt.Logf("PASS Removed")
+	}
+}
@@ -0,0 +1,71 @@
+package dump
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// DefaultClassificationKey is the custom metadata key holding the
+// classification of a secret when the policy names none
+const DefaultClassificationKey = "classification"
+
+// ClassPolicy restricts the destinations secrets are dumped to by their
+// classification, the value of Key in their KV version 2 custom metadata.
+// Secrets without a classification, or with one the policy does not list,
+// are dumped anywhere.
+type ClassPolicy struct {
+	Key string `json:"key"`
+	// Classes holds, per classification, the destinations its secrets may
+	// be dumped to. A destination allows itself and everything below it,
+	// a classification without destinations is never dumped.
+	Classes map[string][]string `json:"classes"`
+}
+
+// ReadClassPolicy reads a JSON or YAML classification policy from fp
+func ReadClassPolicy(fp string) (*ClassPolicy, error) {
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	p := &ClassPolicy{}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("invalid classification policy %s: %w", fp, err)
+	}
+	if len(p.Classes) == 0 {
+		return nil, fmt.Errorf("invalid classification policy %s: it lists no classes", fp)
+	}
+	for class, destinations := range p.Classes {
+		for _, d := range destinations {
+			if strings.TrimSpace(d) == "" {
+				return nil, fmt.Errorf("invalid classification policy %s: class %s has an empty destination", fp, class)
+			}
+		}
+	}
+	if p.Key == "" {
+		p.Key = DefaultClassificationKey
+	}
+	return p, nil
+}
+
+// Allowed reports whether the secret with the custom metadata custom may be
+// dumped to destination, along with its classification
+func (p *ClassPolicy) Allowed(custom map[string]string, destination string) (string, bool) {
+	if p == nil {
+		return "", true
+	}
+	class := custom[p.Key]
+	allowed, listed := p.Classes[class]
+	if class == "" || !listed {
+		return class, true
+	}
+	for _, d := range allowed {
+		d = strings.TrimSuffix(d, "/")
+		if destination == d || strings.HasPrefix(destination, d+"/") {
+			return class, true
+		}
+	}
+	return class, false
+}
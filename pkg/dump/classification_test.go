@@ -0,0 +1,85 @@
+package dump
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestSuiteClassPolicy(tt *testing.T) {
+	policy := &ClassPolicy{
+		Key: DefaultClassificationKey,
+		Classes: map[string][]string{
+			"restricted": {"s3://secure-dumps/", "/var/backups/restricted"},
+			"secret":     {},
+		},
+	}
+	var (
+		tests = []struct {
+			description string
+			policy      *ClassPolicy
+			custom      map[string]string
+			destination string
+			normOutput  string
+		}{
+			{"No policy", nil, map[string]string{"classification": "secret"}, "stdout", ",true"},
+			{"Unclassified", policy, nil, "stdout", ",true"},
+			{"Unlisted class", policy, map[string]string{"classification": "internal"}, "stdout", "internal,true"},
+			{"Allowed bucket", policy, map[string]string{"classification": "restricted"}, "s3://secure-dumps/prod", "restricted,true"},
+			{"Allowed directory", policy, map[string]string{"classification": "restricted"}, "/var/backups/restricted", "restricted,true"},
+			{"Other bucket", policy, map[string]string{"classification": "restricted"}, "s3://secure-dumps-public/prod", "restricted,false"},
+			{"Stdout", policy, map[string]string{"classification": "restricted"}, "stdout", "restricted,false"},
+			{"Refused everywhere", policy, map[string]string{"classification": "secret"}, "s3://secure-dumps/prod", "secret,false"},
+			{"Other key", policy, map[string]string{"level": "secret"}, "stdout", ",true"},
+		}
+	)
+	for _, test := range tests {
+		class, ok := test.policy.Allowed(test.custom, test.destination)
+		norm := fmt.Sprintf("%s,%v", class, ok)
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteReadClassPolicy(tt *testing.T) {
+	dir := tt.TempDir()
+	var (
+		tests = []struct {
+			description string
+			content     string
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"YAML", "classes:\n  restricted: [\"s3://secure-dumps\"]\n  secret: []\n", "classification map[restricted:[s3://secure-dumps] secret:[]]", true},
+			{"JSON with key", `{"key": "level", "classes": {"restricted": ["stdout"]}}`, "level map[restricted:[stdout]]", true},
+			{"No classes", "key: level\n", "", false},
+			{"Empty destination", "classes:\n  restricted: [\"\"]\n", "", false},
+			{"Invalid", "classes: [", "", false},
+		}
+	)
+	for i, test := range tests {
+		fp := filepath.Join(dir, fmt.Sprintf("policy-%d.yaml", i))
+		if err := ioutil.WriteFile(fp, []byte(test.content), 0600); err != nil {
+			tt.Fatal(err)
+		}
+		p, err := ReadClassPolicy(fp)
+		if (err == nil) != test.isSuccess {
+			tt.Errorf("FAIL %s: expected success %v got %v", test.description, test.isSuccess, err)
+			continue
+		}
+		if err != nil {
+			tt.Logf("PASS %s", test.description)
+			continue
+		}
+		norm := fmt.Sprintf("%s %v", p.Key, p.Classes)
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package dump
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// deltaVersion is the format version of delta manifests
+	deltaVersion = 1
+	// DeltaExt is the extension of delta manifests, before their encryption
+	DeltaExt = "delta.json"
+)
+
+// DeltaManifest lists the object holding each file of a dump uploaded as a
+// delta. Files that did not change since the previous upload are not
+// uploaded again and reference the object of that upload instead.
+type DeltaManifest struct {
+	Version int    `json:"version"`
+	Created string `json:"created"`
+	// Files holds the object of each file by the name of its group, empty
+	// for the main file
+	Files map[string]DeltaFile `json:"files"`
+}
+
+// DeltaFile is an uploaded file of a dump, Checksum covers its content
+// except when its manifest was created
+type DeltaFile struct {
+	Object   string `json:"object"`
+	Checksum string `json:"checksum"`
+	Encoding string `json:"encoding"`
+	KMSKey   string `json:"kms_key"`
+	// Indexed means an index was uploaded next to the object
+	Indexed bool `json:"indexed,omitempty"`
+}
+
+// NewDeltaManifest returns an empty delta manifest
+func NewDeltaManifest() *DeltaManifest {
+	return &DeltaManifest{
+		Version: deltaVersion,
+		Created: time.Now().UTC().Format(time.RFC3339),
+		Files:   make(map[string]DeltaFile),
+	}
+}
+
+// ParseDeltaManifest parses a delta manifest written by an earlier upload
+func ParseDeltaManifest(data []byte) (*DeltaManifest, error) {
+	m := &DeltaManifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid delta manifest: %w", err)
+	}
+	if m.Version > deltaVersion {
+		return nil, fmt.Errorf("unsupported delta manifest version %d", m.Version)
+	}
+	return m, nil
+}
+
+// Unchanged returns the object the previous upload holds the file of group
+// in when it matches f, it must be uploaded again otherwise. A nil manifest
+// holds nothing.
+func (m *DeltaManifest) Unchanged(group string, f DeltaFile) (string, bool) {
+	if m == nil || f.Checksum == "" {
+		return "", false
+	}
+	previous, ok := m.Files[group]
+	if !ok || previous.Object == "" {
+		return "", false
+	}
+	if previous.Checksum != f.Checksum || previous.Encoding != f.Encoding || previous.KMSKey != f.KMSKey {
+		return "", false
+	}
+	if f.Indexed && !previous.Indexed {
+		return "", false
+	}
+	return previous.Object, true
+}
+
+// Checksums returns the checksum of each file written by the name of its
+// group, empty for the main file, see DeltaManifest
+func (c *Config) Checksums() map[string]string {
+	return c.checksums
+}
+
+// contentChecksum returns the checksum of a file of the dump, data with its
+// manifest, leaving out when the manifest was created so that files whose
+// secrets and manifest did not change have the same checksum
+func contentChecksum(data map[string]interface{}) string {
+	content := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		content[k] = v
+	}
+	if m, ok := data[ManifestKey].(map[string]interface{}); ok {
+		stripped := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			stripped[k] = v
+		}
+		delete(stripped, "created")
+		content[ManifestKey] = stripped
+	}
+	return hashSecret(content)
+}
@@ -0,0 +1,78 @@
+package dump
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSuiteDeltaManifest(tt *testing.T) {
+	previous, err := ParseDeltaManifest([]byte(`{"version":1,"created":"2020-01-01T00:00:00Z","files":{"":{"object":"s3://b/p/d-1.json.aes","checksum":"a","encoding":"json","kms_key":"k"},"team":{"object":"s3://b/p/d-1.team.json.aes","checksum":"b","encoding":"json","kms_key":"k","indexed":true}}}`))
+	if err != nil {
+		tt.Fatal(err)
+	}
+
+	var (
+		tests = []struct {
+			description string
+			manifest    *DeltaManifest
+			group       string
+			file        DeltaFile
+			normOutput  string
+		}{
+			{"Unchanged", previous, "", DeltaFile{Checksum: "a", Encoding: "json", KMSKey: "k"}, "s3://b/p/d-1.json.aes,true"},
+			{"Unchanged indexed", previous, "team", DeltaFile{Checksum: "b", Encoding: "json", KMSKey: "k", Indexed: true}, "s3://b/p/d-1.team.json.aes,true"},
+			{"Changed checksum", previous, "", DeltaFile{Checksum: "c", Encoding: "json", KMSKey: "k"}, ",false"},
+			{"Changed encoding", previous, "", DeltaFile{Checksum: "a", Encoding: "yaml", KMSKey: "k"}, ",false"},
+			{"Changed key", previous, "", DeltaFile{Checksum: "a", Encoding: "json", KMSKey: "other"}, ",false"},
+			{"Index missing", previous, "", DeltaFile{Checksum: "a", Encoding: "json", KMSKey: "k", Indexed: true}, ",false"},
+			{"New group", previous, "other", DeltaFile{Checksum: "a", Encoding: "json", KMSKey: "k"}, ",false"},
+			{"No previous upload", nil, "", DeltaFile{Checksum: "a", Encoding: "json", KMSKey: "k"}, ",false"},
+		}
+	)
+	for _, test := range tests {
+		object, ok := test.manifest.Unchanged(test.group, test.file)
+		if norm := fmt.Sprintf("%s,%t", object, ok); norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+	if _, err := ParseDeltaManifest([]byte(`{"version":2}`)); err == nil {
+		tt.Errorf("FAIL Newer version: expected an error")
+	}
+}
+
+func TestSuiteContentChecksum(tt *testing.T) {
+	base := contentChecksum(map[string]interface{}{
+		ManifestKey:  map[string]interface{}{"version": 1, "created": "2020-01-01T00:00:00Z", "secrets": 1},
+		"secret/a/b": map[string]interface{}{"k": "v"},
+	})
+
+	var (
+		tests = []struct {
+			description string
+			data        map[string]interface{}
+			isSame      bool
+		}{
+			{"Created later", map[string]interface{}{
+				ManifestKey:  map[string]interface{}{"version": 1, "created": "2020-01-02T00:00:00Z", "secrets": 1},
+				"secret/a/b": map[string]interface{}{"k": "v"},
+			}, true},
+			{"Changed value", map[string]interface{}{
+				ManifestKey:  map[string]interface{}{"version": 1, "created": "2020-01-01T00:00:00Z", "secrets": 1},
+				"secret/a/b": map[string]interface{}{"k": "w"},
+			}, false},
+			{"Changed manifest", map[string]interface{}{
+				ManifestKey:  map[string]interface{}{"version": 1, "created": "2020-01-01T00:00:00Z", "secrets": 1, "partial": true},
+				"secret/a/b": map[string]interface{}{"k": "v"},
+			}, false},
+		}
+	)
+	for _, test := range tests {
+		if same := contentChecksum(test.data) == base; same != test.isSame {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSame, same)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
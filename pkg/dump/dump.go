@@ -6,58 +6,626 @@ package dump
 // like it does not if your token is not granted access to see it
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"path"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/dathan/go-vault-dump/pkg/file"
+	"github.com/dathan/go-vault-dump/pkg/guard"
 	"github.com/dathan/go-vault-dump/pkg/print"
 	"github.com/dathan/go-vault-dump/pkg/vault"
 )
 
+// ErrInterrupted is returned by a dump stopped by its context
+var ErrInterrupted = errors.New("dump interrupted")
+
 // Config
 type Config struct {
 	Debug       bool
 	InputPath   string
 	Filename    string
+	Groups      []Group
 	Output      *output
 	VaultConfig *vault.Config
+	// MaxValueSize skips values larger than this many bytes
+	MaxValueSize int
+	// ExternalizeSize writes values larger than this many bytes to separate files
+	ExternalizeSize int
+	// RelativePaths writes paths relative to InputPath instead of including
+	// the mount, InputPath must be a single path
+	RelativePaths bool
+	// Deleted is how secrets whose latest version is deleted are dumped
+	Deleted string
+	// Publish receives one encoded message per escaped path for kafka output
+	Publish func(messages map[string]string) error
+	// Stream receives the encoded dump for stdout output instead of printing
+	// it, to encrypt it on the way out
+	Stream func(data []byte) error
+	// Processors rewrite every secret in order before it is encoded
+	Processors []Processor
+	// Namespaces are dumped each below its path, relative to the namespace
+	// of VaultConfig, "" dumps the namespace of VaultConfig itself
+	Namespaces []string
+	// YAML writes yaml output with these options, nil writes it as before
+	YAML *print.YAMLOptions
+	// JSON writes json output with these options, nil writes it compact
+	JSON *print.JSONOptions
+	// Concurrency is the most requests to Vault in flight while listing and
+	// reading secrets, the number of CPUs when 0
+	Concurrency int
+	// MetadataOnly dumps the metadata of each secret instead of its values,
+	// an inventory that can not be imported
+	MetadataOnly bool
+	// KeysOnly dumps the key names of each secret without their values, the
+	// structure of the secrets, which can not be imported
+	KeysOnly bool
+	// Context stops the dump once it is done, nothing is written then but
+	// with Partial
+	Context context.Context
+	// Partial writes what was read before Context was done as partial
+	// output, marked in its file name and manifest
+	Partial bool
+	// Checkpoint is a file recording the secrets as they are read, removed
+	// once the dump is written, see checkpoint
+	Checkpoint string
+	// Resume continues the dump recorded in Checkpoint, reading only the
+	// secrets it does not hold
+	Resume bool
+	// Incremental reads the KV version 2 metadata of each secret first, and
+	// takes the secrets whose metadata matches Since from Previous, the
+	// secrets of the previous dump by data path, instead of reading them
+	Incremental bool
+	Since       *IncrementalState
+	Previous    map[string]interface{}
+	// Versions is how many versions of each KV version 2 secret are dumped,
+	// VersionsAll for every version retained. The earlier versions are
+	// recorded in the manifest, see Version.
+	Versions int
+	// IncludeMetadata records the KV version 2 metadata of each secret in
+	// the manifest, its custom metadata, settings and version states
+	IncludeMetadata bool
+	// Filter selects the secrets dumped, nil dumps every secret
+	Filter *Filter
+	// MaxDepth is how many levels below InputPath are listed, 1 for the
+	// secrets directly below it, 0 for no limit
+	MaxDepth int
+	// Policy refuses the secrets whose classification may not be dumped to
+	// Destination, where the output goes, see ClassPolicy
+	Policy      *ClassPolicy
+	Destination string
+	// Deny holds the secrets never dumped, see guard.DenyPolicy
+	Deny *guard.DenyPolicy
+	// DryRun lists and selects the secrets as usual but reads none of their
+	// values and writes nothing, what would be dumped is left in Plan
+	DryRun bool
+	// ModifiedSince only dumps the KV version 2 secrets whose metadata was
+	// updated after it, a delta of the secrets changed since, the zero time
+	// dumps every secret
+	ModifiedSince time.Time
+	// EncryptValues encrypts each value of file output, and with
+	// EncryptNames each key name, with a data key from DataKey per file,
+	// leaving the paths readable, see ValueEncryption
+	EncryptValues bool
+	EncryptNames  bool
+	DataKey       func(group string) (*DataKey, error)
+
+	// cluster identifies the cluster being dumped, nil when unknown
+	cluster *vault.Cluster
+	// root is the escaped data path of InputPath, recorded in the manifest
+	root string
+	// failed holds why each escaped path could not be dumped, it is reported
+	// in the manifest of the artifact the path belongs to
+	failed map[string]Failure
+	// discovered is the number of secrets dumped
+	discovered int
+	// state holds the state of each escaped path for the change feed
+	state map[string]SecretState
+	// tombstones holds the deleted versions per escaped path
+	tombstones map[string]vault.VersionState
+	// history holds the encoded earlier versions per escaped path
+	history map[string][]Version
+	// kvMetadata holds the KV version 2 metadata per escaped path
+	kvMetadata map[string]*vault.SecretMetadata
+	// checksums holds the checksum of each file written by group
+	checksums map[string]string
+	// encryption holds how the values of each file were encrypted by group
+	encryption map[string]*ValueEncryption
+	// skipped lists the oversized values dropped per escaped path, they are
+	// reported in the manifest of the artifact holding the path
+	skipped map[string][]string
+	// scraped holds what was read of every path, Refresh replaces the paths
+	// it reads again
+	scraped *SecretScraper
+	// prefix is the data path of InputPath that RelativePaths trims
+	prefix string
+	// partial is set while partial output is written
+	partial bool
+	// plan is what a dry run found
+	plan *Plan
 }
 
 func New(c *Config) (*Config, error) {
+	deleted := c.Deleted
+	switch deleted {
+	case "":
+		deleted = DeletedSkip
+	case DeletedSkip, DeletedPrevious, DeletedTombstone:
+	default:
+		return nil, fmt.Errorf("invalid deleted secret handling %q, expected %s, %s or %s", c.Deleted, DeletedSkip, DeletedPrevious, DeletedTombstone)
+	}
+	concurrency := c.Concurrency
+	switch {
+	case concurrency < 0:
+		return nil, fmt.Errorf("invalid concurrency %d", c.Concurrency)
+	case concurrency == 0:
+		concurrency = runtime.NumCPU()
+	}
+	if c.MetadataOnly && (len(c.Processors) > 0 || c.ExternalizeSize > 0 || c.MaxValueSize > 0) {
+		return nil, errors.New("a metadata only dump holds no values to post-process, externalize or limit")
+	}
+	if c.KeysOnly && (c.MetadataOnly || len(c.Processors) > 0 || c.ExternalizeSize > 0 || c.MaxValueSize > 0) {
+		return nil, errors.New("a keys only dump holds no values to inventory, post-process, externalize or limit")
+	}
+	// post-processors are stopped along with the dump
+	if c.Partial && len(c.Processors) > 0 {
+		return nil, errors.New("partial output can not be post-processed")
+	}
+	if c.Resume && c.Checkpoint == "" {
+		return nil, errors.New("resuming a dump requires its checkpoint")
+	}
+	// the secrets taken from the previous dump were written as they are
+	// then, they can not be processed or limited again
+	if c.Incremental && (c.MetadataOnly || c.KeysOnly || len(c.Processors) > 0 || c.MaxValueSize > 0) {
+		return nil, errors.New("an incremental dump can not be an inventory, keys only, post-processed or limit values")
+	}
+	if c.MaxDepth < 0 {
+		return nil, fmt.Errorf("invalid depth %d", c.MaxDepth)
+	}
+	if c.DryRun && (c.Checkpoint != "" || c.Incremental || c.Partial) {
+		return nil, errors.New("a dry run can not be checkpointed, incremental or partial")
+	}
+	if c.Policy != nil && c.Destination == "" {
+		return nil, errors.New("a classification policy requires the destination of the dump")
+	}
+	// the secrets left out would be taken from the previous dump
+	if c.Incremental && !c.ModifiedSince.IsZero() {
+		return nil, errors.New("an incremental dump already reads only the secrets changed, it can not be limited to those modified since a time")
+	}
+	versions := c.Versions
+	switch {
+	case versions == 0:
+		versions = VersionsLatest
+	case versions < VersionsAll:
+		return nil, fmt.Errorf("invalid number of versions %d", c.Versions)
+	}
+	// earlier versions are read along with the latest and kept in the
+	// manifest, where nothing else happens to their values
+	if versions != VersionsLatest && (c.MetadataOnly || c.KeysOnly || len(c.Processors) > 0 || c.MaxValueSize > 0 || c.ExternalizeSize > 0) {
+		return nil, errors.New("a dump of earlier versions can not be an inventory, keys only, post-processed, externalize or limit values")
+	}
+	if versions != VersionsLatest && (c.Incremental || c.Checkpoint != "") {
+		return nil, errors.New("a dump of earlier versions can not be incremental or checkpointed")
+	}
+	// the metadata is read along with each secret, those taken from a
+	// previous dump or checkpoint have none
+	if c.IncludeMetadata && c.MetadataOnly {
+		return nil, errors.New("an inventory holds the metadata of each secret already")
+	}
+	if c.IncludeMetadata && (c.Incremental || c.Checkpoint != "") {
+		return nil, errors.New("a dump including metadata can not be incremental or checkpointed")
+	}
+	if c.EncryptNames && !c.EncryptValues {
+		return nil, errors.New("key names are only encrypted along with the values")
+	}
+	// externalized values and earlier versions are written outside of the
+	// secrets encrypted, a previous dump would be read back encrypted
+	if c.EncryptValues && (c.DataKey == nil || c.Output == nil || c.Output.GetKind() != "file") {
+		return nil, errors.New("values are only encrypted in file output, with a data key")
+	}
+	if c.EncryptValues && (c.MetadataOnly || c.KeysOnly || c.ExternalizeSize > 0 || versions != VersionsLatest || c.Incremental) {
+		return nil, errors.New("values can not be encrypted in an inventory, keys only, externalized, of earlier versions or incremental")
+	}
+
 	return &Config{
 		Debug:       c.Debug,
 		InputPath:   c.InputPath,
 		Filename:    c.Filename,
+		Groups:      c.Groups,
 		Output:      c.Output,
 		VaultConfig: c.VaultConfig,
+
+		MaxValueSize:    c.MaxValueSize,
+		ExternalizeSize: c.ExternalizeSize,
+		RelativePaths:   c.RelativePaths,
+		Deleted:         deleted,
+		Publish:         c.Publish,
+		Stream:          c.Stream,
+		Processors:      c.Processors,
+		Namespaces:      c.Namespaces,
+		YAML:            c.YAML,
+		JSON:            c.JSON,
+		Concurrency:     concurrency,
+		MetadataOnly:    c.MetadataOnly,
+		KeysOnly:        c.KeysOnly,
+		Context:         c.Context,
+		Partial:         c.Partial,
+		Checkpoint:      c.Checkpoint,
+		Resume:          c.Resume,
+		Incremental:     c.Incremental,
+		Since:           c.Since,
+		Previous:        c.Previous,
+		Versions:        versions,
+		IncludeMetadata: c.IncludeMetadata,
+		Filter:          c.Filter,
+		MaxDepth:        c.MaxDepth,
+		Policy:          c.Policy,
+		Destination:     c.Destination,
+		Deny:            c.Deny,
+		DryRun:          c.DryRun,
+		ModifiedSince:   c.ModifiedSince,
+		EncryptValues:   c.EncryptValues,
+		EncryptNames:    c.EncryptNames,
+		DataKey:         c.DataKey,
 	}, nil
 }
 
 func (c *Config) Secrets() error {
-	secretScraper, err := NewSecretScraper(c.VaultConfig)
+	if c.RelativePaths && strings.Contains(c.InputPath, ",") {
+		return fmt.Errorf("relative paths require a single path, got %s", c.InputPath)
+	}
+	if c.RelativePaths && len(c.Namespaces) > 0 {
+		return errors.New("relative paths can not be used with namespaces")
+	}
+
+	if cluster, err := c.VaultConfig.Fingerprint(); err != nil {
+		log.Printf("Warning: could not identify the cluster, %s\n", err.Error())
+	} else {
+		c.cluster = &cluster
+	}
+
+	cp, err := c.openCheckpoint()
 	if err != nil {
 		return err
 	}
+	secretScraper, err := c.scrape(cp)
+	if err != nil {
+		cp.close()
+		return err
+	}
+	if c.context().Err() != nil {
+		cp.close()
+		return c.interrupted(secretScraper)
+	}
+	if c.DryRun {
+		c.plan, err = c.newPlan(secretScraper)
+		return err
+	}
+	if c.Incremental {
+		log.Printf("Took %d unchanged secrets from the previous dump, read %d\n", secretScraper.reused, len(secretScraper.Data)-secretScraper.reused)
+	}
+	secretScraper.process(c.Processors)
+	c.scraped = secretScraper
 
-	var wg sync.WaitGroup
+	if err := c.write(secretScraper); err != nil {
+		cp.close()
+		return err
+	}
+	if err := cp.remove(); err != nil {
+		log.Printf("Warning: failed to remove checkpoint %s, %s\n", c.Checkpoint, err.Error())
+	}
+	return nil
+}
 
-	secretScraper.Run(c.InputPath, &wg, runtime.NumCPU())
+// openCheckpoint opens Checkpoint for the dump, nil without one
+func (c *Config) openCheckpoint() (*checkpoint, error) {
+	if c.Checkpoint == "" {
+		return nil, nil
+	}
+	return openCheckpoint(c.Checkpoint, c.Resume, checkpointHeader{
+		InputPath:    c.InputPath,
+		Namespace:    c.VaultConfig.Namespace,
+		Namespaces:   c.Namespaces,
+		Deleted:      c.Deleted,
+		MetadataOnly: c.MetadataOnly,
+		KeysOnly:     c.KeysOnly,
+		Cluster:      c.cluster,
+	})
+}
+
+// context returns the context of the dump, one never done without Context
+func (c *Config) context() context.Context {
+	if c.Context == nil {
+		return context.Background()
+	}
+	return c.Context
+}
+
+// interrupted writes what secretScraper read before the dump was stopped as
+// partial output with Partial and returns ErrInterrupted
+func (c *Config) interrupted(secretScraper *SecretScraper) error {
+	if !c.Partial {
+		log.Println("Interrupted, nothing was written")
+		return ErrInterrupted
+	}
+	log.Printf("Interrupted, writing the %d secrets read so far as partial output\n", len(secretScraper.Data))
+	c.partial = true
+	if err := c.write(secretScraper); err != nil {
+		return err
+	}
+	return ErrInterrupted
+}
+
+// filename is the name of the output, marked when it is partial
+func (c *Config) filename() string {
+	if c.partial {
+		return c.Filename + ".partial"
+	}
+	return c.Filename
+}
+
+// Changed returns the data paths below InputPath of the secrets changed by
+// requests to paths, request paths as Vault's audit log records them
+func (c *Config) Changed(paths []string) ([]string, error) {
+	roots := []string{}
+	mounts := make(map[string]bool)
+	for _, p := range strings.Split(c.InputPath, ",") {
+		root, err := c.VaultConfig.ResolveMountPath(p, "data")
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+		mounts[strings.SplitN(root, "/", 2)[0]] = true
+	}
+	changed := []string{}
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		// other mounts are skipped before asking Vault about them
+		if !mounts[strings.SplitN(vault.NormalizePath(p), "/", 2)[0]] {
+			continue
+		}
+		resolved, err := c.VaultConfig.ResolveSecretPath(p)
+		if err != nil {
+			return nil, err
+		}
+		if seen[resolved] || !within(resolved, roots) {
+			continue
+		}
+		seen[resolved] = true
+		changed = append(changed, resolved)
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// Refresh reads the secrets changed by requests to paths again, see Changed,
+// and writes the output again with what was read of every other path before.
+// It returns the escaped paths read again.
+func (c *Config) Refresh(paths []string) ([]string, error) {
+	if c.scraped == nil {
+		return nil, errors.New("refresh requires the secrets to be dumped first")
+	}
+	if len(c.Namespaces) > 0 {
+		return nil, errors.New("a dump of several namespaces can not be refreshed")
+	}
+	changed, err := c.Changed(paths)
+	if err != nil {
+		return nil, err
+	}
+	if len(changed) == 0 {
+		return []string{}, nil
+	}
+	log.Printf("Reading %d changed secrets again\n", len(changed))
+
+	s, err := NewSecretScraper(c.VaultConfig)
+	if err != nil {
+		return nil, err
+	}
+	s.context = c.context()
+	s.Deleted = c.Deleted
+	s.MetadataOnly = c.MetadataOnly
+	s.KeysOnly = c.KeysOnly
+	s.versions = c.Versions
+	s.includeMetadata = c.IncludeMetadata
+	s.filter = c.Filter
+	s.policy, s.destination = c.Policy, c.Destination
+	s.Deny = c.Deny
+	s.modifiedSince = c.ModifiedSince
+	var wg sync.WaitGroup
+	s.Read(changed, &wg, c.Concurrency)
 	wg.Wait()
+	// what was read before then is dropped, the output stays as it was
+	if c.context().Err() != nil {
+		return nil, ErrInterrupted
+	}
+	s.process(c.Processors)
+	c.scraped.replace(changed, s)
+
+	if err := c.write(c.scraped); err != nil {
+		return nil, err
+	}
+	refreshed := make([]string, len(changed))
+	for i, p := range changed {
+		refreshed[i] = c.outputPath(p)
+	}
+	return refreshed, nil
+}
+
+// within reports whether p is one of roots or below one of them
+func within(p string, roots []string) bool {
+	for _, root := range roots {
+		if p == root || strings.HasPrefix(p, root+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// outputPath returns the escaped path p is written as
+func (c *Config) outputPath(p string) string {
+	if c.RelativePaths {
+		p = strings.TrimPrefix(vault.NormalizePath(p), c.prefix+"/")
+	}
+	return vault.EscapePath(p)
+}
 
-	if len(secretScraper.Data) == 0 {
+// write writes what secretScraper read to the output
+func (c *Config) write(secretScraper *SecretScraper) error {
+	if len(secretScraper.Data) == 0 && len(secretScraper.Failed) == 0 && len(secretScraper.Tombstones) == 0 {
 		log.Println("No secrets found")
 		return nil
 	}
 
-	if err := c.ProcessOutput(secretScraper.Data); err != nil {
+	// paths of several namespaces share no root
+	root := ""
+	if !strings.Contains(c.InputPath, ",") && len(c.Namespaces) == 0 {
+		var err error
+		if root, err = c.VaultConfig.ResolveMountPath(c.InputPath, "data"); err != nil {
+			return err
+		}
+		// a single secret is kept relative to its parent so it keeps its name
+		if _, ok := secretScraper.Data[root]; ok {
+			if root = path.Dir(root); root == "." {
+				root = ""
+			}
+		}
+		c.root = vault.EscapePath(root)
+	}
+	c.prefix = root
+
+	// paths and field names are escaped so that anything Vault accepts
+	// survives encoding and is restored exactly, see vault.EscapePath
+	outputPath := c.outputPath
+	data := make(map[string]interface{}, len(secretScraper.Data))
+	for p, secret := range secretScraper.Data {
+		data[outputPath(p)] = escapeKeys(secret)
+	}
+	c.failed = make(map[string]Failure, len(secretScraper.Failed))
+	c.tombstones = make(map[string]vault.VersionState, len(secretScraper.Tombstones))
+	for p, state := range secretScraper.Tombstones {
+		c.tombstones[outputPath(p)] = state
+	}
+	c.history = make(map[string][]Version, len(secretScraper.History))
+	earlier := 0
+	for p, versions := range secretScraper.History {
+		if _, ok := secretScraper.Data[p]; ok {
+			c.history[outputPath(p)] = encodeHistory(versions)
+			earlier += len(versions)
+		}
+	}
+	if earlier > 0 {
+		log.Printf("Dumped %d earlier versions of %d secrets\n", earlier, len(c.history))
+	}
+	c.kvMetadata = make(map[string]*vault.SecretMetadata, len(secretScraper.KVMetadata))
+	for p, m := range secretScraper.KVMetadata {
+		if _, ok := secretScraper.Data[p]; ok {
+			c.kvMetadata[outputPath(p)] = m
+		}
+	}
+	categories := make(map[string]int)
+	for p, failure := range secretScraper.Failed {
+		c.failed[outputPath(p)] = failure
+		categories[failure.Category]++
+	}
+	for category, count := range categories {
+		log.Printf("Failed to dump %d secrets: %s\n", count, category)
+	}
+	if len(c.failed) > 0 && c.quarantined() {
+		log.Printf("Failed secrets are listed under failed in the manifest and in %s\n", QuarantineFilename(c.filename()))
+	} else if len(c.failed) > 0 {
+		log.Println("Failed secrets are listed under failed in the manifest")
+	}
+
+	c.discovered = len(data)
+	c.state = make(map[string]SecretState, len(data)+len(secretScraper.Failed))
+	for p := range secretScraper.Data {
+		o := outputPath(p)
+		c.state[o] = SecretState{Hash: hashSecret(data[o]), Version: secretScraper.Versions[p]}
+	}
+	for p := range secretScraper.Failed {
+		c.state[outputPath(p)] = SecretState{Unknown: true}
+	}
+
+	c.skipped = dropOversized(data, c.MaxValueSize)
+	for path, values := range c.skipped {
+		for _, v := range values {
+			log.Printf("skipped oversized value %s:%s\n", path, v)
+		}
+	}
+	if len(c.skipped) > 0 {
+		log.Printf("Skipped values larger than %d bytes in %d secrets\n", c.MaxValueSize, len(c.skipped))
+	}
+
+	if err := c.ProcessOutput(data); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// scrape reads the secrets of InputPath, in each of Namespaces when set with
+// the paths of a namespace prefixed with it, recording them to cp
+func (c *Config) scrape(cp *checkpoint) (*SecretScraper, error) {
+	since, previous := c.since()
+	run := func(vc *vault.Config, ns string) (*SecretScraper, error) {
+		s, err := NewSecretScraper(vc)
+		if err != nil {
+			return nil, err
+		}
+		s.checkpoint, s.namespace = cp, ns
+		s.incremental = c.Incremental
+		s.since, s.previous = since, previous
+		s.context = c.context()
+		s.Deleted = c.Deleted
+		s.MetadataOnly = c.MetadataOnly
+		s.KeysOnly = c.KeysOnly
+		s.versions = c.Versions
+		s.includeMetadata = c.IncludeMetadata
+		s.filter = c.Filter
+		s.maxDepth = c.MaxDepth
+		s.policy, s.destination = c.Policy, c.Destination
+		s.Deny = c.Deny
+		s.dryRun = c.DryRun
+		s.modifiedSince = c.ModifiedSince
+		var wg sync.WaitGroup
+		s.Run(c.InputPath, &wg, c.Concurrency)
+		wg.Wait()
+		return s, nil
+	}
+	if len(c.Namespaces) == 0 {
+		return run(c.VaultConfig, "")
+	}
+
+	merged, err := NewSecretScraper(c.VaultConfig)
+	if err != nil {
+		return nil, err
+	}
+	for _, ns := range c.Namespaces {
+		if c.context().Err() != nil {
+			break
+		}
+		vc := c.VaultConfig
+		if ns != "" {
+			if vc, err = c.VaultConfig.WithNamespace(ns); err != nil {
+				return nil, err
+			}
+		}
+		log.Printf("Dumping %s of namespace %q\n", c.InputPath, vault.JoinNamespace(c.VaultConfig.Namespace, ns))
+		s, err := run(vc, vault.NormalizePath(ns))
+		if err != nil {
+			return nil, err
+		}
+		merged.merge(vault.NormalizePath(ns), s)
+	}
+	return merged, nil
+}
+
 func isDir(p string) bool {
 	lastChar := p[len(p)-1:]
 	if lastChar != "/" {
@@ -66,26 +634,40 @@ func isDir(p string) bool {
 	return true
 }
 
-func (c *Config) writeToFile(data map[string]interface{}) error {
+func (c *Config) writeToFile(filename, group string, data map[string]interface{}) error {
 	var (
 		output string
 		err    error
 	)
 
-	switch c.Output.GetEncoding() {
-	case "yaml":
-		output, err = print.ToYaml(data)
+	if c.EncryptValues {
+		key, err := c.DataKey(group)
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to get a data key for %s: %w", filename, err)
 		}
-	default:
-		output, err = print.ToJSON(data)
-		if err != nil {
+		if data, err = encryptValues(data, key.Plaintext, c.EncryptNames); err != nil {
 			return err
 		}
+		if c.encryption == nil {
+			c.encryption = make(map[string]*ValueEncryption)
+		}
+		c.encryption[group] = &ValueEncryption{Algorithm: ValueEncryptionAES256GCM, KMSKey: key.KMSKey, Key: key.Ciphertext, Names: c.EncryptNames}
 	}
+	data, err = c.withManifest(data, group)
+	if err != nil {
+		return err
+	}
+	if c.checksums == nil {
+		c.checksums = make(map[string]string)
+	}
+	c.checksums[group] = contentChecksum(data)
 
-	filename := fmt.Sprintf("%s/%s.%s", c.Output.GetPath(), c.Filename, c.Output.GetEncoding())
+	output, err = c.encode(data)
+	if err != nil {
+		return err
+	}
+
+	filename = fmt.Sprintf("%s/%s.%s", c.Output.GetPath(), filename, c.Output.GetEncoding())
 	if ok := file.WriteFile(filename, output); !ok {
 		return fmt.Errorf("failed to write %v", filename)
 	}
@@ -93,6 +675,51 @@ func (c *Config) writeToFile(data map[string]interface{}) error {
 	return nil
 }
 
+// encode encodes v in the output encoding
+func (c *Config) encode(v interface{}) (string, error) {
+	if c.Output.GetEncoding() != "yaml" {
+		if c.JSON != nil {
+			return print.ToJSONWith(v, *c.JSON)
+		}
+		return print.ToJSON(v)
+	}
+	if c.YAML != nil {
+		return print.ToYamlWith(v, *c.YAML)
+	}
+	return print.ToYaml(v)
+}
+
+// publish encodes each secret on its own and hands the messages to Publish
+func (c *Config) publish(m map[string]interface{}) error {
+	if c.Publish == nil {
+		return errors.New("no publisher configured for kafka output")
+	}
+	messages := make(map[string]string, len(m))
+	for p, secret := range m {
+		value, err := c.encode(secret)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", p, err)
+		}
+		messages[p] = value
+	}
+	return c.Publish(messages)
+}
+
+// Stats returns the number of secrets dumped and of secrets that failed
+func (c *Config) Stats() (int, int) {
+	return c.discovered, len(c.failed)
+}
+
+// Failures returns why each escaped path could not be dumped
+func (c *Config) Failures() map[string]Failure {
+	return c.failed
+}
+
+// State returns the state of each dumped path to find changes with Diff
+func (c *Config) State() map[string]SecretState {
+	return c.state
+}
+
 // GetPathForOutput
 func GetPathForOutput(path string) string {
 	if path == "" {
@@ -104,12 +731,52 @@ func GetPathForOutput(path string) string {
 // ProcessOutput takes action based on inputs to complete the
 // desired output result
 func (c *Config) ProcessOutput(m map[string]interface{}) error {
+	escapeLiterals(m)
+	if c.Output.GetKind() != "stdout" && c.Output.GetKind() != "kafka" {
+		if err := externalize(m, c.Output.GetPath(), c.filename()+".files", c.ExternalizeSize); err != nil {
+			return err
+		}
+	}
+	tagBinary(m)
+
 	switch c.Output.GetKind() {
 
 	case "stdout":
-		print.Stdout(m, c.Output.GetEncoding())
+		data, err := c.withManifest(m, "")
+		if err != nil {
+			return err
+		}
+		encoded, err := c.encode(data)
+		if err != nil {
+			return err
+		}
+		if c.Stream == nil {
+			fmt.Println(encoded)
+			break
+		}
+		if err := c.Stream([]byte(encoded)); err != nil {
+			return err
+		}
+	case "kafka":
+		if err := c.publish(m); err != nil {
+			return err
+		}
 	default:
-		if err := c.writeToFile(m); err != nil {
+		if len(c.Groups) == 0 {
+			if err := c.writeToFile(c.filename(), "", m); err != nil {
+				return err
+			}
+			if err := c.writeQuarantine(len(m)); err != nil {
+				return err
+			}
+			break
+		}
+		for name, data := range SplitByGroup(m, c.Groups) {
+			if err := c.writeToFile(GroupFilename(c.filename(), name), name, data); err != nil {
+				return err
+			}
+		}
+		if err := c.writeQuarantine(len(m)); err != nil {
 			return err
 		}
 
@@ -0,0 +1,129 @@
+package dump
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+func TestSuiteRefresh(tt *testing.T) {
+	// a KV version 1 mount kv/ whose secrets the tests change
+	var (
+		mu      sync.Mutex
+		secrets = map[string]string{"kv/app/a": "1", "kv/app/b": "1", "kv/other/c": "1"}
+		reads   []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/sys/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/"), "/")
+		if r.Method == "LIST" || r.URL.Query().Get("list") == "true" {
+			keys := map[string]bool{}
+			for p := range secrets {
+				if strings.HasPrefix(p, path+"/") {
+					rest := strings.TrimPrefix(p, path+"/")
+					if i := strings.Index(rest, "/"); i >= 0 {
+						rest = rest[:i+1]
+					}
+					keys[rest] = true
+				}
+			}
+			if len(keys) == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			names := []string{}
+			for k := range keys {
+				names = append(names, `"`+k+`"`)
+			}
+			fmt.Fprintf(w, `{"data": {"keys": [%s]}}`, strings.Join(names, ", "))
+			return
+		}
+		reads = append(reads, path)
+		value, ok := secrets[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"data": {"value": "%s"}}`, value)
+	}))
+	defer server.Close()
+
+	vc, err := vault.NewClient(&vault.Config{Address: server.URL, Token: "t", Ignore: &vault.Ignore{}})
+	if err != nil {
+		tt.Fatal(err)
+	}
+	out, _ := NewOutput("", "json", "stdout")
+	c, err := New(&Config{InputPath: "kv/app", Output: out, VaultConfig: vc, Stream: func([]byte) error { return nil }})
+	if err != nil {
+		tt.Fatal(err)
+	}
+	if _, err := c.Refresh([]string{"kv/app/a"}); err == nil {
+		tt.Errorf("FAIL Not dumped: expected an error got none")
+	} else {
+		tt.Logf("PASS Not dumped")
+	}
+	if err := c.Secrets(); err != nil {
+		tt.Fatal(err)
+	}
+
+	var (
+		tests = []struct {
+			description string
+			change      func()
+			paths       []string
+			normOutput  string
+		}{
+			{"Updated", func() { secrets["kv/app/a"] = "2" }, []string{"kv/app/a", "/kv/app/a/"}, "refreshed [kv/app/a] read [kv/app/a] changes [kv/app/a updated]"},
+			{"Added", func() { secrets["kv/app/d"] = "1" }, []string{"kv/app/d"}, "refreshed [kv/app/d] read [kv/app/d] changes [kv/app/d added]"},
+			{"Deleted", func() { delete(secrets, "kv/app/b") }, []string{"kv/app/b"}, "refreshed [kv/app/b] read [kv/app/b] changes [kv/app/b deleted]"},
+			{"Outside the dumped path", func() { secrets["kv/other/c"] = "2" }, []string{"kv/other/c", "sys/policy/x"}, "refreshed [] read [] changes []"},
+		}
+	)
+	for _, test := range tests {
+		mu.Lock()
+		test.change()
+		reads = nil
+		mu.Unlock()
+
+		before := c.State()
+		refreshed, err := c.Refresh(test.paths)
+		changes, _ := Diff(before, c.State())
+		changed := []string{}
+		for _, change := range changes {
+			changed = append(changed, change.Path+" "+change.Type)
+		}
+		mu.Lock()
+		norm := fmt.Sprintf("refreshed %v read %v changes %v", refreshed, reads, changed)
+		mu.Unlock()
+		if err != nil {
+			norm = err.Error()
+		}
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+
+	paths := []string{}
+	for p := range c.State() {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if norm := fmt.Sprint(paths); norm != "[kv/app/a kv/app/d]" {
+		tt.Errorf("FAIL State: expected '[kv/app/a kv/app/d]' got '%s'", norm)
+	} else {
+		tt.Logf("PASS State")
+	}
+}
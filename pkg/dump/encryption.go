@@ -0,0 +1,172 @@
+package dump
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// EncryptedKey marks a value encrypted with the data key recorded in the
+	// manifest, the value is the standard base64 encoding of the nonce
+	// followed by the ciphertext of the json encoding of the original value
+	EncryptedKey = "$encrypted"
+	// ValueEncryptionAES256GCM encrypts values, and names, with AES-256-GCM
+	ValueEncryptionAES256GCM = "aes-256-gcm"
+)
+
+// ValueEncryption describes how the values of a dump, and optionally the key
+// names, were encrypted, leaving its paths readable
+type ValueEncryption struct {
+	Algorithm string `json:"algorithm"`
+	// KMSKey encrypted Key, the data key the values are encrypted with
+	KMSKey string `json:"kms_key"`
+	Key    []byte `json:"key"`
+	// Names means every key name is encrypted as well, as the unpadded url
+	// safe base64 encoding of its nonce and ciphertext
+	Names bool `json:"names,omitempty"`
+}
+
+// DataKey is a data key in plaintext and encrypted by KMSKey
+type DataKey struct {
+	Plaintext  []byte
+	Ciphertext []byte
+	KMSKey     string
+}
+
+// encryptValues returns a copy of data, escaped paths to secrets, with every
+// value, and every key name with names, encrypted with key. Each ciphertext
+// is bound to its path and key name, so it can not be moved to another one.
+func encryptValues(data map[string]interface{}, key []byte, names bool) (map[string]interface{}, error) {
+	aead, err := newValueAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := make(map[string]interface{}, len(data))
+	for path, secret := range data {
+		values, ok := secret.(map[string]interface{})
+		if !ok {
+			encrypted[path] = secret
+			continue
+		}
+		sealed := make(map[string]interface{}, len(values))
+		for k, v := range values {
+			plaintext, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encrypt %s:%s: %w", path, k, err)
+			}
+			value, err := sealValue(aead, plaintext, []byte(path+"\x00"+k))
+			if err != nil {
+				return nil, err
+			}
+			name := k
+			if names {
+				b, err := sealValue(aead, []byte(k), []byte(path))
+				if err != nil {
+					return nil, err
+				}
+				name = base64.RawURLEncoding.EncodeToString(b)
+			}
+			sealed[name] = map[string]interface{}{EncryptedKey: base64.StdEncoding.EncodeToString(value)}
+		}
+		encrypted[path] = sealed
+	}
+	return encrypted, nil
+}
+
+// DecryptValues decrypts in place the values, and key names, of data, a
+// loaded dump without its manifest m, that were encrypted as m records. The
+// data key is decrypted with unwrap, without one the values are left as they
+// are. It returns whether the values were decrypted.
+func DecryptValues(m *Manifest, data map[string]interface{}, unwrap func(cipherkey []byte) ([]byte, error)) (bool, error) {
+	if m == nil || m.Encryption == nil || unwrap == nil {
+		return false, nil
+	}
+	if m.Encryption.Algorithm != ValueEncryptionAES256GCM {
+		return false, fmt.Errorf("unsupported value encryption %q", m.Encryption.Algorithm)
+	}
+	key, err := unwrap(m.Encryption.Key)
+	if err != nil {
+		return false, fmt.Errorf("failed to decrypt the data key of the values: %w", err)
+	}
+	aead, err := newValueAEAD(key)
+	if err != nil {
+		return false, err
+	}
+	for path, secret := range data {
+		values, ok := secret.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		opened := make(map[string]interface{}, len(values))
+		for name, v := range values {
+			k := name
+			if m.Encryption.Names {
+				b, err := base64.RawURLEncoding.DecodeString(name)
+				if err != nil {
+					return false, fmt.Errorf("invalid encrypted key name %s:%s: %w", path, name, err)
+				}
+				plain, err := openValue(aead, b, []byte(path))
+				if err != nil {
+					return false, fmt.Errorf("failed to decrypt a key name of %s: %w", path, err)
+				}
+				k = string(plain)
+			}
+			tag, ok := v.(map[string]interface{})
+			encoded, isString := tag[EncryptedKey].(string)
+			if !ok || len(tag) != 1 || !isString {
+				return false, fmt.Errorf("value %s:%s is not encrypted", path, k)
+			}
+			b, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return false, fmt.Errorf("invalid %s value %s:%s: %w", EncryptedKey, path, k, err)
+			}
+			plaintext, err := openValue(aead, b, []byte(path+"\x00"+k))
+			if err != nil {
+				return false, fmt.Errorf("failed to decrypt %s:%s: %w", path, k, err)
+			}
+			var value interface{}
+			if err := json.Unmarshal(plaintext, &value); err != nil {
+				return false, fmt.Errorf("failed to decrypt %s:%s: %w", path, k, err)
+			}
+			opened[k] = value
+		}
+		data[path] = opened
+	}
+	m.Encryption = nil
+	return true, nil
+}
+
+// newValueAEAD returns the AES-256-GCM cipher of key
+func newValueAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid data key of %d bytes, expected 32", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealValue encrypts plaintext with a random nonce, which is prepended
+func sealValue(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// openValue decrypts what sealValue encrypted
+func openValue(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
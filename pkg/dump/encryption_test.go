@@ -0,0 +1,78 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSuiteEncryptValues(tt *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	unwrap := func(cipherkey []byte) ([]byte, error) {
+		if string(cipherkey) != "wrapped" {
+			return nil, errors.New("unknown data key")
+		}
+		return key, nil
+	}
+	// moveValue moves the only value of secret/a to secret/b
+	moveValue := func(data map[string]interface{}) {
+		data["secret/b"] = data["secret/a"]
+	}
+	var (
+		tests = []struct {
+			description string
+			names       bool
+			change      func(map[string]interface{})
+			unwrap      func([]byte) ([]byte, error)
+			normOutput  string
+		}{
+			{"Values", false, nil, unwrap, `{"secret/a":{"password":"hunter2"},"secret/b":{"port":5432}}`},
+			{"Values and names", true, nil, unwrap, `{"secret/a":{"password":"hunter2"},"secret/b":{"port":5432}}`},
+			{"Value moved to another path", false, moveValue, unwrap, "error"},
+			{"Name moved to another path", true, moveValue, unwrap, "error"},
+			{"Wrong data key", false, nil, func([]byte) ([]byte, error) { return bytes.Repeat([]byte{8}, 32), nil }, "error"},
+			{"Data key not decrypted", false, nil, func([]byte) ([]byte, error) { return nil, errors.New("denied") }, "error"},
+			{"No way to unwrap", false, nil, nil, "encrypted"},
+		}
+	)
+	for _, test := range tests {
+		data := map[string]interface{}{
+			"secret/a": map[string]interface{}{"password": "hunter2"},
+			"secret/b": map[string]interface{}{"port": 5432},
+		}
+		encrypted, err := encryptValues(data, key, test.names)
+		if err != nil {
+			tt.Fatal(err)
+		}
+		b, _ := json.Marshal(encrypted)
+		if strings.Contains(string(b), "hunter2") || (test.names && strings.Contains(string(b), "password")) {
+			tt.Errorf("FAIL %s: plaintext left in %s", test.description, b)
+		}
+		// read back as json, like a dump
+		d := make(map[string]interface{})
+		if err := json.Unmarshal(b, &d); err != nil {
+			tt.Fatal(err)
+		}
+		if test.change != nil {
+			test.change(d)
+		}
+		m := &Manifest{Encryption: &ValueEncryption{Algorithm: ValueEncryptionAES256GCM, Key: []byte("wrapped"), Names: test.names}}
+		norm := "error"
+		if ok, err := DecryptValues(m, d, test.unwrap); err == nil && ok && m.Encryption == nil {
+			b, _ := json.Marshal(d)
+			norm = string(b)
+		} else if err == nil && !ok && m.Encryption != nil {
+			norm = "encrypted"
+		}
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+	if _, err := DecryptValues(&Manifest{}, map[string]interface{}{}, unwrap); err != nil {
+		tt.Errorf("FAIL Not encrypted: expected no error got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package dump
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// Extract returns the secrets of a dump at or below prefix, written as they
+// appear in the dump, e.g. secret/data/team. Their values are kept as they
+// are and the manifest, if any, only reports the extracted secrets.
+func Extract(data map[string]interface{}, prefix string) (map[string]interface{}, error) {
+	secrets := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		secrets[k] = v
+	}
+	m, err := ExtractManifest(secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	p := vault.SanitizePath(prefix)
+	root := ""
+	if m != nil {
+		if m.PathEscaping == PathEscapingSegment {
+			p = vault.EscapePath(p)
+		}
+		if m.PathMode == PathModeRelative {
+			root = m.Root
+		}
+	}
+	// under reports whether a path of the dump is at or below the prefix,
+	// relative paths are below the root of the dump
+	under := func(path string) bool {
+		if root != "" {
+			path = root + "/" + path
+		}
+		path = vault.SanitizePath(path)
+		return path == p || strings.HasPrefix(path, p+"/")
+	}
+
+	out := make(map[string]interface{})
+	for path, secret := range secrets {
+		if under(path) {
+			out[path] = secret
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no secrets below %s in the dump", prefix)
+	}
+	if m == nil {
+		return out, nil
+	}
+
+	m.Secrets = len(out)
+	skipped, failed, tombstones, history, metadata := m.Skipped, m.Failed, m.Tombstones, m.History, m.Metadata
+	m.Skipped, m.Failed, m.Tombstones, m.History, m.Metadata = nil, nil, nil, nil, nil
+	// the quarantine file stays with the dump extracted from
+	m.Quarantine = ""
+	for path, values := range skipped {
+		if under(path) {
+			if m.Skipped == nil {
+				m.Skipped = make(map[string][]string)
+			}
+			m.Skipped[path] = values
+		}
+	}
+	for path, failure := range failed {
+		if under(path) {
+			if m.Failed == nil {
+				m.Failed = make(map[string]Failure)
+			}
+			m.Failed[path] = failure
+		}
+	}
+	for path, state := range tombstones {
+		if under(path) {
+			if m.Tombstones == nil {
+				m.Tombstones = make(map[string]vault.VersionState)
+			}
+			m.Tombstones[path] = state
+		}
+	}
+	for path, versions := range history {
+		if under(path) {
+			if m.History == nil {
+				m.History = make(map[string][]Version)
+			}
+			m.History[path] = versions
+		}
+	}
+	for path, md := range metadata {
+		if under(path) {
+			if m.Metadata == nil {
+				m.Metadata = make(map[string]*vault.SecretMetadata)
+			}
+			m.Metadata[path] = md
+		}
+	}
+	mm, err := m.toMap()
+	if err != nil {
+		return nil, err
+	}
+	out[ManifestKey] = mm
+	return out, nil
+}
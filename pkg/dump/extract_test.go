@@ -0,0 +1,109 @@
+package dump
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSuiteExtract(tt *testing.T) {
+	var (
+		norm    string
+		success bool
+		tests   = []struct {
+			description string
+			data        map[string]interface{}
+			prefix      string
+			normOutput  string
+			isSuccess   bool
+		}{
+			{
+				"Without manifest",
+				map[string]interface{}{"secret/team/a": nil, "secret/team/b/c": nil, "secret/teamb": nil, "secret/other": nil},
+				"secret/team/", "secret/team/a,secret/team/b/c", true,
+			},
+			{
+				"Single secret",
+				map[string]interface{}{"secret/team/a": nil, "secret/team/ab": nil},
+				"secret/team/a", "secret/team/a", true,
+			},
+			{
+				"Escaped paths",
+				map[string]interface{}{
+					ManifestKey:    map[string]interface{}{"version": 1, "path_escaping": PathEscapingSegment, "secrets": 2},
+					"secret/a%25b": nil, "secret/other": nil,
+				},
+				"secret/a%b", "$manifest,secret/a%25b", true,
+			},
+			{
+				"Relative paths",
+				map[string]interface{}{
+					ManifestKey: map[string]interface{}{"version": 1, "path_mode": PathModeRelative, "root": "secret/data", "secrets": 2},
+					"team/a":    nil, "other/b": nil,
+				},
+				"secret/data/team", "$manifest,team/a", true,
+			},
+			{
+				"Manifest filtered",
+				map[string]interface{}{
+					ManifestKey: map[string]interface{}{
+						"version": 1, "secrets": 2,
+						"failed":     map[string]interface{}{"secret/team/x": map[string]interface{}{"category": "permission-denied"}, "secret/other/x": map[string]interface{}{}},
+						"skipped":    map[string]interface{}{"secret/team/a": []interface{}{"k"}, "secret/other/a": []interface{}{"k"}},
+						"tombstones": map[string]interface{}{"secret/team/t": map[string]interface{}{"version": 2}, "secret/other/t": map[string]interface{}{"version": 2}},
+						"metadata":   map[string]interface{}{"secret/team/a": map[string]interface{}{"current_version": 1}, "secret/other/a": map[string]interface{}{"current_version": 1}},
+					},
+					"secret/team/a": nil, "secret/other/a": nil,
+				},
+				"secret/team", "$manifest,failed=secret/team/x,metadata=secret/team/a,secret/team/a,skipped=secret/team/a,tombstone=secret/team/t", true,
+			},
+			{
+				"Nothing below prefix",
+				map[string]interface{}{"secret/other": nil},
+				"secret/team", "", false,
+			},
+		}
+	)
+	for _, test := range tests {
+		out, err := Extract(test.data, test.prefix)
+		success = (err == nil)
+		norm = ""
+		if success {
+			keys := []string{}
+			for k := range out {
+				keys = append(keys, k)
+			}
+			if m, err := ExtractManifest(out); err == nil && m != nil {
+				for p := range m.Failed {
+					keys = append(keys, "failed="+p)
+				}
+				for p := range m.Skipped {
+					keys = append(keys, "skipped="+p)
+				}
+				for p := range m.Tombstones {
+					keys = append(keys, "tombstone="+p)
+				}
+				for p := range m.Metadata {
+					keys = append(keys, "metadata="+p)
+				}
+				if m.Secrets != len(out) {
+					keys = append(keys, "wrong count")
+				}
+			}
+			sort.Strings(keys)
+			norm = strings.Join(keys, ",")
+		}
+
+		if success == test.isSuccess && (!success || norm == test.normOutput) {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		}
+	}
+	data := map[string]interface{}{ManifestKey: map[string]interface{}{"version": 1}, "secret/a": nil}
+	if _, err := Extract(data, "secret"); err != nil || data[ManifestKey] == nil {
+		tt.Errorf("FAIL Extract changed its input")
+	}
+}
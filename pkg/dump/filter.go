@@ -0,0 +1,177 @@
+package dump
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// Filter selects the secrets dumped by regular expressions matched against
+// their data paths and glob patterns matched against their names, the last
+// segment of their paths. Directories matching an exclude are not listed.
+// Secrets can also be selected by their KV version 2 custom metadata.
+type Filter struct {
+	IncludePaths    []*regexp.Regexp
+	ExcludePaths    []*regexp.Regexp
+	IncludeKeys     []string
+	ExcludeKeys     []string
+	IncludeMetadata []MetadataMatch
+	ExcludeMetadata []MetadataMatch
+}
+
+// MetadataMatch matches custom metadata holding Key, with Value unless Any
+type MetadataMatch struct {
+	Key   string
+	Value string
+	Any   bool
+}
+
+// NewFilter compiles the path regular expressions, checks the key glob
+// patterns, see path.Match, and parses the custom metadata matches, see
+// ParseMetadataMatch. It is nil when there is nothing to filter by.
+func NewFilter(includePaths, excludePaths, includeKeys, excludeKeys, includeMetadata, excludeMetadata []string) (*Filter, error) {
+	if len(includePaths)+len(excludePaths)+len(includeKeys)+len(excludeKeys)+len(includeMetadata)+len(excludeMetadata) == 0 {
+		return nil, nil
+	}
+	f := &Filter{IncludeKeys: includeKeys, ExcludeKeys: excludeKeys}
+	var err error
+	if f.IncludeMetadata, err = parseMetadataMatches(includeMetadata); err != nil {
+		return nil, err
+	}
+	if f.ExcludeMetadata, err = parseMetadataMatches(excludeMetadata); err != nil {
+		return nil, err
+	}
+	if f.IncludePaths, err = compileAll(includePaths); err != nil {
+		return nil, err
+	}
+	if f.ExcludePaths, err = compileAll(excludePaths); err != nil {
+		return nil, err
+	}
+	for _, pattern := range append(append([]string{}, includeKeys...), excludeKeys...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid key pattern %q: %w", pattern, err)
+		}
+	}
+	return f, nil
+}
+
+// ParseMetadataMatch parses key=value, matching custom metadata holding key
+// with that value, or key alone, matching it with any value
+func ParseMetadataMatch(s string) (MetadataMatch, error) {
+	key, value, hasValue := s, "", false
+	if i := strings.Index(s, "="); i >= 0 {
+		key, value, hasValue = s[:i], s[i+1:], true
+	}
+	if key = strings.TrimSpace(key); key == "" {
+		return MetadataMatch{}, fmt.Errorf("invalid metadata match %q, expected key=value or key", s)
+	}
+	return MetadataMatch{Key: key, Value: value, Any: !hasValue}, nil
+}
+
+// parseMetadataMatches parses each of matches, see ParseMetadataMatch
+func parseMetadataMatches(matches []string) ([]MetadataMatch, error) {
+	parsed := make([]MetadataMatch, len(matches))
+	for i, m := range matches {
+		var err error
+		if parsed[i], err = ParseMetadataMatch(m); err != nil {
+			return nil, err
+		}
+	}
+	return parsed, nil
+}
+
+// compileAll compiles each of patterns
+func compileAll(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path pattern %q: %w", p, err)
+		}
+		compiled[i] = re
+	}
+	return compiled, nil
+}
+
+// Secret reports whether the secret at the data path p is dumped, which
+// needs it to match an include of each kind given and no exclude
+func (f *Filter) Secret(p string) bool {
+	if f == nil {
+		return true
+	}
+	p = vault.NormalizePath(p)
+	key := path.Base(p)
+	if matchAny(f.ExcludePaths, p) || globAny(f.ExcludeKeys, key) {
+		return false
+	}
+	if len(f.IncludePaths) > 0 && !matchAny(f.IncludePaths, p) {
+		return false
+	}
+	if len(f.IncludeKeys) > 0 && !globAny(f.IncludeKeys, key) {
+		return false
+	}
+	return true
+}
+
+// Prune reports whether the directory at the data path dir is excluded, by
+// its path followed by a slash or its name, so nothing below it is listed
+func (f *Filter) Prune(dir string) bool {
+	if f == nil {
+		return false
+	}
+	dir = vault.NormalizePath(dir)
+	return matchAny(f.ExcludePaths, dir+"/") || globAny(f.ExcludeKeys, path.Base(dir))
+}
+
+// ByMetadata reports whether secrets are selected by their custom metadata,
+// which must then be read before them
+func (f *Filter) ByMetadata() bool {
+	return f != nil && len(f.IncludeMetadata)+len(f.ExcludeMetadata) > 0
+}
+
+// Metadata reports whether the secret with the custom metadata custom is
+// dumped, which needs it to match an include, when given, and no exclude.
+// Secrets without custom metadata, as those of other mounts than KV
+// version 2, only match no include.
+func (f *Filter) Metadata(custom map[string]string) bool {
+	if !f.ByMetadata() {
+		return true
+	}
+	if metadataAny(f.ExcludeMetadata, custom) {
+		return false
+	}
+	return len(f.IncludeMetadata) == 0 || metadataAny(f.IncludeMetadata, custom)
+}
+
+// metadataAny reports whether any of matches matches custom
+func metadataAny(matches []MetadataMatch, custom map[string]string) bool {
+	for _, m := range matches {
+		if v, ok := custom[m.Key]; ok && (m.Any || v == m.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny reports whether any of res matches s
+func matchAny(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// globAny reports whether any of patterns matches name
+func globAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
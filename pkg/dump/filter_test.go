@@ -0,0 +1,86 @@
+package dump
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSuiteFilter(tt *testing.T) {
+	var (
+		tests = []struct {
+			description  string
+			includePaths []string
+			excludePaths []string
+			includeKeys  []string
+			excludeKeys  []string
+			input        string
+			normOutput   string
+		}{
+			{"No filters", nil, nil, nil, nil, "secret/data/a/b", "true,false"},
+			{"Include path", []string{"^secret/data/app/"}, nil, nil, nil, "secret/data/app/db", "true,false"},
+			{"Not included path", []string{"^secret/data/app/"}, nil, nil, nil, "secret/data/web/db", "false,false"},
+			{"Exclude path", nil, []string{"/tmp/"}, nil, nil, "secret/data/tmp/x", "false,true"},
+			{"Exclude directory", nil, []string{"/tmp/$"}, nil, nil, "secret/data/tmp", "true,true"},
+			{"Include key", nil, nil, []string{"*-cert"}, nil, "secret/data/a/tls-cert", "true,false"},
+			{"Not included key", nil, nil, []string{"*-cert"}, nil, "secret/data/a/tls-key", "false,false"},
+			{"Exclude key", nil, nil, nil, []string{"test?"}, "secret/data/test1", "false,true"},
+			{"Exclude wins", []string{"^secret/"}, nil, nil, []string{"b"}, "secret/data/a/b", "false,true"},
+			{"Every include kind", []string{"^secret/data/app/"}, nil, []string{"db"}, nil, "secret/data/app/cache", "false,false"},
+		}
+	)
+	for _, test := range tests {
+		f, err := NewFilter(test.includePaths, test.excludePaths, test.includeKeys, test.excludeKeys, nil, nil)
+		if err != nil {
+			tt.Errorf("FAIL %s: %v", test.description, err)
+			continue
+		}
+		if norm := fmt.Sprintf("%t,%t", f.Secret(test.input), f.Prune(test.input)); norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+	if _, err := NewFilter([]string{"("}, nil, nil, nil, nil, nil); err == nil {
+		tt.Errorf("FAIL Invalid regex: expected an error")
+	}
+	if _, err := NewFilter(nil, nil, nil, []string{"["}, nil, nil); err == nil {
+		tt.Errorf("FAIL Invalid glob: expected an error")
+	}
+}
+
+func TestSuiteFilterMetadata(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			include     []string
+			exclude     []string
+			input       map[string]string
+			isSelected  bool
+		}{
+			{"Opted out", nil, []string{"backup=false"}, map[string]string{"backup": "false"}, false},
+			{"Other value", nil, []string{"backup=false"}, map[string]string{"backup": "true"}, true},
+			{"No metadata", nil, []string{"backup=false"}, nil, true},
+			{"Included", []string{"team=payments"}, nil, map[string]string{"team": "payments"}, true},
+			{"Not included", []string{"team=payments"}, nil, map[string]string{"team": "web"}, false},
+			{"No metadata not included", []string{"team=payments"}, nil, nil, false},
+			{"Any value", []string{"owner"}, nil, map[string]string{"owner": "a"}, true},
+			{"Empty value", []string{"owner="}, nil, map[string]string{"owner": "a"}, false},
+			{"Exclude wins", []string{"team=payments"}, []string{"backup=false"}, map[string]string{"team": "payments", "backup": "false"}, false},
+		}
+	)
+	for _, test := range tests {
+		f, err := NewFilter(nil, nil, nil, nil, test.include, test.exclude)
+		if err != nil {
+			tt.Errorf("FAIL %s: %v", test.description, err)
+			continue
+		}
+		if selected := f.Metadata(test.input); selected != test.isSelected {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSelected, selected)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+	if _, err := NewFilter(nil, nil, nil, nil, []string{"=v"}, nil); err == nil {
+		tt.Errorf("FAIL Missing key: expected an error")
+	}
+}
@@ -0,0 +1,101 @@
+package dump
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+const (
+	// VersionsLatest dumps only the latest version of each secret
+	VersionsLatest = 1
+	// VersionsAll dumps every version KV version 2 retains of each secret
+	VersionsAll = -1
+)
+
+// Version is an earlier version of a KV version 2 secret, recorded in the
+// manifest of dumps of several versions
+type Version struct {
+	Version      int    `json:"version"`
+	CreatedTime  string `json:"created_time"`
+	DeletionTime string `json:"deletion_time,omitempty"`
+	Destroyed    bool   `json:"destroyed,omitempty"`
+	// Data holds the values, escaped and tagged like those of secrets. It is
+	// nil for deleted and destroyed versions, whose values Vault no longer
+	// returns.
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// ParseVersions parses how many versions of each secret to dump, latest, all
+// or a number of versions including the latest
+func ParseVersions(s string) (int, error) {
+	switch s {
+	case "", "latest":
+		return VersionsLatest, nil
+	case "all":
+		return VersionsAll, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid versions %q, expected latest, all or a number of versions", s)
+	}
+	return n, nil
+}
+
+// historic reports whether earlier versions are read along with each secret
+func (s *SecretScraper) historic() bool {
+	return s.versions == VersionsAll || s.versions > VersionsLatest
+}
+
+// history reads the versions of the KV version 2 secret at path before
+// current, the version dumped, oldest first, as listed by its metadata. At
+// most versions-1 of them are read, the latest ones, and every one with
+// VersionsAll.
+func (s *SecretScraper) history(path string, current int, metadata *vault.SecretMetadata) ([]Version, error) {
+	earlier := []int{}
+	for v := range metadata.Versions {
+		if n, err := strconv.Atoi(v); err == nil && n < current {
+			earlier = append(earlier, n)
+		}
+	}
+	sort.Ints(earlier)
+	if s.versions != VersionsAll && len(earlier) > s.versions-1 {
+		earlier = earlier[len(earlier)-(s.versions-1):]
+	}
+
+	history := make([]Version, 0, len(earlier))
+	for _, n := range earlier {
+		vm := metadata.Versions[strconv.Itoa(n)]
+		v := Version{Version: n, CreatedTime: vm.CreatedTime, DeletionTime: vm.DeletionTime, Destroyed: vm.Destroyed}
+		if !v.Destroyed && v.DeletionTime == "" {
+			var err error
+			s.slots <- struct{}{}
+			v.Data, err = s.VaultConfig.ReadVersion(path, n)
+			<-s.slots
+			if err != nil {
+				return nil, fmt.Errorf("failed to read version %d: %w", n, err)
+			}
+		}
+		history = append(history, v)
+	}
+	return history, nil
+}
+
+// encodeHistory returns a copy of versions with their field names escaped
+// and values tagged the way the values of secrets are written
+func encodeHistory(versions []Version) []Version {
+	encoded := make([]Version, len(versions))
+	for i, v := range versions {
+		encoded[i] = v
+		if v.Data == nil {
+			continue
+		}
+		data := map[string]interface{}{"": escapeKeys(v.Data)}
+		escapeLiterals(data)
+		tagBinary(data)
+		encoded[i].Data = data[""].(map[string]interface{})
+	}
+	return encoded
+}
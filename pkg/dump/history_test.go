@@ -0,0 +1,63 @@
+package dump
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestSuiteParseVersions(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			input       string
+			normOutput  int
+			isSuccess   bool
+		}{
+			{"Default", "", VersionsLatest, true},
+			{"Latest", "latest", VersionsLatest, true},
+			{"All", "all", VersionsAll, true},
+			{"Number", "3", 3, true},
+			{"Refuse zero", "0", 0, false},
+			{"Refuse negative", "-1", 0, false},
+			{"Refuse word", "some", 0, false},
+		}
+	)
+	for _, test := range tests {
+		n, err := ParseVersions(test.input)
+		if (err == nil) != test.isSuccess {
+			tt.Errorf("FAIL %s: expected success %v got %v", test.description, test.isSuccess, err)
+		} else if n != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%d' got '%d'", test.description, test.normOutput, n)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteEncodeHistory(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			inputs      []Version
+			normOutput  string
+		}{
+			{"Keep plain values", []Version{{Version: 1, Data: map[string]interface{}{"k": "v"}}}, `[{"version":1,"created_time":"","data":{"k":"v"}}]`},
+			{"Escape field name", []Version{{Version: 1, Data: map[string]interface{}{"a%b": "v"}}}, `[{"version":1,"created_time":"","data":{"a%25b":"v"}}]`},
+			{"Tag binary value", []Version{{Version: 1, Data: map[string]interface{}{"k": "\xff"}}}, `[{"version":1,"created_time":"","data":{"k":{"$binary":"/w=="}}}]`},
+			{"Keep destroyed version", []Version{{Version: 2, Destroyed: true}}, `[{"version":2,"created_time":"","destroyed":true}]`},
+		}
+	)
+	for _, test := range tests {
+		b, err := json.Marshal(encodeHistory(test.inputs))
+		norm := string(b)
+		if err != nil {
+			norm = fmt.Sprint(err)
+		}
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
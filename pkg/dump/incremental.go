@@ -0,0 +1,161 @@
+package dump
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// incrementalVersion is the format version of incremental state files
+const incrementalVersion = 1
+
+// IncrementalState records the KV version 2 metadata of every secret of a
+// dump, so that the next incremental dump only reads the secrets whose
+// metadata changed since and takes the others from the dump. It never holds
+// secret values.
+type IncrementalState struct {
+	Version   int    `json:"version"`
+	Created   string `json:"created"`
+	InputPath string `json:"input_path"`
+	Namespace string `json:"namespace,omitempty"`
+	// Dump is the file the state describes, the values of unchanged secrets
+	// are taken from it
+	Dump string `json:"dump"`
+	// Secrets holds the metadata of each KV version 2 secret of Dump by its
+	// data path, secrets of other mounts are always read
+	Secrets map[string]MetadataState `json:"secrets"`
+}
+
+// MetadataState is what is compared to learn whether a secret changed, the
+// deletion of the current version is kept as well since deleting it does
+// not change the updated time
+type MetadataState struct {
+	CurrentVersion int    `json:"current_version"`
+	UpdatedTime    string `json:"updated_time"`
+	DeletionTime   string `json:"deletion_time,omitempty"`
+	Destroyed      bool   `json:"destroyed,omitempty"`
+}
+
+// metadataState returns the state of m to compare
+func metadataState(m *vault.SecretMetadata) MetadataState {
+	current := m.Versions[strconv.Itoa(m.CurrentVersion)]
+	return MetadataState{
+		CurrentVersion: m.CurrentVersion,
+		UpdatedTime:    m.UpdatedTime,
+		DeletionTime:   current.DeletionTime,
+		Destroyed:      current.Destroyed,
+	}
+}
+
+// ReadIncrementalState reads the state written by an earlier incremental
+// dump from path
+func ReadIncrementalState(path string) (*IncrementalState, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	st := &IncrementalState{}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("invalid incremental state %s: %w", path, err)
+	}
+	if st.Version > incrementalVersion {
+		return nil, fmt.Errorf("unsupported incremental state version %d in %s", st.Version, path)
+	}
+	return st, nil
+}
+
+// Write writes the state to path, replacing it only once it is complete
+func (st *IncrementalState) Write(path string) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// IncrementalState returns the state of the secrets written by Secrets with
+// Incremental, for the next incremental dump to compare against
+func (c *Config) IncrementalState() *IncrementalState {
+	st := &IncrementalState{
+		Version:   incrementalVersion,
+		Created:   time.Now().UTC().Format(time.RFC3339),
+		InputPath: c.InputPath,
+		Namespace: c.VaultConfig.Namespace,
+		Secrets:   map[string]MetadataState{},
+	}
+	if c.scraped == nil {
+		return st
+	}
+	// paths that were not dumped are read again next time
+	for p, m := range c.scraped.metadata {
+		if _, ok := c.scraped.Data[p]; ok {
+			st.Secrets[p] = m
+		}
+	}
+	return st
+}
+
+// since returns the metadata and secrets of the previous dump to compare
+// against, nil when there is none or it is of other paths
+func (c *Config) since() (map[string]MetadataState, map[string]interface{}) {
+	if c.Since == nil {
+		return nil, nil
+	}
+	if c.Since.InputPath != c.InputPath || c.Since.Namespace != c.VaultConfig.Namespace {
+		log.Printf("Warning: the incremental state is of a dump of %s, reading every secret\n", c.Since.InputPath)
+		return nil, nil
+	}
+	return c.Since.Secrets, c.Previous
+}
+
+// unchanged reads the metadata of path and reports whether it matches the
+// previous dump, whose secret it then adds without reading it
+func (s *SecretScraper) unchanged(ctx context.Context, path string) bool {
+	s.slots <- struct{}{}
+	metadata, v2, err := s.VaultConfig.ReadMetadata(path)
+	<-s.slots
+	// a failure is reported by the read that follows
+	if ctx.Err() != nil || err != nil || !v2 || metadata == nil {
+		return false
+	}
+	key := path
+	if s.namespace != "" {
+		key = vault.NormalizePath(s.namespace + "/" + path)
+	}
+	m := metadataState(metadata)
+	s.mu.Lock()
+	s.metadata[key] = m
+	s.mu.Unlock()
+
+	previous, ok := s.previous[key]
+	if !ok || s.since[key] != m {
+		return false
+	}
+	found := secret{path: path, data: previous, version: m.CurrentVersion}
+	found.updated, _ = time.Parse(time.RFC3339Nano, metadata.Versions[strconv.Itoa(m.CurrentVersion)].CreatedTime)
+	s.secrets.channel <- found
+	s.mu.Lock()
+	s.reused++
+	s.mu.Unlock()
+	return true
+}
@@ -0,0 +1,69 @@
+package dump
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+func TestSuiteIncrementalState(tt *testing.T) {
+	dir, err := ioutil.TempDir("", "incremental-*")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dump.state")
+
+	st := &IncrementalState{
+		Version:   incrementalVersion,
+		InputPath: "kv/app",
+		Dump:      "/dumps/vault-dump.json",
+		Secrets: map[string]MetadataState{
+			"kv/data/app/a": metadataState(&vault.SecretMetadata{
+				CurrentVersion: 2,
+				UpdatedTime:    "2021-01-02T00:00:00Z",
+				Versions:       map[string]vault.VersionMetadata{"2": {CreatedTime: "2021-01-02T00:00:00Z"}},
+			}),
+			"kv/data/app/b": metadataState(&vault.SecretMetadata{
+				CurrentVersion: 3,
+				UpdatedTime:    "2021-01-03T00:00:00Z",
+				Versions:       map[string]vault.VersionMetadata{"3": {DeletionTime: "2021-01-04T00:00:00Z"}},
+			}),
+		},
+	}
+	if err := st.Write(path); err != nil {
+		tt.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "newer.state"), []byte(`{"version": 2}`), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "invalid.state"), []byte(`{"version": `), 0600)
+
+	var (
+		tests = []struct {
+			description string
+			path        string
+			normOutput  string
+		}{
+			{"Written state", path, "kv/app /dumps/vault-dump.json kv/data/app/a={2 2021-01-02T00:00:00Z  false} kv/data/app/b={3 2021-01-03T00:00:00Z 2021-01-04T00:00:00Z false}"},
+			{"Newer version", filepath.Join(dir, "newer.state"), "unsupported incremental state version 2 in " + filepath.Join(dir, "newer.state")},
+			{"Invalid", filepath.Join(dir, "invalid.state"), "invalid incremental state " + filepath.Join(dir, "invalid.state") + ": unexpected end of JSON input"},
+		}
+	)
+	for _, test := range tests {
+		var norm string
+		read, err := ReadIncrementalState(test.path)
+		if err != nil {
+			norm = err.Error()
+		} else {
+			norm = fmt.Sprintf("%s %s kv/data/app/a=%v kv/data/app/b=%v", read.InputPath, read.Dump, read.Secrets["kv/data/app/a"], read.Secrets["kv/data/app/b"])
+		}
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
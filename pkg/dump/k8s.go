@@ -1,3 +1,6 @@
+//go:build kubernetes
+// +build kubernetes
+
 package dump
 
 import (
@@ -7,6 +10,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/dathan/go-vault-dump/pkg/feature"
 	"github.com/spf13/viper"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -20,6 +24,10 @@ const (
 	tokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 )
 
+func init() {
+	feature.Register(feature.Kubernetes)
+}
+
 // ToKube
 func ToKube(c *Config, m map[string]interface{}) error {
 	var config *rest.Config
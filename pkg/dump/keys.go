@@ -0,0 +1,30 @@
+package dump
+
+import (
+	"github.com/hashicorp/vault/api"
+)
+
+// read reads the secret at path, with KeysOnly from the KV version 2 subkeys
+// endpoint where Vault has one, so the values are not even read
+func (s *SecretScraper) read(path string) (*api.Secret, error) {
+	if s.KeysOnly {
+		if subkeys, err := s.VaultConfig.ReadSubkeys(path); err != nil || subkeys != nil {
+			return subkeys, err
+		}
+	}
+	return s.VaultConfig.Client.Logical().Read(path)
+}
+
+// keysOf returns the structure of data, its nested objects with nil in place
+// of every other value, as the subkeys endpoint does
+func keysOf(data interface{}) interface{} {
+	m, ok := data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	keys := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		keys[k] = keysOf(v)
+	}
+	return keys
+}
@@ -0,0 +1,32 @@
+package dump
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSuiteKeysOf(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			data        interface{}
+			normOutput  string
+		}{
+			{"Flat secret", map[string]interface{}{"user": "admin", "password": "hunter2"}, `{"password":null,"user":null}`},
+			{"Nested object", map[string]interface{}{"db": map[string]interface{}{"host": "db1", "port": json.Number("5432")}, "token": "t"}, `{"db":{"host":null,"port":null},"token":null}`},
+			{"List value", map[string]interface{}{"hosts": []interface{}{"a", "b"}}, `{"hosts":null}`},
+			{"Empty secret", map[string]interface{}{}, `{}`},
+			{"Not an object", "value", `null`},
+		}
+	)
+	for _, test := range tests {
+		b, err := json.Marshal(keysOf(test.data))
+		if err != nil {
+			tt.Errorf("FAIL %s: %s", test.description, err)
+		} else if string(b) != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, string(b))
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
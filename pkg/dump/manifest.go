@@ -0,0 +1,220 @@
+package dump
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+const (
+	// ManifestKey is the reserved top level key holding the format envelope
+	// of a dump, it is never a secret path
+	ManifestKey = "$manifest"
+	// FormatVersion is the version of the dump format written by this tool
+	FormatVersion = 1
+	// PathEscapingSegment means every path segment is escaped with
+	// vault.EscapePathSegment and must be unescaped before writing to Vault
+	PathEscapingSegment = "percent-segment"
+	// KeyEscapingPercent means every field name is escaped with
+	// vault.EscapeKey and must be unescaped before writing to Vault
+	KeyEscapingPercent = "percent"
+	// ValueTaggingDollar means values may be tagged as $binary, $file or
+	// $literal and must be decoded with UntagValue before writing to Vault
+	ValueTaggingDollar = "dollar-tags"
+	// PathModeAbsolute means paths include the mount, as in secret/data/foo
+	PathModeAbsolute = "absolute"
+	// PathModeRelative means paths are relative to the manifest root, as in foo
+	PathModeRelative = "relative"
+
+	// DeletedSkip reports secrets whose latest version is deleted as failed
+	DeletedSkip = "skip"
+	// DeletedPrevious dumps the latest version which is not deleted
+	DeletedPrevious = "previous"
+	// DeletedTombstone records the deleted version in the manifest
+	DeletedTombstone = "tombstone"
+)
+
+// Manifest describes how a dump was produced and how it must be read back
+type Manifest struct {
+	Version      int    `json:"version"`
+	Created      string `json:"created"`
+	PathEscaping string `json:"path_escaping"`
+	KeyEscaping  string `json:"key_escaping"`
+	ValueTagging string `json:"value_tagging"`
+	PathMode     string `json:"path_mode,omitempty"`
+	// Root is the escaped path the dump was taken from, empty when several
+	// paths were dumped
+	Root    string `json:"root,omitempty"`
+	Secrets int    `json:"secrets"`
+	// MetadataOnly means the dump holds the metadata of each secret in place
+	// of its values, an inventory that must never be written to Vault
+	MetadataOnly bool `json:"metadata_only,omitempty"`
+	// KeysOnly means the dump holds the key names of each secret without
+	// their values, its structure, which must never be written to Vault
+	KeysOnly bool `json:"keys_only,omitempty"`
+	// ModifiedSince means the dump holds only the KV version 2 secrets
+	// modified after this time, a delta rather than a backup
+	ModifiedSince string `json:"modified_since,omitempty"`
+	// Encryption describes how the values were encrypted, they must be
+	// decrypted with DecryptValues before anything else
+	Encryption *ValueEncryption `json:"value_encryption,omitempty"`
+	// Partial means the dump was interrupted, it holds only the secrets read
+	// until then
+	Partial bool `json:"partial,omitempty"`
+	// Namespaces lists the namespaces the paths of the dump are prefixed
+	// with, paths below none of them belong to the namespace dumped from
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Cluster identifies the cluster the dump was taken from
+	Cluster *vault.Cluster `json:"cluster,omitempty"`
+	// Skipped lists, per path, the values left out for exceeding the size limit
+	Skipped map[string][]string `json:"skipped,omitempty"`
+	// Failed holds, per path, why the secret could not be dumped
+	Failed map[string]Failure `json:"failed,omitempty"`
+	// Quarantine is the name of the file listing the failed secrets of the
+	// whole dump, written next to it
+	Quarantine string `json:"quarantine,omitempty"`
+	// Tombstones holds, per path, the deleted or destroyed latest version
+	// recorded in place of the secret
+	Tombstones map[string]vault.VersionState `json:"tombstones,omitempty"`
+	// History holds, per path, the earlier versions of the secret oldest
+	// first, for dumps of several versions
+	History map[string][]Version `json:"history,omitempty"`
+	// Metadata holds, per path, the KV version 2 metadata of the secret, for
+	// dumps including metadata
+	Metadata map[string]*vault.SecretMetadata `json:"metadata,omitempty"`
+}
+
+// Failure describes why a secret could not be dumped, Category is one of the
+// vault.Category values
+type Failure struct {
+	Category string `json:"category"`
+	Reason   string `json:"reason"`
+}
+
+// NewManifest returns the manifest for a dump of count secrets
+func NewManifest(count int) *Manifest {
+	return &Manifest{
+		Version:      FormatVersion,
+		Created:      time.Now().UTC().Format(time.RFC3339),
+		PathEscaping: PathEscapingSegment,
+		KeyEscaping:  KeyEscapingPercent,
+		ValueTagging: ValueTaggingDollar,
+		Secrets:      count,
+	}
+}
+
+// toMap converts the manifest into the generic form used by the encoders
+func (m *Manifest) toMap() (map[string]interface{}, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	mm := make(map[string]interface{})
+	if err := json.Unmarshal(b, &mm); err != nil {
+		return nil, err
+	}
+	return mm, nil
+}
+
+// withManifest returns a copy of data, the secrets of group, with its manifest
+// added, reporting the skipped values of the paths in data and the failed
+// paths of the group
+func (c *Config) withManifest(data map[string]interface{}, group string) (map[string]interface{}, error) {
+	m := NewManifest(len(data))
+	m.PathMode = PathModeAbsolute
+	if c.RelativePaths {
+		m.PathMode = PathModeRelative
+	}
+	m.Root = c.root
+	m.Cluster = c.cluster
+	m.MetadataOnly = c.MetadataOnly
+	m.KeysOnly = c.KeysOnly
+	if !c.ModifiedSince.IsZero() {
+		m.ModifiedSince = c.ModifiedSince.UTC().Format(time.RFC3339)
+	}
+	m.Encryption = c.encryption[group]
+	m.Partial = c.partial
+	for _, ns := range c.Namespaces {
+		if ns = vault.NormalizePath(ns); ns != "" {
+			m.Namespaces = append(m.Namespaces, ns)
+		}
+	}
+	for path, values := range c.skipped {
+		if _, ok := data[path]; ok {
+			if m.Skipped == nil {
+				m.Skipped = make(map[string][]string)
+			}
+			m.Skipped[path] = values
+		}
+	}
+	if c.quarantined() {
+		m.Quarantine = QuarantineFilename(c.filename())
+	}
+	for path, failure := range c.failed {
+		if groupOf(path, c.Groups) == group {
+			if m.Failed == nil {
+				m.Failed = make(map[string]Failure)
+			}
+			m.Failed[path] = failure
+		}
+	}
+	for path, state := range c.tombstones {
+		if groupOf(path, c.Groups) == group {
+			if m.Tombstones == nil {
+				m.Tombstones = make(map[string]vault.VersionState)
+			}
+			m.Tombstones[path] = state
+		}
+	}
+	for path, versions := range c.history {
+		if _, ok := data[path]; ok {
+			if m.History == nil {
+				m.History = make(map[string][]Version)
+			}
+			m.History[path] = versions
+		}
+	}
+	for path, metadata := range c.kvMetadata {
+		if _, ok := data[path]; ok {
+			if m.Metadata == nil {
+				m.Metadata = make(map[string]*vault.SecretMetadata)
+			}
+			m.Metadata[path] = metadata
+		}
+	}
+	mm, err := m.toMap()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		out[k] = v
+	}
+	out[ManifestKey] = mm
+	return out, nil
+}
+
+// ExtractManifest removes the manifest from a loaded dump and returns it,
+// dumps written before the manifest existed return nil
+func ExtractManifest(data map[string]interface{}) (*Manifest, error) {
+	raw, ok := data[ManifestKey]
+	if !ok {
+		return nil, nil
+	}
+	delete(data, ManifestKey)
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ManifestKey, err)
+	}
+	if m.Version > FormatVersion {
+		return nil, fmt.Errorf("dump format version %d is newer than supported version %d", m.Version, FormatVersion)
+	}
+	return m, nil
+}
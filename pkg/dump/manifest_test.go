@@ -0,0 +1,70 @@
+package dump
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+func TestSuiteManifest(tt *testing.T) {
+	groups, err := ParseGroups([]string{"a=secret/a"})
+	if err != nil {
+		tt.Fatal(err)
+	}
+	c := &Config{
+		Groups:     groups,
+		failed:     map[string]Failure{"secret/a/x": {"permission-denied", "permission denied"}, "secret/b": {"wrapped", "response is wrapped"}},
+		skipped:    map[string][]string{"secret/a/y": {"k (10 bytes)"}, "secret/c": {"k (10 bytes)"}},
+		tombstones: map[string]vault.VersionState{"secret/a/z": {Version: 2, DeletionTime: "2020-01-01T00:00:00Z"}},
+		history:    map[string][]Version{"secret/a/y": {{Version: 1}}, "secret/b": {{Version: 1}}},
+		kvMetadata: map[string]*vault.SecretMetadata{"secret/c": {CurrentVersion: 1}, "secret/a/x": {CurrentVersion: 1}},
+	}
+
+	var (
+		tests = []struct {
+			description string
+			group       string
+			data        map[string]interface{}
+			normOutput  string
+		}{
+			{"Main file", "", map[string]interface{}{"secret/c": nil}, "failed=secret/b,metadata=secret/c,skipped=secret/c"},
+			{"Group file", "a", map[string]interface{}{"secret/a/y": nil}, "failed=secret/a/x,history=secret/a/y,skipped=secret/a/y,tombstone=secret/a/z"},
+		}
+	)
+	for _, test := range tests {
+		out, err := c.withManifest(test.data, test.group)
+		if err != nil {
+			tt.Errorf("FAIL %s: %v", test.description, err)
+			continue
+		}
+		m, err := ExtractManifest(out)
+		if err != nil {
+			tt.Errorf("FAIL %s: %v", test.description, err)
+			continue
+		}
+		reported := []string{}
+		for p := range m.Failed {
+			reported = append(reported, "failed="+p)
+		}
+		for p := range m.Skipped {
+			reported = append(reported, "skipped="+p)
+		}
+		for p := range m.Tombstones {
+			reported = append(reported, "tombstone="+p)
+		}
+		for p := range m.History {
+			reported = append(reported, "history="+p)
+		}
+		for p := range m.Metadata {
+			reported = append(reported, "metadata="+p)
+		}
+		sort.Strings(reported)
+		if norm := strings.Join(reported, ","); norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
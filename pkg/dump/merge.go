@@ -0,0 +1,331 @@
+package dump
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// Policies of Merge for paths holding different secrets in several dumps
+const (
+	// ConflictError fails the merge
+	ConflictError = "error"
+	// ConflictFirst keeps the secret of the first dump holding the path
+	ConflictFirst = "first"
+	// ConflictLast keeps the secret of the last dump holding the path
+	ConflictLast = "last"
+	// ConflictNewest keeps the secret of the most recently created dump
+	ConflictNewest = "newest"
+)
+
+// ConflictPolicies are the policies Merge accepts
+var ConflictPolicies = []string{ConflictError, ConflictFirst, ConflictLast, ConflictNewest}
+
+// MergeConflict is a path holding different secrets in several dumps, by
+// index, and the index of the dump whose secret was kept
+type MergeConflict struct {
+	Path  string
+	Dumps []int
+	Kept  int
+}
+
+// mergeInput is a dump converted for Merge
+type mergeInput struct {
+	manifest *Manifest
+	created  time.Time
+	secrets  map[string]interface{}
+	// skipped, failed, tombstones, history and metadata are keyed by
+	// converted paths
+	skipped    map[string][]string
+	failed     map[string]Failure
+	tombstones map[string]vault.VersionState
+	history    map[string][]Version
+	metadata   map[string]*vault.SecretMetadata
+}
+
+// Merge combines dumps, as read with their manifests, into one dump written
+// the way dumps are written now, with absolute paths, whatever format each
+// dump was written in. Secrets of paths found in several dumps with
+// different contents are kept by policy, one of ConflictPolicies, and
+// returned as conflicts. The manifest is created at the time of the oldest
+// dump, so the merged dump is never fresher than its parts.
+func Merge(dumps []map[string]interface{}, policy string) (map[string]interface{}, []MergeConflict, error) {
+	valid := false
+	for _, p := range ConflictPolicies {
+		valid = valid || p == policy
+	}
+	if !valid {
+		return nil, nil, fmt.Errorf("invalid conflict policy %q, expected one of %v", policy, ConflictPolicies)
+	}
+
+	inputs := make([]mergeInput, len(dumps))
+	holders := make(map[string][]int)
+	for i, d := range dumps {
+		in, err := convertForMerge(d)
+		if err != nil {
+			return nil, nil, fmt.Errorf("dump %d: %w", i+1, err)
+		}
+		// inventories hold metadata in place of values, they are only
+		// merged with inventories
+		if i > 0 && in.metadataOnly() != inputs[0].metadataOnly() {
+			return nil, nil, fmt.Errorf("dump %d: metadata only inventories can not be merged with dumps of values", i+1)
+		}
+		if i > 0 && in.keysOnly() != inputs[0].keysOnly() {
+			return nil, nil, fmt.Errorf("dump %d: keys only dumps can not be merged with dumps of values", i+1)
+		}
+		inputs[i] = in
+		for p := range in.secrets {
+			holders[p] = append(holders[p], i)
+		}
+	}
+
+	paths := make([]string, 0, len(holders))
+	for p := range holders {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	out := make(map[string]interface{}, len(paths)+1)
+	kept := make(map[string]int, len(paths))
+	conflicts := []MergeConflict{}
+	for _, p := range paths {
+		idx := holders[p]
+		keep := idx[0]
+		differ := false
+		for _, i := range idx[1:] {
+			differ = differ || !reflect.DeepEqual(inputs[i].secrets[p], inputs[keep].secrets[p])
+		}
+		if differ {
+			switch policy {
+			case ConflictError:
+				return nil, nil, fmt.Errorf("%s differs between dumps %v", p, oneBased(idx))
+			case ConflictLast:
+				keep = idx[len(idx)-1]
+			case ConflictNewest:
+				for _, i := range idx {
+					if inputs[i].created.IsZero() {
+						return nil, nil, fmt.Errorf("%s differs between dumps %v and dump %d has no creation time", p, oneBased(idx), i+1)
+					}
+					if inputs[i].created.After(inputs[keep].created) {
+						keep = i
+					}
+				}
+			}
+			conflicts = append(conflicts, MergeConflict{Path: p, Dumps: idx, Kept: keep})
+		}
+		out[p] = inputs[keep].secrets[p]
+		kept[p] = keep
+	}
+
+	m := NewManifest(len(out))
+	m.PathMode = PathModeAbsolute
+	m.MetadataOnly = inputs[0].metadataOnly()
+	m.KeysOnly = inputs[0].keysOnly()
+	var oldest time.Time
+	for i, in := range inputs {
+		if !in.created.IsZero() && (oldest.IsZero() || in.created.Before(oldest)) {
+			oldest = in.created
+		}
+		// merging a partial dump leaves it partial
+		if in.manifest != nil && in.manifest.Partial {
+			m.Partial = true
+		}
+		// the cluster is only kept when every dump comes from it
+		if in.manifest == nil || in.manifest.Cluster == nil {
+			m.Cluster = nil
+		} else if i == 0 {
+			c := *in.manifest.Cluster
+			m.Cluster = &c
+		} else if m.Cluster != nil && !m.Cluster.Same(*in.manifest.Cluster) {
+			m.Cluster = nil
+		}
+		if in.manifest != nil {
+			m.Namespaces = appendMissing(m.Namespaces, in.manifest.Namespaces...)
+		}
+		for p, values := range in.skipped {
+			if k, ok := kept[p]; ok && k == i {
+				if m.Skipped == nil {
+					m.Skipped = make(map[string][]string)
+				}
+				m.Skipped[p] = values
+			}
+		}
+		// the history and metadata of a path are those of the secret kept
+		for p, versions := range in.history {
+			if k, ok := kept[p]; ok && k == i {
+				if m.History == nil {
+					m.History = make(map[string][]Version)
+				}
+				m.History[p] = versions
+			}
+		}
+		for p, metadata := range in.metadata {
+			if k, ok := kept[p]; ok && k == i {
+				if m.Metadata == nil {
+					m.Metadata = make(map[string]*vault.SecretMetadata)
+				}
+				m.Metadata[p] = metadata
+			}
+		}
+		// failures and tombstones of paths another dump holds a secret
+		// for are dropped, the first dump reporting a path wins
+		for p, failure := range in.failed {
+			if _, ok := out[p]; !ok {
+				if m.Failed == nil {
+					m.Failed = make(map[string]Failure)
+				}
+				if _, ok := m.Failed[p]; !ok {
+					m.Failed[p] = failure
+				}
+			}
+		}
+		for p, state := range in.tombstones {
+			if _, ok := out[p]; !ok {
+				if m.Tombstones == nil {
+					m.Tombstones = make(map[string]vault.VersionState)
+				}
+				if _, ok := m.Tombstones[p]; !ok {
+					m.Tombstones[p] = state
+				}
+			}
+		}
+	}
+	if !oldest.IsZero() {
+		m.Created = oldest.UTC().Format(time.RFC3339)
+	}
+	mm, err := m.toMap()
+	if err != nil {
+		return nil, nil, err
+	}
+	out[ManifestKey] = mm
+	return out, conflicts, nil
+}
+
+func (in mergeInput) metadataOnly() bool {
+	return in.manifest != nil && in.manifest.MetadataOnly
+}
+
+func (in mergeInput) keysOnly() bool {
+	return in.manifest != nil && in.manifest.KeysOnly
+}
+
+// convertForMerge converts the paths, field names and values of a dump to
+// how dumps are written now, with absolute paths
+func convertForMerge(d map[string]interface{}) (mergeInput, error) {
+	secrets := make(map[string]interface{}, len(d))
+	for k, v := range d {
+		secrets[k] = v
+	}
+	m, err := ExtractManifest(secrets)
+	if err != nil {
+		return mergeInput{}, err
+	}
+	// encrypted values are bound to the paths they were written at, which
+	// merging may change, and the merged manifest has no key for them
+	if m != nil && m.Encryption != nil {
+		return mergeInput{}, errors.New("the values of the dump are encrypted, dumps written with --encrypt-values can not be merged")
+	}
+	in := mergeInput{manifest: m, secrets: make(map[string]interface{}, len(secrets))}
+	if m != nil && m.Created != "" {
+		if in.created, err = time.Parse(time.RFC3339, m.Created); err != nil {
+			return mergeInput{}, fmt.Errorf("invalid creation time: %w", err)
+		}
+	}
+
+	convert := func(p string) string {
+		if m == nil || m.PathEscaping != PathEscapingSegment {
+			p = vault.EscapePath(vault.NormalizePath(p))
+		}
+		if m != nil && m.PathMode == PathModeRelative && m.Root != "" {
+			p = m.Root + "/" + p
+		}
+		return vault.SanitizePath(p)
+	}
+	tagged := m != nil && m.ValueTagging == ValueTaggingDollar
+	for p, secret := range secrets {
+		values, ok := secret.(map[string]interface{})
+		if !ok {
+			return mergeInput{}, fmt.Errorf("%s is not a secret of fields", p)
+		}
+		copied := make(map[string]interface{}, len(values))
+		for k, v := range values {
+			if tagged && isFileRef(v) {
+				return mergeInput{}, fmt.Errorf("%s:%s is externalized, dumps with externalized values can not be merged", p, k)
+			}
+			if m == nil || m.KeyEscaping != KeyEscapingPercent {
+				k = vault.EscapeKey(k)
+			}
+			copied[k] = v
+		}
+		c := convert(p)
+		if _, ok := in.secrets[c]; ok {
+			return mergeInput{}, fmt.Errorf("%s is in the dump more than once", c)
+		}
+		in.secrets[c] = copied
+	}
+	if !tagged {
+		escapeLiterals(in.secrets)
+		tagBinary(in.secrets)
+	}
+
+	if m != nil {
+		in.skipped = make(map[string][]string, len(m.Skipped))
+		for p, values := range m.Skipped {
+			in.skipped[convert(p)] = values
+		}
+		in.failed = make(map[string]Failure, len(m.Failed))
+		for p, failure := range m.Failed {
+			in.failed[convert(p)] = failure
+		}
+		in.tombstones = make(map[string]vault.VersionState, len(m.Tombstones))
+		for p, state := range m.Tombstones {
+			in.tombstones[convert(p)] = state
+		}
+		in.history = make(map[string][]Version, len(m.History))
+		for p, versions := range m.History {
+			in.history[convert(p)] = versions
+		}
+		in.metadata = make(map[string]*vault.SecretMetadata, len(m.Metadata))
+		for p, metadata := range m.Metadata {
+			in.metadata[convert(p)] = metadata
+		}
+	}
+	return in, nil
+}
+
+// isFileRef reports whether v references an externalized value
+func isFileRef(v interface{}) bool {
+	ref, ok := v.(map[string]interface{})
+	if !ok || len(ref) != 1 {
+		return false
+	}
+	_, ok = ref[FileRefKey]
+	return ok
+}
+
+// appendMissing appends the values not in s yet
+func appendMissing(s []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, e := range s {
+			found = found || e == v
+		}
+		if !found {
+			s = append(s, v)
+		}
+	}
+	return s
+}
+
+// oneBased returns dump indexes as they are numbered in messages
+func oneBased(idx []int) []int {
+	n := make([]int, len(idx))
+	for i, v := range idx {
+		n[i] = v + 1
+	}
+	return n
+}
@@ -0,0 +1,179 @@
+package dump
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSuiteMerge(tt *testing.T) {
+	manifest := func(created string, extra ...interface{}) map[string]interface{} {
+		m := map[string]interface{}{
+			"version": 1, "created": created, "secrets": 1,
+			"path_escaping": PathEscapingSegment, "key_escaping": KeyEscapingPercent, "value_tagging": ValueTaggingDollar,
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			m[extra[i].(string)] = extra[i+1]
+		}
+		return m
+	}
+	older := manifest("2026-01-01T00:00:00Z")
+	newer := manifest("2026-02-01T00:00:00Z")
+
+	var (
+		norm    string
+		success bool
+		tests   = []struct {
+			description string
+			dumps       []map[string]interface{}
+			policy      string
+			normOutput  string
+			isSuccess   bool
+		}{
+			{
+				"Disjoint dumps", []map[string]interface{}{
+					{ManifestKey: older, "secret/a": map[string]interface{}{"k": "1"}},
+					{ManifestKey: newer, "kv/b": map[string]interface{}{"k": "2"}},
+				}, ConflictError, "kv/b=map[k:2] secret/a=map[k:1] created=2026-01-01T00:00:00Z", true,
+			},
+			{
+				"Equal secrets do not conflict", []map[string]interface{}{
+					{ManifestKey: older, "secret/a": map[string]interface{}{"k": "1"}},
+					{ManifestKey: newer, "secret/a": map[string]interface{}{"k": "1"}},
+				}, ConflictError, "secret/a=map[k:1] created=2026-01-01T00:00:00Z", true,
+			},
+			{
+				"Conflict fails", []map[string]interface{}{
+					{ManifestKey: older, "secret/a": map[string]interface{}{"k": "1"}},
+					{ManifestKey: newer, "secret/a": map[string]interface{}{"k": "2"}},
+				}, ConflictError, "", false,
+			},
+			{
+				"Conflict keeps first", []map[string]interface{}{
+					{ManifestKey: newer, "secret/a": map[string]interface{}{"k": "1"}},
+					{ManifestKey: older, "secret/a": map[string]interface{}{"k": "2"}},
+				}, ConflictFirst, "secret/a=map[k:1] created=2026-01-01T00:00:00Z conflict=secret/a:0", true,
+			},
+			{
+				"Conflict keeps last", []map[string]interface{}{
+					{ManifestKey: newer, "secret/a": map[string]interface{}{"k": "1"}},
+					{ManifestKey: older, "secret/a": map[string]interface{}{"k": "2"}},
+				}, ConflictLast, "secret/a=map[k:2] created=2026-01-01T00:00:00Z conflict=secret/a:1", true,
+			},
+			{
+				"Conflict keeps newest", []map[string]interface{}{
+					{ManifestKey: newer, "secret/a": map[string]interface{}{"k": "1"}},
+					{ManifestKey: older, "secret/a": map[string]interface{}{"k": "2"}},
+				}, ConflictNewest, "secret/a=map[k:1] created=2026-01-01T00:00:00Z conflict=secret/a:0", true,
+			},
+			{
+				"Newest without creation time", []map[string]interface{}{
+					{ManifestKey: newer, "secret/a": map[string]interface{}{"k": "1"}},
+					{"secret/a": map[string]interface{}{"k": "2"}},
+				}, ConflictNewest, "", false,
+			},
+			{
+				"Legacy dump converted", []map[string]interface{}{
+					{"secret/a%b": map[string]interface{}{"k%": "\xff", "t": map[string]interface{}{"$file": "x"}}},
+				}, ConflictError, "secret/a%25b=map[k%25:map[$binary:/w==] t:map[$literal:map[$file:x]]] created=now", true,
+			},
+			{
+				"Relative paths made absolute", []map[string]interface{}{
+					{ManifestKey: manifest("2026-01-01T00:00:00Z", "path_mode", PathModeRelative, "root", "secret/data"), "a": map[string]interface{}{"k": "1"}},
+					{ManifestKey: newer, "secret/data/a": map[string]interface{}{"k": "1"}},
+				}, ConflictError, "secret/data/a=map[k:1] created=2026-01-01T00:00:00Z", true,
+			},
+			{
+				"Failures of merged secrets dropped", []map[string]interface{}{
+					{ManifestKey: manifest("2026-01-01T00:00:00Z", "failed", map[string]interface{}{"secret/a": map[string]interface{}{}, "secret/b": map[string]interface{}{}})},
+					{ManifestKey: newer, "secret/a": map[string]interface{}{"k": "1"}},
+				}, ConflictError, "secret/a=map[k:1] created=2026-01-01T00:00:00Z failed=secret/b", true,
+			},
+			{
+				"Namespaces kept", []map[string]interface{}{
+					{ManifestKey: manifest("2026-01-01T00:00:00Z", "namespaces", []interface{}{"team-a"}), "team-a/secret/a": map[string]interface{}{"k": "1"}},
+					{ManifestKey: manifest("2026-02-01T00:00:00Z", "namespaces", []interface{}{"team-a", "team-b"}), "team-b/secret/a": map[string]interface{}{"k": "1"}},
+				}, ConflictError, "team-a/secret/a=map[k:1] team-b/secret/a=map[k:1] created=2026-01-01T00:00:00Z namespaces=[team-a team-b]", true,
+			},
+			{
+				"Inventories kept metadata only", []map[string]interface{}{
+					{ManifestKey: manifest("2026-01-01T00:00:00Z", "metadata_only", true), "secret/a": map[string]interface{}{"current_version": 1}},
+					{ManifestKey: manifest("2026-02-01T00:00:00Z", "metadata_only", true), "secret/b": map[string]interface{}{}},
+				}, ConflictError, "secret/a=map[current_version:1] secret/b=map[] created=2026-01-01T00:00:00Z metadata_only", true,
+			},
+			{
+				"Inventory and values", []map[string]interface{}{
+					{ManifestKey: older, "secret/a": map[string]interface{}{"k": "1"}},
+					{ManifestKey: manifest("2026-02-01T00:00:00Z", "metadata_only", true), "secret/b": map[string]interface{}{}},
+				}, ConflictError, "", false,
+			},
+			{
+				"Partial dump kept partial", []map[string]interface{}{
+					{ManifestKey: older, "secret/a": map[string]interface{}{"k": "1"}},
+					{ManifestKey: manifest("2026-02-01T00:00:00Z", "partial", true), "secret/b": map[string]interface{}{"k": "2"}},
+				}, ConflictError, "secret/a=map[k:1] secret/b=map[k:2] created=2026-01-01T00:00:00Z partial", true,
+			},
+			{
+				"Externalized values", []map[string]interface{}{
+					{ManifestKey: older, "secret/a": map[string]interface{}{"k": map[string]interface{}{"$file": "x"}}},
+				}, ConflictError, "", false,
+			},
+			{
+				"Encrypted values", []map[string]interface{}{
+					{ManifestKey: manifest("2026-01-01T00:00:00Z", "value_encryption", map[string]interface{}{"algorithm": ValueEncryptionAES256GCM, "kms_key": "k", "key": "d3JhcHBlZA=="}), "secret/a": map[string]interface{}{"k": map[string]interface{}{EncryptedKey: "c2VhbGVk"}}},
+					{ManifestKey: newer, "secret/b": map[string]interface{}{"k": "2"}},
+				}, ConflictError, "", false,
+			},
+			{
+				"Invalid policy", []map[string]interface{}{}, "random", "", false,
+			},
+		}
+	)
+	for _, test := range tests {
+		out, conflicts, err := Merge(test.dumps, test.policy)
+		success = (err == nil)
+		norm = ""
+		if success {
+			m, _ := ExtractManifest(out)
+			parts := []string{}
+			for p, s := range out {
+				parts = append(parts, fmt.Sprintf("%s=%v", p, s))
+			}
+			sort.Strings(parts)
+			// without creation times the merge is created now
+			if c, err := time.Parse(time.RFC3339, m.Created); err == nil && time.Since(c) < time.Minute {
+				m.Created = "now"
+			}
+			parts = append(parts, "created="+m.Created)
+			if len(m.Namespaces) > 0 {
+				parts = append(parts, fmt.Sprintf("namespaces=%v", m.Namespaces))
+			}
+			if m.MetadataOnly {
+				parts = append(parts, "metadata_only")
+			}
+			if m.Partial {
+				parts = append(parts, "partial")
+			}
+			for p := range m.Failed {
+				parts = append(parts, "failed="+p)
+			}
+			for _, c := range conflicts {
+				parts = append(parts, fmt.Sprintf("conflict=%s:%d", c.Path, c.Kept))
+			}
+			if m.Secrets != len(out) {
+				parts = append(parts, "wrong count")
+			}
+			norm = strings.Join(parts, " ")
+		}
+
+		if success == test.isSuccess && (!success || norm == test.normOutput) {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		}
+	}
+}
@@ -6,6 +6,12 @@ import (
 	"os"
 )
 
+// Encodings are the encodings dumps can be written in
+var Encodings = []string{"json", "yaml"}
+
+// Kinds are the kinds of output dumps can be written to
+var Kinds = []string{"file", "stdout", "s3", "kafka"}
+
 type output struct {
 	path     string
 	encoding string
@@ -33,8 +39,7 @@ func (o *output) setPath(s string) bool {
 	return true
 }
 func (o *output) setEncoding(s string) bool {
-	expectedEncodings := []string{"json", "yaml"}
-	for _, e := range expectedEncodings {
+	for _, e := range Encodings {
 		if s == e {
 			o.encoding = s
 			return true
@@ -42,12 +47,11 @@ func (o *output) setEncoding(s string) bool {
 	}
 
 	log.SetOutput(os.Stderr)
-	log.Printf("Unexpected encoding %s, we only accept: %v", s, expectedEncodings)
+	log.Printf("Unexpected encoding %s, we only accept: %v", s, Encodings)
 	return false
 }
 func (o *output) setKind(s string) bool {
-	expectedKinds := []string{"file", "stdout", "s3"}
-	for _, k := range expectedKinds {
+	for _, k := range Kinds {
 		if s == k {
 			o.kind = s
 			return true
@@ -0,0 +1,82 @@
+package dump
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// Plan is what a dry run found would be dumped, see DryRun
+type Plan struct {
+	// Paths are the data paths of the secrets, sorted
+	Paths []string
+	// Keys is the number of key names found, those of KV version 2 secrets
+	// on Vault 1.10 and later, see vault.ReadSubkeys
+	Keys int
+	// Size estimates the bytes of the dump without its values, its paths
+	// and the key names found encoded in the output encoding
+	Size int
+	// Failed holds why each path that could not be listed or read failed
+	Failed map[string]Failure
+}
+
+// plan records path in place of its secret for a dry run, with the key names
+// of KV version 2 secrets from the subkeys endpoint, so no value is read.
+// Other secrets are recorded without keys.
+func (s *SecretScraper) plan(ctx context.Context, path string) {
+	s.slots <- struct{}{}
+	subkeys, err := s.VaultConfig.ReadSubkeys(path)
+	<-s.slots
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		s.fail(path, vault.ClassifyError(err), err.Error())
+		return
+	}
+	found := secret{path: path, data: map[string]interface{}{}}
+	if subkeys != nil {
+		found.data = subkeys.Data["data"]
+		found.version = vault.SecretVersion(subkeys)
+	}
+	s.secrets.channel <- found
+	log.Println("would dump:", path)
+}
+
+// newPlan returns the plan of what secretScraper found
+func (c *Config) newPlan(secretScraper *SecretScraper) (*Plan, error) {
+	p := &Plan{Paths: make([]string, 0, len(secretScraper.Data)), Failed: secretScraper.Failed}
+	data := make(map[string]interface{}, len(secretScraper.Data))
+	for path, keys := range secretScraper.Data {
+		p.Paths = append(p.Paths, path)
+		data[vault.EscapePath(path)] = escapeKeys(keys)
+		p.Keys += countKeys(keys)
+	}
+	sort.Strings(p.Paths)
+	encoded, err := c.encode(data)
+	if err != nil {
+		return nil, err
+	}
+	p.Size = len(encoded)
+	return p, nil
+}
+
+// countKeys returns the number of key names of keys, nested ones included
+func countKeys(keys interface{}) int {
+	m, ok := keys.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	n := len(m)
+	for _, v := range m {
+		n += countKeys(v)
+	}
+	return n
+}
+
+// Plan returns what the dry run found, nil until Secrets ran with DryRun
+func (c *Config) Plan() *Plan {
+	return c.plan
+}
@@ -0,0 +1,39 @@
+package dump
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSuitePlan(tt *testing.T) {
+	out, err := NewOutput("/tmp", "json", "file")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	c := &Config{Output: out}
+	var (
+		tests = []struct {
+			description string
+			data        map[string]interface{}
+			normOutput  string
+		}{
+			{"Nothing found", map[string]interface{}{}, "[] 0 2"},
+			{"Secrets without keys", map[string]interface{}{"kv1/b": map[string]interface{}{}, "kv1/a": map[string]interface{}{}}, "[kv1/a kv1/b] 0 23"},
+			{"Key names", map[string]interface{}{"secret/data/app": map[string]interface{}{"user": nil, "db": map[string]interface{}{"host": nil}}},
+				"[secret/data/app] 3 52"},
+		}
+	)
+	for _, test := range tests {
+		p, err := c.newPlan(&SecretScraper{Data: test.data, Failed: map[string]Failure{}})
+		if err != nil {
+			tt.Errorf("FAIL %s: %s", test.description, err)
+			continue
+		}
+		norm := fmt.Sprintf("%v %d %d", p.Paths, p.Keys, p.Size)
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package dump
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+)
+
+// Processor rewrites a secret after it is read from Vault and before it is
+// encoded, e.g. to re-wrap data keys embedded in values with another KMS key
+// during a migration. It is given the Vault path and fields of the secret
+// and returns the fields to dump. Errors must not include values, they are
+// recorded in the manifest.
+type Processor interface {
+	Process(path string, secret map[string]interface{}) (map[string]interface{}, error)
+}
+
+// ProcessorFunc is a function used as a Processor
+type ProcessorFunc func(path string, secret map[string]interface{}) (map[string]interface{}, error)
+
+// Process calls f
+func (f ProcessorFunc) Process(path string, secret map[string]interface{}) (map[string]interface{}, error) {
+	return f(path, secret)
+}
+
+// processRequest is written to an exec processor for each secret, one JSON
+// object per line
+type processRequest struct {
+	Path string                 `json:"path"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// processResponse is read back from an exec processor for each request, one
+// JSON object per line holding the fields to dump or why it failed
+type processResponse struct {
+	Data  map[string]interface{} `json:"data"`
+	Error string                 `json:"error"`
+}
+
+// ExecProcessor is a Processor running a program for the whole dump. Every
+// secret is written to its stdin as {"path": "...", "data": {...}} on a line
+// of its own and it answers each with {"data": {...}}, or {"error": "..."}
+// to fail the secret, on a line of stdout. Its stderr is passed through.
+type ExecProcessor struct {
+	name string
+	cmd  *exec.Cmd
+	in   io.WriteCloser
+	enc  *json.Encoder
+	dec  *json.Decoder
+	mu   sync.Mutex
+}
+
+// StartExec starts the program of an ExecProcessor
+func StartExec(name string, args ...string) (*ExecProcessor, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("post-processor %s: %w", name, err)
+	}
+	return &ExecProcessor{
+		name: name,
+		cmd:  cmd,
+		in:   in,
+		enc:  json.NewEncoder(in),
+		dec:  json.NewDecoder(bufio.NewReader(out)),
+	}, nil
+}
+
+// Process has the program rewrite the secret
+func (e *ExecProcessor) Process(path string, secret map[string]interface{}) (map[string]interface{}, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.enc.Encode(processRequest{Path: path, Data: secret}); err != nil {
+		return nil, fmt.Errorf("post-processor %s: %w", e.name, err)
+	}
+	var resp processResponse
+	if err := e.dec.Decode(&resp); err != nil {
+		return nil, fmt.Errorf("post-processor %s: %w", e.name, err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("post-processor %s: %s", e.name, resp.Error)
+	}
+	if resp.Data == nil {
+		return nil, fmt.Errorf("post-processor %s returned no data", e.name)
+	}
+	return resp.Data, nil
+}
+
+// Close closes the stdin of the program and waits for it to exit
+func (e *ExecProcessor) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.in.Close()
+	if err := e.cmd.Wait(); err != nil {
+		return fmt.Errorf("post-processor %s: %w", e.name, err)
+	}
+	return nil
+}
+
+// errNoSecret is returned for processors returning no fields
+var errNoSecret = errors.New("returned no secret")
+
+// process runs the processors in order over every secret read, a secret a
+// processor fails on is recorded as failed instead of being dumped
+func (s *SecretScraper) process(processors []Processor) {
+	if len(processors) == 0 {
+		return
+	}
+	// in the order of the paths, so processors see the same sequence on
+	// every dump however the secrets were read
+	paths := make([]string, 0, len(s.Data))
+	for p := range s.Data {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		secret, ok := s.Data[p].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var err error
+		for _, proc := range processors {
+			if secret, err = proc.Process(p, secret); err == nil && secret == nil {
+				err = errNoSecret
+			}
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			delete(s.Data, p)
+			delete(s.Versions, p)
+			delete(s.Updated, p)
+			s.fail(p, "post-processor", err.Error())
+			continue
+		}
+		s.Data[p] = secret
+	}
+}
@@ -0,0 +1,93 @@
+package dump
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHelperPostProcessor is the program of the exec processor under test,
+// it upper cases every string value and fails secret/fail
+func TestHelperPostProcessor(tt *testing.T) {
+	if os.Getenv("VAULT_DUMP_HELPER_PROCESS") != "1" {
+		return
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req processRequest
+		json.Unmarshal(scanner.Bytes(), &req)
+		if req.Path == "secret/fail" {
+			json.NewEncoder(os.Stdout).Encode(processResponse{Error: "refused"})
+			continue
+		}
+		resp := processResponse{Data: map[string]interface{}{}}
+		for k, v := range req.Data {
+			if s, ok := v.(string); ok {
+				v = strings.ToUpper(s)
+			}
+			resp.Data[k] = v
+		}
+		json.NewEncoder(os.Stdout).Encode(resp)
+	}
+	os.Exit(0)
+}
+
+func TestSuiteProcess(tt *testing.T) {
+	tt.Setenv("VAULT_DUMP_HELPER_PROCESS", "1")
+	e, err := StartExec(os.Args[0], "-test.run=TestHelperPostProcessor")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	tag := ProcessorFunc(func(path string, secret map[string]interface{}) (map[string]interface{}, error) {
+		if path == "secret/drop" {
+			return nil, nil
+		}
+		secret["processed"] = true
+		return secret, nil
+	})
+
+	s := &SecretScraper{
+		Data: map[string]interface{}{
+			"secret/a":    map[string]interface{}{"k": "v", "n": 1.0},
+			"secret/fail": map[string]interface{}{"k": "v"},
+			"secret/drop": map[string]interface{}{"k": "v"},
+		},
+		Versions: map[string]int{"secret/a": 1, "secret/fail": 2},
+		Updated:  map[string]time.Time{},
+		Failed:   map[string]Failure{},
+	}
+	s.process([]Processor{e, tag})
+	if err := e.Close(); err != nil {
+		tt.Fatal(err)
+	}
+
+	var (
+		tests = []struct {
+			description string
+			path        string
+			normOutput  string
+		}{
+			{"Rewritten by both", "secret/a", "map[k:V n:1 processed:true]"},
+			{"Failed by the program", "secret/fail", "failed post-processor: post-processor " + os.Args[0] + ": refused"},
+			{"No secret returned", "secret/drop", "failed post-processor: returned no secret"},
+		}
+	)
+	for _, test := range tests {
+		norm := fmt.Sprint(s.Data[test.path])
+		if f, ok := s.Failed[test.path]; ok {
+			norm = fmt.Sprintf("failed %s: %s", f.Category, f.Reason)
+			if _, ok := s.Versions[test.path]; ok {
+				norm += " with version"
+			}
+		}
+		if norm == test.normOutput {
+			tt.Logf("PASS %s", test.description)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package dump
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/file"
+)
+
+// QuarantineExt is the extension of the quarantine file written next to a
+// dump some secrets failed to be dumped for
+const QuarantineExt = "quarantine.json"
+
+// Quarantine lists the secrets left out of a dump because they could not be
+// dumped, so that the dump is not mistaken for a complete one. It never
+// holds values.
+type Quarantine struct {
+	Version int    `json:"version"`
+	Created string `json:"created"`
+	// Dump is the file name of the dump without its extension
+	Dump string `json:"dump"`
+	// Secrets is the number of secrets the dump holds
+	Secrets int `json:"secrets"`
+	// Failed holds, per path, why the secret could not be dumped
+	Failed map[string]Failure `json:"failed"`
+}
+
+// QuarantineFilename is the name of the quarantine file of the dump named
+// filename, without its extension
+func QuarantineFilename(filename string) string {
+	return filename + "." + QuarantineExt
+}
+
+// quarantined reports whether the output gets a quarantine file
+func (c *Config) quarantined() bool {
+	if c.Output == nil || len(c.failed) == 0 {
+		return false
+	}
+	kind := c.Output.GetKind()
+	return kind != "stdout" && kind != "kafka"
+}
+
+// writeQuarantine writes the quarantine file of the dump when secrets
+// failed and removes the one of an earlier dump to the same file otherwise
+func (c *Config) writeQuarantine(secrets int) error {
+	fp := fmt.Sprintf("%s/%s", c.Output.GetPath(), QuarantineFilename(c.filename()))
+	if !c.quarantined() {
+		if err := os.Remove(fp); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	output, err := json.Marshal(&Quarantine{
+		Version: FormatVersion,
+		Created: time.Now().UTC().Format(time.RFC3339),
+		Dump:    c.filename(),
+		Secrets: secrets,
+		Failed:  c.failed,
+	})
+	if err != nil {
+		return err
+	}
+	if ok := file.WriteFile(fp, string(output)); !ok {
+		return fmt.Errorf("failed to write %v", fp)
+	}
+	return nil
+}
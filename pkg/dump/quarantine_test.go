@@ -0,0 +1,75 @@
+package dump
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSuiteQuarantine(tt *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-dump-test-*")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		tests = []struct {
+			description string
+			kind        string
+			failed      map[string]Failure
+			normOutput  string
+		}{
+			{"Failed secrets", "file", map[string]Failure{"secret/a": {"permission-denied", "permission denied"}, "secret/b": {"not-found", "not found"}},
+				"manifest=d.quarantine.json,file=secret/a:permission-denied,secret/b:not-found"},
+			{"No failures removes the earlier file", "file", nil, "manifest=,file=none"},
+			{"Standard output", "stdout", map[string]Failure{"secret/a": {"permission-denied", "permission denied"}}, "manifest=,file=none"},
+		}
+	)
+	for _, test := range tests {
+		out, err := NewOutput(dir, "json", test.kind)
+		if err != nil {
+			tt.Fatal(err)
+		}
+		c := &Config{Filename: "d", Output: out, failed: test.failed}
+		if err := c.writeQuarantine(1); err != nil {
+			tt.Errorf("FAIL %s: %v", test.description, err)
+			continue
+		}
+		data, err := c.withManifest(map[string]interface{}{"secret/c": map[string]interface{}{}}, "")
+		if err != nil {
+			tt.Errorf("FAIL %s: %v", test.description, err)
+			continue
+		}
+		m, err := ExtractManifest(data)
+		if err != nil {
+			tt.Errorf("FAIL %s: %v", test.description, err)
+			continue
+		}
+
+		listed := "none"
+		if b, err := ioutil.ReadFile(filepath.Join(dir, QuarantineFilename("d"))); err == nil {
+			q := &Quarantine{}
+			if err := json.Unmarshal(b, q); err != nil {
+				tt.Errorf("FAIL %s: %v", test.description, err)
+				continue
+			}
+			paths := []string{}
+			for p, f := range q.Failed {
+				paths = append(paths, p+":"+f.Category)
+			}
+			sort.Strings(paths)
+			listed = strings.Join(paths, ",")
+		}
+		if norm := fmt.Sprintf("manifest=%s,file=%s", m.Quarantine, listed); norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package dump
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// ParseSince parses s as a duration before now, e.g. 72h, or an RFC 3339
+// timestamp
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("invalid duration %q, expected a positive one", s)
+		}
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected a duration such as 72h or an RFC 3339 timestamp", s)
+	}
+	return t, nil
+}
+
+// modifiedAfter reports whether the secret with the KV version 2 metadata m
+// was updated after since. Secrets of other mounts have no metadata, and no
+// modification time, they never are.
+func modifiedAfter(m *vault.SecretMetadata, since time.Time) bool {
+	if m == nil {
+		return false
+	}
+	updated, err := time.Parse(time.RFC3339Nano, m.UpdatedTime)
+	return err == nil && updated.After(since)
+}
@@ -0,0 +1,64 @@
+package dump
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+func TestSuiteSince(tt *testing.T) {
+	now := time.Date(2024, 5, 4, 12, 0, 0, 0, time.UTC)
+	var (
+		tests = []struct {
+			description string
+			input       string
+			normOutput  string
+		}{
+			{"Duration", "72h", "2024-05-01T12:00:00Z"},
+			{"Minutes", "90m", "2024-05-04T10:30:00Z"},
+			{"Timestamp", "2024-05-02T08:00:00+02:00", "2024-05-02T06:00:00Z"},
+			{"Negative duration", "-1h", "error"},
+			{"Days", "3d", "error"},
+			{"Date only", "2024-05-02", "error"},
+		}
+	)
+	for _, test := range tests {
+		t, err := ParseSince(test.input, now)
+		norm := t.UTC().Format(time.RFC3339)
+		if err != nil {
+			norm = "error"
+		}
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteModifiedAfter(tt *testing.T) {
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var (
+		tests = []struct {
+			description string
+			metadata    *vault.SecretMetadata
+			normOutput  string
+		}{
+			{"Modified after", &vault.SecretMetadata{UpdatedTime: "2024-05-02T06:00:00.123456789Z"}, "true"},
+			{"Modified before", &vault.SecretMetadata{UpdatedTime: "2024-04-30T06:00:00.123456789Z"}, "false"},
+			{"Modified at", &vault.SecretMetadata{UpdatedTime: "2024-05-01T12:00:00Z"}, "false"},
+			{"No updated time", &vault.SecretMetadata{}, "false"},
+			{"Not KV version 2", nil, "false"},
+		}
+	)
+	for _, test := range tests {
+		norm := fmt.Sprintf("%v", modifiedAfter(test.metadata, since))
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package dump
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// groupName restricts group names to what is safe to use in a file name
+var groupName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// unsafeName matches the runs of characters a group name can not hold
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// Group is a portion of a dump selected by path prefix which is written to
+// its own artifact and encrypted with its own key
+type Group struct {
+	Name   string
+	Prefix string
+	KMSKey string
+	// AgeRecipient encrypts the artifact to an age public key instead of
+	// KMSKey, for teams without access to KMS
+	AgeRecipient string
+}
+
+// ParseGroups converts specs of the form name=prefix[=key] into groups, the
+// key is a KMS key or an age recipient, age1..., and prefixes are escaped with
+// vault.EscapePath to match the dumped paths
+func ParseGroups(specs []string) ([]Group, error) {
+	groups := make([]Group, 0, len(specs))
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid split %q, expected name=prefix[=kms-key|age-recipient]", spec)
+		}
+		if !groupName.MatchString(parts[0]) {
+			return nil, fmt.Errorf("invalid split name %q, only letters, digits, '_' and '-' are allowed", parts[0])
+		}
+		if seen[parts[0]] {
+			return nil, fmt.Errorf("duplicate split name %q", parts[0])
+		}
+		seen[parts[0]] = true
+
+		g := Group{
+			Name:   parts[0],
+			Prefix: vault.EscapePath(vault.SanitizePath(parts[1])),
+		}
+		switch {
+		case len(parts) < 3:
+		case isAgeRecipient(parts[2]):
+			if _, err := age.ParseX25519Recipient(parts[2]); err != nil {
+				return nil, fmt.Errorf("invalid age recipient of split %q: %w", parts[0], err)
+			}
+			g.AgeRecipient = parts[2]
+		default:
+			g.KMSKey = parts[2]
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// PathGroups returns a group for each of paths, as given to dump them,
+// selecting the secrets below its data path in resolved and named after the
+// path with '-' in place of the characters a name can not hold
+func PathGroups(paths, resolved []string) ([]Group, error) {
+	groups := make([]Group, 0, len(paths))
+	seen := make(map[string]string)
+	for i, p := range paths {
+		name := strings.Trim(unsafeName.ReplaceAllString(vault.SanitizePath(p), "-"), "-")
+		if name == "" {
+			return nil, fmt.Errorf("no file name for path %q", p)
+		}
+		if other, ok := seen[name]; ok {
+			return nil, fmt.Errorf("paths %q and %q would both be written as %q", other, p, name)
+		}
+		seen[name] = p
+		groups = append(groups, Group{
+			Name:   name,
+			Prefix: vault.EscapePath(vault.SanitizePath(resolved[i])),
+		})
+	}
+	return groups, nil
+}
+
+// SplitByGroup partitions secrets by the group with the longest matching
+// prefix, secrets that match no group are returned under the empty name.
+// Paths are expected to be escaped like the group prefixes are.
+func SplitByGroup(data map[string]interface{}, groups []Group) map[string]map[string]interface{} {
+	result := make(map[string]map[string]interface{})
+	for path, secret := range data {
+		name := groupOf(path, groups)
+		if _, ok := result[name]; !ok {
+			result[name] = make(map[string]interface{})
+		}
+		result[name][path] = secret
+	}
+	return result
+}
+
+// groupOf returns the name of the group with the longest prefix matching the
+// escaped path, or the empty name when none matches
+func groupOf(path string, groups []Group) string {
+	name := ""
+	longest := -1
+	p := vault.SanitizePath(path)
+	for _, g := range groups {
+		if (p == g.Prefix || strings.HasPrefix(p, g.Prefix+"/")) && len(g.Prefix) > longest {
+			name = g.Name
+			longest = len(g.Prefix)
+		}
+	}
+	return name
+}
+
+// GroupFilename returns the filename, without extension, used for a group
+func GroupFilename(filename, group string) string {
+	if group == "" {
+		return filename
+	}
+	return fmt.Sprintf("%s-%s", filename, group)
+}
@@ -0,0 +1,88 @@
+package dump
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSuiteSplit(tt *testing.T) {
+	const recipient = "age143cejy77cpw0ssu5ece783fe20n7p6dg0euhp3jma227znare9qqsynjz2"
+	var (
+		norm    string
+		success bool
+		tests   = []struct {
+			description string
+			action      string
+			inputs      []string
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Parse group", "Parse", []string{"team=secret/team/=arn:aws:kms:key"}, "team,secret/team,arn:aws:kms:key", true},
+			{"Parse group without key", "Parse", []string{"team=secret/team"}, "team,secret/team,", true},
+			{"Parse group with age recipient", "Parse", []string{"team=secret/team=" + recipient}, "team,secret/team,age:" + recipient, true},
+			{"Parse group with invalid age recipient", "Parse", []string{"team=secret/team=age1xyz"}, "", false},
+			{"Parse invalid group", "Parse", []string{"team"}, "", false},
+			{"Parse duplicate group", "Parse", []string{"a=secret/a", "a=secret/b"}, "", false},
+			{"Parse group name with path", "Parse", []string{"../x=secret/a"}, "", false},
+			{"Parse group name with dot", "Parse", []string{"team.a=secret/a"}, "", false},
+			{"Parse escaped prefix", "Parse", []string{"team=secret/a%b/./c"}, "team,secret/a%25b/%2E/c,", true},
+			{"Split longest prefix", "Split", []string{"a=secret/a", "ab=secret/a/b"}, "=secret/c,a=secret/a/x,ab=secret/a/b/y", true},
+			{"Group per path", "Path", []string{"secret/team-a/=secret/data/team-a", "kv/app=kv/app"}, "secret-team-a,secret/data/team-a;kv-app,kv/app", true},
+			{"Group per escaped path", "Path", []string{"secret/a%b=secret/data/a%b"}, "secret-a-b,secret/data/a%25b", true},
+			{"Group per path with the same name", "Path", []string{"secret/a.b=secret/data/a.b", "secret/a-b=secret/data/a-b"}, "", false},
+		}
+	)
+	for _, test := range tests {
+		switch test.action {
+		case "Parse":
+			groups, err := ParseGroups(test.inputs)
+			success = (err == nil)
+			norm = ""
+			if success {
+				key := groups[0].KMSKey
+				if groups[0].AgeRecipient != "" {
+					key = "age:" + groups[0].AgeRecipient
+				}
+				norm = strings.Join([]string{groups[0].Name, groups[0].Prefix, key}, ",")
+			}
+		case "Split":
+			groups, err := ParseGroups(test.inputs)
+			success = (err == nil)
+			data := map[string]interface{}{
+				"secret/a/x":   nil,
+				"secret/a/b/y": nil,
+				"secret/c":     nil,
+			}
+			out := []string{}
+			for name, secrets := range SplitByGroup(data, groups) {
+				for path := range secrets {
+					out = append(out, name+"="+path)
+				}
+			}
+			sort.Strings(out)
+			norm = strings.Join(out, ",")
+		case "Path":
+			paths, resolved := []string{}, []string{}
+			for _, input := range test.inputs {
+				parts := strings.SplitN(input, "=", 2)
+				paths, resolved = append(paths, parts[0]), append(resolved, parts[1])
+			}
+			groups, err := PathGroups(paths, resolved)
+			success = (err == nil)
+			out := []string{}
+			for _, g := range groups {
+				out = append(out, g.Name+","+g.Prefix)
+			}
+			norm = strings.Join(out, ";")
+		}
+
+		if success == test.isSuccess && (!success || norm == test.normOutput) {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package dump
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dathan/go-vault-dump/pkg/file"
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+const (
+	// FileRefKey marks a value that was written to a separate file, the value
+	// is the location of that file relative to the dump
+	FileRefKey = "$file"
+	// BinaryKey marks a value that is not valid UTF-8, the value is the
+	// standard base64 encoding of the original bytes
+	BinaryKey = "$binary"
+	// LiteralKey wraps a value that would otherwise be read as a tag, that is
+	// an object with a single key starting with '$'
+	LiteralKey = "$literal"
+)
+
+// valueSize returns the size in bytes of a secret value as it would be encoded
+func valueSize(v interface{}) int {
+	if s, ok := v.(string); ok {
+		return len(s)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
+// escapeKeys returns a copy of a secret with every field name escaped with
+// vault.EscapeKey, values other than objects are returned as they are
+func escapeKeys(secret interface{}) interface{} {
+	values, ok := secret.(map[string]interface{})
+	if !ok {
+		return secret
+	}
+	escaped := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		escaped[vault.EscapeKey(k)] = v
+	}
+	return escaped
+}
+
+// dropOversized removes values larger than max bytes from every secret and
+// returns, per path, a description of each removed value
+func dropOversized(data map[string]interface{}, max int) map[string][]string {
+	skipped := make(map[string][]string)
+	if max <= 0 {
+		return skipped
+	}
+	for path, secret := range data {
+		values, ok := secret.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range values {
+			if size := valueSize(v); size > max {
+				delete(values, k)
+				skipped[path] = append(skipped[path], fmt.Sprintf("%s (%d bytes)", k, size))
+			}
+		}
+		sort.Strings(skipped[path])
+	}
+	return skipped
+}
+
+// externalize writes string values larger than min bytes into files below dir
+// and replaces them with a reference relative to the dump, paths are expected
+// to be escaped already and field names are escaped the same way
+func externalize(data map[string]interface{}, dir, base string, min int) error {
+	if min <= 0 {
+		return nil
+	}
+	for path, secret := range data {
+		values, ok := secret.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range values {
+			s, ok := v.(string)
+			if !ok || len(s) <= min {
+				continue
+			}
+			ref := filepath.Join(base, filepath.FromSlash(path), vault.EscapePathSegment(k))
+			if !strings.HasPrefix(ref, base+string(filepath.Separator)) {
+				return fmt.Errorf("refusing to externalize %s:%s outside of %s", path, k, base)
+			}
+			if ok := file.WriteFile(filepath.Join(dir, ref), s); !ok {
+				return fmt.Errorf("failed to externalize %s:%s", path, k)
+			}
+			values[k] = map[string]interface{}{FileRefKey: filepath.ToSlash(ref)}
+			log.Printf("externalized %s:%s to %s\n", path, k, ref)
+		}
+	}
+	return nil
+}
+
+// isTagLike reports whether v has the shape of a tagged value
+func isTagLike(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return false
+	}
+	for k := range m {
+		return strings.HasPrefix(k, "$")
+	}
+	return false
+}
+
+// escapeLiterals wraps values that look like tags so they are restored as
+// they are, it must run before any tags are added
+func escapeLiterals(data map[string]interface{}) {
+	for _, secret := range data {
+		values, ok := secret.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range values {
+			if isTagLike(v) {
+				values[k] = map[string]interface{}{LiteralKey: v}
+			}
+		}
+	}
+}
+
+// tagBinary replaces string values that are not valid UTF-8 with a tagged
+// base64 value so they survive encoding to JSON and YAML unchanged
+func tagBinary(data map[string]interface{}) {
+	for _, secret := range data {
+		values, ok := secret.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range values {
+			if s, ok := v.(string); ok && !utf8.ValidString(s) {
+				values[k] = map[string]interface{}{BinaryKey: base64.StdEncoding.EncodeToString([]byte(s))}
+			}
+		}
+	}
+}
+
+// UntagValue returns the original value of a binary or literal tagged value,
+// and false if the value is not tagged with either
+func UntagValue(v interface{}) (interface{}, bool, error) {
+	tag, ok := v.(map[string]interface{})
+	if !ok || len(tag) != 1 {
+		return nil, false, nil
+	}
+	if literal, ok := tag[LiteralKey]; ok {
+		return literal, true, nil
+	}
+	encoded, ok := tag[BinaryKey].(string)
+	if !ok {
+		return nil, false, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid %s value: %w", BinaryKey, err)
+	}
+	return string(b), true, nil
+}
@@ -0,0 +1,114 @@
+package dump
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSuiteValues(tt *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-dump-test-*")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		norm    string
+		success bool
+		tests   = []struct {
+			description string
+			action      string
+			inputs      map[string]interface{}
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Drop nothing below limit", "Drop", map[string]interface{}{"secret/a": map[string]interface{}{"k": "1234"}}, "|k", true},
+			{"Drop oversized value", "Drop", map[string]interface{}{"secret/a": map[string]interface{}{"k": "12345", "small": "1"}}, "secret/a=k (5 bytes)|small", true},
+			{"Drop oversized structured value", "Drop", map[string]interface{}{"secret/a": map[string]interface{}{"k": []interface{}{"123"}}}, "secret/a=k (7 bytes)|", true},
+			{"Externalize large value", "Externalize", map[string]interface{}{"secret/a": map[string]interface{}{"cert": "12345"}}, "dump.files/secret/a/cert=12345", true},
+			{"Externalize escaped field name", "Externalize", map[string]interface{}{"secret/a": map[string]interface{}{"..": "12345"}}, "dump.files/secret/a/%2E%2E=12345", true},
+			{"Externalize field name with slash", "Externalize", map[string]interface{}{"secret/a": map[string]interface{}{"b/c": "12345"}}, "dump.files/secret/a/b%2Fc=12345", true},
+			{"Keep small value", "Externalize", map[string]interface{}{"secret/a": map[string]interface{}{"k": "1234"}}, "k=1234", true},
+			{"Refuse path outside of files", "Externalize", map[string]interface{}{"../..": map[string]interface{}{"k": "12345"}}, "", false},
+			{"Tag binary value", "Tag", map[string]interface{}{"secret/a": map[string]interface{}{"k": "\xff\x00"}}, `{"$binary":"/wA="}`, true},
+			{"Keep valid UTF-8 value", "Tag", map[string]interface{}{"secret/a": map[string]interface{}{"k": "café"}}, `"café"`, true},
+			{"Escape tag-like value", "Tag", map[string]interface{}{"secret/a": map[string]interface{}{"k": map[string]interface{}{"$binary": "x"}}}, `{"$literal":{"$binary":"x"}}`, true},
+			{"Escape literal-like value", "Tag", map[string]interface{}{"secret/a": map[string]interface{}{"k": map[string]interface{}{"$literal": "x"}}}, `{"$literal":{"$literal":"x"}}`, true},
+			{"Keep multi-key object", "Tag", map[string]interface{}{"secret/a": map[string]interface{}{"k": map[string]interface{}{"$a": "x", "b": "y"}}}, `{"$a":"x","b":"y"}`, true},
+		}
+	)
+	for _, test := range tests {
+		switch test.action {
+		case "Drop":
+			report := []string{}
+			for path, values := range dropOversized(test.inputs, 4) {
+				report = append(report, path+"="+strings.Join(values, ","))
+			}
+			remaining := []string{}
+			for _, secret := range test.inputs {
+				for k := range secret.(map[string]interface{}) {
+					remaining = append(remaining, k)
+				}
+			}
+			sort.Strings(report)
+			sort.Strings(remaining)
+			norm = strings.Join(report, ",") + "|" + strings.Join(remaining, ",")
+			success = true
+		case "Externalize":
+			err := externalize(test.inputs, dir, "dump.files", 4)
+			success = (err == nil)
+			norm = ""
+			if success {
+				for _, secret := range test.inputs {
+					for k, v := range secret.(map[string]interface{}) {
+						ref, ok := v.(map[string]interface{})
+						if !ok {
+							norm = k + "=" + v.(string)
+							continue
+						}
+						name := ref[FileRefKey].(string)
+						data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+						if err != nil {
+							success = false
+						}
+						norm = name + "=" + string(data)
+					}
+				}
+			}
+		case "Tag":
+			original := fmt.Sprint(test.inputs["secret/a"].(map[string]interface{})["k"])
+			escapeLiterals(test.inputs)
+			tagBinary(test.inputs)
+			encoded, err := json.Marshal(test.inputs["secret/a"].(map[string]interface{})["k"])
+			success = (err == nil)
+			norm = string(encoded)
+
+			// the tagged value must decode to the original after a JSON round trip
+			var decoded interface{}
+			if err := json.Unmarshal(encoded, &decoded); err != nil {
+				success = false
+			}
+			if raw, tagged, err := UntagValue(decoded); tagged {
+				decoded = raw
+				success = success && (err == nil)
+			}
+			if fmt.Sprint(decoded) != original {
+				tt.Errorf("FAIL %s: round trip expected '%s' got '%v'", test.description, original, decoded)
+			}
+		}
+
+		if success == test.isSuccess && (!success || norm == test.normOutput) {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		}
+	}
+}
@@ -2,10 +2,14 @@ package dump
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/dathan/go-vault-dump/pkg/guard"
 	"github.com/dathan/go-vault-dump/pkg/vault"
 )
 
@@ -14,8 +18,13 @@ const (
 )
 
 type secret struct {
-	path string
-	data interface{}
+	path    string
+	data    interface{}
+	version int
+	updated time.Time
+	history []Version
+	// metadata is the KV version 2 metadata kept with includeMetadata
+	metadata *vault.SecretMetadata
 }
 
 type secretPathStream struct {
@@ -27,11 +36,76 @@ type secretStream struct {
 	wg      *sync.WaitGroup
 }
 type SecretScraper struct {
-	context     context.Context
-	find        *secretPathStream
-	secrets     *secretStream
-	Data        map[string]interface{}
+	context context.Context
+	find    *secretPathStream
+	secrets *secretStream
+	Data    map[string]interface{}
+	// Versions holds the KV v2 version of each secret in Data that has one
+	Versions map[string]int
+	// Updated holds when the KV v2 version of each secret in Data that has
+	// one was written
+	Updated map[string]time.Time
+	// History holds the earlier versions read of each secret in Data, see
+	// history
+	History map[string][]Version
+	// KVMetadata holds the KV version 2 metadata of each secret in Data
+	// that has one, when it is included
+	KVMetadata map[string]*vault.SecretMetadata
+	// Failed holds why each path that could not be dumped failed
+	Failed map[string]Failure
+	// Deleted is how paths whose latest KV v2 version is deleted or
+	// destroyed are dumped, one of the Deleted values
+	Deleted string
+	// MetadataOnly records the metadata of each secret in Data instead of
+	// its values, see inventory
+	MetadataOnly bool
+	// KeysOnly records the key names of each secret in Data without their
+	// values, see keysOf
+	KeysOnly bool
+	// Deny holds the secrets never read, whatever else selects them, nil
+	// denies none
+	Deny *guard.DenyPolicy
+	// Tombstones holds the deleted versions recorded with DeletedTombstone
+	Tombstones  map[string]vault.VersionState
 	VaultConfig *vault.Config
+	ignorePaths []string
+	mu          sync.Mutex
+	// slots bounds the requests to Vault in flight, listings and reads
+	// alike, to the number of workers
+	slots chan struct{}
+	// checkpoint records what is read and holds what an earlier dump read
+	// of the namespace, nil without one
+	checkpoint *checkpoint
+	namespace  string
+	// incremental reads the metadata of each secret first, those matching
+	// since are taken from previous, see IncrementalState
+	incremental bool
+	since       map[string]MetadataState
+	previous    map[string]interface{}
+	metadata    map[string]MetadataState
+	reused      int
+	// versions is how many versions of each secret are read, see history
+	versions int
+	// includeMetadata keeps the KV version 2 metadata of each secret read
+	includeMetadata bool
+	// filter selects the secrets read, nil reads every secret
+	filter *Filter
+	// maxDepth is how many levels below the path run are listed, 0 for no
+	// limit
+	maxDepth int
+	// policy refuses the secrets whose classification may not be dumped to
+	// destination, nil refuses none
+	policy      *ClassPolicy
+	destination string
+	// dryRun records the paths found and their key names instead of
+	// reading them, see plan
+	dryRun bool
+	// modifiedSince selects the KV version 2 secrets updated after it, the
+	// zero time selects every secret
+	modifiedSince time.Time
+	// listFailed holds why listing each path that was then read as a secret
+	// failed, see missing
+	listFailed map[string]error
 }
 
 func NewSecretScraper(vc *vault.Config) (*SecretScraper, error) {
@@ -47,18 +121,283 @@ func NewSecretScraper(vc *vault.Config) (*SecretScraper, error) {
 		},
 		VaultConfig: vc,
 		Data:        make(map[string]interface{}),
+		Versions:    make(map[string]int),
+		Updated:     make(map[string]time.Time),
+		Failed:      make(map[string]Failure),
+		Deleted:     DeletedSkip,
+		Tombstones:  make(map[string]vault.VersionState),
+		History:     make(map[string][]Version),
+		KVMetadata:  make(map[string]*vault.SecretMetadata),
+		metadata:    make(map[string]MetadataState),
+		listFailed:  make(map[string]error),
 	}, nil
 }
 
-// Run creates n number of workers to secret info from found paths
+// fail records that path could not be dumped
+func (s *SecretScraper) fail(path, category, reason string) {
+	log.Printf("failed to dump %s [%s], %s\n", path, category, reason)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Failed[path] = Failure{Category: category, Reason: reason}
+}
+
+// listFailure records that listing path failed with err, path is read as a
+// secret all the same since it may be one the token can read but not list
+func (s *SecretScraper) listFailure(path string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listFailed[path] = err
+}
+
+// missing records that nothing was found at path, which fails when listing
+// it failed, so a directory that could not be listed is not left out silently
+func (s *SecretScraper) missing(path string) {
+	s.mu.Lock()
+	err := s.listFailed[path]
+	s.mu.Unlock()
+	if err != nil {
+		s.fail(path, vault.ClassifyError(err), "failed to list: "+err.Error())
+		return
+	}
+	log.Println("No entries found at:", path)
+}
+
+// tombstone records the deleted version of path in place of its secret
+func (s *SecretScraper) tombstone(path string, state vault.VersionState) {
+	log.Printf("recorded tombstone of version %d for %s\n", state.Version, path)
+	s.checkpoint.record(checkpointEntry{Namespace: s.namespace, Path: path, Tombstone: &state})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Tombstones[path] = state
+}
+
+// resumed reports whether the checkpoint holds path, whose secret or
+// tombstone it then adds without reading it
+func (s *SecretScraper) resumed(path string) bool {
+	e, ok := s.checkpoint.lookup(s.namespace, path)
+	if !ok {
+		return false
+	}
+	if e.Tombstone != nil {
+		s.tombstone(path, *e.Tombstone)
+		return true
+	}
+	found := secret{path: path, data: e.Data, version: e.Version}
+	found.updated, _ = time.Parse(time.RFC3339Nano, e.Updated)
+	s.secrets.channel <- found
+	return true
+}
+
+// inventory records the KV version 2 metadata of path in place of its secret,
+// versions, timestamps and custom metadata but no values. Other secrets keep
+// no metadata and are recorded empty, they are still read to learn whether
+// they exist but their values are dropped.
+func (s *SecretScraper) inventory(ctx context.Context, path string) {
+	s.slots <- struct{}{}
+	metadata, v2, err := s.VaultConfig.ReadMetadata(path)
+	exists := metadata != nil
+	if err == nil && !v2 {
+		vaultSecret, rerr := s.VaultConfig.Client.Logical().Read(path)
+		exists, err = vaultSecret != nil, rerr
+	}
+	<-s.slots
+
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		s.fail(path, vault.ClassifyError(err), err.Error())
+		return
+	}
+	if !exists {
+		s.missing(path)
+		return
+	}
+	found := secret{path: path, data: map[string]interface{}{}}
+	if metadata != nil {
+		b, err := json.Marshal(metadata)
+		if err == nil {
+			err = json.Unmarshal(b, &found.data)
+		}
+		if err != nil {
+			s.fail(path, vault.CategoryOther, err.Error())
+			return
+		}
+		found.version = metadata.CurrentVersion
+		found.updated, _ = time.Parse(time.RFC3339Nano, metadata.UpdatedTime)
+	}
+	s.secrets.channel <- found
+	log.Println("inventoried:", path)
+}
+
+// selected reads the KV version 2 metadata of path when the filter selects
+// secrets by their custom metadata, the policy restricts them or only those
+// modified since a time are read, and reports whether the secret is dumped.
+// The path fails when its metadata can not be read or its classification may
+// not be dumped to the destination.
+func (s *SecretScraper) selected(ctx context.Context, path string) bool {
+	if !s.filter.ByMetadata() && s.policy == nil && s.modifiedSince.IsZero() {
+		return true
+	}
+	s.slots <- struct{}{}
+	metadata, _, err := s.VaultConfig.ReadMetadata(path)
+	<-s.slots
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		s.fail(path, vault.ClassifyError(err), err.Error())
+		return false
+	}
+	var custom map[string]string
+	if metadata != nil {
+		custom = metadata.CustomMetadata
+	}
+	if !s.filter.Metadata(custom) {
+		log.Println("excluded by its custom metadata:", path)
+		return false
+	}
+	if !s.modifiedSince.IsZero() && !modifiedAfter(metadata, s.modifiedSince) {
+		log.Println("not modified since", s.modifiedSince.Format(time.RFC3339)+":", path)
+		return false
+	}
+	if class, ok := s.policy.Allowed(custom, s.destination); !ok {
+		s.fail(path, "classification", fmt.Sprintf("classified %s, which the policy does not allow to be dumped to %s", class, s.destination))
+		return false
+	}
+	return true
+}
+
+// readMetadata reads the KV version 2 metadata of found, kept with
+// includeMetadata, and the earlier versions it lists when those are read.
+// Secrets of other mounts have no metadata and are left as they are.
+func (s *SecretScraper) readMetadata(found *secret) error {
+	s.slots <- struct{}{}
+	metadata, _, err := s.VaultConfig.ReadMetadata(found.path)
+	<-s.slots
+	if err != nil || metadata == nil {
+		return err
+	}
+	if s.includeMetadata {
+		found.metadata = metadata
+	}
+	if s.historic() && found.version > 1 {
+		found.history, err = s.history(found.path, found.version, metadata)
+	}
+	return err
+}
+
+// merge adds what other read to s, with its paths below the namespace ns
+func (s *SecretScraper) merge(ns string, other *SecretScraper) {
+	prefixed := func(p string) string {
+		if ns == "" {
+			return p
+		}
+		return ns + "/" + p
+	}
+	for p, data := range other.Data {
+		s.Data[prefixed(p)] = data
+	}
+	for p, v := range other.Versions {
+		s.Versions[prefixed(p)] = v
+	}
+	for p, t := range other.Updated {
+		s.Updated[prefixed(p)] = t
+	}
+	for p, failure := range other.Failed {
+		s.Failed[prefixed(p)] = failure
+	}
+	for p, state := range other.Tombstones {
+		s.Tombstones[prefixed(p)] = state
+	}
+	for p, versions := range other.History {
+		s.History[prefixed(p)] = versions
+	}
+	for p, m := range other.KVMetadata {
+		s.KVMetadata[prefixed(p)] = m
+	}
+	// the metadata of incremental dumps is recorded with its namespace
+	for p, m := range other.metadata {
+		s.metadata[p] = m
+	}
+	s.reused += other.reused
+}
+
+// replace drops what s read of paths and adds what other read of them
+func (s *SecretScraper) replace(paths []string, other *SecretScraper) {
+	for _, p := range paths {
+		delete(s.Data, p)
+		delete(s.Versions, p)
+		delete(s.Updated, p)
+		delete(s.Failed, p)
+		delete(s.Tombstones, p)
+		delete(s.History, p)
+		delete(s.KVMetadata, p)
+	}
+	s.merge("", other)
+}
+
+// Run creates n number of workers to secret info from found paths, at most n
+// requests to Vault are in flight at any time. Once the context of s is done
+// no more paths are listed or read, what was read until then is kept.
 func (s *SecretScraper) Run(path string, wg *sync.WaitGroup, n int) error {
-	ctx, cancelFunc := context.WithCancel(context.Background())
+	ctx, cancelFunc := context.WithCancel(s.context)
+	n = s.prepare(n)
 
 	for _, vv := range strings.Split(path, ",") {
+		resolved, err := s.VaultConfig.ResolveMountPath(vv, "metadata")
+		if err != nil {
+			log.Printf("failed to resolve %s, %s\n", vv, err.Error())
+		}
 		s.find.wg.Add(1)
-		go s.secretFinder(ctx, cancelFunc, vv)
+		go s.secretFinder(ctx, cancelFunc, resolved, 1)
 	}
 
+	s.produce(ctx, cancelFunc, wg, n)
+	return nil
+}
+
+// Read reads the secrets of paths, data paths as listing finds them, with n
+// workers like Run but without listing anything
+func (s *SecretScraper) Read(paths []string, wg *sync.WaitGroup, n int) {
+	ctx, cancelFunc := context.WithCancel(s.context)
+	n = s.prepare(n)
+
+	s.find.wg.Add(1)
+	go func() {
+		defer s.find.wg.Done()
+		for _, p := range paths {
+			if !s.found(ctx, p) {
+				return
+			}
+		}
+	}()
+
+	s.produce(ctx, cancelFunc, wg, n)
+}
+
+// prepare bounds the requests in flight to n, at least 1 which it returns,
+// and resolves the ignored paths
+func (s *SecretScraper) prepare(n int) int {
+	if n < 1 {
+		n = 1
+	}
+	s.slots = make(chan struct{}, n)
+
+	// ignored paths are compared against the data paths of KV v2 mounts,
+	// listing starts from their metadata paths
+	for _, ip := range s.VaultConfig.Ignore.Paths {
+		resolved, err := s.VaultConfig.ResolveMountPath(ip, "data")
+		if err != nil {
+			log.Printf("failed to resolve ignored path %s, %s\n", ip, err.Error())
+		}
+		s.ignorePaths = append(s.ignorePaths, resolved)
+	}
+	return n
+}
+
+// produce reads the paths found with n workers until every path is read
+func (s *SecretScraper) produce(ctx context.Context, cancelFunc context.CancelFunc, wg *sync.WaitGroup, n int) {
 	s.secrets.wg.Add(n)
 	for i := 0; i != n; i++ {
 		go s.secretProducer(ctx, cancelFunc, n)
@@ -70,7 +409,24 @@ func (s *SecretScraper) Run(path string, wg *sync.WaitGroup, n int) error {
 	go func(wg *sync.WaitGroup) {
 		defer wg.Done()
 		for secret := range s.secrets.channel {
+			e := checkpointEntry{Namespace: s.namespace, Path: secret.path, Data: secret.data, Version: secret.version}
+			if !secret.updated.IsZero() {
+				e.Updated = secret.updated.Format(time.RFC3339Nano)
+			}
+			s.checkpoint.record(e)
 			s.Data[secret.path] = secret.data
+			if secret.version > 0 {
+				s.Versions[secret.path] = secret.version
+			}
+			if !secret.updated.IsZero() {
+				s.Updated[secret.path] = secret.updated
+			}
+			if len(secret.history) > 0 {
+				s.History[secret.path] = secret.history
+			}
+			if secret.metadata != nil {
+				s.KVMetadata[secret.path] = secret.metadata
+			}
 		}
 	}(wg)
 
@@ -81,10 +437,21 @@ func (s *SecretScraper) Run(path string, wg *sync.WaitGroup, n int) error {
 	log.Println("Completed producing secrets from found paths")
 
 	cancelFunc()
-	return nil
 }
 
-func (s *SecretScraper) secretFinder(ctx context.Context, cancelFunc context.CancelFunc, path string) {
+// found queues path to be read, false once ctx is done
+func (s *SecretScraper) found(ctx context.Context, path string) bool {
+	select {
+	case s.find.secretpath <- path:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// secretFinder lists path, depth levels below the path run, and queues the
+// secrets found to be read
+func (s *SecretScraper) secretFinder(ctx context.Context, cancelFunc context.CancelFunc, path string, depth int) {
 	defer s.find.wg.Done()
 
 	select {
@@ -93,21 +460,41 @@ func (s *SecretScraper) secretFinder(ctx context.Context, cancelFunc context.Can
 		log.Println("Received signal to stop, stopping secretFinder")
 		return
 	default:
-		results, _ := s.VaultConfig.Client.Logical().List(path)
+		s.slots <- struct{}{}
+		results, err := s.VaultConfig.Client.Logical().List(path)
+		<-s.slots
 
 		if data, ok := vault.ExtractListData(results); !ok {
 			// maybe it's leaf node; if not, secretProducer will filter it out
-			s.find.secretpath <- strings.Replace(vault.EnsureNoTrailingSlash(path), "metadata", "data", 1)
+			leaf := strings.Replace(vault.EnsureNoTrailingSlash(path), "metadata", "data", 1)
+			if err != nil && ctx.Err() == nil {
+				s.listFailure(leaf, err)
+			}
+			s.found(ctx, leaf)
 		} else {
 			for _, v := range data {
 				newpath := vault.EnsureNoTrailingSlash(path) + "/" + vault.EnsureNoTrailingSlash(v.(string))
 				if isDir(v.(string)) {
+					if s.Deny.Prune(strings.Replace(newpath, "metadata", "data", 1)) {
+						log.Println("denied by the deny policy, not listing:", newpath)
+						continue
+					}
+					if s.filter.Prune(strings.Replace(newpath, "metadata", "data", 1)) {
+						log.Println("excluded by the filters, not listing:", newpath)
+						continue
+					}
+					if s.maxDepth > 0 && depth >= s.maxDepth {
+						log.Println("below the maximum depth, not listing:", newpath)
+						continue
+					}
 					s.find.wg.Add(1)
-					go s.secretFinder(ctx, cancelFunc, newpath)
+					go s.secretFinder(ctx, cancelFunc, newpath, depth+1)
 				} else {
 
 					// reconciling v2 secret engine requirement for list operation
-					s.find.secretpath <- strings.Replace(newpath, "metadata", "data", 1)
+					if !s.found(ctx, strings.Replace(newpath, "metadata", "data", 1)) {
+						return
+					}
 				}
 			}
 		}
@@ -126,8 +513,8 @@ func (s *SecretScraper) secretProducer(ctx context.Context, cancelFunc context.C
 			return
 		default:
 			ignored := false
-			for _, ip := range s.VaultConfig.Ignore.Paths {
-				if strings.HasPrefix(path, ip) {
+			for _, ip := range s.ignorePaths {
+				if strings.HasPrefix(vault.NormalizePath(path), ip) {
 					ignored = true
 					break
 				}
@@ -139,11 +526,63 @@ func (s *SecretScraper) secretProducer(ctx context.Context, cancelFunc context.C
 					break
 				}
 			}
+			if !s.filter.Secret(path) {
+				ignored = true
+			}
+			if !ignored && s.Deny.Denied(path) {
+				log.Println("denied by the deny policy:", path)
+				ignored = true
+			}
 
+			if !ignored && s.resumed(path) {
+				continue
+			}
+			if !ignored && !s.selected(ctx, path) {
+				continue
+			}
+			if !ignored && s.dryRun {
+				s.plan(ctx, path)
+				continue
+			}
+			if !ignored && s.incremental && s.unchanged(ctx, path) {
+				continue
+			}
+			if !ignored && s.MetadataOnly {
+				s.inventory(ctx, path)
+				continue
+			}
 			if !ignored {
-				vaultSecret, err := s.VaultConfig.Client.Logical().Read(path)
-				if err != nil {
-					log.Printf("failed to get secrets in %s, %s\n", path, err.Error())
+				s.slots <- struct{}{}
+				vaultSecret, err := s.read(path)
+				<-s.slots
+				if state, deleted := vault.DeletedVersion(vaultSecret); deleted {
+					switch s.Deleted {
+					case DeletedTombstone:
+						s.tombstone(path, state)
+						continue
+					case DeletedPrevious:
+						// without an undeleted version the path is reported
+						// as deleted below
+						s.slots <- struct{}{}
+						previous, version, perr := s.VaultConfig.ReadLatestUndeleted(path)
+						<-s.slots
+						if perr != nil {
+							vaultSecret, err = nil, perr
+						} else if previous != nil {
+							log.Printf("using version %d of %s, version %d is deleted\n", version, path, state.Version)
+							vaultSecret = previous
+						}
+					}
+				}
+				// a read cancelled with the dump is not a failure of the path
+				if ctx.Err() != nil {
+					return
+				}
+				// a read can also succeed without returning the value, which
+				// must not end up in the dump as an empty secret
+				if category, reason := vault.ClassifyRead(vaultSecret, err); category != "" {
+					s.fail(path, category, reason)
+					continue
 				}
 
 				// handles case when the path does not have a vault value: No value found at XYZ
@@ -156,16 +595,30 @@ func (s *SecretScraper) secretProducer(ctx context.Context, cancelFunc context.C
 						data = vaultSecret.Data
 					}
 				}
+				if data != nil && s.KeysOnly {
+					data = keysOf(data)
+				}
 
 				if data != nil {
 					secret := secret{
-						path: path,
-						data: data,
+						path:    path,
+						data:    data,
+						version: vault.SecretVersion(vaultSecret),
+						updated: vault.SecretUpdated(vaultSecret),
+					}
+					if s.includeMetadata || (s.historic() && secret.version > 1) {
+						if err := s.readMetadata(&secret); err != nil {
+							if ctx.Err() != nil {
+								return
+							}
+							s.fail(path, vault.ClassifyError(err), err.Error())
+							continue
+						}
 					}
 					s.secrets.channel <- secret
 					log.Println("created secret from:", path)
 				} else {
-					log.Println("No entries found at:", path)
+					s.missing(path)
 				}
 			}
 		}
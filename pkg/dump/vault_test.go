@@ -0,0 +1,232 @@
+package dump
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+func TestSuiteRunConcurrency(tt *testing.T) {
+	// a KV version 1 mount kv/ holding 5 directories of 5 secrets, each
+	// request taking a while so that concurrent requests overlap
+	var (
+		mu       sync.Mutex
+		inFlight int
+		peak     int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/sys/internal/ui/mounts/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		time.Sleep(5 * time.Millisecond)
+
+		path := strings.TrimPrefix(r.URL.Path, "/v1/")
+		switch {
+		case r.Method == "LIST" || r.URL.Query().Get("list") == "true":
+			keys := []string{}
+			for i := 0; i < 5; i++ {
+				if path == "kv" || path == "kv/" {
+					keys = append(keys, fmt.Sprintf("d%d/", i))
+				} else {
+					keys = append(keys, fmt.Sprintf("s%d", i))
+				}
+			}
+			fmt.Fprintf(w, `{"data": {"keys": ["%s"]}}`, strings.Join(keys, `", "`))
+		default:
+			fmt.Fprintf(w, `{"data": {"value": "%s"}}`, path)
+		}
+	}))
+	defer server.Close()
+
+	var (
+		tests = []struct {
+			description string
+			workers     int
+			normOutput  string
+		}{
+			{"One worker", 1, "25 secrets, at most 1 requests"},
+			{"Three workers", 3, "25 secrets, at most 3 requests"},
+			{"Invalid workers", 0, "25 secrets, at most 1 requests"},
+		}
+	)
+	for _, test := range tests {
+		vc, err := vault.NewClient(&vault.Config{Address: server.URL, Token: "t", Ignore: &vault.Ignore{}})
+		if err != nil {
+			tt.Fatal(err)
+		}
+		s, _ := NewSecretScraper(vc)
+		mu.Lock()
+		peak = 0
+		mu.Unlock()
+
+		var wg sync.WaitGroup
+		s.Run("kv", &wg, test.workers)
+		wg.Wait()
+
+		mu.Lock()
+		norm := fmt.Sprintf("%d secrets, at most %d requests", len(s.Data), peak)
+		mu.Unlock()
+		if peak > test.workers && peak > 1 || len(s.Data) != 25 || s.Data["kv/d4/s4"] == nil {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteRunMaxDepth(tt *testing.T) {
+	// a KV version 1 mount kv/ holding kv/a, kv/d/b and kv/d/e/c
+	var (
+		mu     sync.Mutex
+		listed []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/sys/internal/ui/mounts/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/"), "/")
+		if r.Method == "LIST" || r.URL.Query().Get("list") == "true" {
+			mu.Lock()
+			listed = append(listed, path)
+			mu.Unlock()
+			switch path {
+			case "kv":
+				fmt.Fprint(w, `{"data": {"keys": ["a", "d/"]}}`)
+			case "kv/d":
+				fmt.Fprint(w, `{"data": {"keys": ["b", "e/"]}}`)
+			case "kv/d/e":
+				fmt.Fprint(w, `{"data": {"keys": ["c"]}}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+			return
+		}
+		fmt.Fprintf(w, `{"data": {"value": "%s"}}`, path)
+	}))
+	defer server.Close()
+
+	excludeE, err := NewFilter(nil, nil, nil, []string{"e"}, nil, nil)
+	if err != nil {
+		tt.Fatal(err)
+	}
+	var (
+		tests = []struct {
+			description string
+			maxDepth    int
+			filter      *Filter
+			normOutput  string
+		}{
+			{"No limit", 0, nil, "3 secrets, 3 listed"},
+			{"Top level", 1, nil, "1 secrets, 1 listed"},
+			{"Two levels", 2, nil, "2 secrets, 2 listed"},
+			{"Excluded directory", 0, excludeE, "2 secrets, 2 listed"},
+		}
+	)
+	for _, test := range tests {
+		vc, err := vault.NewClient(&vault.Config{Address: server.URL, Token: "t", Ignore: &vault.Ignore{}})
+		if err != nil {
+			tt.Fatal(err)
+		}
+		s, _ := NewSecretScraper(vc)
+		s.maxDepth, s.filter = test.maxDepth, test.filter
+		mu.Lock()
+		listed = nil
+		mu.Unlock()
+
+		var wg sync.WaitGroup
+		s.Run("kv", &wg, 1)
+		wg.Wait()
+
+		mu.Lock()
+		norm := fmt.Sprintf("%d secrets, %d listed", len(s.Data), len(listed))
+		mu.Unlock()
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteRunListingDenied(tt *testing.T) {
+	// a KV version 1 mount kv/ holding kv/a, kv/d/b and kv/s, where kv/d and
+	// kv/s may be read but not listed
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/sys/internal/ui/mounts/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/"), "/")
+		if r.Method == "LIST" || r.URL.Query().Get("list") == "true" {
+			switch path {
+			case "kv":
+				fmt.Fprint(w, `{"data": {"keys": ["a", "d/"]}}`)
+			case "kv/d", "kv/s":
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"errors": ["permission denied"]}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+			return
+		}
+		switch path {
+		case "kv/a", "kv/d/b", "kv/s":
+			fmt.Fprintf(w, `{"data": {"value": "%s"}}`, path)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var (
+		tests = []struct {
+			description string
+			path        string
+			normOutput  string
+		}{
+			{"Directory not listed", "kv", "1 secrets, failed map[kv/d:permission-denied]"},
+			{"Secret not listed", "kv/s", "1 secrets, failed map[]"},
+		}
+	)
+	for _, test := range tests {
+		vc, err := vault.NewClient(&vault.Config{Address: server.URL, Token: "t", Ignore: &vault.Ignore{}})
+		if err != nil {
+			tt.Fatal(err)
+		}
+		s, _ := NewSecretScraper(vc)
+
+		var wg sync.WaitGroup
+		s.Run(test.path, &wg, 1)
+		wg.Wait()
+
+		failed := make(map[string]string)
+		for p, f := range s.Failed {
+			failed[p] = f.Category
+		}
+		norm := fmt.Sprintf("%d secrets, failed %v", len(s.Data), failed)
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
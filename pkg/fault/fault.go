@@ -0,0 +1,138 @@
+package fault
+
+// fault injects failures into Vault requests and uploads so retry and
+// alerting configuration can be validated before relying on scheduled backups
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config holds the probability of each kind of fault, a nil Config injects
+// nothing
+type Config struct {
+	// ErrorRate is the probability of a Vault request failing with a 500
+	ErrorRate float64
+	// SlowRate is the probability of a Vault request being delayed by Delay
+	SlowRate float64
+	Delay    time.Duration
+	// UploadRate is the probability of an upload failing
+	UploadRate float64
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// ErrInjected is returned for injected upload failures
+var ErrInjected = errors.New("injected fault")
+
+// Parse reads a spec of the form error=0.1,slow=0.1,delay=2s,upload=0.5, an
+// empty spec returns nil
+func Parse(spec string) (*Config, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	c := &Config{
+		Delay: 2 * time.Second,
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, opt := range strings.Split(spec, ",") {
+		kv := strings.SplitN(strings.TrimSpace(opt), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid fault %q, expected name=value", opt)
+		}
+		var err error
+		switch kv[0] {
+		case "error":
+			c.ErrorRate, err = parseRate(kv[1])
+		case "slow":
+			c.SlowRate, err = parseRate(kv[1])
+		case "upload":
+			c.UploadRate, err = parseRate(kv[1])
+		case "delay":
+			c.Delay, err = time.ParseDuration(kv[1])
+		default:
+			err = fmt.Errorf("unknown fault %q, expected error, slow, delay or upload", kv[0])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func parseRate(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("invalid fault rate %q, expected a number between 0 and 1", s)
+	}
+	return rate, nil
+}
+
+// hit reports whether a fault with the given probability occurs
+func (c *Config) hit(rate float64) bool {
+	if c == nil || rate <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rand == nil {
+		c.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return c.rand.Float64() < rate
+}
+
+// Transport wraps next so that requests are delayed or answered with a
+// Vault error response, next is returned as it is by a nil Config
+func (c *Config) Transport(next http.RoundTripper) http.RoundTripper {
+	if c == nil {
+		return next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripper(func(req *http.Request) (*http.Response, error) {
+		if c.hit(c.SlowRate) {
+			log.Printf("fault: delaying %s %s by %s\n", req.Method, req.URL.Path, c.Delay)
+			time.Sleep(c.Delay)
+		}
+		if c.hit(c.ErrorRate) {
+			log.Printf("fault: failing %s %s\n", req.Method, req.URL.Path)
+			return &http.Response{
+				Status:     "500 Internal Server Error",
+				StatusCode: http.StatusInternalServerError,
+				Proto:      req.Proto,
+				ProtoMajor: req.ProtoMajor,
+				ProtoMinor: req.ProtoMinor,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"errors":["injected fault"]}`)),
+				Request:    req,
+			}, nil
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+// Upload returns ErrInjected when an upload to path should fail
+func (c *Config) Upload(path string) error {
+	if c != nil && c.hit(c.UploadRate) {
+		log.Printf("fault: failing upload to %s\n", path)
+		return fmt.Errorf("upload to %s: %w", path, ErrInjected)
+	}
+	return nil
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
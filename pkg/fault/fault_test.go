@@ -0,0 +1,64 @@
+package fault
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSuiteFault(tt *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var (
+		norm    int
+		success bool
+		tests   = []struct {
+			description string
+			action      string
+			spec        string
+			normOutput  int
+			isSuccess   bool
+		}{
+			{"No faults", "Request", "", http.StatusOK, true},
+			{"Vault errors", "Request", "error=1", http.StatusInternalServerError, true},
+			{"Slow responses", "Request", "slow=1,delay=1ms", http.StatusOK, true},
+			{"Upload failures", "Upload", "upload=1", 0, false},
+			{"No upload failures", "Upload", "error=1", 0, true},
+			{"No faults on upload", "Upload", "", 0, true},
+			{"Invalid rate", "Parse", "error=2", 0, false},
+			{"Unknown fault", "Parse", "crash=1", 0, false},
+		}
+	)
+	for _, test := range tests {
+		c, err := Parse(test.spec)
+		success = (err == nil)
+		norm = 0
+		if success {
+			switch test.action {
+			case "Request":
+				client := &http.Client{Transport: c.Transport(nil)}
+				resp, err := client.Get(server.URL)
+				success = (err == nil)
+				if success {
+					norm = resp.StatusCode
+					resp.Body.Close()
+				}
+			case "Upload":
+				err := c.Upload("s3://bucket/key")
+				success = !errors.Is(err, ErrInjected)
+			}
+		}
+
+		if success == test.isSuccess && (!success || norm == test.normOutput) {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else {
+			tt.Errorf("FAIL %s: expected '%d' got '%d'", test.description, test.normOutput, norm)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package feature
+
+// feature is the registry of the optional backends compiled into the binary.
+// Each backend is gated by a build tag and registers itself from an init
+// function of a file behind that tag, so a binary can tell at runtime which
+// backends it was built with.
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Backends and the build tags that gate them. AWS and Kafka are compiled in
+// unless left out with their no tag, Kubernetes only with its tag.
+const (
+	AWS        = "aws"        // S3, KMS, CloudWatch, EventBridge and the aws auth method, left out with noaws
+	Kafka      = "kafka"      // kafka output, left out with nokafka
+	Kubernetes = "kubernetes" // dump.ToKube, compiled in with kubernetes
+)
+
+var (
+	mu         sync.Mutex
+	registered = map[string]bool{}
+)
+
+// Register records that the backends names are compiled in
+func Register(names ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range names {
+		registered[name] = true
+	}
+}
+
+// Enabled reports whether the backend name is compiled in
+func Enabled(name string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return registered[name]
+}
+
+// List returns the sorted names of the backends compiled in
+func List() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	names := make([]string, 0, len(registered))
+	for name := range registered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Require returns an error unless the backend name is compiled in
+func Require(name string) error {
+	if !Enabled(name) {
+		return fmt.Errorf("%s support is not compiled into this binary, see vault-dump info", name)
+	}
+	return nil
+}
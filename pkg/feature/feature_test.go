@@ -0,0 +1,35 @@
+package feature
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSuiteFeature(tt *testing.T) {
+	Register(Kafka, AWS)
+	Register(AWS)
+
+	var (
+		tests = []struct {
+			description string
+			name        string
+			normOutput  string
+		}{
+			{"Registered", AWS, "true <nil>"},
+			{"Not registered", Kubernetes, "false kubernetes support is not compiled into this binary, see vault-dump info"},
+		}
+	)
+	for _, test := range tests {
+		norm := fmt.Sprintf("%v %v", Enabled(test.name), Require(test.name))
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+	if norm := fmt.Sprint(List()); norm != "[aws kafka]" {
+		tt.Errorf("FAIL List: expected '[aws kafka]' got '%s'", norm)
+	} else {
+		tt.Logf("PASS List")
+	}
+}
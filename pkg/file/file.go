@@ -9,6 +9,7 @@ import (
 )
 
 func WriteFile(path, data string) bool {
+	path = LocalPath(path)
 	dirpath := filepath.Dir(path)
 	if err := os.MkdirAll(dirpath, 0755); err != nil {
 		log.Println(err)
@@ -21,7 +22,11 @@ func WriteFile(path, data string) bool {
 		f.Close()
 		return false
 	}
-	f.Chmod(0600) // only you can access this file
+	if err := restrictPermissions(f); err != nil { // only you can access this file
+		log.Println(err)
+		f.Close()
+		return false
+	}
 
 	b, err := f.WriteString(data)
 	if err != nil {
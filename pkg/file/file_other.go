@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package file
+
+import (
+	"os"
+)
+
+// LocalPath returns path unchanged, only Windows needs adjustments
+func LocalPath(path string) string {
+	return path
+}
+
+// restrictPermissions makes the file accessible to the owner only
+func restrictPermissions(f *os.File) error {
+	return f.Chmod(0600)
+}
@@ -0,0 +1,68 @@
+//go:build windows
+// +build windows
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// maxPath is the length from which Windows requires the extended-length
+// prefix, MAX_PATH minus room for an 8.3 file name
+const maxPath = 248
+
+// LocalPath sanitizes every component of path below the volume and adds the
+// extended-length prefix to long absolute paths
+func LocalPath(path string) string {
+	volume := filepath.VolumeName(path)
+	rest := strings.Split(filepath.ToSlash(path[len(volume):]), "/")
+	for i, name := range rest {
+		if name != "" && name != "." && name != ".." {
+			rest[i] = SanitizeName(name)
+		}
+	}
+	path = volume + filepath.FromSlash(strings.Join(rest, "/"))
+
+	if len(path) < maxPath || !filepath.IsAbs(path) || strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	path = filepath.Clean(path)
+	if strings.HasPrefix(path, `\\`) {
+		return `\\?\UNC\` + path[2:]
+	}
+	return `\\?\` + path
+}
+
+// restrictPermissions replaces the inherited ACL of the file with one that
+// only grants the current user access, chmod only toggles read-only here
+func restrictPermissions(f *os.File) error {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return err
+	}
+	acl, err := windows.ACLFromEntries([]windows.EXPLICIT_ACCESS{
+		{
+			AccessPermissions: windows.GENERIC_ALL,
+			AccessMode:        windows.GRANT_ACCESS,
+			Inheritance:       windows.NO_INHERITANCE,
+			Trustee: windows.TRUSTEE{
+				TrusteeForm:  windows.TRUSTEE_IS_SID,
+				TrusteeType:  windows.TRUSTEE_IS_USER,
+				TrusteeValue: windows.TrusteeValueFromSID(user.User.Sid),
+			},
+		},
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return windows.SetNamedSecurityInfo(
+		f.Name(),
+		windows.SE_FILE_OBJECT,
+		windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION,
+		nil, nil, acl, nil,
+	)
+}
@@ -0,0 +1,69 @@
+package file
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// windowsReserved are device names which can not be used as a file name on
+// Windows, with or without an extension
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeName replaces the characters of a single file name that are invalid
+// on Windows, and renames reserved device names and trailing dots or spaces.
+// Characters are replaced by %XX, and % itself by %25 so that names already
+// holding %XX round-trip
+func SanitizeName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r < 0x20, strings.ContainsRune(`%<>:"/\|?*`, r):
+			fmt.Fprintf(&b, "%%%02X", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	s := b.String()
+
+	trimmed := strings.TrimRight(s, ". ")
+	if trimmed != s {
+		s = trimmed + strings.Repeat("_", len(s)-len(trimmed))
+	}
+
+	base := strings.ToUpper(strings.SplitN(s, ".", 2)[0])
+	if windowsReserved[base] {
+		s = "_" + s
+	}
+	return s
+}
+
+// FromURL converts a file:// URL into a local path, file://host/share/dir is
+// returned as the UNC path \\host\share\dir
+func FromURL(dest string) (string, error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("not a file URL: %s", dest)
+	}
+
+	p := u.Path
+	switch {
+	case u.Host != "" && u.Host != "localhost":
+		return `\\` + u.Host + filepath.FromSlash(strings.ReplaceAll(p, "/", `\`)), nil
+	case len(p) > 2 && p[0] == '/' && p[2] == ':':
+		// file:///C:/dir
+		return filepath.FromSlash(p[1:]), nil
+	default:
+		return filepath.FromSlash(p), nil
+	}
+}
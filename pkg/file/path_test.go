@@ -0,0 +1,53 @@
+package file
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSuitePath(tt *testing.T) {
+	var (
+		norm    string
+		success bool
+		tests   = []struct {
+			description string
+			action      string
+			input       string
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Sanitize plain name", "Sanitize", "vault-dump.json", "vault-dump.json", true},
+			{"Sanitize invalid characters", "Sanitize", `a:b*c?"d|e<f>g\h`, "a%3Ab%2Ac%3F%22d%7Ce%3Cf%3Eg%5Ch", true},
+			{"Sanitize control characters", "Sanitize", "a\tb", "a%09b", true},
+			{"Sanitize percent", "Sanitize", "a%3Ab:c", "a%253Ab%3Ac", true},
+			{"Sanitize trailing dots and spaces", "Sanitize", "name. .", "name___", true},
+			{"Sanitize reserved name", "Sanitize", "CON", "_CON", true},
+			{"Sanitize reserved name with extension", "Sanitize", "nul.json", "_nul.json", true},
+			{"Sanitize unicode", "Sanitize", "café", "café", true},
+			{"URL local path", "URL", "file:///tmp/dumps", filepath.FromSlash("/tmp/dumps"), true},
+			{"URL localhost", "URL", "file://localhost/tmp/dumps", filepath.FromSlash("/tmp/dumps"), true},
+			{"URL drive letter", "URL", "file:///C:/dumps", filepath.FromSlash("C:/dumps"), true},
+			{"URL UNC share", "URL", "file://server/share/dumps", `\\server\share\dumps`, true},
+			{"URL wrong scheme", "URL", "s3://bucket/dumps", "", false},
+		}
+	)
+	for _, test := range tests {
+		switch test.action {
+		case "Sanitize":
+			norm = SanitizeName(test.input)
+			success = true
+		case "URL":
+			out, err := FromURL(test.input)
+			norm = out
+			success = (err == nil)
+		}
+
+		if success == test.isSuccess && (!success || norm == test.normOutput) {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		}
+	}
+}
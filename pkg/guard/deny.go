@@ -0,0 +1,133 @@
+package guard
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// SignatureExt is the extension of the file next to a deny policy holding
+// its signature
+const SignatureExt = "sig"
+
+// DenyPolicy holds the secrets that are never read to be exported, whatever
+// the flags, as set by an organization rather than by whoever runs a command
+type DenyPolicy struct {
+	// Mounts are glob patterns, see path.Match, matched against the mount
+	// of a path, its first segment
+	Mounts []string `json:"mounts"`
+	// Paths are regular expressions matched against the data path of a
+	// secret, and of a directory followed by a slash
+	Paths []string `json:"paths"`
+	paths []*regexp.Regexp
+}
+
+// ReadDenyPolicy reads a JSON or YAML deny policy from fp. With publicKey,
+// a base64 encoded ed25519 public key, the policy must be signed by it, with
+// the base64 encoded signature of the file in fp.sig.
+func ReadDenyPolicy(fp, publicKey string) (*DenyPolicy, error) {
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	if publicKey != "" {
+		sig, err := ioutil.ReadFile(fp + "." + SignatureExt)
+		if err != nil {
+			return nil, fmt.Errorf("the deny policy %s is not signed: %w", fp, err)
+		}
+		if err := VerifySignature(data, sig, publicKey); err != nil {
+			return nil, fmt.Errorf("the deny policy %s: %w", fp, err)
+		}
+	}
+	p, err := ParseDenyPolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deny policy %s: %w", fp, err)
+	}
+	return p, nil
+}
+
+// ParseDenyPolicy parses a JSON or YAML deny policy
+func ParseDenyPolicy(data []byte) (*DenyPolicy, error) {
+	p := &DenyPolicy{}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	if len(p.Mounts)+len(p.Paths) == 0 {
+		return nil, errors.New("it denies nothing")
+	}
+	for _, pattern := range p.Mounts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid mount pattern %q: %w", pattern, err)
+		}
+	}
+	for _, expr := range p.Paths {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path pattern %q: %w", expr, err)
+		}
+		p.paths = append(p.paths, re)
+	}
+	return p, nil
+}
+
+// VerifySignature checks that sig, base64 encoded, is the ed25519 signature
+// of data by publicKey, base64 encoded as well
+func VerifySignature(data, sig []byte, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("invalid public key, expected a base64 encoded ed25519 key")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, decoded) {
+		return errors.New("the signature does not match")
+	}
+	return nil
+}
+
+// Denied reports whether the secret at the data path p must not be read
+func (p *DenyPolicy) Denied(dataPath string) bool {
+	if p == nil {
+		return false
+	}
+	dataPath = strings.Trim(dataPath, "/")
+	return p.mountDenied(dataPath) || p.pathDenied(dataPath)
+}
+
+// Prune reports whether nothing below the directory at the data path dir
+// may be read, so it is not listed
+func (p *DenyPolicy) Prune(dir string) bool {
+	if p == nil {
+		return false
+	}
+	dir = strings.Trim(dir, "/")
+	return p.mountDenied(dir) || p.pathDenied(dir+"/")
+}
+
+func (p *DenyPolicy) mountDenied(dataPath string) bool {
+	mount := strings.SplitN(dataPath, "/", 2)[0]
+	for _, pattern := range p.Mounts {
+		if ok, _ := path.Match(strings.Trim(pattern, "/"), mount); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *DenyPolicy) pathDenied(dataPath string) bool {
+	for _, re := range p.paths {
+		if re.MatchString(dataPath) {
+			return true
+		}
+	}
+	return false
+}
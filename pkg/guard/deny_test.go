@@ -0,0 +1,95 @@
+package guard
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestSuiteDenyPolicy(tt *testing.T) {
+	p, err := ParseDenyPolicy([]byte("mounts: [\"pki-root\", \"hr-*/\"]\npaths: [\"^secret/data/payroll/\", \"/break-glass$\"]\n"))
+	if err != nil {
+		tt.Fatal(err)
+	}
+	var (
+		tests = []struct {
+			description string
+			policy      *DenyPolicy
+			path        string
+			normOutput  string
+		}{
+			{"No policy", nil, "pki-root/cert/ca", "false,false"},
+			{"Denied mount", p, "pki-root/cert/ca", "true,true"},
+			{"Denied mount pattern", p, "/hr-eu/data/people", "true,true"},
+			{"Other mount", p, "pki/cert/ca", "false,false"},
+			{"Denied directory", p, "secret/data/payroll", "false,true"},
+			{"Denied below directory", p, "secret/data/payroll/bank", "true,true"},
+			{"Denied secret", p, "secret/data/app/break-glass", "true,false"},
+			{"Allowed secret", p, "secret/data/app/db", "false,false"},
+		}
+	)
+	for _, test := range tests {
+		norm := fmt.Sprintf("%v,%v", test.policy.Denied(test.path), test.policy.Prune(test.path))
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteReadDenyPolicy(tt *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		tt.Fatal(err)
+	}
+	_, other, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		tt.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(public)
+	policy := []byte("mounts: [\"pki-root\"]\n")
+	sign := func(k ed25519.PrivateKey, data []byte) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(k, data))
+	}
+
+	dir := tt.TempDir()
+	var (
+		tests = []struct {
+			description string
+			content     []byte
+			signature   string
+			key         string
+			isSuccess   bool
+		}{
+			{"Signed", policy, sign(private, policy), key, true},
+			{"Not verified without a key", policy, "", "", true},
+			{"Not signed", policy, "", key, false},
+			{"Signed by another key", policy, sign(other, policy), key, false},
+			{"Changed after signing", []byte("mounts: [\"other\"]\n"), sign(private, policy), key, false},
+			{"Invalid key", policy, sign(private, policy), "not a key", false},
+			{"Denies nothing", []byte("mounts: []\n"), sign(private, []byte("mounts: []\n")), key, false},
+			{"Invalid path pattern", []byte("paths: [\"(\"]\n"), "", "", false},
+		}
+	)
+	for i, test := range tests {
+		fp := filepath.Join(dir, fmt.Sprintf("deny-%d.yaml", i))
+		if err := ioutil.WriteFile(fp, test.content, 0600); err != nil {
+			tt.Fatal(err)
+		}
+		if test.signature != "" {
+			if err := ioutil.WriteFile(fp+"."+SignatureExt, []byte(test.signature+"\n"), 0600); err != nil {
+				tt.Fatal(err)
+			}
+		}
+		_, err := ReadDenyPolicy(fp, test.key)
+		if (err == nil) != test.isSuccess {
+			tt.Errorf("FAIL %s: expected success %v got %v", test.description, test.isSuccess, err)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package guard
+
+// guard holds the guardrails of commands reading from and writing to Vault,
+// such as the time windows writes are allowed in and the secrets never
+// exported.
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Window is a daily time window on some weekdays. Windows ending before they
+// start end on the next day, the weekdays are those the window starts on.
+type Window struct {
+	Days     [7]bool
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+	spec     string
+}
+
+// ParseWindow parses "[days] HH:MM-HH:MM [zone]", e.g. "22:00-06:00" or
+// "Mon-Fri 09:00-17:00 Europe/Berlin". Days are names, ranges of names or a
+// comma separated list of both, every day by default. Times are in the zone,
+// an IANA name, or the local time zone.
+func ParseWindow(spec string) (Window, error) {
+	w := Window{Location: time.Local, spec: spec}
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 3 {
+		return Window{}, fmt.Errorf("invalid window %q, expected [days] HH:MM-HH:MM [zone]", spec)
+	}
+
+	i := 0
+	if !strings.Contains(fields[0], ":") {
+		if err := w.parseDays(fields[0]); err != nil {
+			return Window{}, fmt.Errorf("invalid window %q: %w", spec, err)
+		}
+		i++
+	} else {
+		for d := range w.Days {
+			w.Days[d] = true
+		}
+	}
+	if i >= len(fields) {
+		return Window{}, fmt.Errorf("invalid window %q, the times are missing", spec)
+	}
+	times := strings.SplitN(fields[i], "-", 2)
+	if len(times) != 2 {
+		return Window{}, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", spec)
+	}
+	var err error
+	if w.Start, err = parseClock(times[0]); err == nil {
+		w.End, err = parseClock(times[1])
+	}
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", spec, err)
+	}
+	if w.Start == w.End {
+		return Window{}, fmt.Errorf("invalid window %q, it is empty", spec)
+	}
+	if i++; i < len(fields) {
+		if w.Location, err = time.LoadLocation(fields[i]); err != nil {
+			return Window{}, fmt.Errorf("invalid window %q: %w", spec, err)
+		}
+	}
+	return w, nil
+}
+
+func (w *Window) parseDays(s string) error {
+	for _, part := range strings.Split(strings.ToLower(s), ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		first, ok := weekdays[bounds[0]]
+		if !ok {
+			return fmt.Errorf("unknown day %q", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = weekdays[bounds[1]]; !ok {
+				return fmt.Errorf("unknown day %q", bounds[1])
+			}
+		}
+		// ranges may wrap around the week, e.g. Fri-Mon
+		for d := first; ; d = (d + 1) % 7 {
+			w.Days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock parses HH:MM into the time since midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t is within the window
+func (w Window) Contains(t time.Time) bool {
+	t = t.In(w.Location)
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	day := t.Weekday()
+	if w.Start < w.End {
+		return w.Days[day] && clock >= w.Start && clock < w.End
+	}
+	// the window wraps past midnight, after midnight it belongs to the day
+	// before
+	if clock >= w.Start {
+		return w.Days[day]
+	}
+	return clock < w.End && w.Days[(day+6)%7]
+}
+
+// String returns the window as it was parsed
+func (w Window) String() string {
+	return w.spec
+}
@@ -0,0 +1,52 @@
+package guard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSuiteWindow(tt *testing.T) {
+	// 2026-01-02 is a Friday
+	at := func(day int, clock string) time.Time {
+		t, _ := time.Parse("15:04", clock)
+		return time.Date(2026, 1, day, t.Hour(), t.Minute(), 0, 0, time.UTC)
+	}
+	var (
+		success bool
+		tests   = []struct {
+			description string
+			spec        string
+			time        time.Time
+			contains    bool
+			isSuccess   bool
+		}{
+			{"Every day inside", "09:00-17:00 UTC", at(2, "12:00"), true, true},
+			{"Every day at the end", "09:00-17:00 UTC", at(2, "17:00"), false, true},
+			{"Every day before", "09:00-17:00 UTC", at(2, "08:59"), false, true},
+			{"Weekdays on Friday", "Mon-Fri 09:00-17:00 UTC", at(2, "10:00"), true, true},
+			{"Weekdays on Saturday", "Mon-Fri 09:00-17:00 UTC", at(3, "10:00"), false, true},
+			{"List of days", "sat,sun 00:00-23:59 UTC", at(4, "10:00"), true, true},
+			{"Range wrapping the week", "Fri-Mon 09:00-17:00 UTC", at(4, "10:00"), true, true},
+			{"Overnight after midnight", "Fri 22:00-06:00 UTC", at(3, "02:00"), true, true},
+			{"Overnight before midnight", "Fri 22:00-06:00 UTC", at(2, "23:00"), true, true},
+			{"Overnight of another day", "Fri 22:00-06:00 UTC", at(2, "02:00"), false, true},
+			{"Zone", "09:00-17:00 Europe/Berlin", at(2, "16:30"), false, true},
+			{"Unknown day", "Someday 09:00-17:00", time.Time{}, false, false},
+			{"Invalid time", "9-17", time.Time{}, false, false},
+			{"Empty window", "09:00-09:00", time.Time{}, false, false},
+			{"Unknown zone", "09:00-17:00 Mars/Olympus", time.Time{}, false, false},
+			{"Missing times", "Mon-Fri", time.Time{}, false, false},
+		}
+	)
+	for _, test := range tests {
+		w, err := ParseWindow(test.spec)
+		success = (err == nil)
+		if success == test.isSuccess && (!success || w.Contains(test.time) == test.contains) {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t, %v", test.description, test.isSuccess, success, err)
+		} else {
+			tt.Errorf("FAIL %s: expected %s in %s to be %t", test.description, test.time, test.spec, test.contains)
+		}
+	}
+}
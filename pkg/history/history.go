@@ -0,0 +1,164 @@
+package history
+
+// history keeps the results of recent runs in a local JSON lines file so the
+// health of scheduled backups can be checked from the CLI
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Keep is the number of runs kept in the history file
+var Keep = 500
+
+// mu serializes appends of the clusters dumped in parallel
+var mu sync.Mutex
+
+// Run is the result of a dump or import, it never holds secret values
+type Run struct {
+	Command  string        `json:"command"`
+	Cluster  string        `json:"cluster"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Success  bool          `json:"success"`
+	Secrets  int           `json:"secrets"`
+	Failed   int           `json:"failed"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Load returns the runs recorded in path, oldest first, a missing file holds
+// no runs
+func Load(path string) ([]Run, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	runs := []Run{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var r Run
+		// a line cut short by a crash is skipped rather than losing the
+		// whole history
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			continue
+		}
+		runs = append(runs, r)
+	}
+	return runs, scanner.Err()
+}
+
+// Append records r in path, dropping the oldest runs beyond Keep
+func Append(path string, r Run) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	runs, err := Load(path)
+	if err != nil {
+		return err
+	}
+	runs = append(runs, r)
+	if len(runs) > Keep {
+		runs = runs[len(runs)-Keep:]
+	}
+
+	var b strings.Builder
+	for _, r := range runs {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteString("\n")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".history-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Status summarizes the runs of a command against a cluster
+type Status struct {
+	Command     string
+	Cluster     string
+	Runs        int
+	Last        Run
+	LastSuccess *Run
+	// Streak is the number of failed runs since the last success
+	Streak int
+	// LongestStreak is the longest run of consecutive failures
+	LongestStreak int
+	// Durations are the durations of the most recent successful runs,
+	// oldest first
+	Durations []time.Duration
+}
+
+// Trend is the number of durations reported per status
+const Trend = 5
+
+// Summarize returns the status of every command and cluster in runs, sorted
+// by cluster and command
+func Summarize(runs []Run) []Status {
+	byKey := map[string]*Status{}
+	keys := []string{}
+	for _, r := range runs {
+		key := r.Cluster + "\x00" + r.Command
+		s, ok := byKey[key]
+		if !ok {
+			s = &Status{Command: r.Command, Cluster: r.Cluster}
+			byKey[key] = s
+			keys = append(keys, key)
+		}
+		s.Runs++
+		s.Last = r
+		if r.Success {
+			last := r
+			s.LastSuccess = &last
+			s.Streak = 0
+			s.Durations = append(s.Durations, r.Duration)
+			if len(s.Durations) > Trend {
+				s.Durations = s.Durations[1:]
+			}
+			continue
+		}
+		s.Streak++
+		if s.Streak > s.LongestStreak {
+			s.LongestStreak = s.Streak
+		}
+	}
+
+	sort.Strings(keys)
+	statuses := make([]Status, 0, len(keys))
+	for _, k := range keys {
+		statuses = append(statuses, *byKey[k])
+	}
+	return statuses
+}
@@ -0,0 +1,68 @@
+package history
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSuiteSummarize(tt *testing.T) {
+	run := func(cluster string, success bool, seconds int) Run {
+		return Run{Command: "dump", Cluster: cluster, Success: success, Duration: time.Duration(seconds) * time.Second}
+	}
+
+	var (
+		tests = []struct {
+			description string
+			runs        []Run
+			normOutput  string
+		}{
+			{"No runs", nil, "[]"},
+			{"Healthy", []Run{run("a", true, 1), run("a", true, 2)}, "[a runs=2 streak=0 longest=0 success=true durations=[1s 2s]]"},
+			{"Failing", []Run{run("a", true, 1), run("a", false, 0), run("a", false, 0)}, "[a runs=3 streak=2 longest=2 success=true durations=[1s]]"},
+			{"Recovered", []Run{run("a", false, 0), run("a", false, 0), run("a", true, 3)}, "[a runs=3 streak=0 longest=2 success=true durations=[3s]]"},
+			{"Never succeeded", []Run{run("a", false, 0)}, "[a runs=1 streak=1 longest=1 success=false durations=[]]"},
+			{"Per cluster", []Run{run("b", true, 1), run("a", false, 0)}, "[a runs=1 streak=1 longest=1 success=false durations=[] b runs=1 streak=0 longest=0 success=true durations=[1s]]"},
+			{"Trend", []Run{run("a", true, 1), run("a", true, 2), run("a", true, 3), run("a", true, 4), run("a", true, 5), run("a", true, 6)}, "[a runs=6 streak=0 longest=0 success=true durations=[2s 3s 4s 5s 6s]]"},
+		}
+	)
+	for _, test := range tests {
+		norm := []string{}
+		for _, s := range Summarize(test.runs) {
+			norm = append(norm, fmt.Sprintf("%s runs=%d streak=%d longest=%d success=%t durations=%v", s.Cluster, s.Runs, s.Streak, s.LongestStreak, s.LastSuccess != nil, s.Durations))
+		}
+		if fmt.Sprint(norm) != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, fmt.Sprint(norm))
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteAppend(tt *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	Keep = 3
+	path := filepath.Join(dir, "history.jsonl")
+	for i := 0; i < 5; i++ {
+		if err := Append(path, Run{Command: "dump", Secrets: i}); err != nil {
+			tt.Fatal(err)
+		}
+	}
+	runs, err := Load(path)
+	if err != nil {
+		tt.Fatal(err)
+	}
+	if len(runs) != 3 || runs[0].Secrets != 2 || runs[2].Secrets != 4 {
+		tt.Errorf("FAIL Append: expected the last 3 runs got %v", runs)
+	} else {
+		tt.Logf("PASS Append")
+	}
+}
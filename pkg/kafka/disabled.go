@@ -0,0 +1,13 @@
+//go:build nokafka
+// +build nokafka
+
+package kafka
+
+import "errors"
+
+// ErrDisabled is returned by Publish in binaries built with the nokafka tag
+var ErrDisabled = errors.New("Kafka support is not compiled into this binary, it was built with the nokafka tag")
+
+func Publish(brokers []string, topic string, messages map[string]string) error {
+	return ErrDisabled
+}
@@ -0,0 +1,60 @@
+//go:build !nokafka
+// +build !nokafka
+
+package kafka
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/feature"
+	"github.com/segmentio/kafka-go"
+)
+
+// Timeout bounds writing all messages of a dump
+var Timeout = 2 * time.Minute
+
+func init() {
+	feature.Register(feature.Kafka)
+}
+
+// Publish writes one message per entry of messages to topic, keyed by the
+// entry's key so every version of a secret lands on the same partition
+func Publish(brokers []string, topic string, messages map[string]string) error {
+	if len(brokers) == 0 {
+		return errors.New("no Kafka brokers specified")
+	}
+	if topic == "" {
+		return errors.New("no Kafka topic specified")
+	}
+
+	keys := make([]string, 0, len(messages))
+	for k := range messages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	msgs := make([]kafka.Message, 0, len(keys))
+	for _, k := range keys {
+		msgs = append(msgs, kafka.Message{Key: []byte(k), Value: []byte(messages[k])})
+	}
+
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	if err := w.WriteMessages(ctx, msgs...); err != nil {
+		return err
+	}
+	log.Printf("Published %d messages to Kafka topic %s\n", len(msgs), topic)
+	return nil
+}
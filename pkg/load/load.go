@@ -1,79 +1,238 @@
 package load
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha1"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
-	"os/signal"
+	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
-	"syscall"
+	"time"
 
+	"github.com/dathan/go-vault-dump/pkg/dump"
 	"github.com/dathan/go-vault-dump/pkg/file"
 	"github.com/dathan/go-vault-dump/pkg/vault"
+	"github.com/ghodss/yaml"
 	"golang.org/x/sync/syncmap"
 )
 
 var DatabaseConnectionDetailsKey = "connection_details"
 
+// ErrInterrupted is returned when the Context of a restore is done before
+// every secret was written
+var ErrInterrupted = errors.New("import interrupted")
+
 // Config
 type Config struct {
 	VaultConfig *vault.Config
-	wg          *sync.WaitGroup
-	errInfo     *errInfo
+	// Target replaces the root of the dump, relative dumps are restored
+	// below their recorded root when it is empty
+	Target string
+	// RestoreDeletions deletes or destroys the latest version of the paths
+	// recorded as tombstones once the secrets are written
+	RestoreDeletions bool
+	// RestoreHistory writes the earlier versions recorded in dumps of
+	// several versions, oldest first, before the secret itself
+	RestoreHistory bool
+	// RestoreMetadata replaces the custom metadata and settings of each KV
+	// version 2 secret with those recorded in dumps including metadata
+	RestoreMetadata bool
+	// Force restores into a different cluster than the dump was taken from
+	Force bool
+	// MaxAge is the age above which a dump is refused unless AllowStale is
+	// set, 0 disables the check
+	MaxAge     time.Duration
+	AllowStale bool
+	// RotateDatabase rotates the root credentials of restored database
+	// connections
+	RotateDatabase bool
+	// RotateWebhooks are posted the restored paths so their credentials can
+	// be rotated
+	RotateWebhooks []string
+	// Order constrains the order the secrets are written in, they are all
+	// written in parallel when it is nil
+	Order *Order
+	// Confirm is shown what the restore changes before anything is written,
+	// the restore is cancelled when it returns an error
+	Confirm func(Plan) error
+	// Context stops the restore once it is done, what was written until
+	// then stays written
+	Context context.Context
+	// UnwrapKey decrypts the data key of dumps with encrypted values
+	UnwrapKey func(cipherkey []byte) ([]byte, error)
+	written   *sync.Map
+	wg        *sync.WaitGroup
+	errInfo   *errInfo
+	// quarantined holds why each secret missing from the dump being
+	// restored could not be dumped
+	quarantined map[string]string
+	// namespaces holds the client of each namespace the paths of the dump
+	// being restored are prefixed with
+	namespaces map[string]*vault.Config
 }
 
 type errInfo struct {
 	count *sync.Map
 	data  *sync.Map
+	// reason holds why each path failed, without its value
+	reason *sync.Map
 }
 
 // New
 func New(c *Config) (*Config, error) {
 	return &Config{
 		VaultConfig: c.VaultConfig,
-		wg:          new(sync.WaitGroup),
+		Target:      c.Target,
+
+		RestoreDeletions: c.RestoreDeletions,
+		RestoreHistory:   c.RestoreHistory,
+		RestoreMetadata:  c.RestoreMetadata,
+		Force:            c.Force,
+		MaxAge:           c.MaxAge,
+		AllowStale:       c.AllowStale,
+		RotateDatabase:   c.RotateDatabase,
+		RotateWebhooks:   c.RotateWebhooks,
+		Order:            c.Order,
+		Confirm:          c.Confirm,
+		Context:          c.Context,
+		UnwrapKey:        c.UnwrapKey,
+		written:          new(syncmap.Map),
+		wg:               new(sync.WaitGroup),
 		errInfo: &errInfo{
-			count: new(syncmap.Map),
-			data:  new(syncmap.Map),
+			count:  new(syncmap.Map),
+			data:   new(syncmap.Map),
+			reason: new(syncmap.Map),
 		},
 	}, nil
 }
 
 // FromFile
 func (c *Config) FromFile(filepath string) error {
-	ctx, cancelFunc := context.WithCancel(context.Background())
+	df, err := readSecretsFromFile(filepath, c.Target, c.UnwrapKey)
+	if err != nil {
+		return err
+	}
+	return c.restore(df)
+}
+
+// FromReader restores the dump read from r, it is kept in memory only so
+// values can not be externalized
+func (c *Config) FromReader(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	df, err := readSecrets(data, "-", c.Target, c.UnwrapKey)
+	if err != nil {
+		return err
+	}
+	return c.restore(df)
+}
 
-	signalChan := make(chan os.Signal, 1)
-	go signalHandler(ctx, cancelFunc, signalChan)
+// restore writes the secrets of df to Vault
+func (c *Config) restore(df *dumpFile) error {
+	parent := c.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancelFunc := context.WithCancel(parent)
 
-	secrets, err := readSecretsFromFile(filepath)
+	if df.manifest != nil && df.manifest.Encryption != nil {
+		cancelFunc()
+		return errors.New("the values of the dump are encrypted and there is no way to decrypt their data key")
+	}
+	if err := c.checkAge(df.manifest, time.Now()); err != nil {
+		cancelFunc()
+		return err
+	}
+	if err := c.checkCluster(df.manifest); err != nil {
+		cancelFunc()
+		return err
+	}
+	if err := c.useNamespaces(df.manifest); err != nil {
+		cancelFunc()
+		return err
+	}
+	c.quarantined = df.quarantined
+	paths := make([]string, 0, len(df.secrets))
+	for p := range df.secrets {
+		paths = append(paths, p)
+	}
+	stages, deps, err := c.Order.stages(paths)
 	if err != nil {
 		cancelFunc()
 		return err
 	}
+	if c.Confirm != nil {
+		p, err := c.plan(df)
+		if err == nil {
+			err = c.Confirm(p)
+		}
+		if err != nil {
+			cancelFunc()
+			return err
+		}
+	}
 
-	secretChan := make(chan map[string]interface{})
-	c.wg.Add(1)
-	go c.secretProducer(ctx, secrets, secretChan)
+	history := df.history
+	if len(history) > 0 && !c.RestoreHistory {
+		log.Printf("Not restoring the earlier versions of %d secrets, use --restore-history to write them\n", len(history))
+		history = nil
+	}
+	metadata := df.metadata
+	if len(metadata) > 0 && !c.RestoreMetadata {
+		log.Printf("Not restoring the metadata of %d secrets, use --restore-metadata to write it\n", len(metadata))
+		metadata = nil
+	}
 
-	for i := 0; i != 2*runtime.NumCPU(); i++ {
+	for n, stage := range stages {
+		if ctx.Err() != nil {
+			break
+		}
+		if len(stages) > 1 {
+			log.Printf("Writing stage %d of %d, %d secrets\n", n+1, len(stages), len(stage))
+		}
+		stage = c.skipDependents(stage, deps, df.secrets)
+
+		secretChan := make(chan map[string]interface{})
 		c.wg.Add(1)
-		go c.secretConsumer(ctx, secretChan)
+		go c.secretProducer(ctx, stage, df.secrets, history, metadata, secretChan)
+
+		for i := 0; i != 2*runtime.NumCPU(); i++ {
+			c.wg.Add(1)
+			go c.secretConsumer(ctx, secretChan)
+		}
+
+		c.wg.Wait()
+	}
+
+	// deletions and rotations are left out of an interrupted restore
+	if ctx.Err() != nil {
+		cancelFunc()
+		if err := writeFailedToFile(c.errInfo.data); err != nil {
+			return err
+		}
+		return ErrInterrupted
 	}
 
-	c.wg.Wait()
+	c.restoreDeletions(df.tombstones)
+	c.rotate()
 
 	c.errInfo.count.Range(func(k, v interface{}) bool {
 		log.Println(k, v.(int))
 		return true
 	})
 	if err := writeFailedToFile(c.errInfo.data); err != nil {
+		cancelFunc()
 		return err
 	}
 
@@ -81,6 +240,39 @@ func (c *Config) FromFile(filepath string) error {
 	return nil
 }
 
+// Written returns the sorted paths written by FromFile
+func (c *Config) Written() []string {
+	paths := []string{}
+	c.written.Range(func(k, v interface{}) bool {
+		paths = append(paths, k.(string))
+		return true
+	})
+	sort.Strings(paths)
+	return paths
+}
+
+// Failures returns why each path FromFile failed to write, never its value
+func (c *Config) Failures() map[string]string {
+	failures := map[string]string{}
+	c.errInfo.reason.Range(func(k, v interface{}) bool {
+		failures[k.(string)] = v.(string)
+		return true
+	})
+	return failures
+}
+
+// Quarantined returns why each secret the dump restored by FromFile is
+// missing could not be dumped, the dump is incomplete unless it is empty
+func (c *Config) Quarantined() map[string]string {
+	quarantined := map[string]string{}
+	for p, reason := range c.quarantined {
+		if !c.ignored(p) {
+			quarantined[p] = reason
+		}
+	}
+	return quarantined
+}
+
 func writeFailedToFile(sm *sync.Map) error {
 	failed := make(map[string]interface{})
 	sm.Range(func(k, v interface{}) bool {
@@ -110,66 +302,442 @@ func writeFailedToFile(sm *sync.Map) error {
 	return nil
 }
 
-// readSecretsFromFile returns a map from the given json file
-func readSecretsFromFile(filepath string) (map[string]interface{}, error) {
-	data, err := ioutil.ReadFile(filepath)
+// dumpFile is a dump read back for restore
+type dumpFile struct {
+	// manifest is nil for dumps written before the manifest existed
+	manifest   *dump.Manifest
+	secrets    map[string]interface{}
+	tombstones map[string]vault.VersionState
+	// history holds the earlier versions of secrets, oldest first
+	history map[string][]dump.Version
+	// metadata holds the KV version 2 metadata of secrets
+	metadata map[string]*vault.SecretMetadata
+	// quarantined holds why each secret left out of the dump, because it
+	// could not be dumped, failed
+	quarantined map[string]string
+}
+
+// readSecretsFromFile reads the given json file with the paths of its
+// secrets and tombstones moved below target, see rebase
+func readSecretsFromFile(fp, target string, unwrap func([]byte) ([]byte, error)) (*dumpFile, error) {
+	data, err := ioutil.ReadFile(fp)
 	if err != nil {
-		return map[string]interface{}{}, err
+		return nil, err
 	}
+	return readSecrets(data, fp, target, unwrap)
+}
 
+// readSecrets parses a json or yaml dump read from fp, externalized values
+// are looked up next to fp. Encrypted values are decrypted with the data key
+// unwrap decrypts, they are left encrypted without it.
+func readSecrets(data []byte, fp, target string, unwrap func([]byte) ([]byte, error)) (*dumpFile, error) {
+	// json dumps are objects, anything else is read as yaml
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] != '{' {
+		converted, err := yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid yaml dump: %w", err)
+		}
+		data = converted
+	}
 	d := make(map[string]interface{})
-	if err = json.Unmarshal(data, &d); err != nil {
-		return map[string]interface{}{}, err
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+
+	manifest, err := dump.ExtractManifest(d)
+	if err != nil {
+		return nil, err
+	}
+	if manifest != nil && manifest.MetadataOnly {
+		return nil, errors.New("the dump is a metadata only inventory, it holds no secrets to write")
+	}
+	if manifest != nil && manifest.KeysOnly {
+		return nil, errors.New("the dump holds the key names of the secrets only, it holds no values to write")
+	}
+	if manifest != nil && manifest.Partial {
+		log.Printf("Warning: %s is a partial dump, it was interrupted before every secret was read\n", fp)
+	}
+	if manifest != nil && len(manifest.Failed) > 0 {
+		listed := "under failed in its manifest"
+		if manifest.Quarantine != "" {
+			listed = "in " + manifest.Quarantine
+		}
+		log.Printf("Warning: %s is incomplete, %d secrets could not be dumped and are listed %s\n", fp, len(manifest.Failed), listed)
+	}
+	// values are encrypted bound to the paths and key names as written
+	if _, err := dump.DecryptValues(manifest, d, unwrap); err != nil {
+		return nil, err
+	}
+	if d, err = restorePaths(manifest, d, target); err != nil {
+		return nil, err
+	}
+
+	// tombstones are keyed by paths in the same form as the secrets
+	tombstones := make(map[string]vault.VersionState)
+	if manifest != nil && len(manifest.Tombstones) > 0 {
+		t := make(map[string]interface{}, len(manifest.Tombstones))
+		for p, state := range manifest.Tombstones {
+			t[p] = state
+		}
+		if t, err = restorePaths(manifest, t, target); err != nil {
+			return nil, err
+		}
+		for p, state := range t {
+			tombstones[p] = state.(vault.VersionState)
+		}
+	}
+
+	// the values of earlier versions are read like those of the secrets
+	history := make(map[string][]dump.Version)
+	if manifest != nil && len(manifest.History) > 0 {
+		h := make(map[string]interface{}, len(manifest.History))
+		for p, versions := range manifest.History {
+			h[p] = versions
+		}
+		if h, err = restorePaths(manifest, h, target); err != nil {
+			return nil, err
+		}
+		for p, versions := range h {
+			history[p] = versions.([]dump.Version)
+		}
+	}
+	quarantined := make(map[string]string)
+	if manifest != nil && len(manifest.Failed) > 0 {
+		q := make(map[string]interface{}, len(manifest.Failed))
+		for p, failure := range manifest.Failed {
+			q[p] = failure
+		}
+		if q, err = restorePaths(manifest, q, target); err != nil {
+			return nil, err
+		}
+		for p, failure := range q {
+			f := failure.(dump.Failure)
+			quarantined[p] = fmt.Sprintf("%s: %s", f.Category, f.Reason)
+		}
+	}
+	metadata := make(map[string]*vault.SecretMetadata)
+	if manifest != nil && len(manifest.Metadata) > 0 {
+		m := make(map[string]interface{}, len(manifest.Metadata))
+		for p, md := range manifest.Metadata {
+			m[p] = md
+		}
+		if m, err = restorePaths(manifest, m, target); err != nil {
+			return nil, err
+		}
+		for p, md := range m {
+			metadata[p] = md.(*vault.SecretMetadata)
+		}
+	}
+
+	if manifest != nil && manifest.KeyEscaping == dump.KeyEscapingPercent {
+		for path, secret := range d {
+			values, ok := secret.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if d[path], err = unescapeKeys(values); err != nil {
+				return nil, err
+			}
+		}
+		for _, versions := range history {
+			for i := range versions {
+				if versions[i].Data, err = unescapeKeys(versions[i].Data); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if manifest != nil && manifest.ValueTagging == dump.ValueTaggingDollar {
+		base := strings.TrimSuffix(filepath.Base(fp), filepath.Ext(fp)) + ".files"
+		if err := decodeValues(filepath.Dir(fp), base, d); err != nil {
+			return nil, err
+		}
+		for path, versions := range history {
+			for _, v := range versions {
+				if err := decodeValues(filepath.Dir(fp), base, map[string]interface{}{path: v.Data}); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return &dumpFile{manifest: manifest, secrets: d, tombstones: tombstones, history: history, metadata: metadata, quarantined: quarantined}, nil
+}
+
+// unescapeKeys returns a copy of values with the field names unescaped, nil
+// for nil values
+func unescapeKeys(values map[string]interface{}) (map[string]interface{}, error) {
+	if values == nil {
+		return nil, nil
 	}
+	unescaped := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		u, err := vault.UnescapeKey(k)
+		if err != nil {
+			return nil, err
+		}
+		unescaped[u] = v
+	}
+	return unescaped, nil
+}
 
-	return d, nil
+// Secrets parses a json or yaml dump read from fp and returns its secrets by the
+// paths import would write them to, see readSecrets for unwrap
+func Secrets(data []byte, fp string, unwrap func([]byte) ([]byte, error)) (map[string]interface{}, error) {
+	df, err := readSecrets(data, fp, "", unwrap)
+	if err != nil {
+		return nil, err
+	}
+	return df.secrets, nil
+}
+
+// Dump is a dump as import reads it, keyed by the paths it would write to
+type Dump struct {
+	Secrets map[string]interface{}
+	// Metadata holds the KV version 2 metadata of secrets, for dumps
+	// including metadata
+	Metadata map[string]*vault.SecretMetadata
+	// History holds the earlier versions of secrets, oldest first, for
+	// dumps of several versions
+	History map[string][]dump.Version
 }
 
-func signalHandler(ctx context.Context, cancelFunc context.CancelFunc, signalChan chan os.Signal) {
-	defer close(signalChan)
-	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+// ReadDump parses a json or yaml dump read from fp like Secrets, along with
+// what it records of the versions of its secrets
+func ReadDump(data []byte, fp string, unwrap func([]byte) ([]byte, error)) (*Dump, error) {
+	df, err := readSecrets(data, fp, "", unwrap)
+	if err != nil {
+		return nil, err
+	}
+	return &Dump{Secrets: df.secrets, Metadata: df.metadata, History: df.history}, nil
+}
 
-	select {
-	case <-ctx.Done():
-		break
-	case s := <-signalChan:
-		if s != nil {
-			log.Println("Caught signal:", s)
+// restorePaths converts the paths of a dump into the paths to write to,
+// undoing their escaping and moving them below target
+func restorePaths(manifest *dump.Manifest, d map[string]interface{}, target string) (map[string]interface{}, error) {
+	if manifest != nil && manifest.PathEscaping == dump.PathEscapingSegment {
+		unescaped := make(map[string]interface{}, len(d))
+		for k, v := range d {
+			p, err := vault.UnescapePath(k)
+			if err != nil {
+				return nil, err
+			}
+			unescaped[p] = v
 		}
+		d = unescaped
 	}
-	cancelFunc()
+
+	// paths are normalized so that restore writes to the same location
+	// however the path was written in the dump
+	normalized := make(map[string]interface{}, len(d))
+	for k, v := range d {
+		normalized[vault.NormalizePath(k)] = v
+	}
+	d = normalized
+
+	return rebase(manifest, d, target)
 }
 
-func (c *Config) secretProducer(ctx context.Context, secrets map[string]interface{}, secretChan chan map[string]interface{}) {
-	defer c.wg.Done()
+// rebase joins the paths of a relative dump to target, or to the recorded
+// root without one, and moves the paths of an absolute dump from its recorded
+// root to target
+func rebase(manifest *dump.Manifest, secrets map[string]interface{}, target string) (map[string]interface{}, error) {
+	relative := manifest != nil && manifest.PathMode == dump.PathModeRelative
+	if target == "" && !relative {
+		return secrets, nil
+	}
 
-	for p, s := range secrets {
-		select {
-		case <-ctx.Done():
-			close(secretChan)
-			return
-		default:
-			ignored := false
-			for _, ip := range c.VaultConfig.Ignore.Paths {
-				if strings.HasPrefix(p, ip) {
-					ignored = true
-					break
-				}
+	root := ""
+	if manifest != nil && manifest.Root != "" {
+		var err error
+		if root, err = vault.UnescapePath(manifest.Root); err != nil {
+			return nil, err
+		}
+		root = vault.NormalizePath(root)
+	}
+	if !relative && root == "" {
+		return nil, fmt.Errorf("a target requires a dump of a single path")
+	}
+	if target == "" {
+		target = root
+	}
+	target = vault.NormalizePath(target)
+
+	rebased := make(map[string]interface{}, len(secrets))
+	for p, v := range secrets {
+		if !relative {
+			if p != root && !strings.HasPrefix(p, root+"/") {
+				return nil, fmt.Errorf("path %s is outside of the dump root %s", p, root)
 			}
-			for _, ik := range c.VaultConfig.Ignore.Keys {
-				if strings.HasSuffix(p, ik) {
-					ignored = true
-					break
+			p = strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+		}
+		rebased[vault.NormalizePath(target+"/"+p)] = v
+	}
+	return rebased, nil
+}
+
+// decodeValues replaces values that were tagged during the dump with their
+// original contents, externalized values are only read from below base in dir
+func decodeValues(dir, base string, secrets map[string]interface{}) error {
+	for path, secret := range secrets {
+		values, ok := secret.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range values {
+			if raw, tagged, err := dump.UntagValue(v); tagged {
+				if err != nil {
+					return fmt.Errorf("failed to decode %s:%s: %w", path, k, err)
 				}
+				values[k] = raw
+				continue
+			}
+			ref, ok := v.(map[string]interface{})
+			if !ok || len(ref) != 1 {
+				continue
+			}
+			name, ok := ref[dump.FileRefKey].(string)
+			if !ok {
+				continue
 			}
+			rel := filepath.Clean(filepath.FromSlash(name))
+			if filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" || !strings.HasPrefix(rel, base+string(filepath.Separator)) {
+				return fmt.Errorf("refusing to read externalized value %s:%s from %s outside of %s", path, k, name, base)
+			}
+			data, err := ioutil.ReadFile(file.LocalPath(filepath.Join(dir, rel)))
+			if err != nil {
+				return fmt.Errorf("failed to read externalized value %s: %w", name, err)
+			}
+			values[k] = string(data)
+		}
+	}
+	return nil
+}
 
-			if !ignored {
-				secretChan <- map[string]interface{}{
-					"k": p,
-					"v": s,
-				}
+// checkAge refuses to restore a dump older than MaxAge at now unless stale
+// dumps are allowed, so secrets are not rolled back by accident
+func (c *Config) checkAge(manifest *dump.Manifest, now time.Time) error {
+	if c.MaxAge <= 0 {
+		return nil
+	}
+	if manifest == nil || manifest.Created == "" {
+		log.Println("Warning: the dump does not record when it was created")
+		return nil
+	}
+	created, err := time.Parse(time.RFC3339, manifest.Created)
+	if err != nil {
+		return fmt.Errorf("invalid creation time %q in the dump: %w", manifest.Created, err)
+	}
+	age := now.Sub(created)
+	if age <= c.MaxAge {
+		return nil
+	}
+	msg := fmt.Sprintf("the dump was created %s ago at %s, more than %s ago", age.Round(time.Minute), manifest.Created, c.MaxAge)
+	if c.AllowStale {
+		log.Printf("Warning: %s\n", msg)
+		return nil
+	}
+	return fmt.Errorf("%s, use --allow-stale to restore it anyway", msg)
+}
+
+// checkCluster refuses to restore into a different cluster than the dump was
+// taken from unless forced, a cluster that can not be identified is allowed
+func (c *Config) checkCluster(manifest *dump.Manifest) error {
+	if manifest == nil || manifest.Cluster == nil {
+		log.Println("Warning: the dump does not record its cluster")
+		return nil
+	}
+	target, err := c.VaultConfig.Fingerprint()
+	if err != nil {
+		log.Printf("Warning: could not identify the target cluster, %s\n", err.Error())
+		return nil
+	}
+	if manifest.Cluster.Same(target) {
+		return nil
+	}
+	msg := fmt.Sprintf("the dump was taken from cluster %s but is being restored into %s", manifest.Cluster, target)
+	if c.Force {
+		log.Printf("Warning: %s\n", msg)
+		return nil
+	}
+	return fmt.Errorf("%s, use --force to restore anyway", msg)
+}
+
+// ignored reports whether the path is excluded by the ignored paths or keys,
+// paths in a namespace are compared relative to it
+func (c *Config) ignored(p string) bool {
+	_, p = c.vaultFor(p)
+	for _, ip := range c.VaultConfig.Ignore.Paths {
+		if strings.HasPrefix(vault.NormalizePath(p), vault.NormalizePath(ip)) {
+			return true
+		}
+	}
+	for _, ik := range c.VaultConfig.Ignore.Keys {
+		if strings.HasSuffix(p, ik) {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreDeletions reproduces the deleted or destroyed latest versions
+// recorded as tombstones, failures are counted like failed writes
+func (c *Config) restoreDeletions(tombstones map[string]vault.VersionState) {
+	if len(tombstones) == 0 {
+		return
+	}
+	if !c.RestoreDeletions {
+		log.Printf("Not restoring %d deleted secrets, use --restore-deletions to delete them\n", len(tombstones))
+		return
+	}
+	for p, state := range tombstones {
+		if c.ignored(p) {
+			continue
+		}
+		vc, rest := c.vaultFor(p)
+		if err := vc.ApplyVersionState(rest, state); err != nil {
+			c.countError("failed to restore deletion of", p, err)
+			continue
+		}
+		log.Println("restored deletion of", p)
+	}
+}
+
+// skipDependents returns the paths of stage whose dependencies were all
+// written, the others are failed without being written
+func (c *Config) skipDependents(stage []string, deps map[string][]string, secrets map[string]interface{}) []string {
+	remaining := make([]string, 0, len(stage))
+	for _, p := range stage {
+		failed := ""
+		for _, d := range deps[p] {
+			if _, ok := c.errInfo.reason.Load(d); ok {
+				failed = d
+				break
 			}
+		}
+		if failed == "" || c.ignored(p) {
+			remaining = append(remaining, p)
+			continue
+		}
+		values, _ := secrets[p].(map[string]interface{})
+		c.handleConsumerError(fmt.Errorf("not written, %s it is ordered after failed", failed), map[string]interface{}{"k": p, "v": values})
+	}
+	return remaining
+}
+
+func (c *Config) secretProducer(ctx context.Context, paths []string, secrets map[string]interface{}, history map[string][]dump.Version, metadata map[string]*vault.SecretMetadata, secretChan chan map[string]interface{}) {
+	defer c.wg.Done()
 
+	for _, p := range paths {
+		if c.ignored(p) {
+			continue
+		}
+		select {
+		case secretChan <- map[string]interface{}{"k": p, "v": secrets[p], "h": history[p], "m": metadata[p]}:
+		case <-ctx.Done():
+			close(secretChan)
+			return
 		}
 	}
 
@@ -195,11 +763,12 @@ func (c *Config) secretConsumer(ctx context.Context, secretChan chan map[string]
 				log.Println("type checking failed", s["k"])
 				return
 			}
-			if vault.IsPolicy(s["k"].(string)) {
+			vc, path := c.vaultFor(s["k"].(string))
+			if vault.IsPolicy(path) {
 				name, hasName := secret["name"].(string)
 				rules, hasRules := secret["rules"].(string)
 				if hasName && hasRules && len(rules) > 0 {
-					err := c.VaultConfig.OverwritePolicy(name, rules)
+					err := vc.OverwritePolicy(name, rules)
 					if err != nil {
 						c.handleConsumerError(err, s)
 					}
@@ -207,7 +776,7 @@ func (c *Config) secretConsumer(ctx context.Context, secretChan chan map[string]
 					log.Println("Warning: unhandled policy ", secret)
 				}
 			} else {
-				if vault.IsDatabaseConfig(s["k"].(string)) {
+				if vault.IsDatabaseConfig(path) {
 					for kk, vv := range secret {
 						if kk == DatabaseConnectionDetailsKey {
 							for cdk, cdv := range vv.(map[string]interface{}) {
@@ -216,21 +785,47 @@ func (c *Config) secretConsumer(ctx context.Context, secretChan chan map[string]
 							delete(secret, DatabaseConnectionDetailsKey)
 						}
 					}
-					if err := c.VaultConfig.OverwriteSecret(s["k"].(string), secret); err != nil {
+					if err := vc.OverwriteSecret(path, secret); err != nil {
 						c.handleConsumerError(err, s)
 					}
 				}
-				if err := c.VaultConfig.OverwriteSecret(s["k"].(string), secret); err != nil {
+				if err := c.writeHistory(vc, path, s["h"].([]dump.Version)); err != nil {
+					c.handleConsumerError(err, s)
+					continue
+				}
+				if err := vc.OverwriteSecret(path, secret); err != nil {
 					c.handleConsumerError(err, s)
+					continue
+				}
+				if m := s["m"].(*vault.SecretMetadata); m != nil {
+					if _, err := vc.WriteMetadata(path, m); err != nil {
+						c.handleConsumerError(fmt.Errorf("failed to write metadata: %w", err), s)
+						continue
+					}
 				}
+				c.written.Store(s["k"].(string), true)
 			}
 		}
 	}
 }
 
+// writeHistory writes the earlier versions of the secret at path oldest
+// first, before the secret itself is written as its latest version. Deleted
+// and destroyed versions hold no values and are left out.
+func (c *Config) writeHistory(vc *vault.Config, path string, history []dump.Version) error {
+	for _, v := range history {
+		if v.Data == nil {
+			continue
+		}
+		if err := vc.OverwriteSecret(path, v.Data); err != nil {
+			return fmt.Errorf("failed to write version %d: %w", v.Version, err)
+		}
+	}
+	return nil
+}
+
 func (c *Config) handleConsumerError(err error, secret map[string]interface{}) {
-	errSlice := strings.Split(err.Error(), ":")
-	errID := strings.TrimSpace(errSlice[len(errSlice)-1])
+	errID := vault.ClassifyError(err)
 	count, ok := c.errInfo.count.LoadOrStore(errID, 1)
 	if ok {
 		ec := count.(int) // cast interface to integer
@@ -238,6 +833,7 @@ func (c *Config) handleConsumerError(err error, secret map[string]interface{}) {
 		c.errInfo.count.Store(errID, ec)
 	}
 
-	log.Println(err.Error())
+	log.Printf("failed to import %s [%s], %s\n", secret["k"], errID, err.Error())
 	c.errInfo.data.Store(secret["k"].(string), secret["v"].(map[string]interface{}))
+	c.errInfo.reason.Store(secret["k"].(string), fmt.Sprintf("%s: %s", errID, err.Error()))
 }
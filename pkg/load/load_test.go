@@ -0,0 +1,309 @@
+package load
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/dump"
+	"github.com/dathan/go-vault-dump/pkg/file"
+)
+
+func TestSuiteReadSecrets(tt *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-dump-test-*")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if ok := file.WriteFile(filepath.Join(dir, "dump.files", "secret", "a", "cert"), "certificate"); !ok {
+		tt.Fatal("failed to write externalized value")
+	}
+	if ok := file.WriteFile(filepath.Join(dir, "outside"), "not a secret"); !ok {
+		tt.Fatal("failed to write outside file")
+	}
+
+	var (
+		norm    string
+		success bool
+		tests   = []struct {
+			description string
+			input       string
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Plain value", `{"secret/a":{"k":"v"}}`, "v", true},
+			{"Externalized value", `{"$manifest":{"version":1,"value_tagging":"dollar-tags"},"secret/a":{"k":{"$file":"dump.files/secret/a/cert"}}}`, "certificate", true},
+			{"Externalized value outside of files", `{"$manifest":{"version":1,"value_tagging":"dollar-tags"},"secret/a":{"k":{"$file":"dump.files/../outside"}}}`, "", false},
+			{"Externalized absolute path", fmt.Sprintf(`{"$manifest":{"version":1,"value_tagging":"dollar-tags"},"secret/a":{"k":{"$file":%q}}}`, filepath.ToSlash(filepath.Join(dir, "outside"))), "", false},
+			{"Externalized value in other directory", `{"$manifest":{"version":1,"value_tagging":"dollar-tags"},"secret/a":{"k":{"$file":"other.files/secret/a/cert"}}}`, "", false},
+			{"Binary value", `{"$manifest":{"version":1,"value_tagging":"dollar-tags"},"secret/a":{"k":{"$binary":"/w=="}}}`, "\xff", true},
+			{"Literal value", `{"$manifest":{"version":1,"value_tagging":"dollar-tags"},"secret/a":{"k":{"$literal":{"$binary":"x"}}}}`, "map[$binary:x]", true},
+			{"Escaped field name", `{"$manifest":{"version":1,"key_escaping":"percent"},"secret/a":{"%6B":"v"}}`, "v", true},
+			{"Escaped path", `{"$manifest":{"version":1,"path_escaping":"percent-segment"},"secret/%61":{"k":"v"}}`, "v", true},
+			{"Tag-like value without manifest", `{"secret/a":{"k":{"$file":"dump.files/secret/a/cert"}}}`, "map[$file:dump.files/secret/a/cert]", true},
+			{"YAML dump", "secret/a:\n  k: v\n", "v", true},
+			{"YAML dump with manifest", "$manifest:\n  version: 1\n  value_tagging: dollar-tags\nsecret/a:\n  k:\n    $binary: /w==\n", "\xff", true},
+			{"Invalid YAML dump", "secret/a: [\n", "", false},
+			{"Metadata only inventory", `{"$manifest":{"version":1,"metadata_only":true},"secret/a":{"current_version":1}}`, "", false},
+		}
+	)
+	for _, test := range tests {
+		fp := filepath.Join(dir, "dump.json")
+		if ok := file.WriteFile(fp, test.input); !ok {
+			tt.Fatal("failed to write dump")
+		}
+		df, err := readSecretsFromFile(fp, "", nil)
+		success = (err == nil)
+		norm = ""
+		if success {
+			norm = fmt.Sprint(df.secrets["secret/a"].(map[string]interface{})["k"])
+		}
+
+		if success == test.isSuccess && (!success || norm == test.normOutput) {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		}
+	}
+}
+
+func TestSuiteRebase(tt *testing.T) {
+	var (
+		norm    string
+		success bool
+		tests   = []struct {
+			description string
+			manifest    *dump.Manifest
+			input       string
+			target      string
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Absolute without target", &dump.Manifest{PathMode: dump.PathModeAbsolute, Root: "secret/data/app"}, "secret/data/app/db", "", "secret/data/app/db", true},
+			{"Absolute with target", &dump.Manifest{PathMode: dump.PathModeAbsolute, Root: "secret/data/app"}, "secret/data/app/db", "kv/app/", "kv/app/db", true},
+			{"Absolute with target and no root", &dump.Manifest{PathMode: dump.PathModeAbsolute}, "secret/data/app/db", "kv/app", "", false},
+			{"Relative without target", &dump.Manifest{PathMode: dump.PathModeRelative, Root: "secret/data/app"}, "db", "", "secret/data/app/db", true},
+			{"Relative with target", &dump.Manifest{PathMode: dump.PathModeRelative, Root: "secret/data/app"}, "db", "kv/app", "kv/app/db", true},
+			{"Relative with escaped root", &dump.Manifest{PathMode: dump.PathModeRelative, Root: "secret/a%2541"}, "db", "", "secret/a%41/db", true},
+			{"No manifest", nil, "secret/data/app/db", "", "secret/data/app/db", true},
+		}
+	)
+	for _, test := range tests {
+		secrets, err := rebase(test.manifest, map[string]interface{}{test.input: nil}, test.target)
+		success = (err == nil)
+		norm = ""
+		for p := range secrets {
+			norm = p
+		}
+
+		if success == test.isSuccess && (!success || norm == test.normOutput) {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		}
+	}
+}
+
+func TestSuiteReadTombstones(tt *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-dump-test-*")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		tests = []struct {
+			description string
+			input       string
+			target      string
+			normOutput  string
+		}{
+			{"Escaped path", `{"$manifest":{"version":1,"path_escaping":"percent-segment","tombstones":{"secret/data/a%2541":{"version":2,"destroyed":true}}}}`, "", "secret/data/a%41=2,true"},
+			{"Target", `{"$manifest":{"version":1,"path_mode":"relative","root":"secret/data","tombstones":{"a":{"version":3,"deletion_time":"2020-01-01T00:00:00Z"}}}}`, "kv", "kv/a=3,false"},
+		}
+	)
+	for _, test := range tests {
+		fp := filepath.Join(dir, "dump.json")
+		if ok := file.WriteFile(fp, test.input); !ok {
+			tt.Fatal("failed to write dump")
+		}
+		df, err := readSecretsFromFile(fp, test.target, nil)
+		if err != nil {
+			tt.Errorf("FAIL %s: %v", test.description, err)
+			continue
+		}
+		norm := ""
+		for p, state := range df.tombstones {
+			norm = fmt.Sprintf("%s=%d,%t", p, state.Version, state.Destroyed)
+		}
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteReadHistory(tt *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-dump-test-*")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		tests = []struct {
+			description string
+			input       string
+			target      string
+			normOutput  string
+		}{
+			{"Escaped", `{"$manifest":{"version":1,"path_escaping":"percent-segment","key_escaping":"percent","value_tagging":"dollar-tags","history":{"secret/data/a%2541":[{"version":1,"created_time":"2020-01-01T00:00:00Z","data":{"%6B":{"$binary":"/w=="}}},{"version":2,"created_time":"2020-01-02T00:00:00Z","destroyed":true}]}},"secret/data/a%2541":{"k":"v3"}}`, "", "secret/data/a%41=[1:map[k:\xff] 2:map[]]"},
+			{"Target", `{"$manifest":{"version":1,"path_mode":"relative","root":"secret/data","history":{"a":[{"version":1,"created_time":"2020-01-01T00:00:00Z","data":{"k":"v1"}}]}},"a":{"k":"v2"}}`, "kv", "kv/a=[1:map[k:v1]]"},
+		}
+	)
+	for _, test := range tests {
+		fp := filepath.Join(dir, "dump.json")
+		if ok := file.WriteFile(fp, test.input); !ok {
+			tt.Fatal("failed to write dump")
+		}
+		df, err := readSecretsFromFile(fp, test.target, nil)
+		if err != nil {
+			tt.Errorf("FAIL %s: %v", test.description, err)
+			continue
+		}
+		norm := ""
+		for p, versions := range df.history {
+			v := []string{}
+			for _, version := range versions {
+				v = append(v, fmt.Sprintf("%d:%v", version.Version, version.Data))
+			}
+			norm = fmt.Sprintf("%s=%v", p, v)
+		}
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteReadMetadata(tt *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-dump-test-*")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		tests = []struct {
+			description string
+			input       string
+			target      string
+			normOutput  string
+		}{
+			{"Escaped path", `{"$manifest":{"version":1,"path_escaping":"percent-segment","metadata":{"secret/data/a%2541":{"current_version":2,"cas_required":true,"custom_metadata":{"owner":"team-a"}}}},"secret/data/a%2541":{"k":"v"}}`, "", "secret/data/a%41=map[owner:team-a],true"},
+			{"Target", `{"$manifest":{"version":1,"path_mode":"relative","root":"secret/data","metadata":{"a":{"current_version":1,"max_versions":5}}},"a":{"k":"v"}}`, "kv", "kv/a=map[],false"},
+		}
+	)
+	for _, test := range tests {
+		fp := filepath.Join(dir, "dump.json")
+		if ok := file.WriteFile(fp, test.input); !ok {
+			tt.Fatal("failed to write dump")
+		}
+		df, err := readSecretsFromFile(fp, test.target, nil)
+		if err != nil {
+			tt.Errorf("FAIL %s: %v", test.description, err)
+			continue
+		}
+		norm := ""
+		for p, m := range df.metadata {
+			norm = fmt.Sprintf("%s=%v,%v", p, m.CustomMetadata, m.CasRequired)
+		}
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteReadQuarantined(tt *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-dump-test-*")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		tests = []struct {
+			description string
+			input       string
+			target      string
+			normOutput  string
+		}{
+			{"Complete dump", `{"$manifest":{"version":1},"secret/a":{"k":"v"}}`, "", ""},
+			{"Escaped path", `{"$manifest":{"version":1,"path_escaping":"percent-segment","quarantine":"d.quarantine.json","failed":{"secret/data/a%2541":{"category":"permission-denied","reason":"permission denied"}}},"secret/data/b":{"k":"v"}}`, "", "secret/data/a%41=permission-denied: permission denied"},
+			{"Target", `{"$manifest":{"version":1,"path_mode":"relative","root":"secret/data","failed":{"a":{"category":"not-found","reason":"not found"}}},"b":{"k":"v"}}`, "kv", "kv/a=not-found: not found"},
+		}
+	)
+	for _, test := range tests {
+		fp := filepath.Join(dir, "dump.json")
+		if ok := file.WriteFile(fp, test.input); !ok {
+			tt.Fatal("failed to write dump")
+		}
+		df, err := readSecretsFromFile(fp, test.target, nil)
+		if err != nil {
+			tt.Errorf("FAIL %s: %v", test.description, err)
+			continue
+		}
+		norm := ""
+		for p, reason := range df.quarantined {
+			norm = fmt.Sprintf("%s=%s", p, reason)
+		}
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteCheckAge(tt *testing.T) {
+	now := time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC)
+	var (
+		success bool
+		tests   = []struct {
+			description string
+			created     string
+			maxAge      time.Duration
+			allowStale  bool
+			isSuccess   bool
+		}{
+			{"Fresh dump", "2020-01-09T00:00:00Z", 48 * time.Hour, false, true},
+			{"Stale dump", "2020-01-01T00:00:00Z", 48 * time.Hour, false, false},
+			{"Stale dump allowed", "2020-01-01T00:00:00Z", 48 * time.Hour, true, true},
+			{"Check disabled", "2020-01-01T00:00:00Z", 0, false, true},
+			{"Unknown age", "", 48 * time.Hour, false, true},
+			{"Invalid creation time", "yesterday", 48 * time.Hour, true, false},
+		}
+	)
+	for _, test := range tests {
+		c := &Config{MaxAge: test.maxAge, AllowStale: test.allowStale}
+		err := c.checkAge(&dump.Manifest{Created: test.created}, now)
+		success = (err == nil)
+		if success == test.isSuccess {
+			tt.Logf("PASS %s", test.description)
+		} else {
+			tt.Errorf("FAIL %s: expected %t got %t (%v)", test.description, test.isSuccess, success, err)
+		}
+	}
+}
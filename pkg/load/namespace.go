@@ -0,0 +1,40 @@
+package load
+
+import (
+	"github.com/dathan/go-vault-dump/pkg/dump"
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// useNamespaces prepares a client for each namespace the paths of the dump
+// are prefixed with
+func (c *Config) useNamespaces(manifest *dump.Manifest) error {
+	c.namespaces = make(map[string]*vault.Config)
+	if manifest == nil {
+		return nil
+	}
+	for _, ns := range manifest.Namespaces {
+		vc, err := c.VaultConfig.WithNamespace(ns)
+		if err != nil {
+			return err
+		}
+		c.namespaces[vault.NormalizePath(ns)] = vc
+	}
+	return nil
+}
+
+// vaultFor returns the client of the namespace p is below and p relative to
+// that namespace
+func (c *Config) vaultFor(p string) (*vault.Config, string) {
+	if len(c.namespaces) == 0 {
+		return c.VaultConfig, p
+	}
+	namespaces := make([]string, 0, len(c.namespaces))
+	for ns := range c.namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	ns, rest := vault.SplitNamespace(p, namespaces)
+	if ns == "" {
+		return c.VaultConfig, p
+	}
+	return c.namespaces[ns], rest
+}
@@ -0,0 +1,124 @@
+package load
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+	"github.com/ghodss/yaml"
+)
+
+// Order holds the constraints on the order secrets are written in, every
+// secret not constrained is written in parallel with the others
+type Order struct {
+	Rules []OrderRule `json:"rules"`
+}
+
+// OrderRule writes the secrets below any of Paths only once every secret
+// below any of After was written, paths as they are restored
+type OrderRule struct {
+	Paths []string `json:"paths"`
+	After []string `json:"after"`
+}
+
+// ReadOrder reads a JSON or YAML restore order from fp
+func ReadOrder(fp string) (*Order, error) {
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	o := &Order{}
+	if err := yaml.Unmarshal(data, o); err != nil {
+		return nil, fmt.Errorf("invalid restore order %s: %w", fp, err)
+	}
+	for i, r := range o.Rules {
+		if len(r.Paths) == 0 || len(r.After) == 0 {
+			return nil, fmt.Errorf("invalid restore order %s: rule %d needs paths and after", fp, i+1)
+		}
+	}
+	return o, nil
+}
+
+// below reports whether p is below any of the prefixes
+func below(p string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(vault.NormalizePath(p), vault.NormalizePath(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// stages splits paths into the sorted paths written one stage after the
+// other, each stage once the earlier ones are done, and returns the paths
+// each path waits for. A nil order writes every path in a single stage.
+func (o *Order) stages(paths []string) ([][]string, map[string][]string, error) {
+	sorted := append([]string{}, paths...)
+	sort.Strings(sorted)
+	if o == nil || len(o.Rules) == 0 {
+		return [][]string{sorted}, map[string][]string{}, nil
+	}
+
+	// the secrets each rule waits for
+	after := make([][]string, len(o.Rules))
+	for i, r := range o.Rules {
+		for _, p := range sorted {
+			if below(p, r.After) {
+				after[i] = append(after[i], p)
+			}
+		}
+	}
+	deps := make(map[string][]string)
+	for _, p := range sorted {
+		for i, r := range o.Rules {
+			if !below(p, r.Paths) {
+				continue
+			}
+			for _, d := range after[i] {
+				if d != p {
+					deps[p] = append(deps[p], d)
+				}
+			}
+		}
+	}
+
+	const visiting = -1
+	levels := make(map[string]int, len(sorted))
+	var level func(p string) (int, error)
+	level = func(p string) (int, error) {
+		switch l, ok := levels[p]; {
+		case ok && l == visiting:
+			return 0, fmt.Errorf("the restore order has a cycle through %s", p)
+		case ok:
+			return l, nil
+		}
+		levels[p] = visiting
+		l := 0
+		for _, d := range deps[p] {
+			dl, err := level(d)
+			if err != nil {
+				return 0, err
+			}
+			if dl+1 > l {
+				l = dl + 1
+			}
+		}
+		levels[p] = l
+		return l, nil
+	}
+
+	stages := [][]string{}
+	for _, p := range sorted {
+		l, err := level(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		for len(stages) <= l {
+			stages = append(stages, []string{})
+		}
+		stages[l] = append(stages[l], p)
+	}
+	return stages, deps, nil
+}
@@ -0,0 +1,45 @@
+package load
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSuiteOrderStages(tt *testing.T) {
+	paths := []string{"pki/ca", "secret/app/tls", "secret/app/db", "secret/web/tls", "secret/other"}
+	var (
+		tests = []struct {
+			description string
+			order       *Order
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"No order", nil, "[[pki/ca secret/app/db secret/app/tls secret/other secret/web/tls]]", true},
+			{"CA first", &Order{Rules: []OrderRule{{Paths: []string{"secret/app/tls", "secret/web/"}, After: []string{"pki/"}}}},
+				"[[pki/ca secret/app/db secret/other] [secret/app/tls secret/web/tls]]", true},
+			{"Chained rules", &Order{Rules: []OrderRule{
+				{Paths: []string{"secret/app/"}, After: []string{"pki/"}},
+				{Paths: []string{"secret/web/"}, After: []string{"secret/app/tls"}},
+			}}, "[[pki/ca secret/other] [secret/app/db secret/app/tls] [secret/web/tls]]", true},
+			{"Rule on itself", &Order{Rules: []OrderRule{{Paths: []string{"secret/app/"}, After: []string{"secret/app/"}}}},
+				"", false},
+			{"Cycle", &Order{Rules: []OrderRule{
+				{Paths: []string{"pki/"}, After: []string{"secret/web/"}},
+				{Paths: []string{"secret/web/"}, After: []string{"pki/"}},
+			}}, "", false},
+			{"Nothing to wait for", &Order{Rules: []OrderRule{{Paths: []string{"secret/"}, After: []string{"missing/"}}}},
+				"[[pki/ca secret/app/db secret/app/tls secret/other secret/web/tls]]", true},
+		}
+	)
+	for _, test := range tests {
+		stages, _, err := test.order.stages(paths)
+		norm := fmt.Sprint(stages)
+		if (err == nil) != test.isSuccess {
+			tt.Errorf("FAIL %s: expected success %v got %v", test.description, test.isSuccess, err)
+		} else if err == nil && norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package load
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// Plan is what restoring a dump changes in Vault
+type Plan struct {
+	Creates    int
+	Overwrites int
+	Deletes    int
+}
+
+// plan reads every path of df that is not ignored from Vault to tell the
+// secrets created from the ones overwritten
+func (c *Config) plan(df *dumpFile) (Plan, error) {
+	var (
+		p    Plan
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		err  error
+		jobs = make(chan string)
+	)
+	for i := 0; i != 2*runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				exists, e := c.exists(path, df.secrets[path])
+				mu.Lock()
+				switch {
+				case e != nil:
+					if err == nil {
+						err = e
+					}
+				case exists:
+					p.Overwrites++
+				default:
+					p.Creates++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for path := range df.secrets {
+		if !c.ignored(path) {
+			jobs <- path
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err != nil {
+		return Plan{}, err
+	}
+
+	if c.RestoreDeletions {
+		for path := range df.tombstones {
+			if !c.ignored(path) {
+				p.Deletes++
+			}
+		}
+	}
+	return p, nil
+}
+
+// exists reports whether the secret, or policy, at path is in Vault
+func (c *Config) exists(path string, secret interface{}) (bool, error) {
+	vc, path := c.vaultFor(path)
+	if vault.IsPolicy(path) {
+		values, _ := secret.(map[string]interface{})
+		name, _ := values["name"].(string)
+		rules, err := vc.Client.Sys().GetPolicy(name)
+		return rules != "", err
+	}
+	data, _, err := vc.ReadSecret(path)
+	return data != nil, err
+}
@@ -0,0 +1,63 @@
+package load
+
+import (
+	"log"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/notify"
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// RotationEvent is posted to the rotation webhooks after a restore, it
+// never holds secret values
+type RotationEvent struct {
+	Event string   `json:"event"`
+	Time  string   `json:"time"`
+	Paths []string `json:"paths"`
+}
+
+// rotate triggers rotation of the credentials of the restored paths,
+// failures are logged and counted like failed writes
+func (c *Config) rotate() {
+	paths := c.Written()
+	if len(paths) == 0 {
+		return
+	}
+
+	if c.RotateDatabase {
+		for _, p := range paths {
+			vc, rest := c.vaultFor(p)
+			if !vault.IsDatabaseConfig(rest) {
+				continue
+			}
+			if err := vc.RotateRoot(rest); err != nil {
+				c.countError("failed to rotate root credentials of", p, err)
+				continue
+			}
+			log.Println("rotated root credentials of", p)
+		}
+	}
+
+	event := RotationEvent{
+		Event: "restore",
+		Time:  time.Now().UTC().Format(time.RFC3339),
+		Paths: paths,
+	}
+	for _, url := range c.RotateWebhooks {
+		if err := notify.PostJSON(url, event); err != nil {
+			c.countError("failed to notify rotation webhook for", url, err)
+			continue
+		}
+		log.Printf("notified rotation webhook of %d restored paths\n", len(paths))
+	}
+}
+
+// countError logs an error about subject and counts it by category
+func (c *Config) countError(msg, subject string, err error) {
+	category := vault.ClassifyError(err)
+	log.Printf("%s %s [%s], %s\n", msg, subject, category, err.Error())
+	count, ok := c.errInfo.count.LoadOrStore(category, 1)
+	if ok {
+		c.errInfo.count.Store(category, count.(int)+1)
+	}
+}
@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Default templates of failure emails, they are executed with the report
+// of the failed run
+const (
+	DefaultSubject = "vault-dump {{.Command}} of {{.Cluster}} failed"
+	DefaultBody    = `The {{.Command}} of {{.Cluster}} started at {{.Start.Format "2006-01-02T15:04:05Z07:00"}} failed.
+{{if .Error}}
+Error: {{.Error}}
+{{end}}
+Secrets: {{.Secrets}}
+Failed: {{.Failed}}
+
+The failed paths are attached, secret values are never included.
+`
+)
+
+// Email holds the SMTP settings of failure notifications
+type Email struct {
+	// Addr is the host:port of the SMTP server
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+	// Subject and Body are text/template templates, the defaults are used
+	// when they are empty
+	Subject string
+	Body    string
+}
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Send renders the subject and body with data and sends them along with the
+// attachments to every recipient
+func (e *Email) Send(data interface{}, attachments ...Attachment) error {
+	msg, err := e.message(data, time.Now(), attachments)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, err := net.SplitHostPort(e.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+	return smtp.SendMail(e.Addr, auth, e.From, e.To, msg)
+}
+
+// message returns the MIME message sent by Send
+func (e *Email) message(data interface{}, date time.Time, attachments []Attachment) ([]byte, error) {
+	if e.Addr == "" || e.From == "" || len(e.To) == 0 {
+		return nil, errors.New("an SMTP server, sender and recipient are required")
+	}
+	subject, err := render("subject", e.Subject, DefaultSubject, data)
+	if err != nil {
+		return nil, err
+	}
+	body, err := render("body", e.Body, DefaultBody, data)
+	if err != nil {
+		return nil, err
+	}
+
+	boundary := fmt.Sprintf("vault-dump-%d", date.UnixNano())
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject)))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	fmt.Fprintf(&b, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&b, "Content-Transfer-Encoding: base64\r\n\r\n")
+	writeBase64(&b, []byte(body))
+
+	for _, a := range attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		fmt.Fprintf(&b, "--%s\r\n", boundary)
+		fmt.Fprintf(&b, "Content-Type: %s\r\n", contentType)
+		fmt.Fprintf(&b, "Content-Disposition: attachment; filename=%q\r\n", a.Name)
+		fmt.Fprintf(&b, "Content-Transfer-Encoding: base64\r\n\r\n")
+		writeBase64(&b, a.Data)
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes(), nil
+}
+
+func render(name, text, fallback string, data interface{}) (string, error) {
+	if text == "" {
+		text = fallback
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid email %s template: %w", name, err)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("invalid email %s template: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// writeBase64 writes data base64 encoded in lines of 76 characters
+func writeBase64(b *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+}
@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSuiteEmail(tt *testing.T) {
+	report := struct {
+		Command, Cluster, Error string
+		Secrets, Failed         int
+		Start                   time.Time
+	}{"dump", "https://vault:8200", "1 secret failed", 10, 1, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	attachment := Attachment{Name: "failures.json", ContentType: "application/json", Data: []byte(`{"secret/a":"permission-denied"}`)}
+
+	var (
+		success bool
+		tests   = []struct {
+			description string
+			email       Email
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Default templates", Email{Addr: "smtp:25", From: "a@b", To: []string{"c@d"}}, "Subject: vault-dump dump of https://vault:8200 failed", true},
+			{"Custom subject", Email{Addr: "smtp:25", From: "a@b", To: []string{"c@d"}, Subject: "[backup] {{.Failed}} failed"}, "Subject: [backup] 1 failed", true},
+			{"Attachment", Email{Addr: "smtp:25", From: "a@b", To: []string{"c@d"}}, `Content-Disposition: attachment; filename="failures.json"`, true},
+			{"Unknown field", Email{Addr: "smtp:25", From: "a@b", To: []string{"c@d"}, Body: "{{.Value}}"}, "", false},
+			{"No recipient", Email{Addr: "smtp:25", From: "a@b"}, "", false},
+		}
+	)
+	for _, test := range tests {
+		msg, err := test.email.message(report, report.Start, []Attachment{attachment})
+		success = (err == nil)
+		if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else if success && !strings.Contains(string(msg), test.normOutput) {
+			tt.Errorf("FAIL %s: expected '%s' in '%s'", test.description, test.normOutput, msg)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
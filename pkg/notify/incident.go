@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Pager opens and resolves incidents, incidents are identified by a key so
+// repeated triggers update the same incident
+type Pager interface {
+	Trigger(key, summary, source string) error
+	Resolve(key string) error
+}
+
+// PagerDuty sends incidents through the PagerDuty Events API v2
+type PagerDuty struct {
+	RoutingKey string
+	// URL defaults to the PagerDuty events endpoint
+	URL string
+}
+
+func (p *PagerDuty) endpoint() string {
+	if p.URL != "" {
+		return p.URL
+	}
+	return "https://events.pagerduty.com/v2/enqueue"
+}
+
+// Trigger opens or updates the incident for key
+func (p *PagerDuty) Trigger(key, summary, source string) error {
+	return PostJSON(p.endpoint(), map[string]interface{}{
+		"routing_key":  p.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    key,
+		"payload": map[string]string{
+			"summary":  summary,
+			"source":   source,
+			"severity": "error",
+		},
+	})
+}
+
+// Resolve resolves the incident for key
+func (p *PagerDuty) Resolve(key string) error {
+	return PostJSON(p.endpoint(), map[string]string{
+		"routing_key":  p.RoutingKey,
+		"event_action": "resolve",
+		"dedup_key":    key,
+	})
+}
+
+// Opsgenie sends incidents as Opsgenie alerts
+type Opsgenie struct {
+	APIKey string
+	// URL defaults to the Opsgenie alert API, use https://api.eu.opsgenie.com/v2/alerts
+	// for the EU instance
+	URL string
+}
+
+func (o *Opsgenie) endpoint() string {
+	if o.URL != "" {
+		return o.URL
+	}
+	return "https://api.opsgenie.com/v2/alerts"
+}
+
+func (o *Opsgenie) header() http.Header {
+	return http.Header{"Authorization": {"GenieKey " + o.APIKey}}
+}
+
+// Trigger creates the alert for key, Opsgenie deduplicates open alerts by
+// their alias
+func (o *Opsgenie) Trigger(key, summary, source string) error {
+	return postJSON(o.endpoint(), o.header(), map[string]string{
+		"message":  summary,
+		"alias":    key,
+		"source":   source,
+		"priority": "P2",
+	})
+}
+
+// Resolve closes the alert for key
+func (o *Opsgenie) Resolve(key string) error {
+	u := o.endpoint() + "/" + url.PathEscape(key) + "/close?identifierType=alias"
+	return postJSON(u, o.header(), map[string]string{"source": "vault-dump"})
+}
+
+// Escalation triggers an incident once a job failed After times in a row
+// and resolves it at the next success
+type Escalation struct {
+	Pagers []Pager
+	After  int
+	// Key identifies the incident of the job, Source names the host it runs on
+	Key    string
+	Source string
+
+	failures int
+	open     bool
+}
+
+// Failed records a failed run, the returned error is the first failure to
+// notify a pager
+func (e *Escalation) Failed(err error) error {
+	e.failures++
+	if len(e.Pagers) == 0 || e.failures < e.After {
+		return nil
+	}
+	summary := fmt.Sprintf("%s failed %d times in a row: %s", e.Key, e.failures, err)
+	var first error
+	for _, p := range e.Pagers {
+		if err := p.Trigger(e.Key, summary, e.Source); err != nil && first == nil {
+			first = err
+		}
+	}
+	// resolving an incident that failed to open is harmless
+	e.open = true
+	return first
+}
+
+// Succeeded records a successful run, resolving the open incident
+func (e *Escalation) Succeeded() error {
+	e.failures = 0
+	if !e.open {
+		return nil
+	}
+	var first error
+	for _, p := range e.Pagers {
+		if err := p.Resolve(e.Key); err != nil && first == nil {
+			first = err
+		}
+	}
+	e.open = first != nil
+	return first
+}
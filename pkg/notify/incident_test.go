@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSuiteIncident(tt *testing.T) {
+	var (
+		actions []string
+		auth    string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if a, ok := body["event_action"].(string); ok {
+			actions = append(actions, a)
+		} else if strings.HasSuffix(r.URL.Path, "/close") {
+			actions = append(actions, "resolve")
+		} else {
+			actions = append(actions, "trigger")
+		}
+		auth = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	var (
+		tests = []struct {
+			description string
+			pager       Pager
+			runs        string
+			normOutput  string
+		}{
+			{"Below threshold", &PagerDuty{RoutingKey: "k", URL: server.URL}, "ff.", ""},
+			{"Trigger once reached", &PagerDuty{RoutingKey: "k", URL: server.URL}, "fff", "trigger"},
+			{"Resolve on success", &PagerDuty{RoutingKey: "k", URL: server.URL}, "ffff.", "trigger,trigger,resolve"},
+			{"Streak reset by success", &PagerDuty{RoutingKey: "k", URL: server.URL}, "ff.ff", ""},
+			{"Opsgenie", &Opsgenie{APIKey: "k", URL: server.URL}, "fff.", "trigger,resolve"},
+		}
+	)
+	for _, test := range tests {
+		actions = nil
+		e := &Escalation{Pagers: []Pager{test.pager}, After: 3, Key: "vault-dump", Source: "host"}
+		for _, r := range test.runs {
+			if r == 'f' {
+				e.Failed(errors.New("dump failed"))
+			} else {
+				e.Succeeded()
+			}
+		}
+		norm := strings.Join(actions, ",")
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else if _, ok := test.pager.(*Opsgenie); ok && auth != "GenieKey k" {
+			tt.Errorf("FAIL %s: expected 'GenieKey k' got '%s'", test.description, auth)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Timeout bounds each webhook request
+var Timeout = 30 * time.Second
+
+// PostJSON posts v encoded as JSON to url, any status other than 2xx is an
+// error
+func PostJSON(url string, v interface{}) error {
+	return postJSON(url, nil, v)
+}
+
+func postJSON(url string, header http.Header, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// Ping posts body as text to url, as expected by healthchecks.io style dead
+// man's switches
+func Ping(url, body string) error {
+	client := &http.Client{Timeout: Timeout}
+	resp, err := client.Post(url, "text/plain", strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("healthcheck %s returned %s", url, resp.Status)
+	}
+	return nil
+}
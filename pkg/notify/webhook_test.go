@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSuiteWebhook(tt *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	var (
+		success bool
+		tests   = []struct {
+			description string
+			url         string
+			isSuccess   bool
+		}{
+			{"Delivered", server.URL + "/ok", true},
+			{"Rejected", server.URL + "/fail", false},
+		}
+	)
+	for _, test := range tests {
+		received = nil
+		err := PostJSON(test.url, map[string]interface{}{"event": "test"})
+		success = (err == nil)
+		if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else if success && received["event"] != "test" {
+			tt.Errorf("FAIL %s: expected 'test' got '%v'", test.description, received["event"])
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuitePing(tt *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing/fail" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		received = r.URL.Path + " " + string(body)
+	}))
+	defer server.Close()
+
+	var (
+		success bool
+		tests   = []struct {
+			description string
+			url         string
+			body        string
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Start", server.URL + "/check/start", "", "/check/start ", true},
+			{"Success", server.URL + "/check", "duration=1s", "/check duration=1s", true},
+			{"Unknown check", server.URL + "/missing/fail", "", "", false},
+		}
+	)
+	for _, test := range tests {
+		received = ""
+		err := Ping(test.url, test.body)
+		success = (err == nil)
+		if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else if success && received != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, received)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package print
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	alsoyaml "github.com/ghodss/yaml"
 	"gopkg.in/yaml.v2"
@@ -48,3 +51,40 @@ func ToYaml(i interface{}) (string, error) {
 
 	return string(y), nil
 }
+
+// JSONOptions control how ToJSONWith writes json, keys are always sorted
+type JSONOptions struct {
+	// Indent indents nested values by this many spaces, 0 writes json on a
+	// single line
+	Indent int
+	// NoEscapeHTML writes <, > and & as they are instead of as \u003c, \u003e
+	// and \u0026
+	NoEscapeHTML bool
+}
+
+// ToJSONWith encodes i as json written as o asks, numbers are kept as ToJSON
+// writes them
+func ToJSONWith(i interface{}, o JSONOptions) (string, error) {
+	if o.Indent < 0 {
+		return "", fmt.Errorf("invalid json indent %d", o.Indent)
+	}
+	j, err := ToJSON(i)
+	if err != nil || o == (JSONOptions{}) {
+		return j, err
+	}
+
+	var v interface{}
+	d := json.NewDecoder(strings.NewReader(j))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return "", fmt.Errorf("error when reencoding json: %w", err)
+	}
+	var b bytes.Buffer
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(!o.NoEscapeHTML)
+	e.SetIndent("", strings.Repeat(" ", o.Indent))
+	if err := e.Encode(v); err != nil {
+		return "", fmt.Errorf("error when reencoding json: %w", err)
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
@@ -51,3 +51,38 @@ func TestSuitePrint(tt *testing.T) {
 		}
 	}
 }
+
+func TestSuiteToJSONWith(tt *testing.T) {
+	secret := map[string]interface{}{"b": map[string]interface{}{"url": "https://a?x=1&y=<2>"}, "a": float64(12345678901234567)}
+	var (
+		norm    string
+		success bool
+		tests   = []struct {
+			description string
+			inputs      map[string]interface{}
+			options     JSONOptions
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Defaults", secret, JSONOptions{}, `{"a":12345678901234568,"b":{"url":"https://a?x=1\u0026y=\u003c2\u003e"}}`, true},
+			{"Pretty", secret, JSONOptions{Indent: 2}, "{\n  \"a\": 12345678901234568,\n  \"b\": {\n    \"url\": \"https://a?x=1\\u0026y=\\u003c2\\u003e\"\n  }\n}", true},
+			{"HTML not escaped", secret, JSONOptions{NoEscapeHTML: true}, `{"a":12345678901234568,"b":{"url":"https://a?x=1&y=<2>"}}`, true},
+			{"Escaped text kept", map[string]interface{}{"k": `\u003c`}, JSONOptions{NoEscapeHTML: true}, `{"k":"\\u003c"}`, true},
+			{"Empty", nil, JSONOptions{Indent: 4}, "{}", true},
+			{"Invalid indent", secret, JSONOptions{Indent: -1}, "", false},
+		}
+	)
+
+	for _, test := range tests {
+		output, err := ToJSONWith(test.inputs, test.options)
+		success = (err == nil)
+		norm = output
+		if success == test.isSuccess && (!success || norm == test.normOutput) {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		}
+	}
+}
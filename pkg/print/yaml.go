@@ -0,0 +1,426 @@
+package print
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Quoting of strings by ToYamlWith, see YAMLOptions
+const (
+	// QuoteSingle single quotes the strings that need quotes
+	QuoteSingle = "single"
+	// QuoteDouble double quotes the strings that need quotes
+	QuoteDouble = "double"
+	// QuoteAlways double quotes every string
+	QuoteAlways = "always"
+)
+
+// QuoteStyles are the quoting styles ToYamlWith accepts
+var QuoteStyles = []string{QuoteSingle, QuoteDouble, QuoteAlways}
+
+// YAMLOptions control how ToYamlWith writes yaml. Whatever the options,
+// strings any yaml 1.1 or 1.2 reader could take for something else, such as
+// on, no, ~, 0123, 1e3, 1:20 or 2001-12-14, are quoted and keys are sorted,
+// so the output reads back the same with other tooling and is reproducible.
+type YAMLOptions struct {
+	// Indent is the number of spaces per level, 2 when 0
+	Indent int
+	// Quote is one of QuoteStyles, QuoteSingle when empty
+	Quote string
+	// LineWidth folds strings that would make a line longer, as double
+	// quoted strings with escaped line breaks, 0 never folds
+	LineWidth int
+}
+
+// implicit keys of yaml are limited to 1024 characters
+const maxKeyLength = 1024
+
+var (
+	ambiguousWord   = regexp.MustCompile(`(?i)^(y|yes|n|no|true|false|on|off|null|~|[-+]?\.(inf|nan))$`)
+	ambiguousNumber = regexp.MustCompile(`^[-+]?(0b[01_]+|0o?[0-7_]+|0x[0-9a-fA-F_]+|[0-9][0-9_]*(:[0-5]?[0-9])+(\.[0-9_]*)?|(\.[0-9_]+|[0-9][0-9_]*(\.[0-9_]*)?)([eE][-+]?[0-9]+)?)$`)
+	ambiguousDate   = regexp.MustCompile(`^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}`)
+)
+
+// Validate checks the options
+func (o YAMLOptions) Validate() error {
+	if o.Indent < 0 || o.Indent > 9 {
+		return fmt.Errorf("invalid yaml indent %d, expected 2 to 9", o.Indent)
+	}
+	if o.Indent == 1 {
+		return fmt.Errorf("invalid yaml indent 1, expected 2 to 9")
+	}
+	switch o.Quote {
+	case "", QuoteSingle, QuoteDouble, QuoteAlways:
+	default:
+		return fmt.Errorf("invalid yaml quoting %q, expected one of %v", o.Quote, QuoteStyles)
+	}
+	if o.LineWidth < 0 {
+		return fmt.Errorf("invalid yaml line width %d", o.LineWidth)
+	}
+	return nil
+}
+
+// ToYamlWith encodes i, made of the values json decodes into, as yaml
+// written as o asks
+func ToYamlWith(i interface{}, o YAMLOptions) (string, error) {
+	if err := o.Validate(); err != nil {
+		return "", err
+	}
+	if o.Indent == 0 {
+		o.Indent = 2
+	}
+	if o.Quote == "" {
+		o.Quote = QuoteSingle
+	}
+	e := &yamlEncoder{o: o}
+	if err := e.node(i, 0, 0, false); err != nil {
+		return "", err
+	}
+	return e.b.String(), nil
+}
+
+type yamlEncoder struct {
+	o YAMLOptions
+	b strings.Builder
+}
+
+// node writes v at indent, the first line starting at column col, inline
+// when it follows a "- " on the same line
+func (e *yamlEncoder) node(v interface{}, indent, col int, inline bool) error {
+	v = generic(v)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			e.b.WriteString("{}\n")
+			return nil
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			if i > 0 || !inline {
+				e.b.WriteString(strings.Repeat(" ", indent))
+			}
+			key, err := e.key(k)
+			if err != nil {
+				return err
+			}
+			e.b.WriteString(key + ":")
+			if err := e.value(v[k], indent, indent+len(key)+1); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			e.b.WriteString("[]\n")
+			return nil
+		}
+		for i, item := range v {
+			if i > 0 || !inline {
+				e.b.WriteString(strings.Repeat(" ", indent))
+			}
+			e.b.WriteString("-" + strings.Repeat(" ", e.o.Indent-1))
+			if err := e.node(item, indent+e.o.Indent, indent+e.o.Indent, true); err != nil {
+				return err
+			}
+		}
+	default:
+		s, err := e.scalar(v, indent+e.o.Indent, col)
+		if err != nil {
+			return err
+		}
+		e.b.WriteString(s + "\n")
+	}
+	return nil
+}
+
+// value writes the value of a key at indent, after the colon at column col
+func (e *yamlEncoder) value(v interface{}, indent, col int) error {
+	v = generic(v)
+	switch c := v.(type) {
+	case map[string]interface{}:
+		if len(c) > 0 {
+			e.b.WriteString("\n")
+			return e.node(c, indent+e.o.Indent, indent+e.o.Indent, false)
+		}
+	case []interface{}:
+		if len(c) > 0 {
+			e.b.WriteString("\n")
+			return e.node(c, indent+e.o.Indent, indent+e.o.Indent, false)
+		}
+	}
+	e.b.WriteString(" ")
+	return e.node(v, indent, col+1, true)
+}
+
+// key returns a mapping key, always on a single line
+func (e *yamlEncoder) key(k string) (string, error) {
+	if utf8.RuneCountInString(k) > maxKeyLength {
+		return "", fmt.Errorf("key %.40q... is longer than the %d characters of a yaml key", k, maxKeyLength)
+	}
+	if !utf8.ValidString(k) {
+		return "", fmt.Errorf("key %q is not valid UTF-8", k)
+	}
+	if e.o.Quote == QuoteAlways || needsEscapes(k) {
+		return doubleQuoted(k), nil
+	}
+	if needsQuotes(k) {
+		return e.quoted(k), nil
+	}
+	return k, nil
+}
+
+// scalar returns a scalar starting at column col, the lines of block and
+// folded strings are indented by indent
+func (e *yamlEncoder) scalar(v interface{}, indent, col int) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case string:
+		return e.str(v, indent, col)
+	case json.Number:
+		return v.String(), nil
+	case float64:
+		return formatFloat(v, 64), nil
+	case float32:
+		return formatFloat(float64(v), 32), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("can not encode %T as yaml", v)
+}
+
+func (e *yamlEncoder) str(s string, indent, col int) (string, error) {
+	if !utf8.ValidString(s) {
+		return "", fmt.Errorf("string %q is not valid UTF-8", s)
+	}
+	if e.o.Quote != QuoteAlways && strings.Contains(s, "\n") && literalSafe(s) {
+		return literal(s, indent), nil
+	}
+	var out string
+	switch {
+	case e.o.Quote == QuoteAlways || needsEscapes(s):
+		out = doubleQuoted(s)
+	case needsQuotes(s):
+		out = e.quoted(s)
+	default:
+		out = s
+	}
+	if e.o.LineWidth > 0 && col+utf8.RuneCountInString(out) > e.o.LineWidth {
+		return folded(s, indent, col, e.o.LineWidth), nil
+	}
+	return out, nil
+}
+
+// quoted quotes a string that needs no escapes in the configured style
+func (e *yamlEncoder) quoted(s string) string {
+	if e.o.Quote == QuoteSingle {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	return doubleQuoted(s)
+}
+
+// needsQuotes reports whether s can not be written as a plain scalar, or
+// could be read back as something else than a string
+func needsQuotes(s string) bool {
+	if s == "" || ambiguousWord.MatchString(s) || ambiguousNumber.MatchString(s) || ambiguousDate.MatchString(s) {
+		return true
+	}
+	switch s {
+	case "<<", "=":
+		return true
+	}
+	if strings.HasPrefix(s, "---") || strings.HasPrefix(s, "...") {
+		return true
+	}
+	if strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", rune(s[0])) {
+		return true
+	}
+	first, _ := utf8.DecodeRuneInString(s)
+	last, _ := utf8.DecodeLastRuneInString(s)
+	if unicode.IsSpace(first) || unicode.IsSpace(last) || last == ':' {
+		return true
+	}
+	return strings.Contains(s, ": ") || strings.Contains(s, " #")
+}
+
+// needsEscapes reports whether s holds characters only double quoted
+// strings can write
+func needsEscapes(s string) bool {
+	for _, r := range s {
+		if !printable(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// printable reports whether r may appear as is in a yaml scalar
+func printable(r rune) bool {
+	switch r {
+	case '\uFEFF', '\u2028', '\u2029', '\u0085':
+		return false
+	}
+	return unicode.IsPrint(r)
+}
+
+// escapes returns the characters of s as they are written between double
+// quotes
+func escapes(s string) []string {
+	out := make([]string, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\\':
+			out = append(out, `\\`)
+		case r == '"':
+			out = append(out, `\"`)
+		case r == '\n':
+			out = append(out, `\n`)
+		case r == '\t':
+			out = append(out, `\t`)
+		case r == '\r':
+			out = append(out, `\r`)
+		case printable(r):
+			out = append(out, string(r))
+		case r <= 0xff:
+			out = append(out, fmt.Sprintf(`\x%02X`, r))
+		case r <= 0xffff:
+			out = append(out, fmt.Sprintf(`\u%04X`, r))
+		default:
+			out = append(out, fmt.Sprintf(`\U%08X`, r))
+		}
+	}
+	return out
+}
+
+func doubleQuoted(s string) string {
+	return `"` + strings.Join(escapes(s), "") + `"`
+}
+
+// folded double quotes s over several lines of at most width columns, each
+// line break escaped so it is not read as a space. A leading space of a
+// continued line is escaped since it would be dropped.
+func folded(s string, indent, col, width int) string {
+	var b strings.Builder
+	b.WriteString(`"`)
+	used := col + 1
+	start := true
+	for _, esc := range escapes(s) {
+		if !start && used+utf8.RuneCountInString(esc)+1 > width {
+			b.WriteString("\\\n" + strings.Repeat(" ", indent))
+			used = indent
+			start = true
+			if esc == " " {
+				esc = `\ `
+			}
+		}
+		b.WriteString(esc)
+		used += utf8.RuneCountInString(esc)
+		start = false
+	}
+	b.WriteString(`"`)
+	return b.String()
+}
+
+// literalSafe reports whether a string of several lines reads back the same
+// as a literal block
+func literalSafe(s string) bool {
+	for _, r := range s {
+		if r != '\n' && !printable(r) {
+			return false
+		}
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimRight(line, " ") != line {
+			return false
+		}
+	}
+	first, _ := utf8.DecodeRuneInString(s)
+	return first != '\n' && !unicode.IsSpace(first)
+}
+
+// literal writes s as a literal block whose chomping keeps its trailing line
+// breaks exactly
+func literal(s string, indent int) string {
+	content := strings.TrimRight(s, "\n")
+	trailing := len(s) - len(content)
+	header := "|"
+	switch {
+	case trailing == 0:
+		header = "|-"
+	case trailing > 1:
+		header = "|+"
+	}
+	var b strings.Builder
+	b.WriteString(header)
+	for _, line := range strings.Split(content, "\n") {
+		b.WriteString("\n")
+		if line != "" {
+			b.WriteString(strings.Repeat(" ", indent) + line)
+		}
+	}
+	b.WriteString(strings.Repeat("\n", trailing-1+boolToInt(trailing == 0)))
+	return b.String()
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func formatFloat(f float64, bits int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	case math.IsNaN(f):
+		return ".nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bits)
+}
+
+// generic converts maps with string keys and slices of other types into the
+// types json decodes into
+func generic(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, map[string]interface{}, []interface{}:
+		return v
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v
+		}
+		m := make(map[string]interface{}, rv.Len())
+		for _, k := range rv.MapKeys() {
+			m[k.String()] = rv.MapIndex(k).Interface()
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+		l := make([]interface{}, rv.Len())
+		for i := range l {
+			l[i] = rv.Index(i).Interface()
+		}
+		return l
+	}
+	return v
+}
@@ -0,0 +1,128 @@
+package print
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestSuiteToYamlWith(tt *testing.T) {
+	secret := map[string]interface{}{
+		"app": map[string]interface{}{"enabled": "on", "pin": "0123", "port": float64(5432)},
+	}
+	var (
+		norm    string
+		success bool
+		tests   = []struct {
+			description string
+			inputs      interface{}
+			options     YAMLOptions
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Empty", map[string]interface{}{}, YAMLOptions{}, "{}\n", true},
+			{"Defaults", secret, YAMLOptions{}, "app:\n  enabled: 'on'\n  pin: '0123'\n  port: 5432\n", true},
+			{"Indent", secret, YAMLOptions{Indent: 4}, "app:\n    enabled: 'on'\n    pin: '0123'\n    port: 5432\n", true},
+			{"Double quotes", secret, YAMLOptions{Quote: QuoteDouble}, "app:\n  enabled: \"on\"\n  pin: \"0123\"\n  port: 5432\n", true},
+			{"Always quote", secret, YAMLOptions{Quote: QuoteAlways}, "\"app\":\n  \"enabled\": \"on\"\n  \"pin\": \"0123\"\n  \"port\": 5432\n", true},
+			{"Ambiguous key", map[string]interface{}{"on": "yes"}, YAMLOptions{}, "'on': 'yes'\n", true},
+			{"Lists", map[string]interface{}{"l": []interface{}{"a", map[string]interface{}{"b": "c", "d": "e"}, []interface{}{}}}, YAMLOptions{Indent: 4}, "l:\n    -   a\n    -   b: c\n        d: e\n    -   []\n", true},
+			{"Literal", map[string]interface{}{"k": "a\nb\n"}, YAMLOptions{}, "k: |\n  a\n  b\n", true},
+			{"Literal without newline", map[string]interface{}{"k": "a\n\nb"}, YAMLOptions{}, "k: |-\n  a\n\n  b\n", true},
+			{"Escapes", map[string]interface{}{"k": "a\tb"}, YAMLOptions{}, "k: \"a\\tb\"\n", true},
+			{"Fold", map[string]interface{}{"k": "abcdefghij klmn"}, YAMLOptions{LineWidth: 9}, "k: \"abcd\\\n  efghij\\\n  \\ klmn\"\n", true},
+			{"Short lines not folded", map[string]interface{}{"k": "abc"}, YAMLOptions{LineWidth: 10}, "k: abc\n", true},
+			{"Invalid indent", secret, YAMLOptions{Indent: 1}, "", false},
+			{"Invalid quote", secret, YAMLOptions{Quote: "backtick"}, "", false},
+			{"Invalid UTF-8", map[string]interface{}{"k": "\xff"}, YAMLOptions{}, "", false},
+			{"Unsupported", map[string]interface{}{"k": struct{}{}}, YAMLOptions{}, "", false},
+		}
+	)
+
+	for _, test := range tests {
+		output, err := ToYamlWith(test.inputs, test.options)
+		success = (err == nil)
+		norm = output
+		if success == test.isSuccess && (!success || norm == test.normOutput) {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		}
+	}
+}
+
+func TestSuiteToYamlWithRoundTrip(tt *testing.T) {
+	values := []interface{}{
+		"", "plain", "on", "Off", "YES", "n", "~", "null", "true", "0123", "0o17", "0x1F", "1_000", "1e3", ".5",
+		"-.inf", ".NaN", "1:20", "2001-12-14", "<<", "=", "---", "...", "- item", "? key", "key: value",
+		"value #comment", "trailing:", " leading", "trailing ", "it's", `say "hi"`, `back\slash`, "tab\there",
+		"\x00\x1b", "line\nbreaks\n", "keep\n\n", "\n leading break", "  indented\nlines", "trailing \nspace",
+		"crlf\r\n", "naïve ünïcode", "\u00a0nbsp", "\ufeffbom", "line\u2028separator", strings.Repeat("long words ", 20),
+		strings.Repeat("x", 100), float64(5432), 1.5, float64(-0.001), true, false, nil,
+		map[string]interface{}{}, []interface{}{},
+	}
+	data := map[string]interface{}{}
+	for i, v := range values {
+		data[fmt.Sprintf("key %d", i)] = v
+		if s, ok := v.(string); ok {
+			data[s] = i
+		}
+	}
+	data["nested"] = map[string]interface{}{"list": values, "map": map[string]interface{}{"on": values}}
+
+	options := []YAMLOptions{
+		{}, {Indent: 4}, {Indent: 3, Quote: QuoteDouble}, {Quote: QuoteAlways},
+		{LineWidth: 20}, {Indent: 9, Quote: QuoteSingle, LineWidth: 1}, {Quote: QuoteAlways, LineWidth: 40},
+	}
+	want := normalize(data)
+	for _, o := range options {
+		description := fmt.Sprintf("Round trip %+v", o)
+		output, err := ToYamlWith(data, o)
+		if err != nil {
+			tt.Errorf("FAIL %s: %v", description, err)
+			continue
+		}
+		var got interface{}
+		if err := yaml.Unmarshal([]byte(output), &got); err != nil {
+			tt.Errorf("FAIL %s: %v\n%s", description, err, output)
+			continue
+		}
+		if got = normalize(got); !reflect.DeepEqual(got, want) {
+			tt.Errorf("FAIL %s: expected '%v' got '%v'", description, want, got)
+		} else {
+			tt.Logf("PASS %s", description)
+		}
+	}
+}
+
+// normalize converts what yaml and json decode into the same types
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, e := range v {
+			m[fmt.Sprint(k)] = normalize(e)
+		}
+		return m
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, e := range v {
+			m[k] = normalize(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = normalize(e)
+		}
+		return l
+	case int:
+		return float64(v)
+	}
+	return v
+}
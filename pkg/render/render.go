@@ -0,0 +1,138 @@
+package render
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// Renderer renders Go templates with the secrets of a dump, keyed by path
+type Renderer struct {
+	secrets map[string]interface{}
+}
+
+// New returns a Renderer for the secrets of a dump
+func New(secrets map[string]interface{}) *Renderer {
+	return &Renderer{secrets: secrets}
+}
+
+// Parse parses a template with the functions of the renderer. Missing map
+// keys are errors, so a template never renders a config with an empty
+// secret.
+func (r *Renderer) Parse(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Funcs(r.Funcs()).Parse(text)
+}
+
+// Render parses and executes a template, the dump is its context
+func (r *Renderer) Render(w io.Writer, name, text string) error {
+	t, err := r.Parse(name, text)
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, r.secrets)
+}
+
+// Funcs returns the template functions:
+//
+//	secret "path"         the fields of a secret, it fails when there is none
+//	secrets "path"        the sorted paths of the secrets at or below a path
+//	toJSON value          value as JSON
+//	base64Encode string   string encoded as standard base64
+//	base64Decode string   string decoded from standard base64
+//	join list sep         the strings of list joined by sep
+//	split string sep      string split around sep
+//
+// Paths can be given with or without the data/ of KV version 2 mounts,
+// secret/app and secret/data/app find the same secret.
+func (r *Renderer) Funcs() template.FuncMap {
+	return template.FuncMap{
+		"secret":       r.secret,
+		"secrets":      r.list,
+		"toJSON":       toJSON,
+		"base64Encode": base64Encode,
+		"base64Decode": base64Decode,
+		"join":         join,
+		"split":        strings.Split,
+	}
+}
+
+func (r *Renderer) secret(path string) (map[string]interface{}, error) {
+	for _, p := range candidates(path) {
+		if s, ok := r.secrets[p]; ok {
+			fields, ok := s.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s is not a secret of fields", p)
+			}
+			return fields, nil
+		}
+	}
+	return nil, fmt.Errorf("no secret at %s in the dump", path)
+}
+
+func (r *Renderer) list(path string) []string {
+	for _, prefix := range candidates(path) {
+		paths := []string{}
+		for p := range r.secrets {
+			if p == prefix || strings.HasPrefix(p, prefix+"/") {
+				paths = append(paths, p)
+			}
+		}
+		if len(paths) > 0 {
+			sort.Strings(paths)
+			return paths
+		}
+	}
+	return []string{}
+}
+
+// candidates returns the normalized path followed by the path with a data
+// segment added or removed at each position, the forms a path can take
+// depending on the KV version of its mount
+func candidates(path string) []string {
+	path = vault.NormalizePath(path)
+	paths := []string{path}
+	segments := strings.Split(path, "/")
+	for i := 1; i < len(segments); i++ {
+		with := append(append(append([]string{}, segments[:i]...), "data"), segments[i:]...)
+		paths = append(paths, strings.Join(with, "/"))
+		if segments[i] == "data" {
+			without := append(append([]string{}, segments[:i]...), segments[i+1:]...)
+			paths = append(paths, strings.Join(without, "/"))
+		}
+	}
+	return paths
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func base64Encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func base64Decode(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	return string(b), err
+}
+
+func join(list interface{}, sep string) (string, error) {
+	switch l := list.(type) {
+	case []string:
+		return strings.Join(l, sep), nil
+	case []interface{}:
+		s := make([]string, len(l))
+		for i, v := range l {
+			s[i] = fmt.Sprint(v)
+		}
+		return strings.Join(s, sep), nil
+	}
+	return "", fmt.Errorf("join needs a list, got %T", list)
+}
@@ -0,0 +1,52 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+var secrets = map[string]interface{}{
+	"secret/data/app/db":  map[string]interface{}{"user": "app", "password": "p@ss", "port": float64(5432)},
+	"secret/data/app/api": map[string]interface{}{"token": "t", "hosts": []interface{}{"a", "b"}},
+	"kv/legacy":           map[string]interface{}{"key": "v1"},
+}
+
+func TestSuiteRender(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			template    string
+			expected    string
+			fail        bool
+		}{
+			{"KV v2 path without data", `{{ with secret "secret/app/db" }}{{ .user }}:{{ .password }}@db:{{ .port }}{{ end }}`, "app:p@ss@db:5432", false},
+			{"KV v2 path with data", `{{ (secret "secret/data/app/db").user }}`, "app", false},
+			{"KV v1 path", `{{ (secret "/kv/legacy/").key }}`, "v1", false},
+			{"KV v1 path with data", `{{ (secret "kv/data/legacy").key }}`, "v1", false},
+			{"List secrets", `{{ range secrets "secret/app" }}{{ . }} {{ end }}`, "secret/data/app/api secret/data/app/db ", false},
+			{"Dump as context", `{{ index . "kv/legacy" "key" }}`, "v1", false},
+			{"Functions", `{{ join (secret "secret/app/api").hosts "," }} {{ base64Encode "x" }} {{ base64Decode "eA==" }} {{ toJSON (secret "kv/legacy") }}`, `a,b eA== x {"key":"v1"}`, false},
+			{"Missing secret", `{{ (secret "secret/app/missing").user }}`, "", true},
+			{"Missing field", `{{ (secret "secret/app/db").passwd }}`, "", true},
+			{"Invalid template", `{{ secret`, "", true},
+		}
+	)
+	r := New(secrets)
+	for _, test := range tests {
+		var b strings.Builder
+		err := r.Render(&b, test.description, test.template)
+		if test.fail {
+			if err == nil {
+				tt.Errorf("FAIL %s: expected an error got %q", test.description, b.String())
+			} else {
+				tt.Logf("PASS %s", test.description)
+			}
+			continue
+		}
+		if err != nil || b.String() != test.expected {
+			tt.Errorf("FAIL %s: expected %q got %q, %v", test.description, test.expected, b.String(), err)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
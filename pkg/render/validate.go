@@ -0,0 +1,298 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/parser"
+	"gopkg.in/yaml.v2"
+)
+
+// Formats of rendered files Validate checks
+const (
+	// FormatK8s is Kubernetes manifests, one or more yaml documents
+	FormatK8s = "k8s"
+	// FormatHelm is helm values, a yaml mapping
+	FormatHelm = "helm"
+	// FormatTerraform is terraform variables or other HCL, parsed as HCL 1
+	FormatTerraform = "terraform"
+)
+
+// Formats are the formats Validate accepts
+var Formats = []string{FormatK8s, FormatHelm, FormatTerraform}
+
+var (
+	kindLine     = regexp.MustCompile(`(?m)^kind:`)
+	dnsSubdomain = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	dnsLabel     = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	configKey    = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+)
+
+// Problem points to what is invalid in a rendered file
+type Problem struct {
+	// Path locates the problem, a field path such as [0].data.password in
+	// yaml, where [0] is the document, or a line and column in HCL
+	Path   string
+	Reason string
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return p.Reason
+	}
+	return p.Path + ": " + p.Reason
+}
+
+// FormatOf returns the format of a rendered file by its name, without the
+// extension of a template, yaml files with a top level kind are manifests.
+// Files of other formats are not validated and return "".
+func FormatOf(name string, data []byte) string {
+	for _, ext := range []string{".tmpl", ".tpl", ".gotmpl"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml":
+		if kindLine.Match(data) {
+			return FormatK8s
+		}
+		return FormatHelm
+	case ".tfvars", ".hcl":
+		return FormatTerraform
+	}
+	return ""
+}
+
+// Validate checks a rendered file of one of Formats and returns its
+// problems, none when it is valid
+func Validate(format string, data []byte) ([]Problem, error) {
+	switch format {
+	case FormatK8s:
+		docs, problem := decodeYAML(data)
+		if problem != nil {
+			return []Problem{*problem}, nil
+		}
+		problems := []Problem{}
+		for i, doc := range docs {
+			if doc != nil {
+				problems = append(problems, validateManifest(fmt.Sprintf("[%d]", i), doc)...)
+			}
+		}
+		return problems, nil
+	case FormatHelm:
+		docs, problem := decodeYAML(data)
+		if problem != nil {
+			return []Problem{*problem}, nil
+		}
+		if len(docs) > 1 {
+			return []Problem{{Reason: "helm values must be a single yaml document"}}, nil
+		}
+		if len(docs) == 1 && docs[0] != nil {
+			if _, ok := docs[0].(map[string]interface{}); !ok {
+				return []Problem{{Reason: "helm values must be a mapping"}}, nil
+			}
+		}
+		return []Problem{}, nil
+	case FormatTerraform:
+		if _, err := hcl.ParseBytes(data); err != nil {
+			var pe *parser.PosError
+			if errors.As(err, &pe) {
+				return []Problem{{Path: fmt.Sprintf("line %d, column %d", pe.Pos.Line, pe.Pos.Column), Reason: pe.Err.Error()}}, nil
+			}
+			return []Problem{{Reason: err.Error()}}, nil
+		}
+		return []Problem{}, nil
+	}
+	return nil, fmt.Errorf("invalid format %q, expected one of %v", format, Formats)
+}
+
+// decodeYAML decodes every document of data, duplicate keys are refused
+func decodeYAML(data []byte) ([]interface{}, *Problem) {
+	docs := []interface{}{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.SetStrict(true)
+	for {
+		var doc interface{}
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, &Problem{Path: fmt.Sprintf("[%d]", len(docs)), Reason: err.Error()}
+		}
+		docs = append(docs, stringKeys(doc))
+	}
+}
+
+// stringKeys converts the mappings yaml decodes into the maps json does
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, vv := range v {
+			m[fmt.Sprint(k)] = stringKeys(vv)
+		}
+		return m
+	case []interface{}:
+		for i, vv := range v {
+			v[i] = stringKeys(vv)
+		}
+	}
+	return v
+}
+
+// field checks the value of a field of a manifest
+type field func(path string, v interface{}) []Problem
+
+// schemas are the fields of the kinds vault-dump secrets are rendered into,
+// by kind, the fields of other kinds are not checked
+var schemas = map[string]map[string]field{
+	"Secret": {
+		"data":       keysOf(base64Value),
+		"stringData": keysOf(stringValue),
+		"type":       stringValue,
+		"immutable":  boolValue,
+	},
+	"ConfigMap": {
+		"data":       keysOf(stringValue),
+		"binaryData": keysOf(base64Value),
+		"immutable":  boolValue,
+	},
+}
+
+// validateManifest checks the fields every object has, and the fields of the
+// kinds in schemas
+func validateManifest(path string, doc interface{}) []Problem {
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return []Problem{{Path: path, Reason: "a manifest must be a mapping"}}
+	}
+	problems := []Problem{}
+	for _, f := range []string{"apiVersion", "kind"} {
+		if s, ok := m[f].(string); !ok || s == "" {
+			problems = append(problems, Problem{Path: path + "." + f, Reason: "required string"})
+		}
+	}
+	problems = append(problems, validateMetadata(path+".metadata", m["metadata"])...)
+
+	kind, _ := m["kind"].(string)
+	schema, ok := schemas[kind]
+	if !ok {
+		return problems
+	}
+	if m["apiVersion"] != "v1" {
+		problems = append(problems, Problem{Path: path + ".apiVersion", Reason: fmt.Sprintf("%s must be v1, got %v", kind, m["apiVersion"])})
+	}
+	for _, k := range sortedKeys(m) {
+		switch k {
+		case "apiVersion", "kind", "metadata":
+			continue
+		}
+		check, ok := schema[k]
+		if !ok {
+			problems = append(problems, Problem{Path: path + "." + k, Reason: "unknown field of " + kind})
+			continue
+		}
+		problems = append(problems, check(path+"."+k, m[k])...)
+	}
+	return problems
+}
+
+func validateMetadata(path string, v interface{}) []Problem {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return []Problem{{Path: path, Reason: "required mapping"}}
+	}
+	problems := []Problem{}
+	name, _ := m["name"].(string)
+	switch {
+	case name == "":
+		problems = append(problems, Problem{Path: path + ".name", Reason: "required string"})
+	case len(name) > 253 || !dnsSubdomain.MatchString(name):
+		problems = append(problems, Problem{Path: path + ".name", Reason: fmt.Sprintf("%q is not a lowercase DNS subdomain", name)})
+	}
+	if ns, ok := m["namespace"]; ok {
+		if s, _ := ns.(string); len(s) > 63 || !dnsLabel.MatchString(s) {
+			problems = append(problems, Problem{Path: path + ".namespace", Reason: fmt.Sprintf("%v is not a lowercase DNS label", ns)})
+		}
+	}
+	for _, f := range []string{"labels", "annotations"} {
+		if values, ok := m[f]; ok {
+			problems = append(problems, mapOf(stringValue)(path+"."+f, values)...)
+		}
+	}
+	return problems
+}
+
+// keysOf checks a mapping of configuration keys to values checked by value
+func keysOf(value field) field {
+	return func(path string, v interface{}) []Problem {
+		problems := mapOf(value)(path, v)
+		m, _ := v.(map[string]interface{})
+		for _, k := range sortedKeys(m) {
+			if len(k) > 253 || !configKey.MatchString(k) {
+				problems = append(problems, Problem{Path: path + "." + k, Reason: "keys may only hold letters, digits, '-', '_' and '.'"})
+			}
+		}
+		return problems
+	}
+}
+
+// mapOf checks a mapping of values checked by value
+func mapOf(value field) field {
+	return func(path string, v interface{}) []Problem {
+		if v == nil {
+			return nil
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return []Problem{{Path: path, Reason: "must be a mapping"}}
+		}
+		problems := []Problem{}
+		for _, k := range sortedKeys(m) {
+			problems = append(problems, value(path+"."+k, m[k])...)
+		}
+		return problems
+	}
+}
+
+func stringValue(path string, v interface{}) []Problem {
+	if _, ok := v.(string); !ok {
+		return []Problem{{Path: path, Reason: fmt.Sprintf("must be a string, got %T, quote it", v)}}
+	}
+	return nil
+}
+
+func base64Value(path string, v interface{}) []Problem {
+	s, ok := v.(string)
+	if !ok {
+		return stringValue(path, v)
+	}
+	if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+		return []Problem{{Path: path, Reason: "must be standard base64, use base64Encode"}}
+	}
+	return nil
+}
+
+func boolValue(path string, v interface{}) []Problem {
+	if _, ok := v.(bool); !ok {
+		return []Problem{{Path: path, Reason: fmt.Sprintf("must be a boolean, got %T", v)}}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,78 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSuiteValidate(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			format      string
+			input       string
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Valid secret", FormatK8s, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: app-db\n  namespace: prod\ndata:\n  PASSWORD: cEBzcw==\nstringData:\n  USER: app\ntype: Opaque\n", "", true},
+			{"Several documents", FormatK8s, "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\ndata:\n  k: v\n---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: b\nspec: {}\n", "", true},
+			{"Not base64", FormatK8s, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: app\ndata:\n  PASSWORD: p@ss\n", "[0].data.PASSWORD: must be standard base64, use base64Encode", true},
+			{"Pointer to document", FormatK8s, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\ndata:\n  port: 5432\n", "[1].data.port: must be a string, got int, quote it", true},
+			{"Unknown field", FormatK8s, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: app\nstring_data:\n  k: v\n", "[0].string_data: unknown field of Secret", true},
+			{"Invalid key", FormatK8s, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: app\nstringData:\n  db/password: v\n", "[0].stringData.db/password: keys may only hold letters, digits, '-', '_' and '.'", true},
+			{"Invalid name", FormatK8s, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: App_DB\n", "[0].metadata.name: \"App_DB\" is not a lowercase DNS subdomain", true},
+			{"Missing fields", FormatK8s, "metadata:\n  labels:\n    tier: 1\n", "[0].apiVersion: required string; [0].kind: required string; [0].metadata.name: required string; [0].metadata.labels.tier: must be a string, got int, quote it", true},
+			{"Wrong version", FormatK8s, "apiVersion: v2\nkind: Secret\nmetadata:\n  name: app\n", "[0].apiVersion: Secret must be v1, got v2", true},
+			{"Duplicate key", FormatK8s, "apiVersion: v1\nkind: Secret\nkind: Secret\n", "[0]: yaml: unmarshal errors:\n  line 3: key \"kind\" already set in map", true},
+			{"Helm values", FormatHelm, "db:\n  password: p@ss\n", "", true},
+			{"Empty helm values", FormatHelm, "", "", true},
+			{"Helm values not a mapping", FormatHelm, "- a\n", "helm values must be a mapping", true},
+			{"Helm syntax error", FormatHelm, "db:\n  password: [p@ss\n", "[0]: yaml: line 2: did not find expected ',' or ']'", true},
+			{"Terraform variables", FormatTerraform, "db_password = \"p@ss\"\ntags = {\n  team = \"a\"\n}\n", "", true},
+			{"Terraform syntax error", FormatTerraform, "db_password = \"p@ss\ndb_user = \"app\"\n", "line 1, column 20: literal not terminated", true},
+			{"Invalid format", "random", "", "", false},
+		}
+	)
+	for _, test := range tests {
+		problems, err := Validate(test.format, []byte(test.input))
+		success := err == nil
+		parts := []string{}
+		for _, p := range problems {
+			parts = append(parts, p.String())
+		}
+		norm := strings.Join(parts, "; ")
+
+		if success == test.isSuccess && (!success || norm == test.normOutput) {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		}
+	}
+}
+
+func TestSuiteFormatOf(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			name        string
+			input       string
+			normOutput  string
+		}{
+			{"Manifest", "secret.yaml", "apiVersion: v1\nkind: Secret\n", FormatK8s},
+			{"Manifest template", "secret.yml.tmpl", "kind: Secret\n", FormatK8s},
+			{"Helm values", "values.yaml", "db:\n  kind: postgres\n", FormatHelm},
+			{"Terraform variables", "prod.tfvars.tpl", "", FormatTerraform},
+			{"HCL", "agent.hcl", "", FormatTerraform},
+			{"Other", "app.env", "", ""},
+		}
+	)
+	for _, test := range tests {
+		if norm := FormatOf(test.name, []byte(test.input)); norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
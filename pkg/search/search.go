@@ -0,0 +1,137 @@
+package search
+
+// search finds secrets in a dump by the regular expressions their paths,
+// field names and values match, or by an index of a dump holding only its
+// paths and field names, so large or encrypted dumps need not be read whole.
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// IndexVersion is the version of the index format
+const IndexVersion = 1
+
+// Query matches secrets, every expression set must match. Without Key and
+// Value whole secrets match, otherwise each matching field is a match.
+type Query struct {
+	Path  *regexp.Regexp
+	Key   *regexp.Regexp
+	Value *regexp.Regexp
+}
+
+// Match is a secret, or a field of it, matching a Query. Value is only set
+// when the query matched values of a dump.
+type Match struct {
+	Path  string
+	Key   string
+	Value interface{}
+}
+
+// Index lists the field names of each secret of a dump, written next to the
+// dump
+type Index struct {
+	Version int                 `json:"version"`
+	Created string              `json:"created,omitempty"`
+	Secrets map[string][]string `json:"secrets"`
+}
+
+// NewIndex returns the index of the secrets of a dump, created is the
+// creation time of the dump
+func NewIndex(secrets map[string]interface{}, created string) Index {
+	idx := Index{Version: IndexVersion, Created: created, Secrets: make(map[string][]string, len(secrets))}
+	for p, secret := range secrets {
+		values, _ := secret.(map[string]interface{})
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		idx.Secrets[p] = keys
+	}
+	return idx
+}
+
+// ParseIndex parses an index written by NewIndex
+func ParseIndex(data []byte) (Index, error) {
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return Index{}, fmt.Errorf("invalid index: %w", err)
+	}
+	if idx.Version != IndexVersion {
+		return Index{}, fmt.Errorf("unsupported index version %d", idx.Version)
+	}
+	return idx, nil
+}
+
+// Search returns the matches of the secrets of a dump, read back as import
+// reads them, sorted by path and key
+func Search(secrets map[string]interface{}, q Query) []Match {
+	matches := []Match{}
+	for p, secret := range secrets {
+		if q.Path != nil && !q.Path.MatchString(p) {
+			continue
+		}
+		values, _ := secret.(map[string]interface{})
+		if q.Key == nil && q.Value == nil {
+			matches = append(matches, Match{Path: p})
+			continue
+		}
+		for k, v := range values {
+			if q.Key != nil && !q.Key.MatchString(k) {
+				continue
+			}
+			if q.Value != nil && !q.Value.MatchString(valueString(v)) {
+				continue
+			}
+			matches = append(matches, Match{Path: p, Key: k, Value: v})
+		}
+	}
+	sortMatches(matches)
+	return matches
+}
+
+// Search returns the matches of the indexed dump without values, queries on
+// values need the dump itself
+func (idx Index) Search(q Query) ([]Match, error) {
+	if q.Value != nil {
+		return nil, fmt.Errorf("an index holds no values to match")
+	}
+	matches := []Match{}
+	for p, keys := range idx.Secrets {
+		if q.Path != nil && !q.Path.MatchString(p) {
+			continue
+		}
+		if q.Key == nil {
+			matches = append(matches, Match{Path: p})
+			continue
+		}
+		for _, k := range keys {
+			if q.Key.MatchString(k) {
+				matches = append(matches, Match{Path: p, Key: k})
+			}
+		}
+	}
+	sortMatches(matches)
+	return matches, nil
+}
+
+// valueString returns strings as they are and other values as JSON
+func valueString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func sortMatches(matches []Match) {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Path != matches[j].Path {
+			return matches[i].Path < matches[j].Path
+		}
+		return matches[i].Key < matches[j].Key
+	})
+}
@@ -0,0 +1,102 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSuiteSearch(tt *testing.T) {
+	secrets := map[string]interface{}{
+		"secret/prod/db":  map[string]interface{}{"password": "hunter2", "port": float64(5432)},
+		"secret/prod/api": map[string]interface{}{"token": "abc", "url": "https://api.example.com"},
+		"secret/dev/db":   map[string]interface{}{"password": "dev"},
+	}
+	data, err := json.Marshal(NewIndex(secrets, "2026-01-01T00:00:00Z"))
+	if err != nil {
+		tt.Fatal(err)
+	}
+	idx, err := ParseIndex(data)
+	if err != nil {
+		tt.Fatal(err)
+	}
+
+	re := func(expr string) *regexp.Regexp {
+		if expr == "" {
+			return nil
+		}
+		return regexp.MustCompile(expr)
+	}
+	var (
+		norm    string
+		success bool
+		tests   = []struct {
+			description string
+			path        string
+			key         string
+			value       string
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Path", "^secret/prod/", "", "", "secret/prod/api secret/prod/db", true},
+			{"Key", "", "^pass", "", "secret/dev/db:password secret/prod/db:password", true},
+			{"Path and key", "prod", "password", "", "secret/prod/db:password", true},
+			{"Value", "", "", "^hunter", "secret/prod/db:password=hunter2", true},
+			{"Non-string value", "", "", "^5432$", "secret/prod/db:port=5432", true},
+			{"Key and value", "", "url", "example", "secret/prod/api:url=https://api.example.com", true},
+			{"No match", "staging", "", "", "", true},
+			{"Invalid key", "", "(", "", "", false},
+		}
+	)
+	for _, test := range tests {
+		q := Query{Path: re(test.path), Value: re(test.value)}
+		q.Key, err = regexp.Compile(test.key)
+		if test.key == "" {
+			q.Key = nil
+		}
+		success = (err == nil)
+		norm = ""
+		if success {
+			found := Search(secrets, q)
+			indexed := []Match{}
+			if q.Value == nil {
+				indexed, _ = idx.Search(q)
+			}
+			parts := []string{}
+			for i, m := range found {
+				s := m.Path
+				if m.Key != "" {
+					s += ":" + m.Key
+				}
+				if q.Value != nil {
+					s += fmt.Sprintf("=%v", m.Value)
+				} else if i >= len(indexed) || indexed[i].Path != m.Path || indexed[i].Key != m.Key {
+					s += "(not indexed)"
+				}
+				parts = append(parts, s)
+			}
+			norm = strings.Join(parts, " ")
+		}
+
+		if success == test.isSuccess && (!success || norm == test.normOutput) {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		}
+	}
+
+	if _, err := idx.Search(Query{Value: re("x")}); err == nil {
+		tt.Errorf("FAIL Index.Search: expected a query on values to fail")
+	} else {
+		tt.Logf("PASS Index.Search")
+	}
+	if _, err := ParseIndex([]byte(`{"version": 2, "secrets": {}}`)); err == nil {
+		tt.Errorf("FAIL ParseIndex: expected an unsupported version to fail")
+	} else {
+		tt.Logf("PASS ParseIndex")
+	}
+}
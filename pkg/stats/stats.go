@@ -0,0 +1,213 @@
+package stats
+
+// stats summarizes the KV tree of Vault for capacity planning without
+// revealing it: counts, sizes and histograms by mount, never paths below the
+// mount, field names or values.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Formats of Write
+const (
+	Prometheus = "prometheus"
+	JSON       = "json"
+)
+
+// Formats are the formats Write accepts
+var Formats = []string{Prometheus, JSON}
+
+// Bounds of the histograms, the upper bounds of their buckets
+var (
+	DepthBounds  = []float64{1, 2, 3, 4, 5, 6, 8, 10, 15}
+	FieldsBounds = []float64{1, 2, 5, 10, 20, 50, 100}
+	BytesBounds  = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576}
+	AgeBounds    = []float64{
+		float64(24 * time.Hour / time.Second),
+		float64(7 * 24 * time.Hour / time.Second),
+		float64(30 * 24 * time.Hour / time.Second),
+		float64(90 * 24 * time.Hour / time.Second),
+		float64(180 * 24 * time.Hour / time.Second),
+		float64(365 * 24 * time.Hour / time.Second),
+		float64(730 * 24 * time.Hour / time.Second),
+	}
+)
+
+// Histogram counts observations by bucket, Counts has one more bucket than
+// Bounds for observations above the last bound
+type Histogram struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []int     `json:"counts"`
+	Sum    float64   `json:"sum"`
+	Count  int       `json:"count"`
+}
+
+// NewHistogram returns an empty histogram with the upper bounds of its
+// buckets, in increasing order
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{Bounds: bounds, Counts: make([]int, len(bounds)+1)}
+}
+
+// Observe adds a value to the histogram
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.Bounds, v)
+	h.Counts[i]++
+	h.Sum += v
+	h.Count++
+}
+
+// Mount holds the statistics of the secrets of a mount. Depth is the number
+// of path segments below the mount, Bytes the size of secrets as JSON and
+// Age the time since the current version was written, known for KV version
+// 2 secrets only.
+type Mount struct {
+	Secrets int        `json:"secrets"`
+	Fields  int        `json:"fields"`
+	Bytes   int        `json:"bytes"`
+	Failed  int        `json:"failed"`
+	Depth   *Histogram `json:"depth"`
+	Size    *Histogram `json:"size"`
+	Width   *Histogram `json:"fields_per_secret"`
+	Age     *Histogram `json:"age_seconds"`
+}
+
+// Stats holds the statistics of each mount
+type Stats struct {
+	Created time.Time         `json:"created"`
+	Mounts  map[string]*Mount `json:"mounts"`
+}
+
+// New returns empty statistics created at now, ages are computed from now
+func New(now time.Time) *Stats {
+	return &Stats{Created: now.UTC(), Mounts: make(map[string]*Mount)}
+}
+
+func (s *Stats) mount(name string) *Mount {
+	m, ok := s.Mounts[name]
+	if !ok {
+		m = &Mount{
+			Depth: NewHistogram(DepthBounds),
+			Size:  NewHistogram(BytesBounds),
+			Width: NewHistogram(FieldsBounds),
+			Age:   NewHistogram(AgeBounds),
+		}
+		s.Mounts[name] = m
+	}
+	return m
+}
+
+// Add counts a secret read from path, a path as read from Vault. kv2 strips
+// the data prefix of KV version 2 paths, updated is when the secret was
+// written or the zero time when unknown.
+func (s *Stats) Add(path string, secret interface{}, kv2 bool, updated time.Time) {
+	name, rest := splitMount(path, kv2)
+	m := s.mount(name)
+
+	size := 0
+	if b, err := json.Marshal(secret); err == nil {
+		size = len(b)
+	}
+	fields := 0
+	if values, ok := secret.(map[string]interface{}); ok {
+		fields = len(values)
+	}
+	m.Secrets++
+	m.Fields += fields
+	m.Bytes += size
+	m.Depth.Observe(float64(len(strings.Split(rest, "/"))))
+	m.Size.Observe(float64(size))
+	m.Width.Observe(float64(fields))
+	if !updated.IsZero() {
+		age := s.Created.Sub(updated)
+		if age < 0 {
+			age = 0
+		}
+		m.Age.Observe(age.Seconds())
+	}
+}
+
+// Fail counts a secret of path that could not be read
+func (s *Stats) Fail(path string) {
+	name, _ := splitMount(path, false)
+	s.mount(name).Failed++
+}
+
+// splitMount returns the mount of a path and the path below it
+func splitMount(path string, kv2 bool) (string, string) {
+	parts := strings.SplitN(strings.Trim(path, "/"), "/", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	rest := parts[1]
+	if kv2 && strings.HasPrefix(rest, "data/") {
+		rest = strings.TrimPrefix(rest, "data/")
+	}
+	return parts[0], rest
+}
+
+// Write writes the statistics in format, one of Formats
+func (s *Stats) Write(w io.Writer, format string) error {
+	switch format {
+	case Prometheus:
+		return s.writePrometheus(w)
+	case JSON:
+		b, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	}
+	return fmt.Errorf("invalid format %q, expected one of %v", format, Formats)
+}
+
+// writePrometheus writes the statistics in the text exposition format, for
+// the textfile collector of the node exporter
+func (s *Stats) writePrometheus(w io.Writer) error {
+	names := make([]string, 0, len(s.Mounts))
+	for name := range s.Mounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	gauge := func(metric, help string, value func(*Mount) int) {
+		fmt.Fprintf(&b, "# HELP vault_dump_%s %s\n# TYPE vault_dump_%s gauge\n", metric, help, metric)
+		for _, name := range names {
+			fmt.Fprintf(&b, "vault_dump_%s{mount=%q} %d\n", metric, name, value(s.Mounts[name]))
+		}
+	}
+	histogram := func(metric, help string, h func(*Mount) *Histogram) {
+		fmt.Fprintf(&b, "# HELP vault_dump_%s %s\n# TYPE vault_dump_%s histogram\n", metric, help, metric)
+		for _, name := range names {
+			hist := h(s.Mounts[name])
+			cumulative := 0
+			for i, bound := range hist.Bounds {
+				cumulative += hist.Counts[i]
+				fmt.Fprintf(&b, "vault_dump_%s_bucket{mount=%q,le=\"%g\"} %d\n", metric, name, bound, cumulative)
+			}
+			fmt.Fprintf(&b, "vault_dump_%s_bucket{mount=%q,le=\"+Inf\"} %d\n", metric, name, hist.Count)
+			fmt.Fprintf(&b, "vault_dump_%s_sum{mount=%q} %g\n", metric, name, hist.Sum)
+			fmt.Fprintf(&b, "vault_dump_%s_count{mount=%q} %d\n", metric, name, hist.Count)
+		}
+	}
+
+	gauge("secrets", "Secrets read below the mount.", func(m *Mount) int { return m.Secrets })
+	gauge("fields", "Fields of the secrets below the mount.", func(m *Mount) int { return m.Fields })
+	gauge("bytes", "Size of the secrets below the mount as JSON.", func(m *Mount) int { return m.Bytes })
+	gauge("failed", "Secrets below the mount that could not be read.", func(m *Mount) int { return m.Failed })
+	histogram("secret_depth", "Path segments of secrets below the mount.", func(m *Mount) *Histogram { return m.Depth })
+	histogram("secret_bytes", "Size of secrets as JSON.", func(m *Mount) *Histogram { return m.Size })
+	histogram("secret_fields", "Fields per secret.", func(m *Mount) *Histogram { return m.Width })
+	histogram("secret_age_seconds", "Time since the current version of KV version 2 secrets was written.", func(m *Mount) *Histogram { return m.Age })
+	fmt.Fprintf(&b, "# HELP vault_dump_stats_timestamp_seconds When the statistics were collected.\n# TYPE vault_dump_stats_timestamp_seconds gauge\n")
+	fmt.Fprintf(&b, "vault_dump_stats_timestamp_seconds %d\n", s.Created.Unix())
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
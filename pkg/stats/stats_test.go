@@ -0,0 +1,102 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSuiteStats(tt *testing.T) {
+	now := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	s := New(now)
+	s.Add("secret/data/team/db", map[string]interface{}{"password": "hunter2"}, true, now.Add(-2*24*time.Hour))
+	s.Add("secret/data/team/a/b/api", map[string]interface{}{"token": "abc", "url": "https://example.com"}, true, now.Add(-400*24*time.Hour))
+	s.Add("kv/app", map[string]interface{}{"k": "v"}, false, time.Time{})
+	s.Fail("secret/data/team/denied")
+
+	secret, kv := s.Mounts["secret"], s.Mounts["kv"]
+	var (
+		tests = []struct {
+			description string
+			norm        func() string
+			normOutput  string
+		}{
+			{"KV v2 counts", func() string {
+				return "secrets=" + itoa(secret.Secrets) + " fields=" + itoa(secret.Fields) + " failed=" + itoa(secret.Failed)
+			}, "secrets=2 fields=3 failed=1"},
+			{"KV v2 depth", func() string {
+				return ints(secret.Depth.Counts) + " sum=" + itoa(int(secret.Depth.Sum))
+			}, "[0 1 0 1 0 0 0 0 0 0] sum=6"},
+			{"KV v2 age", func() string {
+				return ints(secret.Age.Counts) + " count=" + itoa(secret.Age.Count)
+			}, "[0 1 0 0 0 0 1 0] count=2"},
+			{"KV v1 without age", func() string {
+				return "secrets=" + itoa(kv.Secrets) + " depth=" + ints(kv.Depth.Counts) + " age=" + itoa(kv.Age.Count)
+			}, "secrets=1 depth=[1 0 0 0 0 0 0 0 0 0] age=0"},
+		}
+	)
+	for _, test := range tests {
+		if norm := test.norm(); norm == test.normOutput {
+			tt.Logf("PASS %s", test.description)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := s.Write(&b, Prometheus); err != nil {
+		tt.Fatalf("FAIL Prometheus: %s", err)
+	}
+	for _, line := range []string{
+		`vault_dump_secrets{mount="kv"} 1`,
+		`vault_dump_failed{mount="secret"} 1`,
+		`vault_dump_secret_depth_bucket{mount="secret",le="2"} 1`,
+		`vault_dump_secret_depth_bucket{mount="secret",le="4"} 2`,
+		`vault_dump_secret_age_seconds_bucket{mount="secret",le="+Inf"} 2`,
+		`vault_dump_secret_age_seconds_count{mount="kv"} 0`,
+		`vault_dump_stats_timestamp_seconds 1769817600`,
+	} {
+		if strings.Contains(b.String(), line+"\n") {
+			tt.Logf("PASS Prometheus %s", line)
+		} else {
+			tt.Errorf("FAIL Prometheus: expected '%s' in\n%s", line, b.String())
+		}
+	}
+	for _, leak := range []string{"team", "password", "hunter2", "token", "app"} {
+		if strings.Contains(b.String(), leak) {
+			tt.Errorf("FAIL Prometheus: '%s' leaked", leak)
+		}
+	}
+
+	b.Reset()
+	if err := s.Write(&b, JSON); err != nil {
+		tt.Fatalf("FAIL JSON: %s", err)
+	}
+	var decoded Stats
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil || decoded.Mounts["secret"].Bytes != s.Mounts["secret"].Bytes {
+		tt.Errorf("FAIL JSON: %v", err)
+	} else {
+		tt.Logf("PASS JSON")
+	}
+
+	if err := s.Write(&b, "xml"); err == nil {
+		tt.Errorf("FAIL Invalid format: expected an error")
+	} else {
+		tt.Logf("PASS Invalid format")
+	}
+}
+
+func itoa(i int) string {
+	b, _ := json.Marshal(i)
+	return string(b)
+}
+
+func ints(v []int) string {
+	parts := make([]string, len(v))
+	for i, n := range v {
+		parts[i] = itoa(n)
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
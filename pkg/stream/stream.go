@@ -0,0 +1,267 @@
+package stream
+
+// stream writes dumps as a framed encrypted stream so they can be piped into
+// other processes without the plaintext ever leaving vault-dump. The stream
+// starts with a header carrying the encrypted data key, so it can be
+// decrypted with nothing but access to the KMS key:
+//
+//	"VDSTREAM" | uint32 header length | JSON header
+//	uint32 frame length | flag | AES-256-GCM sealed chunk
+//	...
+//
+// Every chunk is sealed with its sequence number as nonce and the header and
+// its flag as additional data, so reordered, altered or dropped chunks and
+// truncated streams fail to decrypt.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Magic starts every stream
+const Magic = "VDSTREAM"
+
+// Version is the version of the stream format written
+const Version = 1
+
+// DefaultChunkSize is the size of the plaintext of each frame
+const DefaultChunkSize = 64 * 1024
+
+// maxHeaderSize bounds the header read before it is authenticated
+const maxHeaderSize = 64 * 1024
+
+// Flags of a frame
+const (
+	flagMore  byte = 0
+	flagFinal byte = 1
+)
+
+// Header describes a stream, it is sent in the clear and authenticated by
+// every frame
+type Header struct {
+	Version int `json:"version"`
+	// Key is the data key of the stream encrypted by KMS
+	Key       []byte `json:"key"`
+	KMSKey    string `json:"kms_key,omitempty"`
+	Encoding  string `json:"encoding,omitempty"`
+	Created   string `json:"created,omitempty"`
+	ChunkSize int    `json:"chunk_size"`
+}
+
+// ErrTruncated is returned when a stream ends before its final frame
+var ErrTruncated = errors.New("stream truncated")
+
+// Writer encrypts everything written to it into frames, Close must be called
+// to write the final frame
+type Writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	ad     []byte
+	seq    uint64
+	buf    []byte
+	size   int
+	closed bool
+}
+
+// NewWriter writes h to w and returns a Writer sealing chunks with key, the
+// plaintext data key whose encrypted form is h.Key
+func NewWriter(w io.Writer, h Header, key []byte) (*Writer, error) {
+	h.Version = Version
+	if h.ChunkSize <= 0 {
+		h.ChunkSize = DefaultChunkSize
+	}
+	if len(h.Key) == 0 {
+		return nil, errors.New("the header has no encrypted data key")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, len(Magic)+4)
+	copy(prefix, Magic)
+	binary.BigEndian.PutUint32(prefix[len(Magic):], uint32(len(header)))
+	if _, err := w.Write(append(prefix, header...)); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, ad: digest(header), size: h.ChunkSize}, nil
+}
+
+// Write buffers p and writes every full chunk, the last chunk is only
+// written by Close so it can be marked final
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed stream")
+	}
+	w.buf = append(w.buf, p...)
+	for len(w.buf) > w.size {
+		if err := w.frame(w.buf[:w.size], flagMore); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[w.size:]
+	}
+	return len(p), nil
+}
+
+// Close writes the final frame, it does not close the underlying writer
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	err := w.frame(w.buf, flagFinal)
+	w.buf = nil
+	return err
+}
+
+func (w *Writer) frame(chunk []byte, flag byte) error {
+	sealed := w.aead.Seal(nil, nonce(w.seq, w.aead.NonceSize()), chunk, append(w.ad, flag))
+	w.seq++
+
+	prefix := make([]byte, 5)
+	binary.BigEndian.PutUint32(prefix, uint32(len(sealed)))
+	prefix[4] = flag
+	_, err := w.w.Write(append(prefix, sealed...))
+	return err
+}
+
+// Reader decrypts a stream written by Writer
+type Reader struct {
+	r      io.Reader
+	header Header
+	aead   cipher.AEAD
+	ad     []byte
+	seq    uint64
+	max    int
+	buf    []byte
+	done   bool
+}
+
+// NewReader reads the header of the stream in r and decrypts its data key
+// with unwrap
+func NewReader(r io.Reader, unwrap func(h Header) ([]byte, error)) (*Reader, error) {
+	prefix := make([]byte, len(Magic)+4)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, fmt.Errorf("not a vault-dump stream: %w", err)
+	}
+	if string(prefix[:len(Magic)]) != Magic {
+		return nil, errors.New("not a vault-dump stream")
+	}
+	size := binary.BigEndian.Uint32(prefix[len(Magic):])
+	if size > maxHeaderSize {
+		return nil, fmt.Errorf("stream header of %d bytes is too large", size)
+	}
+	header := make([]byte, size)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrTruncated
+	}
+
+	var h Header
+	if err := json.Unmarshal(header, &h); err != nil {
+		return nil, fmt.Errorf("invalid stream header: %w", err)
+	}
+	if h.Version != Version {
+		return nil, fmt.Errorf("unsupported stream version %d", h.Version)
+	}
+	if h.ChunkSize <= 0 {
+		return nil, fmt.Errorf("invalid stream chunk size %d", h.ChunkSize)
+	}
+	key, err := unwrap(h)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: r, header: h, aead: aead, ad: digest(header), max: h.ChunkSize + aead.Overhead()}, nil
+}
+
+// Header returns the header of the stream
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Read returns the decrypted plaintext, io.EOF is only returned after the
+// final frame was authenticated
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.frame(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *Reader) frame() error {
+	prefix := make([]byte, 5)
+	if _, err := io.ReadFull(r.r, prefix); err != nil {
+		return ErrTruncated
+	}
+	size, flag := int(binary.BigEndian.Uint32(prefix)), prefix[4]
+	if size > r.max || (flag != flagMore && flag != flagFinal) {
+		return fmt.Errorf("invalid frame %d", r.seq)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(r.r, sealed); err != nil {
+		return ErrTruncated
+	}
+	chunk, err := r.aead.Open(nil, nonce(r.seq, r.aead.NonceSize()), sealed, append(r.ad, flag))
+	if err != nil {
+		return fmt.Errorf("frame %d failed to decrypt: %w", r.seq, err)
+	}
+	r.seq++
+
+	if flag == flagFinal {
+		r.done = true
+		// anything after the final frame was not written by Writer
+		if n, _ := r.r.Read(make([]byte, 1)); n > 0 {
+			return errors.New("data after the final frame")
+		}
+	}
+	r.buf = chunk
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("expected a 256 bit data key, got %d bits", len(key)*8)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// digest returns the additional data binding frames to their header, it has
+// spare capacity so the flag can be appended without copying
+func digest(header []byte) []byte {
+	sum := sha256.Sum256(header)
+	ad := make([]byte, len(sum), len(sum)+1)
+	copy(ad, sum[:])
+	return ad
+}
+
+// nonce returns the nonce of the frame seq, the data key is random per
+// stream so sequence numbers never repeat for a key
+func nonce(seq uint64, size int) []byte {
+	n := make([]byte, size)
+	binary.BigEndian.PutUint64(n[size-8:], seq)
+	return n
+}
@@ -0,0 +1,72 @@
+package stream
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestSuiteStream(tt *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	unwrap := func(h Header) ([]byte, error) {
+		if string(h.Key) != "wrapped" {
+			return nil, errors.New("unknown key")
+		}
+		return key, nil
+	}
+	write := func(plaintext string, chunkSize int) []byte {
+		var b bytes.Buffer
+		w, err := NewWriter(&b, Header{Key: []byte("wrapped"), Encoding: "json", ChunkSize: chunkSize}, key)
+		if err != nil {
+			tt.Fatal(err)
+		}
+		for _, part := range strings.SplitAfter(plaintext, ",") {
+			w.Write([]byte(part))
+		}
+		if err := w.Close(); err != nil {
+			tt.Fatal(err)
+		}
+		return b.Bytes()
+	}
+	payload := `{"secret/a":{"password":"hunter2"},"secret/b":{"token":"s.abc"}}`
+	headerSize := len(write("", 4)) - 5 - 16
+
+	var (
+		success bool
+		tests   = []struct {
+			description string
+			stream      []byte
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Round trip", write(payload, 4), payload, true},
+			{"Single chunk", write(payload, DefaultChunkSize), payload, true},
+			{"Exact chunks", write("abcdefgh", 4), "abcdefgh", true},
+			{"Empty", write("", 4), "", true},
+			{"Truncated", write(payload, 4)[:headerSize+3*(5+4+16)], "", false},
+			{"Missing final frame", write("abcdefgh", 4)[:headerSize+(5+4+16)], "", false},
+			{"Tampered", func() []byte { s := write(payload, 4); s[len(s)-1] ^= 1; return s }(), "", false},
+			{"Trailing data", append(write(payload, 4), 0), "", false},
+			{"Not a stream", []byte(payload), "", false},
+		}
+	)
+	for _, test := range tests {
+		var out []byte
+		r, err := NewReader(bytes.NewReader(test.stream), unwrap)
+		if err == nil {
+			out, err = ioutil.ReadAll(r)
+		}
+		success = (err == nil)
+		if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t (%v)", test.description, test.isSuccess, success, err)
+		} else if success && string(out) != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, out)
+		} else if success && bytes.Contains(test.stream, []byte("hunter2")) {
+			tt.Errorf("FAIL %s: plaintext found in the stream", test.description)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// Result is the outcome of one test case of a transform definition
+type Result struct {
+	Name     string
+	Passed   bool
+	Failures []string
+}
+
+// RunTests runs the transforms of definition against the inputs of its
+// "tests" and compares the outputs with the expected ones:
+//
+//	{"transforms": [...], "tests": [
+//	  {"name": "...", "input": {...}, "expected": {...}},
+//	  {"name": "...", "input": {...}, "error": true}
+//	]}
+//
+// Paths are normalized like those of dumps being transformed.
+func RunTests(definition map[string]interface{}) ([]Result, error) {
+	tests, ok := definition["tests"].([]interface{})
+	if !ok || len(tests) == 0 {
+		return nil, errors.New("definition has no 'tests'")
+	}
+	results := make([]Result, 0, len(tests))
+	for ii, tt := range tests {
+		tc, ok := tt.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("test %d must be an object", ii+1)
+		}
+		name, _ := tc["name"].(string)
+		if name == "" {
+			name = fmt.Sprintf("test %d", ii+1)
+		}
+		input, ok := tc["input"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s must have an 'input' object", name)
+		}
+		wantErr, _ := tc["error"].(bool)
+		expected, ok := tc["expected"].(map[string]interface{})
+		if !ok && !wantErr {
+			return nil, fmt.Errorf("%s must have an 'expected' object or \"error\": true", name)
+		}
+
+		result := Result{Name: name}
+		output, err := runCase(definition, normalize(input))
+		switch {
+		case wantErr && err == nil:
+			result.Failures = []string{"expected an error"}
+		case wantErr:
+		case err != nil:
+			result.Failures = []string{err.Error()}
+		default:
+			result.Failures = compare(normalize(expected), output)
+		}
+		result.Passed = len(result.Failures) == 0
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// runCase transforms input, a malformed definition fails the case instead
+// of panicking
+func runCase(definition, input map[string]interface{}) (output map[string]interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid transform: %v", r)
+		}
+	}()
+	return Transform(definition, input)
+}
+
+// normalize returns a deep copy of secrets with normalized paths
+func normalize(secrets map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(secrets))
+	for k, v := range secrets {
+		copied[vault.NormalizePath(k)] = deepCopy(v)
+	}
+	return copied
+}
+
+// deepCopy copies decoded JSON, so cases never share values
+func deepCopy(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(vv))
+		for k, e := range vv {
+			m[k] = deepCopy(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(vv))
+		for i, e := range vv {
+			l[i] = deepCopy(e)
+		}
+		return l
+	}
+	return v
+}
+
+// compare describes every path missing from, unexpected in or different in
+// output, sorted by path
+func compare(expected, output map[string]interface{}) []string {
+	var failures []string
+	for path, want := range expected {
+		got, exists := output[path]
+		if !exists {
+			failures = append(failures, fmt.Sprintf("%s: missing", path))
+		} else if !reflect.DeepEqual(want, got) {
+			failures = append(failures, fmt.Sprintf("%s: expected %s got %s", path, encode(want), encode(got)))
+		}
+	}
+	for path := range output {
+		if _, exists := expected[path]; !exists {
+			failures = append(failures, fmt.Sprintf("%s: unexpected", path))
+		}
+	}
+	sort.Strings(failures)
+	return failures
+}
+
+func encode(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
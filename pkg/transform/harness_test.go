@@ -0,0 +1,78 @@
+package transform
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSuiteRunTests(tt *testing.T) {
+	definition := `{
+		"transforms": [[{"scope": "key", "replace": "prod", "with": "staging"}]],
+		"tests": [
+			{"name": "Renamed", "input": {"/secret/prod/a": {"x": "1"}}, "expected": {"secret/staging/a": {"x": "1"}}},
+			{"name": "Wrong value", "input": {"secret/prod/a": {"x": "1"}}, "expected": {"secret/staging/a": {"x": "2"}}},
+			{"name": "Missing and unexpected", "input": {"secret/prod/a": {"x": "1"}}, "expected": {"secret/prod/a": {"x": "1"}}},
+			{"input": {"secret/a": {"x": "1"}}, "error": true}
+		]
+	}`
+	var dd map[string]interface{}
+	if err := json.Unmarshal([]byte(definition), &dd); err != nil {
+		tt.Fatalf("FAIL %s", err)
+	}
+	results, err := RunTests(dd)
+	if err != nil {
+		tt.Fatalf("FAIL RunTests: %s", err)
+	}
+
+	var (
+		tests = []struct {
+			description string
+			expected    Result
+		}{
+			{"Passing case", Result{Name: "Renamed", Passed: true}},
+			{"Different value", Result{Name: "Wrong value", Failures: []string{`secret/staging/a: expected {"x":"2"} got {"x":"1"}`}}},
+			{"Different paths", Result{Name: "Missing and unexpected", Failures: []string{"secret/prod/a: missing", "secret/staging/a: unexpected"}}},
+			{"Unnamed error case", Result{Name: "test 4", Failures: []string{"expected an error"}}},
+		}
+	)
+	if len(results) != len(tests) {
+		tt.Fatalf("FAIL expected %d results got %d", len(tests), len(results))
+	}
+	for ii, test := range tests {
+		if !reflect.DeepEqual(results[ii], test.expected) {
+			tt.Errorf("FAIL %s: expected %+v got %+v", test.description, test.expected, results[ii])
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteRunTestsInvalid(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			definition  string
+			isSuccess   bool
+		}{
+			{"No tests", `{"transforms": []}`, false},
+			{"Missing expected", `{"transforms": [], "tests": [{"input": {}}]}`, false},
+			{"Malformed transform", `{"transforms": [[{"replace": "a", "with": "b"}]], "tests": [{"input": {"a": {}}, "expected": {}}]}`, true},
+		}
+	)
+	for _, test := range tests {
+		var dd map[string]interface{}
+		if err := json.Unmarshal([]byte(test.definition), &dd); err != nil {
+			tt.Fatalf("FAIL %s: %s", test.description, err)
+		}
+		results, err := RunTests(dd)
+		success := err == nil
+		if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t (%v)", test.description, test.isSuccess, success, err)
+		} else if success && results[0].Passed {
+			tt.Errorf("FAIL %s: expected the case to fail", test.description)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package transform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// httpClient fetches imported definitions
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// maxImportSize bounds imported definitions fetched over https
+const maxImportSize = 10 << 20
+
+// Load reads the transform definition at location, a file or https URL, and
+// expands its imports. Imports are named, and the name used in place of a
+// group of transforms inserts the transforms of that definition there:
+//
+//	{"imports": {"strip": "common/strip-internal.json"},
+//	 "transforms": ["strip", [{"scope": "key", ...}]]}
+//
+// Relative imports are resolved against the importing definition, a
+// location may be pinned with #sha256=<hex>, and each definition is loaded
+// once however often it is imported. Definitions fetched over https can only
+// import others over https, never local files.
+func Load(location string) (map[string]interface{}, error) {
+	l := &loader{loading: make(map[string]bool), loaded: make(map[string][]interface{})}
+	dd, err := read(location)
+	if err != nil {
+		return nil, err
+	}
+	l.loading[location] = true
+	transforms, err := l.expand(location, dd)
+	if err != nil {
+		return nil, err
+	}
+	delete(dd, "imports")
+	dd["transforms"] = transforms
+	return dd, nil
+}
+
+// loader tracks the definitions being and already loaded, by location with
+// its pin, so a definition is only taken as loaded once its pin is checked
+type loader struct {
+	loading map[string]bool
+	loaded  map[string][]interface{}
+}
+
+// expand returns the transforms of dd, read from location, with the names
+// of its imports replaced by their transforms
+func (l *loader) expand(location string, dd map[string]interface{}) ([]interface{}, error) {
+	imports := map[string]interface{}{}
+	if ii, hasImports := dd["imports"]; hasImports {
+		var ok bool
+		if imports, ok = ii.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("%s: 'imports' must be an object of names to locations", location)
+		}
+	}
+	groups, ok := dd["transforms"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: 'transforms' must be a list", location)
+	}
+
+	used := make(map[string]bool)
+	expanded := make([]interface{}, 0, len(groups))
+	for _, group := range groups {
+		name, isName := group.(string)
+		if !isName {
+			expanded = append(expanded, group)
+			continue
+		}
+		ref, ok := imports[name].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown import %q", location, name)
+		}
+		imported := resolve(location, ref)
+		if strings.HasPrefix(strip(location), "https://") && !strings.HasPrefix(imported, "https://") {
+			return nil, fmt.Errorf("%s: import %q is not https, definitions fetched over https can not import local files", location, name)
+		}
+		transforms, err := l.load(imported)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, transforms...)
+		used[name] = true
+	}
+	for name := range imports {
+		if !used[name] {
+			return nil, fmt.Errorf("%s: import %q is not used", location, name)
+		}
+	}
+	return expanded, nil
+}
+
+// load returns the expanded transforms of the definition at location
+func (l *loader) load(location string) ([]interface{}, error) {
+	if transforms, ok := l.loaded[location]; ok {
+		return transforms, nil
+	}
+	if l.loading[location] {
+		return nil, fmt.Errorf("import cycle through %s", strip(location))
+	}
+	l.loading[location] = true
+	defer delete(l.loading, location)
+
+	dd, err := read(location)
+	if err != nil {
+		return nil, err
+	}
+	transforms, err := l.expand(strip(location), dd)
+	if err != nil {
+		return nil, err
+	}
+	l.loaded[location] = transforms
+	return transforms, nil
+}
+
+// read returns the definition at location, checking its pin if any
+func read(location string) (map[string]interface{}, error) {
+	path, pin := location, ""
+	if i := strings.Index(location, "#sha256="); i >= 0 {
+		path, pin = location[:i], location[i+len("#sha256="):]
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	switch {
+	case strings.HasPrefix(path, "https://"):
+		data, err = fetch(path)
+	case strings.Contains(path, "://"):
+		return nil, fmt.Errorf("%s: only files and https imports are supported", path)
+	default:
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if pin != "" {
+		sum := sha256.Sum256(data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), pin) {
+			return nil, fmt.Errorf("%s: sha256 does not match the pinned %s", path, pin)
+		}
+	}
+	dd := make(map[string]interface{})
+	if err := json.Unmarshal(data, &dd); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return dd, nil
+}
+
+// fetch downloads a definition over https
+func fetch(location string) ([]byte, error) {
+	resp, err := httpClient.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", location, resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxImportSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", location, err)
+	}
+	if len(data) > maxImportSize {
+		return nil, fmt.Errorf("%s: larger than %d bytes", location, maxImportSize)
+	}
+	return data, nil
+}
+
+// resolve returns the location of ref imported by the definition at base
+func resolve(base, ref string) string {
+	if strings.Contains(ref, "://") || filepath.IsAbs(ref) {
+		return ref
+	}
+	base = strip(base)
+	if strings.HasPrefix(base, "https://") {
+		u, err := url.Parse(base)
+		if err != nil {
+			return ref
+		}
+		r, err := url.Parse(ref)
+		if err != nil {
+			return ref
+		}
+		return u.ResolveReference(r).String()
+	}
+	return filepath.Join(filepath.Dir(base), ref)
+}
+
+// strip removes the pin from location
+func strip(location string) string {
+	if i := strings.Index(location, "#sha256="); i >= 0 {
+		return location[:i]
+	}
+	return location
+}
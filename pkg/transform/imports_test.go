@@ -0,0 +1,161 @@
+package transform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSuiteLoad(tt *testing.T) {
+	strip := `{"transforms": [[{"scope": "key", "replace": "internal/", "with": ""}]]}`
+	sum := sha256.Sum256([]byte(strip))
+	pin := hex.EncodeToString(sum[:])
+
+	// local is a definition on disk, imported by /lib/local.json
+	var local string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lib/local.json":
+			imports, _ := json.Marshal(map[string]string{"strip": local})
+			w.Write([]byte(`{"imports": ` + string(imports) + `, "transforms": ["strip"]}`))
+		case "/lib/strip.json":
+			w.Write([]byte(strip))
+		case "/lib/both.json":
+			w.Write([]byte(`{"imports": {"strip": "strip.json"}, "transforms": ["strip", [{"scope": "key", "replace": "a", "with": "b"}]]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	httpClient = server.Client()
+
+	dir, err := ioutil.TempDir("", "transform")
+	if err != nil {
+		tt.Fatalf("FAIL %s", err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"common/strip.json": strip,
+		"common/names.json": `{"imports": {"strip": "strip.json"}, "transforms": ["strip", [{"scope": "key", "replace": "-", "with": "_"}]]}`,
+		"cycle-a.json":      `{"imports": {"b": "cycle-b.json"}, "transforms": ["b"]}`,
+		"cycle-b.json":      `{"imports": {"a": "cycle-a.json"}, "transforms": ["a"]}`,
+	}
+	local = filepath.Join(dir, "common/strip.json")
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			tt.Fatalf("FAIL %s", err)
+		}
+	}
+
+	var (
+		tests = []struct {
+			description string
+			definition  string
+			expected    string
+			isSuccess   bool
+		}{
+			{
+				"No imports",
+				`{"transforms": [[{"scope": "key", "replace": "a", "with": "b"}]]}`,
+				`[[{"scope": "key", "replace": "a", "with": "b"}]]`,
+				true,
+			},
+			{
+				"Relative file import in place",
+				`{"imports": {"strip": "common/strip.json"}, "transforms": [[{"scope": "value", "replace": "x", "with": "y"}], "strip"]}`,
+				`[[{"scope": "value", "replace": "x", "with": "y"}], [{"scope": "key", "replace": "internal/", "with": ""}]]`,
+				true,
+			},
+			{
+				"Nested and repeated imports",
+				`{"imports": {"names": "common/names.json", "strip": "common/strip.json"}, "transforms": ["names", "strip"]}`,
+				`[[{"scope": "key", "replace": "internal/", "with": ""}], [{"scope": "key", "replace": "-", "with": "_"}], [{"scope": "key", "replace": "internal/", "with": ""}]]`,
+				true,
+			},
+			{
+				"https import with relative import and pin",
+				`{"imports": {"both": "` + server.URL + `/lib/both.json", "strip": "` + server.URL + `/lib/strip.json#sha256=` + pin + `"}, "transforms": ["both", "strip"]}`,
+				`[[{"scope": "key", "replace": "internal/", "with": ""}], [{"scope": "key", "replace": "a", "with": "b"}], [{"scope": "key", "replace": "internal/", "with": ""}]]`,
+				true,
+			},
+			{
+				"Pin mismatch",
+				`{"imports": {"strip": "common/strip.json#sha256=00"}, "transforms": ["strip"]}`,
+				``,
+				false,
+			},
+			{
+				"Plain http import",
+				`{"imports": {"strip": "http://example.com/strip.json"}, "transforms": ["strip"]}`,
+				``,
+				false,
+			},
+			{
+				"Unknown import",
+				`{"transforms": ["strip"]}`,
+				``,
+				false,
+			},
+			{
+				"Unused import",
+				`{"imports": {"strip": "common/strip.json"}, "transforms": []}`,
+				``,
+				false,
+			},
+			{
+				"Remote import of a local file",
+				`{"imports": {"local": "` + server.URL + `/lib/local.json"}, "transforms": ["local"]}`,
+				``,
+				false,
+			},
+			{
+				"Same import with a wrong pin",
+				`{"imports": {"strip": "common/strip.json", "pinned": "common/strip.json#sha256=00"}, "transforms": ["strip", "pinned"]}`,
+				``,
+				false,
+			},
+			{
+				"Import cycle",
+				`{"imports": {"a": "cycle-a.json"}, "transforms": ["a"]}`,
+				``,
+				false,
+			},
+			{
+				"Missing import",
+				`{"imports": {"gone": "common/gone.json"}, "transforms": ["gone"]}`,
+				``,
+				false,
+			},
+		}
+	)
+	for _, test := range tests {
+		path := filepath.Join(dir, "definition.json")
+		if err := ioutil.WriteFile(path, []byte(test.definition), 0600); err != nil {
+			tt.Fatalf("FAIL %s", err)
+		}
+		dd, err := Load(path)
+		success := err == nil
+		if success && test.isSuccess {
+			var expected interface{}
+			json.Unmarshal([]byte(test.expected), &expected)
+			if _, hasImports := dd["imports"]; hasImports || !reflect.DeepEqual(dd["transforms"], expected) {
+				tt.Errorf("FAIL %s: expected %v got %v", test.description, expected, dd)
+				continue
+			}
+		}
+		if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t (%v)", test.description, test.isSuccess, success, err)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/dathan/go-vault-dump/pkg/vault"
+)
+
+// isMove reports whether tx moves fields between secrets, a merge or split,
+// rather than rewriting each secret
+func isMove(tx map[string]interface{}) bool {
+	_, isMerge := tx["merge"]
+	_, isSplit := tx["split"]
+	return isMerge || isSplit
+}
+
+// move applies a merge or split action to secrets
+func move(tx map[string]interface{}) error {
+	if _, isMerge := tx["merge"]; isMerge {
+		return merge(tx)
+	}
+	return split(tx)
+}
+
+// merge copies the fields of every "merge" path, in order, into the "into"
+// secret and removes the sources unless "keep" is set. Fields with different
+// values in two sources are an error unless "overwrite" is set, then the
+// later source wins.
+//
+//	{"merge": ["secret/svc/db", "secret/svc/api"], "into": "secret/svc"}
+func merge(tx map[string]interface{}) error {
+	sources, ok := stringList(tx["merge"])
+	if !ok || len(sources) == 0 {
+		return errors.New("'merge' must be a list of paths")
+	}
+	into, ok := tx["into"].(string)
+	if !ok || into == "" {
+		return errors.New("'merge' actions must include 'into'")
+	}
+	into = vault.NormalizePath(into)
+	keep, _ := tx["keep"].(bool)
+	overwrite, _ := tx["overwrite"].(bool)
+
+	merged, err := fieldsOf(into, false)
+	if err != nil {
+		return err
+	}
+	for _, source := range sources {
+		source = vault.NormalizePath(source)
+		fields, err := fieldsOf(source, true)
+		if err != nil {
+			return err
+		}
+		if err := copyFields(merged, fields, nil, overwrite, into); err != nil {
+			return err
+		}
+	}
+	for _, source := range sources {
+		if source = vault.NormalizePath(source); !keep && source != into {
+			delete(secrets, source)
+		}
+	}
+	secrets[into] = merged
+	return nil
+}
+
+// split moves the fields listed for every "into" path out of the "split"
+// secret, which is removed once empty. With "keep" fields are copied instead.
+//
+//	{"split": "secret/svc", "into": {"secret/svc/db": ["user", "password"]}}
+func split(tx map[string]interface{}) error {
+	source, ok := tx["split"].(string)
+	if !ok || source == "" {
+		return errors.New("'split' must be a path")
+	}
+	source = vault.NormalizePath(source)
+	targets, ok := tx["into"].(map[string]interface{})
+	if !ok || len(targets) == 0 {
+		return errors.New("'split' actions must include 'into', an object of paths to lists of fields")
+	}
+	keep, _ := tx["keep"].(bool)
+	overwrite, _ := tx["overwrite"].(bool)
+
+	fields, err := fieldsOf(source, true)
+	if err != nil {
+		return err
+	}
+	remaining := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		remaining[k] = v
+	}
+	for target, ff := range targets {
+		names, ok := stringList(ff)
+		if !ok || len(names) == 0 {
+			return fmt.Errorf("'into' of %s must map %s to a list of fields", source, target)
+		}
+		target = vault.NormalizePath(target)
+		if target == source {
+			return fmt.Errorf("can not split %s into itself", source)
+		}
+		moved, err := fieldsOf(target, false)
+		if err != nil {
+			return err
+		}
+		if err := copyFields(moved, fields, names, overwrite, target); err != nil {
+			return fmt.Errorf("splitting %s: %w", source, err)
+		}
+		secrets[target] = moved
+		for _, name := range names {
+			delete(remaining, name)
+		}
+	}
+	if keep {
+		return nil
+	}
+	if len(remaining) == 0 {
+		delete(secrets, source)
+	} else {
+		secrets[source] = remaining
+	}
+	return nil
+}
+
+// fieldsOf returns a copy of the fields of the secret at path, an empty
+// secret if it does not exist and required is not set
+func fieldsOf(path string, required bool) (map[string]interface{}, error) {
+	secret, exists := secrets[path]
+	if !exists {
+		if required {
+			return nil, fmt.Errorf("secret %s not found", path)
+		}
+		return make(map[string]interface{}), nil
+	}
+	fields, ok := secret.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("secret %s is not an object", path)
+	}
+	copied := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		copied[k] = v
+	}
+	return copied, nil
+}
+
+// copyFields copies the named fields, or all fields when names is nil, from
+// src into dst, the secret at path
+func copyFields(dst, src map[string]interface{}, names []string, overwrite bool, path string) error {
+	if names == nil {
+		for k := range src {
+			names = append(names, k)
+		}
+	}
+	for _, name := range names {
+		v, found := src[name]
+		if !found {
+			return fmt.Errorf("field %s not found", name)
+		}
+		if current, exists := dst[name]; exists && !overwrite && !reflect.DeepEqual(current, v) {
+			return fmt.Errorf("conflicting values for field %s of %s", name, path)
+		}
+		dst[name] = v
+	}
+	return nil
+}
+
+// stringList converts a decoded JSON list of strings
+func stringList(v interface{}) ([]string, bool) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	ss := make([]string, 0, len(list))
+	for _, e := range list {
+		s, ok := e.(string)
+		if !ok {
+			return nil, false
+		}
+		ss = append(ss, s)
+	}
+	return ss, true
+}
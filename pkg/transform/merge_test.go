@@ -0,0 +1,128 @@
+package transform
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSuiteMove(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			transform   string
+			input       string
+			expected    string
+			isSuccess   bool
+		}{
+			{
+				"Merge",
+				`{"merge": ["secret/svc/db", "secret/svc/api"], "into": "secret/svc"}`,
+				`{"secret/svc/db": {"user": "u", "host": "h"}, "secret/svc/api": {"token": "t", "host": "h"}, "secret/other": {"a": "b"}}`,
+				`{"secret/svc": {"user": "u", "host": "h", "token": "t"}, "secret/other": {"a": "b"}}`,
+				true,
+			},
+			{
+				"Merge into existing secret",
+				`{"merge": ["secret/db"], "into": "/secret/app/"}`,
+				`{"secret/db": {"user": "u"}, "secret/app": {"name": "n"}}`,
+				`{"secret/app": {"user": "u", "name": "n"}}`,
+				true,
+			},
+			{
+				"Merge keeping sources",
+				`{"merge": ["secret/a", "secret/b"], "into": "secret/c", "keep": true}`,
+				`{"secret/a": {"x": "1"}, "secret/b": {"y": "2"}}`,
+				`{"secret/a": {"x": "1"}, "secret/b": {"y": "2"}, "secret/c": {"x": "1", "y": "2"}}`,
+				true,
+			},
+			{
+				"Merge conflict",
+				`{"merge": ["secret/a", "secret/b"], "into": "secret/c"}`,
+				`{"secret/a": {"x": "1"}, "secret/b": {"x": "2"}}`,
+				``,
+				false,
+			},
+			{
+				"Merge conflict with overwrite",
+				`{"merge": ["secret/a", "secret/b"], "into": "secret/c", "overwrite": true}`,
+				`{"secret/a": {"x": "1"}, "secret/b": {"x": "2"}}`,
+				`{"secret/c": {"x": "2"}}`,
+				true,
+			},
+			{
+				"Merge missing source",
+				`{"merge": ["secret/a", "secret/missing"], "into": "secret/c"}`,
+				`{"secret/a": {"x": "1"}}`,
+				``,
+				false,
+			},
+			{
+				"Split",
+				`{"split": "secret/svc", "into": {"secret/svc/db": ["user", "password"], "secret/svc/api": ["token"]}}`,
+				`{"secret/svc": {"user": "u", "password": "p", "token": "t"}}`,
+				`{"secret/svc/db": {"user": "u", "password": "p"}, "secret/svc/api": {"token": "t"}}`,
+				true,
+			},
+			{
+				"Split leaving fields",
+				`{"split": "secret/svc", "into": {"secret/svc/db": ["user"]}}`,
+				`{"secret/svc": {"user": "u", "name": "n"}}`,
+				`{"secret/svc": {"name": "n"}, "secret/svc/db": {"user": "u"}}`,
+				true,
+			},
+			{
+				"Split keeping source",
+				`{"split": "secret/svc", "into": {"secret/svc/db": ["user"]}, "keep": true}`,
+				`{"secret/svc": {"user": "u"}}`,
+				`{"secret/svc": {"user": "u"}, "secret/svc/db": {"user": "u"}}`,
+				true,
+			},
+			{
+				"Split missing field",
+				`{"split": "secret/svc", "into": {"secret/svc/db": ["password"]}}`,
+				`{"secret/svc": {"user": "u"}}`,
+				``,
+				false,
+			},
+			{
+				"Split conflict with existing secret",
+				`{"split": "secret/svc", "into": {"secret/svc/db": ["user"]}}`,
+				`{"secret/svc": {"user": "u"}, "secret/svc/db": {"user": "other"}}`,
+				``,
+				false,
+			},
+			{
+				"Merge then split round trip",
+				`{"merge": ["secret/a", "secret/b"], "into": "secret/c"}, {"split": "secret/c", "into": {"secret/a": ["x"], "secret/b": ["y"]}}`,
+				`{"secret/a": {"x": "1"}, "secret/b": {"y": "2"}}`,
+				`{"secret/a": {"x": "1"}, "secret/b": {"y": "2"}}`,
+				true,
+			},
+		}
+	)
+	for _, test := range tests {
+		var transforms, input, expected map[string]interface{}
+		if err := json.Unmarshal([]byte(`{"transforms": [[`+test.transform+`]]}`), &transforms); err != nil {
+			tt.Fatalf("FAIL %s: %s", test.description, err)
+		}
+		if err := json.Unmarshal([]byte(test.input), &input); err != nil {
+			tt.Fatalf("FAIL %s: %s", test.description, err)
+		}
+		if test.isSuccess {
+			if err := json.Unmarshal([]byte(test.expected), &expected); err != nil {
+				tt.Fatalf("FAIL %s: %s", test.description, err)
+			}
+		}
+		output, err := Transform(transforms, input)
+		success := err == nil && reflect.DeepEqual(output, expected)
+		if !test.isSuccess {
+			success = err != nil
+		}
+		if !success {
+			tt.Errorf("FAIL %s: expected %v got %v (%v)", test.description, expected, output, err)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
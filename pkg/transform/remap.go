@@ -0,0 +1,118 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dathan/go-vault-dump/pkg/dump"
+)
+
+// Remap rewrites environment markers, such as hostnames, account IDs and
+// region names, in the paths and string values of a dump
+type Remap struct {
+	replacer *strings.Replacer
+}
+
+// NewRemap returns a Remap replacing every key of mapping with its value.
+// Longer markers win over the shorter ones they contain and replaced text is
+// never replaced again, so prod=staging and staging=dev swap cleanly.
+func NewRemap(mapping map[string]string) (*Remap, error) {
+	if len(mapping) == 0 {
+		return nil, errors.New("remap needs at least one marker")
+	}
+	from := make([]string, 0, len(mapping))
+	for f := range mapping {
+		if f == "" {
+			return nil, errors.New("remap markers can not be empty")
+		}
+		from = append(from, f)
+	}
+	sort.Slice(from, func(i, j int) bool {
+		if len(from[i]) != len(from[j]) {
+			return len(from[i]) > len(from[j])
+		}
+		return from[i] < from[j]
+	})
+	pairs := make([]string, 0, 2*len(from))
+	for _, f := range from {
+		pairs = append(pairs, f, mapping[f])
+	}
+	return &Remap{replacer: strings.NewReplacer(pairs...)}, nil
+}
+
+// ParseRemap parses from=to markers
+func ParseRemap(specs []string) (map[string]string, error) {
+	mapping := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid remap %q, expected from=to", spec)
+		}
+		mapping[parts[0]] = parts[1]
+	}
+	return mapping, nil
+}
+
+// Apply returns the secrets with their paths and string values remapped.
+// Tagged values other than $literal are left alone, and it is an error for
+// two paths to be remapped to the same path.
+func (r *Remap) Apply(secrets map[string]interface{}) (map[string]interface{}, error) {
+	remapped := make(map[string]interface{}, len(secrets))
+	sources := make(map[string]string, len(secrets))
+	for path, secret := range secrets {
+		to := r.replacer.Replace(path)
+		if other, exists := sources[to]; exists {
+			return nil, fmt.Errorf("both %s and %s remap to %s", other, path, to)
+		}
+		sources[to] = path
+		if fields, ok := secret.(map[string]interface{}); ok {
+			remapped[to] = r.fields(fields)
+		} else {
+			remapped[to] = r.value(secret)
+		}
+	}
+	return remapped, nil
+}
+
+// value remaps the strings in v
+func (r *Remap) value(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case string:
+		return r.replacer.Replace(vv)
+	case []interface{}:
+		out := make([]interface{}, len(vv))
+		for i, e := range vv {
+			out[i] = r.value(e)
+		}
+		return out
+	case map[string]interface{}:
+		if len(vv) == 1 {
+			for k, e := range vv {
+				if k == dump.LiteralKey {
+					// the wrapped value is never a tag itself
+					if m, ok := e.(map[string]interface{}); ok {
+						return map[string]interface{}{k: r.fields(m)}
+					}
+					return map[string]interface{}{k: r.value(e)}
+				}
+				if strings.HasPrefix(k, "$") {
+					// $binary and $file values are not text
+					return vv
+				}
+			}
+		}
+		return r.fields(vv)
+	}
+	return v
+}
+
+// fields remaps the strings in the fields of m
+func (r *Remap) fields(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, e := range m {
+		out[k] = r.value(e)
+	}
+	return out
+}
@@ -0,0 +1,124 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuiteRemap(tt *testing.T) {
+	mapping := map[string]string{
+		"prod":                "staging",
+		"staging":             "dev",
+		"prod-eu":             "staging-us",
+		"111111111111":        "222222222222",
+		"us-east-1":           "us-west-2",
+		"db.prod.example.com": "db.stg.example.com",
+	}
+	remap, err := NewRemap(mapping)
+	if err != nil {
+		tt.Fatalf("FAIL NewRemap: %s", err)
+	}
+
+	var (
+		tests = []struct {
+			description string
+			input       map[string]interface{}
+			expected    map[string]interface{}
+			isSuccess   bool
+		}{
+			{
+				"Path and value",
+				map[string]interface{}{"secret/prod/db": map[string]interface{}{"host": "db.prod.example.com"}},
+				map[string]interface{}{"secret/staging/db": map[string]interface{}{"host": "db.stg.example.com"}},
+				true,
+			},
+			{
+				"Longest marker wins",
+				map[string]interface{}{"secret/prod-eu/a": map[string]interface{}{"v": "prod-eu prod"}},
+				map[string]interface{}{"secret/staging-us/a": map[string]interface{}{"v": "staging-us staging"}},
+				true,
+			},
+			{
+				"Replaced text is not replaced again",
+				map[string]interface{}{"secret/staging/a": map[string]interface{}{"v": "prod"}},
+				map[string]interface{}{"secret/dev/a": map[string]interface{}{"v": "staging"}},
+				true,
+			},
+			{
+				"Account IDs and regions in nested values",
+				map[string]interface{}{"secret/a": map[string]interface{}{
+					"arns": []interface{}{"arn:aws:iam::111111111111:role/x", "arn:aws:s3:us-east-1:111111111111:b"},
+					"port": float64(5432),
+				}},
+				map[string]interface{}{"secret/a": map[string]interface{}{
+					"arns": []interface{}{"arn:aws:iam::222222222222:role/x", "arn:aws:s3:us-west-2:222222222222:b"},
+					"port": float64(5432),
+				}},
+				true,
+			},
+			{
+				"Tagged values",
+				map[string]interface{}{"secret/a": map[string]interface{}{
+					"bin":  map[string]interface{}{"$binary": "prod"},
+					"file": map[string]interface{}{"$file": "prod.files/a"},
+					"lit":  map[string]interface{}{"$literal": map[string]interface{}{"$x": "prod"}},
+				}},
+				map[string]interface{}{"secret/a": map[string]interface{}{
+					"bin":  map[string]interface{}{"$binary": "prod"},
+					"file": map[string]interface{}{"$file": "prod.files/a"},
+					"lit":  map[string]interface{}{"$literal": map[string]interface{}{"$x": "staging"}},
+				}},
+				true,
+			},
+			{
+				"Field names are kept",
+				map[string]interface{}{"secret/a": map[string]interface{}{"prod": "prod"}},
+				map[string]interface{}{"secret/a": map[string]interface{}{"prod": "staging"}},
+				true,
+			},
+			{
+				"Colliding paths",
+				map[string]interface{}{"secret/prod/a": "x", "secret/staging/a": "y", "secret/dev/a": "z"},
+				nil,
+				false,
+			},
+		}
+	)
+	for _, test := range tests {
+		output, err := remap.Apply(test.input)
+		success := err == nil && reflect.DeepEqual(output, test.expected)
+		if !test.isSuccess {
+			success = err != nil
+		}
+		if !success {
+			tt.Errorf("FAIL %s: expected %v got %v (%v)", test.description, test.expected, output, err)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteParseRemap(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			specs       []string
+			expected    map[string]string
+			isSuccess   bool
+		}{
+			{"Markers", []string{"prod=staging", "a=b=c"}, map[string]string{"prod": "staging", "a": "b=c"}, true},
+			{"Empty replacement", []string{"-prod="}, map[string]string{"-prod": ""}, true},
+			{"Missing equals", []string{"prod"}, nil, false},
+			{"Empty marker", []string{"=staging"}, nil, false},
+		}
+	)
+	for _, test := range tests {
+		output, err := ParseRemap(test.specs)
+		success := (err == nil) == test.isSuccess && (err != nil || reflect.DeepEqual(output, test.expected))
+		if !success {
+			tt.Errorf("FAIL %s: expected %v got %v (%v)", test.description, test.expected, output, err)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -19,8 +19,14 @@ func Transform(transforms map[string]interface{}, input map[string]interface{})
 	for _, ttx := range transforms["transforms"].([]interface{}) {
 		params = make(map[string]interface{})
 		for ii, tt := range ttx.([]interface{}) {
+			tx := tt.(map[string]interface{})
+			if isMove(tx) {
+				if err := move(tx); err != nil {
+					return nil, err
+				}
+				continue
+			}
 			for kk, vv := range secrets {
-				tx := tt.(map[string]interface{})
 				xk, xv, err := apply(tx, kk, vv, ii)
 				if err != nil {
 					return nil, err
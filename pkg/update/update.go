@@ -0,0 +1,261 @@
+package update
+
+// update replaces the running binary with a release from a mirror laid out
+// as <mirror>/latest, holding the latest version, and for each version
+// <mirror>/<version>/ holding the binaries and the VERSION file written by
+// make release, their SHA256SUMS and its ed25519 signature SHA256SUMS.sig.
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dathan/go-vault-dump/pkg/guard"
+)
+
+const (
+	// ChecksumsFile lists the SHA-256 checksum of each binary of a version
+	ChecksumsFile = "SHA256SUMS"
+	// VersionFile holds the version of a release, listed in its checksums so
+	// that the version is signed as well
+	VersionFile = "VERSION"
+	// maxMetadataSize bounds the version and checksum files fetched
+	maxMetadataSize = 1 << 20
+)
+
+// httpClient fetches from the mirror, binaries included
+var httpClient = &http.Client{Timeout: 10 * time.Minute}
+
+// Release is the binary of a version for a platform
+type Release struct {
+	Version  string
+	Asset    string
+	Checksum string
+}
+
+// AssetName is the name of the binary for goos and goarch, as written by
+// make release
+func AssetName(goos, goarch string) string {
+	name := fmt.Sprintf("vault-tools-%s-%s", goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Latest returns the latest version on the mirror
+func Latest(mirror string) (string, error) {
+	b, err := fetch(join(mirror, "latest"), maxMetadataSize)
+	if err != nil {
+		return "", err
+	}
+	version := strings.TrimSpace(string(b))
+	if version == "" || strings.ContainsAny(version, "/\\ \n") {
+		return "", fmt.Errorf("invalid latest version %q on %s", version, mirror)
+	}
+	return version, nil
+}
+
+// Find returns the release of asset in version, once the checksums of the
+// version are verified to be signed by publicKey, see guard.VerifySignature,
+// and the version they sign is version, so that the signed files of another
+// release can not be served in its place
+func Find(mirror, version, asset, publicKey string) (Release, error) {
+	base := join(mirror, version)
+	sums, err := fetch(join(base, ChecksumsFile), maxMetadataSize)
+	if err != nil {
+		return Release{}, err
+	}
+	sig, err := fetch(join(base, ChecksumsFile+"."+guard.SignatureExt), maxMetadataSize)
+	if err != nil {
+		return Release{}, err
+	}
+	if err := guard.VerifySignature(sums, sig, publicKey); err != nil {
+		return Release{}, fmt.Errorf("the checksums of %s: %w", version, err)
+	}
+	checksums, err := ParseChecksums(sums)
+	if err != nil {
+		return Release{}, err
+	}
+	if err := verifyVersion(base, version, checksums); err != nil {
+		return Release{}, err
+	}
+	checksum, ok := checksums[asset]
+	if !ok {
+		return Release{}, fmt.Errorf("version %s has no %s", version, asset)
+	}
+	return Release{Version: version, Asset: asset, Checksum: checksum}, nil
+}
+
+// verifyVersion checks that the VersionFile at base matches its checksum and
+// holds version
+func verifyVersion(base, version string, checksums map[string]string) error {
+	checksum, ok := checksums[VersionFile]
+	if !ok {
+		return fmt.Errorf("the checksums of %s do not cover its %s", version, VersionFile)
+	}
+	b, err := fetch(join(base, VersionFile), maxMetadataSize)
+	if err != nil {
+		return err
+	}
+	if sum := sha256.Sum256(b); hex.EncodeToString(sum[:]) != checksum {
+		return fmt.Errorf("the checksum of the %s of %s does not match", VersionFile, version)
+	}
+	if signed := strings.TrimSpace(string(b)); signed != version {
+		return fmt.Errorf("the release at %s is version %s", version, signed)
+	}
+	return nil
+}
+
+// Older reports whether version a is older than version b. Both are
+// v<major>.<minor>.<patch> as tagged, possibly followed by what git describe
+// appends, versions that are not, like dev builds, are never older.
+func Older(a, b string) bool {
+	va, ok := parseVersion(a)
+	if !ok {
+		return false
+	}
+	vb, ok := parseVersion(b)
+	if !ok {
+		return false
+	}
+	for i := range va {
+		if va[i] != vb[i] {
+			return va[i] < vb[i]
+		}
+	}
+	return false
+}
+
+// parseVersion returns the major, minor and patch numbers of version
+func parseVersion(version string) ([3]int, bool) {
+	var n [3]int
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) != len(n) {
+		return n, false
+	}
+	for i, p := range parts {
+		x, err := strconv.Atoi(p)
+		if err != nil || x < 0 {
+			return n, false
+		}
+		n[i] = x
+	}
+	return n, true
+}
+
+// ParseChecksums parses the output of sha256sum into the checksum of each
+// file name
+func ParseChecksums(data []byte) (map[string]string, error) {
+	checksums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid checksum line %q", line)
+		}
+		sum := strings.ToLower(fields[0])
+		if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid checksum line %q", line)
+		}
+		// sha256sum marks files read in binary mode with *
+		checksums[strings.TrimPrefix(fields[1], "*")] = sum
+	}
+	return checksums, scanner.Err()
+}
+
+// Install downloads r from mirror next to exe, checks its checksum and
+// replaces exe with it by renaming, so exe is either the old or the new
+// binary at any time
+func Install(mirror string, r Release, exe string) error {
+	resp, err := httpClient.Get(join(mirror, r.Version, r.Asset))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", resp.Request.URL, resp.Status)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(exe), "."+filepath.Base(exe)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), resp.Body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != r.Checksum {
+		return fmt.Errorf("the checksum of %s is %s, expected %s", r.Asset, sum, r.Checksum)
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+
+	// a running binary can not be replaced on windows, only moved away
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), exe); err != nil {
+			os.Rename(old, exe)
+			return err
+		}
+		return nil
+	}
+	return os.Rename(tmp.Name(), exe)
+}
+
+// fetch returns the body of url, at most limit bytes
+func fetch(url string, limit int64) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, fmt.Errorf("%s is larger than %d bytes", url, limit)
+	}
+	return b, nil
+}
+
+// join joins the parts of a URL with slashes
+func join(parts ...string) string {
+	for i, p := range parts {
+		parts[i] = strings.Trim(p, "/")
+	}
+	return strings.Join(parts, "/")
+}
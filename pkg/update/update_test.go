@@ -0,0 +1,174 @@
+package update
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestSuiteParseChecksums(tt *testing.T) {
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte("binary")))
+	var (
+		tests = []struct {
+			description string
+			input       string
+			normOutput  string
+		}{
+			{"Text mode", sum + "  vault-tools-linux-amd64\n", "map[vault-tools-linux-amd64:" + sum + "]"},
+			{"Binary mode", sum + " *vault-tools-windows-amd64.exe\n\n", "map[vault-tools-windows-amd64.exe:" + sum + "]"},
+			{"Empty", "", "map[]"},
+			{"No name", sum + "\n", "error"},
+			{"Not a checksum", "abc  vault-tools-linux-amd64\n", "error"},
+		}
+	)
+	for _, test := range tests {
+		checksums, err := ParseChecksums([]byte(test.input))
+		norm := fmt.Sprintf("%v", checksums)
+		if err != nil {
+			norm = "error"
+		}
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteUpdate(tt *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		tt.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(public)
+	_, other, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		tt.Fatal(err)
+	}
+	sign := func(k ed25519.PrivateKey, data string) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(k, []byte(data)))
+	}
+
+	asset := AssetName("linux", "amd64")
+	// checksums lists the binary and the VERSION file of a release
+	checksums := func(binary, version string) string {
+		return fmt.Sprintf("%x  %s\n%x  %s\n", sha256.Sum256([]byte(binary)), asset, sha256.Sum256([]byte(version+"\n")), VersionFile)
+	}
+	sums := checksums("v2", "v2")
+	files := map[string]string{
+		"/latest":                  "v2\n",
+		"/v2/" + asset:             "v2",
+		"/v2/VERSION":              "v2\n",
+		"/v2/SHA256SUMS":           sums,
+		"/v2/SHA256SUMS.sig":       sign(private, sums),
+		"/other/" + asset:          "v2",
+		"/other/VERSION":           "other\n",
+		"/other/SHA256SUMS":        checksums("v2", "other"),
+		"/other/SHA256SUMS.sig":    sign(other, checksums("v2", "other")),
+		"/tampered/" + asset:       "tampered",
+		"/tampered/VERSION":        "tampered\n",
+		"/tampered/SHA256SUMS":     checksums("v2", "tampered"),
+		"/tampered/SHA256SUMS.sig": sign(private, checksums("v2", "tampered")),
+		"/unsigned/" + asset:       "v2",
+		"/unsigned/VERSION":        "unsigned\n",
+		"/unsigned/SHA256SUMS":     checksums("v2", "unsigned"),
+		"/v3/SHA256SUMS":           "",
+		"/v3/SHA256SUMS.sig":       sign(private, ""),
+		"/v4/" + asset:             "v2",
+		"/v4/VERSION":              "v2\n",
+		"/v4/SHA256SUMS":           sums,
+		"/v4/SHA256SUMS.sig":       sign(private, sums),
+		"/v5/" + asset:             "v2",
+		"/v5/VERSION":              "v6\n",
+		"/v5/SHA256SUMS":           checksums("v2", "v5"),
+		"/v5/SHA256SUMS.sig":       sign(private, checksums("v2", "v5")),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+	defer server.Close()
+
+	latest, err := Latest(server.URL + "/")
+	if err != nil || latest != "v2" {
+		tt.Fatalf("FAIL Latest: expected 'v2' got '%s' %v", latest, err)
+	}
+
+	dir := tt.TempDir()
+	var (
+		tests = []struct {
+			description string
+			version     string
+			normOutput  string
+		}{
+			{"Signed release", "v2", "v2"},
+			{"Signed by another key", "other", "error"},
+			{"Not signed", "unsigned", "error"},
+			{"Changed after signing", "tampered", "old"},
+			{"No binary for the platform", "v3", "error"},
+			{"Signed files of another version", "v4", "error"},
+			{"Version changed after signing", "v5", "error"},
+		}
+	)
+	for i, test := range tests {
+		exe := filepath.Join(dir, fmt.Sprintf("vault-tools-%d", i))
+		if err := ioutil.WriteFile(exe, []byte("old"), 0755); err != nil {
+			tt.Fatal(err)
+		}
+		norm := "error"
+		if r, err := Find(server.URL, test.version, asset, key); err == nil {
+			// a failed install leaves the old binary in place
+			Install(server.URL, r, exe)
+			b, err := ioutil.ReadFile(exe)
+			if err != nil {
+				tt.Fatal(err)
+			}
+			norm = string(b)
+		}
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+	if left, _ := filepath.Glob(filepath.Join(dir, ".*")); len(left) != 0 {
+		tt.Errorf("FAIL Temporary files: expected none got %v", left)
+	}
+}
+
+func TestSuiteOlder(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			a           string
+			b           string
+			normOutput  bool
+		}{
+			{"Older patch", "v1.4.9", "v1.5.0", true},
+			{"Older minor", "v1.4.10", "v1.10.0", true},
+			{"Same", "v1.5.0", "v1.5.0", false},
+			{"Newer", "v2.0.0", "v1.5.0", false},
+			{"Described", "v1.4.0", "v1.4.0-3-gabc1234-dirty", false},
+			{"Older than described", "v1.3.0", "v1.4.0-3-gabc1234", true},
+			{"Dev build", "v1.5.0", "dev", false},
+			{"Not a version", "latest", "v1.5.0", false},
+		}
+	)
+	for _, test := range tests {
+		if norm := Older(test.a, test.b); norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%t' got '%t'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package usage
+
+// usage counts the requests a run sends to Vault and AWS and estimates what
+// the AWS requests cost
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Services as named in Counts, the AWS ones are the service IDs of the SDK
+const (
+	Vault       = "Vault"
+	KMS         = "KMS"
+	S3          = "S3"
+	CloudWatch  = "CloudWatch"
+	EventBridge = "EventBridge"
+)
+
+// Request is a kind of request, an operation of a service. The operations of
+// Vault are HTTP methods, so paths never reach the counts.
+type Request struct {
+	Service   string
+	Operation string
+}
+
+// Counts are the number of requests of each kind
+type Counts map[Request]int64
+
+// Counter counts requests, it is safe for concurrent use and a nil Counter
+// counts nothing
+type Counter struct {
+	mu     sync.Mutex
+	counts Counts
+}
+
+// Add counts a request of operation to service
+func (c *Counter) Add(service, operation string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = Counts{}
+	}
+	c.counts[Request{service, operation}]++
+}
+
+// Counts returns a copy of the counts so far
+func (c *Counter) Counts() Counts {
+	counts := Counts{}
+	if c == nil {
+		return counts
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for r, n := range c.counts {
+		counts[r] = n
+	}
+	return counts
+}
+
+// Transport wraps next so that every request sent through it is counted as
+// a request to Vault
+func (c *Counter) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		c.Add(Vault, req.Method)
+		return next.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Since returns the requests counted since before was taken
+func (c Counts) Since(before Counts) Counts {
+	counts := Counts{}
+	for r, n := range c {
+		if d := n - before[r]; d > 0 {
+			counts[r] = d
+		}
+	}
+	return counts
+}
+
+// Service returns the number of requests to service
+func (c Counts) Service(service string) int64 {
+	var n int64
+	for r, count := range c {
+		if r.Service == service {
+			n += count
+		}
+	}
+	return n
+}
+
+// Prices in USD per request, the us-east-1 list prices of requests. Storage,
+// data transfer, retrievals from archive classes and keys are not included.
+var (
+	// PriceKMS is the price of any KMS request
+	PriceKMS = 0.03 / 10000
+	// PriceS3Write is the price of S3 PUT, COPY, POST and LIST requests
+	PriceS3Write = 0.005 / 1000
+	// PriceS3Read is the price of S3 GET and every other request
+	PriceS3Read = 0.0004 / 1000
+	// PriceCloudWatch is the price of publishing metrics
+	PriceCloudWatch = 0.01 / 1000
+	// PriceEventBridge is the price of publishing an event
+	PriceEventBridge = 1.0 / 1000000
+)
+
+// s3Writes are the S3 operations charged as PUT, COPY, POST or LIST
+var s3Writes = map[string]bool{
+	"PutObject": true, "CopyObject": true, "PostObject": true,
+	"CreateMultipartUpload": true, "UploadPart": true, "UploadPartCopy": true, "CompleteMultipartUpload": true,
+	"ListObjects": true, "ListObjectsV2": true, "ListObjectVersions": true, "ListBuckets": true,
+	"ListMultipartUploads": true, "ListParts": true, "RestoreObject": true,
+}
+
+// Price returns the estimated price of a request in USD, requests of
+// services not charged per request, like Vault and STS, are free
+func (r Request) Price() float64 {
+	switch r.Service {
+	case KMS:
+		return PriceKMS
+	case S3:
+		switch {
+		case r.Operation == "DeleteObject" || r.Operation == "DeleteObjects":
+			return 0
+		case s3Writes[r.Operation]:
+			return PriceS3Write
+		}
+		return PriceS3Read
+	case CloudWatch:
+		return PriceCloudWatch
+	case EventBridge:
+		return PriceEventBridge
+	}
+	return 0
+}
+
+// Cost returns the estimated cost of the requests in USD
+func (c Counts) Cost() float64 {
+	var cost float64
+	for r, n := range c {
+		cost += float64(n) * r.Price()
+	}
+	return cost
+}
+
+// String summarizes the requests by service, e.g.
+// "Vault=120 KMS=2 S3=3 cost=$0.000021"
+func (c Counts) String() string {
+	services := map[string]int64{}
+	for r, n := range c {
+		services[r.Service] += n
+	}
+	names := make([]string, 0, len(services))
+	for s := range services {
+		names = append(names, s)
+	}
+	// Vault first, then the AWS services
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == Vault) != (names[j] == Vault) {
+			return names[i] == Vault
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, 0, len(names)+1)
+	for _, s := range names {
+		parts = append(parts, fmt.Sprintf("%s=%d", s, services[s]))
+	}
+	parts = append(parts, fmt.Sprintf("cost=$%.6f", c.Cost()))
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,75 @@
+package usage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSuiteCounts(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			before      []Request
+			requests    []Request
+			normOutput  string
+		}{
+			{"Nothing", nil, nil, "cost=$0.000000"},
+			{"Vault is free", nil, []Request{{Vault, "GET"}, {Vault, "LIST"}}, "Vault=2 cost=$0.000000"},
+			{"KMS", nil, []Request{{KMS, "Encrypt"}, {KMS, "GenerateDataKey"}}, "KMS=2 cost=$0.000006"},
+			{"S3 writes and reads", nil, []Request{{S3, "PutObject"}, {S3, "ListObjectsV2"}, {S3, "GetObject"}, {S3, "HeadObject"}}, "S3=4 cost=$0.000011"},
+			{"S3 deletes are free", nil, []Request{{S3, "DeleteObject"}}, "S3=1 cost=$0.000000"},
+			{"Monitoring", nil, []Request{{CloudWatch, "PutMetricData"}, {EventBridge, "PutEvents"}}, "CloudWatch=1 EventBridge=1 cost=$0.000011"},
+			{"STS is free", nil, []Request{{"STS", "AssumeRole"}}, "STS=1 cost=$0.000000"},
+			{"Vault first", nil, []Request{{S3, "GetObject"}, {Vault, "GET"}, {KMS, "Decrypt"}}, "Vault=1 KMS=1 S3=1 cost=$0.000003"},
+			{"Since", []Request{{Vault, "GET"}, {S3, "PutObject"}}, []Request{{Vault, "GET"}, {Vault, "GET"}}, "Vault=2 cost=$0.000000"},
+		}
+	)
+	for _, test := range tests {
+		c := &Counter{}
+		for _, r := range test.before {
+			c.Add(r.Service, r.Operation)
+		}
+		before := c.Counts()
+		for _, r := range test.requests {
+			c.Add(r.Service, r.Operation)
+		}
+		norm := c.Counts().Since(before).String()
+
+		if norm == test.normOutput {
+			tt.Logf("PASS %s", test.description)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		}
+	}
+}
+
+func TestSuiteTransport(tt *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	c := &Counter{}
+	client := &http.Client{Transport: c.Transport(nil)}
+	for _, method := range []string{http.MethodGet, "LIST", http.MethodGet} {
+		req, _ := http.NewRequest(method, server.URL+"/v1/secret/data/a", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			tt.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	counts := c.Counts()
+	if counts[Request{Vault, "GET"}] == 2 && counts[Request{Vault, "LIST"}] == 1 && len(counts) == 2 {
+		tt.Logf("PASS Transport")
+	} else {
+		tt.Errorf("FAIL Transport: got %v", counts)
+	}
+
+	var nilCounter *Counter
+	nilCounter.Add(Vault, "GET")
+	if len(nilCounter.Counts()) == 0 {
+		tt.Logf("PASS Nil counter")
+	} else {
+		tt.Errorf("FAIL Nil counter")
+	}
+}
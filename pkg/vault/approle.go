@@ -0,0 +1,101 @@
+package vault
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// AppRole logs in with a role ID and a secret ID, given as is or wrapped in a
+// response wrapping token as recommended for secure introduction
+type AppRole struct {
+	// Mount is the path of the AppRole auth method, approle by default
+	Mount    string
+	RoleID   string
+	SecretID string
+	// WrappedSecretID is a wrapping token holding the secret ID, it is
+	// unwrapped on the first login
+	WrappedSecretID string
+
+	mu sync.Mutex
+}
+
+// Login returns a token for the role, the wrapped secret ID is only unwrapped
+// once so later logins of --watch reuse it
+func (a *AppRole) Login(client *vaultapi.Client) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	mount := strings.Trim(a.Mount, "/")
+	if mount == "" {
+		mount = "approle"
+	}
+	if a.RoleID == "" {
+		return "", fmt.Errorf("an AppRole role ID is required")
+	}
+
+	// the client's token must not be sent along or replaced by the wrapping
+	// token
+	clone, err := client.Clone()
+	if err != nil {
+		return "", err
+	}
+	clone.ClearToken()
+
+	if a.SecretID == "" && a.WrappedSecretID != "" {
+		secretID, err := unwrapSecretID(clone, mount, a.WrappedSecretID)
+		if err != nil {
+			return "", err
+		}
+		a.SecretID, a.WrappedSecretID = secretID, ""
+	}
+	if a.SecretID == "" {
+		return "", fmt.Errorf("an AppRole secret ID or wrapped secret ID is required")
+	}
+
+	secret, err := clone.Logical().Write("auth/"+mount+"/login", map[string]interface{}{
+		"role_id":   a.RoleID,
+		"secret_id": a.SecretID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("AppRole login failed: %w", err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return "", fmt.Errorf("AppRole login returned no token")
+	}
+	return secret.Auth.ClientToken, nil
+}
+
+// unwrapSecretID checks that the wrapping token was created by the secret ID
+// endpoint of mount before unwrapping it, so a token wrapping anything else,
+// or one already unwrapped by someone else, is refused
+func unwrapSecretID(client *vaultapi.Client, mount, wrapped string) (string, error) {
+	lookup, err := client.Logical().Write("sys/wrapping/lookup", map[string]interface{}{"token": wrapped})
+	if err != nil {
+		return "", fmt.Errorf("invalid wrapped secret ID, it may have expired or been unwrapped already: %w", err)
+	}
+	if lookup == nil {
+		return "", fmt.Errorf("invalid wrapped secret ID")
+	}
+	path, _ := lookup.Data["creation_path"].(string)
+	if !strings.HasPrefix(path, "auth/"+mount+"/role/") || !strings.HasSuffix(path, "/secret-id") {
+		return "", fmt.Errorf("wrapped secret ID was created by %q, not by the secret ID endpoint of auth/%s", path, mount)
+	}
+
+	secret, err := client.Logical().Unwrap(wrapped)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap the secret ID: %w", err)
+	}
+	if secret == nil {
+		return "", fmt.Errorf("failed to unwrap the secret ID: empty response")
+	}
+	secretID, _ := secret.Data["secret_id"].(string)
+	if secretID == "" {
+		return "", fmt.Errorf("failed to unwrap the secret ID: no secret_id in the response")
+	}
+	log.Println("Unwrapped the AppRole secret ID")
+	return secretID, nil
+}
@@ -0,0 +1,70 @@
+package vault
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// AWS logs in through the AWS auth method with a signed
+// sts:GetCallerIdentity request, so jobs on EC2, ECS or Lambda need no Vault
+// token
+type AWS struct {
+	// Mount is the path of the AWS auth method, aws by default
+	Mount string
+	Role  string
+	// ServerID is signed along as the X-Vault-AWS-IAM-Server-ID header when
+	// set
+	ServerID string
+	// Sign returns a signed sts:GetCallerIdentity request and its body, it is
+	// called on every login as the request expires
+	Sign func(serverID string) (*http.Request, []byte, error)
+}
+
+// Login returns a token for the role of the signing identity
+func (a *AWS) Login(client *vaultapi.Client) (string, error) {
+	mount := strings.Trim(a.Mount, "/")
+	if mount == "" {
+		mount = "aws"
+	}
+	if a.Role == "" {
+		return "", fmt.Errorf("an AWS auth role is required")
+	}
+	if a.Sign == nil {
+		return "", fmt.Errorf("no signer for the AWS auth method")
+	}
+	req, body, err := a.Sign(a.ServerID)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign the AWS login request: %w", err)
+	}
+	headers, err := json.Marshal(req.Header)
+	if err != nil {
+		return "", err
+	}
+
+	// the client's token must not be sent along
+	clone, err := client.Clone()
+	if err != nil {
+		return "", err
+	}
+	clone.ClearToken()
+
+	secret, err := clone.Logical().Write("auth/"+mount+"/login", map[string]interface{}{
+		"role":                    a.Role,
+		"iam_http_request_method": req.Method,
+		"iam_request_url":         base64.StdEncoding.EncodeToString([]byte(req.URL.String())),
+		"iam_request_body":        base64.StdEncoding.EncodeToString(body),
+		"iam_request_headers":     base64.StdEncoding.EncodeToString(headers),
+	})
+	if err != nil {
+		return "", fmt.Errorf("AWS login failed: %w", err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return "", fmt.Errorf("AWS login returned no token")
+	}
+	return secret.Auth.ClientToken, nil
+}
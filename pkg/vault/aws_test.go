@@ -0,0 +1,81 @@
+package vault
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+func TestSuiteAWSLogin(tt *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		decoded := map[string]string{}
+		for _, k := range []string{"iam_request_url", "iam_request_body", "iam_request_headers"} {
+			b, _ := base64.StdEncoding.DecodeString(body[k])
+			decoded[k] = string(b)
+		}
+		var headers http.Header
+		json.Unmarshal([]byte(decoded["iam_request_headers"]), &headers)
+		if r.Header.Get("X-Vault-Token") != "" || body["role"] != "dumper" || body["iam_http_request_method"] != http.MethodPost ||
+			decoded["iam_request_url"] != "https://sts.amazonaws.com/" || decoded["iam_request_body"] != "Action=GetCallerIdentity" ||
+			headers.Get("Authorization") != "signed" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"auth": {"client_token": "%s%s"}}`, r.URL.Path, headers.Get("X-Vault-AWS-IAM-Server-ID"))
+	}))
+	defer server.Close()
+
+	client, err := vaultapi.NewClient(&vaultapi.Config{Address: server.URL})
+	if err != nil {
+		tt.Fatal(err)
+	}
+	client.SetToken("stale")
+
+	sign := func(serverID string) (*http.Request, []byte, error) {
+		req, _ := http.NewRequest(http.MethodPost, "https://sts.amazonaws.com/", strings.NewReader("Action=GetCallerIdentity"))
+		req.Header.Set("Authorization", "signed")
+		if serverID != "" {
+			req.Header.Set("X-Vault-AWS-IAM-Server-ID", serverID)
+		}
+		return req, []byte("Action=GetCallerIdentity"), nil
+	}
+	failing := func(string) (*http.Request, []byte, error) {
+		return nil, nil, errors.New("no credentials")
+	}
+
+	var (
+		success bool
+		tests   = []struct {
+			description string
+			login       *AWS
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Default mount", &AWS{Role: "dumper", Sign: sign}, "/v1/auth/aws/login", true},
+			{"Server ID", &AWS{Mount: "aws-prod/", Role: "dumper", ServerID: "vault.example.com", Sign: sign}, "/v1/auth/aws-prod/loginvault.example.com", true},
+			{"Rejected role", &AWS{Role: "other", Sign: sign}, "", false},
+			{"No role", &AWS{Sign: sign}, "", false},
+			{"No credentials", &AWS{Role: "dumper", Sign: failing}, "", false},
+		}
+	)
+	for _, test := range tests {
+		token, err := test.login.Login(client)
+		success = (err == nil)
+		if success == test.isSuccess && token == test.normOutput && client.Token() == "stale" {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t, %v", test.description, test.isSuccess, success, err)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, token)
+		}
+	}
+}
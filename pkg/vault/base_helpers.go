@@ -5,7 +5,10 @@ package vault
 // making this easier to use
 
 import (
+	"fmt"
+	"net/url"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/hashicorp/vault/api"
 )
@@ -69,3 +72,84 @@ func ExtractListData(secret *api.Secret) ([]interface{}, bool) {
 func SanitizePath(s string) string {
 	return EnsureNoTrailingSlash(EnsureNoLeadingSlash(strings.TrimSpace(s)))
 }
+
+// NormalizePath sanitizes a path and collapses repeated slashes, so that
+// differently written forms of the same path compare equal.
+func NormalizePath(s string) string {
+	s = strings.TrimSpace(s)
+	for strings.Contains(s, "//") {
+		s = strings.ReplaceAll(s, "//", "/")
+	}
+	return SanitizePath(s)
+}
+
+// escapeRunes percent-encodes '%', control characters, invalid UTF-8 and any
+// rune in extra. Valid unicode is kept as is, without any normalization, so
+// the result round-trips byte for byte.
+func escapeRunes(s, extra string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if (r == utf8.RuneError && size == 1) || r == '%' || r < 0x20 || r == 0x7f || strings.ContainsRune(extra, r) {
+			for j := 0; j < size; j++ {
+				fmt.Fprintf(&b, "%%%02X", s[i+j])
+			}
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// EscapePathSegment percent-encodes the characters of a single path segment
+// that do not survive a dump unchanged: '%', '/', control characters, invalid
+// UTF-8 and the dot segments.
+func EscapePathSegment(s string) string {
+	if s == "." || s == ".." {
+		return strings.Repeat("%2E", len(s))
+	}
+	return escapeRunes(s, "/")
+}
+
+// EscapePath escapes every segment of a path with EscapePathSegment. A
+// leading '$' is escaped as well, top level keys starting with '$' are
+// reserved for the dump format.
+func EscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = EscapePathSegment(s)
+	}
+	if strings.HasPrefix(segments[0], "$") {
+		segments[0] = "%24" + segments[0][1:]
+	}
+	return strings.Join(segments, "/")
+}
+
+// UnescapePath reverses EscapePath.
+func UnescapePath(p string) (string, error) {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		u, err := url.PathUnescape(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid escaped path %q: %w", p, err)
+		}
+		segments[i] = u
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// EscapeKey percent-encodes the characters of a secret's field name that do
+// not survive a dump unchanged: '%', control characters and invalid UTF-8.
+func EscapeKey(k string) string {
+	return escapeRunes(k, "")
+}
+
+// UnescapeKey reverses EscapeKey.
+func UnescapeKey(k string) (string, error) {
+	u, err := url.PathUnescape(k)
+	if err != nil {
+		return "", fmt.Errorf("invalid escaped key %q: %w", k, err)
+	}
+	return u, nil
+}
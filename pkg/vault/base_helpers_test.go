@@ -0,0 +1,117 @@
+package vault
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+	"unicode/utf8"
+)
+
+func TestSuiteEscapePath(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			action      string
+			input       string
+			normOutput  string
+		}{
+			{"Plain path", "Path", "secret/foo/bar", "secret/foo/bar"},
+			{"Unicode path", "Path", "secret/café/日本", "secret/café/日本"},
+			{"Percent in segment", "Path", "secret/a%41", "secret/a%2541"},
+			{"Dot segments", "Path", "secret/./..", "secret/%2E/%2E%2E"},
+			{"Control characters", "Path", "secret/a\tb", "secret/a%09b"},
+			{"Invalid UTF-8", "Path", "secret/\xff", "secret/%FF"},
+			{"Reserved top level name", "Path", "$manifest", "%24manifest"},
+			{"Dollar below top level", "Path", "secret/$manifest", "secret/$manifest"},
+			{"Plain key", "Key", "password", "password"},
+			{"Key with slash and dots", "Key", "a/b..", "a/b.."},
+			{"Key with percent", "Key", "a%41", "a%2541"},
+			{"Key with invalid UTF-8", "Key", "k\xff\n", "k%FF%0A"},
+			{"Normalize plain path", "Normalize", "secret/foo", "secret/foo"},
+			{"Normalize slashes", "Normalize", " /secret//foo///bar/ ", "secret/foo/bar"},
+		}
+	)
+	for _, test := range tests {
+		var (
+			norm string
+			back string
+			err  error
+		)
+		switch test.action {
+		case "Path":
+			norm = EscapePath(test.input)
+			back, err = UnescapePath(norm)
+		case "Key":
+			norm = EscapeKey(test.input)
+			back, err = UnescapeKey(norm)
+		case "Normalize":
+			norm = NormalizePath(test.input)
+			back = test.input
+		}
+		if err != nil {
+			tt.Errorf("FAIL %s: %v", test.description, err)
+		} else if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else if back != test.input {
+			tt.Errorf("FAIL %s: round trip expected '%s' got '%s'", test.description, test.input, back)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+// fuzzPath is a path built from the bytes the escaping scheme exists for
+type fuzzPath string
+
+// fuzzBytes mixes separators, escape characters, dots, control characters,
+// bytes of multi-byte runes and bytes which are never valid UTF-8
+var fuzzBytes = []byte{'/', '/', '%', '%', '.', '.', '$', 'a', '2', 'E', 'F', 0x00, '\t', '\n', 0x7f, 0xc3, 0xa9, 0xe6, 0x97, 0xa5, 0xff, 0xfe, 0x80}
+
+func (fuzzPath) Generate(r *rand.Rand, size int) reflect.Value {
+	b := make([]byte, r.Intn(size+1))
+	for i := range b {
+		b[i] = fuzzBytes[r.Intn(len(fuzzBytes))]
+	}
+	return reflect.ValueOf(fuzzPath(b))
+}
+
+func TestFuzzEscapePath(tt *testing.T) {
+	roundTrip := func(fp fuzzPath) bool {
+		p := string(fp)
+		escaped := EscapePath(p)
+		if !utf8.ValidString(escaped) || strings.HasPrefix(escaped, "$") {
+			return false
+		}
+		segments := strings.Split(escaped, "/")
+		if len(segments) != len(strings.Split(p, "/")) {
+			return false
+		}
+		for _, s := range segments {
+			if s == "." || s == ".." || strings.IndexFunc(s, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0 {
+				return false
+			}
+		}
+		back, err := UnescapePath(escaped)
+		return err == nil && back == p
+	}
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 20000}); err != nil {
+		tt.Error(err)
+	}
+}
+
+func TestFuzzEscapeKey(tt *testing.T) {
+	roundTrip := func(fp fuzzPath) bool {
+		k := string(fp)
+		escaped := EscapeKey(k)
+		if !utf8.ValidString(escaped) {
+			return false
+		}
+		back, err := UnescapeKey(escaped)
+		return err == nil && back == k
+	}
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 20000}); err != nil {
+		tt.Error(err)
+	}
+}
@@ -0,0 +1,75 @@
+package vault
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ContextTransport wraps next so that requests fail once ctx is done, those
+// in flight are cancelled and those sent after are never sent. The vault api
+// client reads without a context, so this is how a dump is stopped.
+func ContextTransport(next http.RoundTripper, ctx context.Context) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &cancelling{next: next, ctx: ctx}
+}
+
+// cancelled reports whether the context of vc is done, failed writes are not
+// retried then, not even with Brute
+func (vc *Config) cancelled() bool {
+	return vc.Context != nil && vc.Context.Err() != nil
+}
+
+type cancelling struct {
+	next http.RoundTripper
+	ctx  context.Context
+}
+
+func (t *cancelling) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.ctx.Err(); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	done := make(chan struct{})
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			close(done)
+			cancel()
+		})
+	}
+	go func() {
+		select {
+		case <-t.ctx.Done():
+			cancel()
+		case <-done:
+		}
+	}()
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		release()
+		return nil, err
+	}
+	// the request stays cancellable until its body is read and closed
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
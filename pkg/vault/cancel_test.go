@@ -0,0 +1,107 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSuiteContextTransport(tt *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/slow" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+		w.Write([]byte(`{"data": {}}`))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := &http.Client{Transport: ContextTransport(nil, ctx)}
+	get := func(path string) error {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, err = ioutil.ReadAll(resp.Body)
+		return err
+	}
+
+	var (
+		tests = []struct {
+			description string
+			path        string
+			cancelAfter time.Duration
+			normOutput  string
+		}{
+			{"Not cancelled", "/v1/fast", 0, "<nil>"},
+			{"Cancelled in flight", "/v1/slow", 50 * time.Millisecond, "context canceled"},
+			{"Sent after", "/v1/fast", 0, "context canceled"},
+		}
+	)
+	for _, test := range tests {
+		if test.cancelAfter > 0 {
+			time.AfterFunc(test.cancelAfter, cancel)
+		}
+		err := get(test.path)
+		norm := "<nil>"
+		if errors.Is(err, context.Canceled) {
+			norm = context.Canceled.Error()
+		} else if err != nil {
+			norm = err.Error()
+		}
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteCancelledWrites(tt *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors": ["denied"]}`))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	vc, err := NewClient(&Config{Address: server.URL, Token: "t", Brute: true, Context: ctx})
+	if err != nil {
+		tt.Fatal(err)
+	}
+	var (
+		tests = []struct {
+			description string
+			write       func() error
+		}{
+			{"Secret", func() error { return vc.OverwriteSecret("secret/a", map[string]interface{}{"k": "v"}) }},
+			{"Policy", func() error { return vc.OverwritePolicy("dump", `path "secret/*" {}`) }},
+		}
+	)
+	for _, test := range tests {
+		done := make(chan error, 1)
+		go func() { done <- test.write() }()
+		select {
+		case err := <-done:
+			if err == nil {
+				tt.Errorf("FAIL %s: expected an error got none", test.description)
+			} else {
+				tt.Logf("PASS %s", test.description)
+			}
+		case <-time.After(5 * time.Second):
+			tt.Fatalf("FAIL %s: still retrying after the context is done", test.description)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// Categories of failed Vault requests as reported in logs and failure reports
+const (
+	CategoryPermissionDenied = "permission-denied"
+	CategorySentinelDenied   = "sentinel-denied"
+	CategoryNotFound         = "not-found"
+	CategorySoftDeleted      = "soft-deleted"
+	CategoryDestroyed        = "destroyed"
+	CategoryConsistency      = "consistency"
+	CategoryWrapped          = "wrapped"
+	CategoryFiltered         = "filtered"
+	CategoryOther            = "other"
+)
+
+// statusCode matches the status code in errors that were wrapped as text
+var statusCode = regexp.MustCompile(`Code: (\d{3})`)
+
+// ClassifyError returns the category of an error returned by Vault, so a
+// permissions problem can be told apart from a missing secret
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	code := 0
+	msg := err.Error()
+	var respErr *api.ResponseError
+	if errors.As(err, &respErr) {
+		code = respErr.StatusCode
+		msg = strings.Join(respErr.Errors, "; ")
+	} else if m := statusCode.FindStringSubmatch(msg); m != nil {
+		code, _ = strconv.Atoi(m[1])
+	}
+
+	switch code {
+	case 403:
+		// Sentinel denials name the EGP or RGP policy that failed
+		lower := strings.ToLower(msg)
+		for _, s := range []string{"sentinel", "egp", "rgp"} {
+			if strings.Contains(lower, s) {
+				return CategorySentinelDenied
+			}
+		}
+		return CategoryPermissionDenied
+	case 404:
+		return CategoryNotFound
+	case 412:
+		return CategoryConsistency
+	}
+	return CategoryOther
+}
+
+// ClassifyRead returns the category and reason of a read that did not return
+// a usable secret, including reads that succeeded without returning the value
+// such as wrapped responses, filtered data and deleted KV v2 versions. It
+// returns an empty category for a usable secret or one that does not exist.
+func ClassifyRead(secret *api.Secret, err error) (string, string) {
+	if err != nil {
+		return ClassifyError(err), err.Error()
+	}
+	if secret == nil {
+		return "", ""
+	}
+	if secret.WrapInfo != nil {
+		return CategoryWrapped, fmt.Sprintf("response is wrapped (accessor %s), the value must be unwrapped", secret.WrapInfo.Accessor)
+	}
+
+	// KV v2 answers a read of a deleted or destroyed version with its
+	// metadata and no data
+	if state, ok := DeletedVersion(secret); ok {
+		if state.Destroyed {
+			return CategoryDestroyed, fmt.Sprintf("version %d was destroyed", state.Version)
+		}
+		return CategorySoftDeleted, fmt.Sprintf("version %d was deleted at %s", state.Version, state.DeletionTime)
+	}
+
+	data := secret.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		data = inner
+	}
+	if len(data) == 0 && len(secret.Warnings) > 0 {
+		return CategoryFiltered, "no data returned: " + strings.Join(secret.Warnings, "; ")
+	}
+	return "", ""
+}
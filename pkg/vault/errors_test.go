@@ -0,0 +1,42 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+)
+
+func TestSuiteClassify(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			secret      *api.Secret
+			err         error
+			normOutput  string
+		}{
+			{"Policy denial", nil, &api.ResponseError{StatusCode: 403, Errors: []string{"permission denied"}}, CategoryPermissionDenied},
+			{"Sentinel denial", nil, &api.ResponseError{StatusCode: 403, Errors: []string{`egp standard policy "root/mfa" evaluation resulted in denial`}}, CategorySentinelDenied},
+			{"Wrapped denial", nil, fmt.Errorf("retries exhausted: %w", &api.ResponseError{StatusCode: 403}), CategoryPermissionDenied},
+			{"Denial as text", nil, errors.New("Error making API request.\n\nCode: 403. Errors:\n\n* permission denied"), CategoryPermissionDenied},
+			{"Not found", nil, &api.ResponseError{StatusCode: 404}, CategoryNotFound},
+			{"Consistency", nil, &api.ResponseError{StatusCode: 412}, CategoryConsistency},
+			{"Server error", nil, &api.ResponseError{StatusCode: 500}, CategoryOther},
+			{"Missing secret", nil, nil, ""},
+			{"Secret", &api.Secret{Data: map[string]interface{}{"data": map[string]interface{}{"k": "v"}}}, nil, ""},
+			{"Wrapped response", &api.Secret{WrapInfo: &api.SecretWrapInfo{Accessor: "a"}}, nil, CategoryWrapped},
+			{"Filtered data", &api.Secret{Data: map[string]interface{}{}, Warnings: []string{"filtered"}}, nil, CategoryFiltered},
+			{"Deleted version", &api.Secret{Data: map[string]interface{}{"data": nil, "metadata": map[string]interface{}{"deletion_time": "2020-01-01T00:00:00Z", "version": 2}}}, nil, CategorySoftDeleted},
+			{"Destroyed version", &api.Secret{Data: map[string]interface{}{"data": nil, "metadata": map[string]interface{}{"destroyed": true, "version": 2}}}, nil, CategoryDestroyed},
+		}
+	)
+	for _, test := range tests {
+		norm, _ := ClassifyRead(test.secret, test.err)
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
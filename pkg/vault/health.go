@@ -0,0 +1,47 @@
+package vault
+
+import "time"
+
+// Health is the state of the cluster the client talks to
+type Health struct {
+	Cluster     Cluster
+	Version     string
+	Initialized bool
+	Sealed      bool
+	Standby     bool
+	// Skew is how far the clock of the Vault server is ahead of the local
+	// clock, to the second
+	Skew time.Duration
+}
+
+// Health reads the health of the cluster, which needs no token
+func (vc *Config) Health() (*Health, error) {
+	before := time.Now()
+	h, err := vc.Client.Sys().Health()
+	if err != nil {
+		return nil, err
+	}
+	return &Health{
+		Cluster:     Cluster{ID: h.ClusterID, Name: h.ClusterName},
+		Version:     h.Version,
+		Initialized: h.Initialized,
+		Sealed:      h.Sealed,
+		Standby:     h.Standby,
+		Skew:        clockSkew(h.ServerTimeUTC, before, time.Now()),
+	}, nil
+}
+
+// clockSkew returns how far server, a unix time read between before and
+// after, is ahead of the local clock. The server time is in seconds, less
+// than a second of skew can not be told apart from none.
+func clockSkew(server int64, before, after time.Time) time.Duration {
+	if server == 0 {
+		return 0
+	}
+	local := before.Add(after.Sub(before) / 2)
+	skew := time.Unix(server, 0).Sub(local)
+	if skew > -time.Second && skew < time.Second {
+		return 0
+	}
+	return skew.Round(time.Second)
+}
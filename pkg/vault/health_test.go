@@ -0,0 +1,34 @@
+package vault
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSuiteClockSkew(tt *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	var (
+		tests = []struct {
+			description string
+			server      int64
+			before      time.Time
+			after       time.Time
+			normOutput  string
+		}{
+			{"In sync", now.Unix(), now, now, "0s"},
+			{"Within the resolution", now.Unix(), now.Add(-400 * time.Millisecond), now.Add(600 * time.Millisecond), "0s"},
+			{"Server ahead", now.Add(90 * time.Second).Unix(), now, now, "1m30s"},
+			{"Server behind", now.Add(-5 * time.Minute).Unix(), now, now, "-5m0s"},
+			{"Slow request", now.Unix(), now.Add(-4 * time.Second), now, "2s"},
+			{"No server time", 0, now, now, "0s"},
+		}
+	)
+	for _, test := range tests {
+		norm := clockSkew(test.server, test.before, test.after).String()
+		if norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package vault
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// ServiceAccountTokenFile is where Kubernetes mounts the service account
+// token of a pod
+const ServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Kubernetes logs in with the service account token of the pod through the
+// kubernetes auth method, for running in a CronJob without a pre-issued token
+type Kubernetes struct {
+	// Mount is the path of the kubernetes auth method, kubernetes by default
+	Mount string
+	Role  string
+	// TokenFile holds the service account token, ServiceAccountTokenFile by
+	// default
+	TokenFile string
+}
+
+// Login returns a token for the role, the service account token is read on
+// every login as projected tokens are rotated
+func (k *Kubernetes) Login(client *vaultapi.Client) (string, error) {
+	mount := strings.Trim(k.Mount, "/")
+	if mount == "" {
+		mount = "kubernetes"
+	}
+	if k.Role == "" {
+		return "", fmt.Errorf("a kubernetes auth role is required")
+	}
+	tokenFile := k.TokenFile
+	if tokenFile == "" {
+		tokenFile = ServiceAccountTokenFile
+	}
+	jwt, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the service account token: %w", err)
+	}
+
+	// the client's token must not be sent along
+	clone, err := client.Clone()
+	if err != nil {
+		return "", err
+	}
+	clone.ClearToken()
+
+	secret, err := clone.Logical().Write("auth/"+mount+"/login", map[string]interface{}{
+		"role": k.Role,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("kubernetes login failed: %w", err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return "", fmt.Errorf("kubernetes login returned no token")
+	}
+	return secret.Auth.ClientToken, nil
+}
@@ -0,0 +1,70 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+func TestSuiteKubernetesLogin(tt *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Header.Get("X-Vault-Token") != "" || body["jwt"] != "sa-jwt" || body["role"] != "dumper" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"auth": {"client_token": "%s"}}`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "kubernetes")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("sa-jwt\n"), 0600); err != nil {
+		tt.Fatal(err)
+	}
+
+	client, err := vaultapi.NewClient(&vaultapi.Config{Address: server.URL})
+	if err != nil {
+		tt.Fatal(err)
+	}
+	client.SetToken("stale")
+
+	var (
+		success bool
+		tests   = []struct {
+			description string
+			login       *Kubernetes
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Default mount", &Kubernetes{Role: "dumper", TokenFile: tokenFile}, "/v1/auth/kubernetes/login", true},
+			{"Custom mount", &Kubernetes{Mount: "/k8s/prod/", Role: "dumper", TokenFile: tokenFile}, "/v1/auth/k8s/prod/login", true},
+			{"Rejected role", &Kubernetes{Role: "other", TokenFile: tokenFile}, "", false},
+			{"No role", &Kubernetes{TokenFile: tokenFile}, "", false},
+			{"No token file", &Kubernetes{Role: "dumper", TokenFile: filepath.Join(dir, "missing")}, "", false},
+		}
+	)
+	for _, test := range tests {
+		token, err := test.login.Login(client)
+		success = (err == nil)
+		if success == test.isSuccess && token == test.normOutput && client.Token() == "stale" {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t, %v", test.description, test.isSuccess, success, err)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, token)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package vault
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/sync/syncmap"
+)
+
+// JoinNamespace returns the path of the namespace ns below parent
+func JoinNamespace(parent, ns string) string {
+	parent, ns = NormalizePath(parent), NormalizePath(ns)
+	switch {
+	case parent == "":
+		return ns
+	case ns == "":
+		return parent
+	}
+	return parent + "/" + ns
+}
+
+// WithNamespace returns a copy of vc sending its requests to the namespace ns
+// below the namespace of vc, with the same token and transport
+func (vc *Config) WithNamespace(ns string) (*Config, error) {
+	client, err := vc.Client.Clone()
+	if err != nil {
+		return nil, err
+	}
+	client.SetHeaders(vc.Client.Headers())
+	client.SetToken(vc.Client.Token())
+	full := JoinNamespace(vc.Namespace, ns)
+	client.SetNamespace(full)
+
+	c := *vc
+	c.Client = client
+	c.Namespace = full
+	c.Renew = false
+	c.memo = new(syncmap.Map)
+	c.stop = nil
+	return &c, nil
+}
+
+// Namespaces lists the namespaces below the namespace of vc, recursively,
+// as sorted paths relative to it without trailing slashes
+func (vc *Config) Namespaces() ([]string, error) {
+	var found []string
+	var walk func(c *Config, parent string) error
+	walk = func(c *Config, parent string) error {
+		secret, err := c.Client.Logical().List("sys/namespaces")
+		if err != nil {
+			return err
+		}
+		keys, ok := ExtractListData(secret)
+		if !ok {
+			return nil
+		}
+		for _, k := range keys {
+			name, ok := k.(string)
+			if !ok {
+				continue
+			}
+			ns := JoinNamespace(parent, name)
+			found = append(found, ns)
+			child, err := c.WithNamespace(name)
+			if err != nil {
+				return err
+			}
+			if err := walk(child, ns); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(vc, ""); err != nil {
+		return nil, err
+	}
+	sort.Strings(found)
+	return found, nil
+}
+
+// SplitNamespace returns the longest of namespaces p is below and the rest of
+// p, or "" and p when it is below none of them
+func SplitNamespace(p string, namespaces []string) (string, string) {
+	p = NormalizePath(p)
+	match := ""
+	for _, ns := range namespaces {
+		ns = NormalizePath(ns)
+		if ns != "" && len(ns) > len(match) && strings.HasPrefix(p, ns+"/") {
+			match = ns
+		}
+	}
+	if match == "" {
+		return "", p
+	}
+	return match, strings.TrimPrefix(p, match+"/")
+}
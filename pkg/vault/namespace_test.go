@@ -0,0 +1,55 @@
+package vault
+
+import (
+	"testing"
+)
+
+func TestSuiteSplitNamespace(tt *testing.T) {
+	namespaces := []string{"team-a", "team-a/child", "team-b/"}
+	var (
+		tests = []struct {
+			description string
+			path        string
+			normOutput  string
+		}{
+			{"Namespace", "team-a/secret/data/db", "team-a secret/data/db"},
+			{"Longest namespace", "team-a/child/secret/data/db", "team-a/child secret/data/db"},
+			{"Trailing slash of namespace", "team-b/kv/db", "team-b kv/db"},
+			{"Sibling", "team-ab/secret/data/db", " team-ab/secret/data/db"},
+			{"Base namespace", "secret/data/db", " secret/data/db"},
+			{"Namespace itself", "team-a", " team-a"},
+			{"Normalized", "/team-a//secret/data/db/", "team-a secret/data/db"},
+		}
+	)
+	for _, test := range tests {
+		ns, p := SplitNamespace(test.path, namespaces)
+		if norm := ns + " " + p; norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteJoinNamespace(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			parent      string
+			ns          string
+			normOutput  string
+		}{
+			{"Root", "", "team-a/", "team-a"},
+			{"Child", "team-a/", "child/", "team-a/child"},
+			{"Parent only", "team-a", "", "team-a"},
+			{"Neither", "", "", ""},
+		}
+	)
+	for _, test := range tests {
+		if norm := JoinNamespace(test.parent, test.ns); norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
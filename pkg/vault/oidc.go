@@ -0,0 +1,158 @@
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// oidcTimeout is how long the login in the browser may take
+const oidcTimeout = 5 * time.Minute
+
+// OIDC logs in an operator through the OIDC auth method in their browser,
+// receiving the authorization code on a local callback listener as `vault
+// login -method=oidc` does
+type OIDC struct {
+	// Mount is the path of the OIDC auth method, oidc by default
+	Mount string
+	// Role is the role to log in as, the default role of the mount when empty
+	Role string
+	// CallbackAddr is the address to listen for the callback on, it must
+	// match a redirect URI allowed by the role, localhost:8250 by default
+	CallbackAddr string
+	// Open opens the login URL for the operator, e.g. in a browser, the URL
+	// is only logged when nil
+	Open func(url string) error
+
+	mu sync.Mutex
+	// tokens holds the token of each Vault address logged in to
+	tokens map[string]string
+}
+
+// Login returns a token for the operator, logging in once per Vault address
+// so later logins of --watch do not open the browser again
+func (o *OIDC) Login(client *vaultapi.Client) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if token, ok := o.tokens[client.Address()]; ok {
+		return token, nil
+	}
+
+	mount := strings.Trim(o.Mount, "/")
+	if mount == "" {
+		mount = "oidc"
+	}
+	addr := o.CallbackAddr
+	if addr == "" {
+		addr = "localhost:8250"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to listen for the OIDC callback: %w", err)
+	}
+	defer listener.Close()
+	// a port chosen by the system is only known once listening
+	if _, port, _ := net.SplitHostPort(addr); port == "0" {
+		addr = listener.Addr().String()
+	}
+	redirect := "http://" + addr + "/oidc/callback"
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	clientNonce := hex.EncodeToString(nonce)
+
+	// the client's token must not be sent along
+	clone, err := client.Clone()
+	if err != nil {
+		return "", err
+	}
+	clone.ClearToken()
+
+	secret, err := clone.Logical().Write("auth/"+mount+"/oidc/auth_url", map[string]interface{}{
+		"role":         o.Role,
+		"redirect_uri": redirect,
+		"client_nonce": clientNonce,
+	})
+	if err != nil {
+		return "", fmt.Errorf("OIDC login failed: %w", err)
+	}
+	authURL := ""
+	if secret != nil {
+		authURL, _ = secret.Data["auth_url"].(string)
+	}
+	if authURL == "" {
+		return "", fmt.Errorf("OIDC login returned no URL, check that %s is an allowed redirect URI of the role", redirect)
+	}
+
+	type callback struct {
+		code, state string
+		err         error
+	}
+	done := make(chan callback, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oidc/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		c := callback{code: q.Get("code"), state: q.Get("state")}
+		if e := q.Get("error"); e != "" {
+			c.err = fmt.Errorf("%s: %s", e, q.Get("error_description"))
+		}
+		if c.err != nil {
+			fmt.Fprintln(w, "Login failed, return to vault-dump.")
+		} else {
+			fmt.Fprintln(w, "Login complete, you can close this window.")
+		}
+		select {
+		case done <- c:
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	log.Printf("Complete the login at %s", authURL)
+	if o.Open != nil {
+		if err := o.Open(authURL); err != nil {
+			log.Printf("Failed to open the browser, open the URL above: %s", err)
+		}
+	}
+
+	var c callback
+	select {
+	case c = <-done:
+	case <-time.After(oidcTimeout):
+		return "", fmt.Errorf("OIDC login timed out after %s", oidcTimeout)
+	}
+	if c.err != nil {
+		return "", fmt.Errorf("OIDC login failed: %w", c.err)
+	}
+
+	secret, err = clone.Logical().ReadWithData("auth/"+mount+"/oidc/callback", map[string][]string{
+		"code":         {c.code},
+		"state":        {c.state},
+		"client_nonce": {clientNonce},
+	})
+	if err != nil {
+		return "", fmt.Errorf("OIDC login failed: %w", err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return "", fmt.Errorf("OIDC login returned no token")
+	}
+	if o.tokens == nil {
+		o.tokens = make(map[string]string)
+	}
+	o.tokens[client.Address()] = secret.Auth.ClientToken
+	return secret.Auth.ClientToken, nil
+}
@@ -0,0 +1,99 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+func TestSuiteOIDCLogin(tt *testing.T) {
+	// the identity provider redirects straight back with the query of the
+	// role, as if the operator logged in
+	var (
+		mu     sync.Mutex
+		nonces = map[string]string{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("X-Vault-Token") != "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/auth/oidc/oidc/auth_url", "/v1/auth/sso/oidc/auth_url":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			query := "code=abc&state=st"
+			if body["role"] == "denied" {
+				query = "error=access_denied&error_description=no"
+			}
+			nonces["st"] = body["client_nonce"]
+			fmt.Fprintf(w, `{"data": {"auth_url": "%s?%s"}}`, body["redirect_uri"], query)
+		case "/v1/auth/oidc/oidc/callback", "/v1/auth/sso/oidc/callback":
+			q := r.URL.Query()
+			if q.Get("code") != "abc" || q.Get("client_nonce") != nonces[q.Get("state")] {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, `{"auth": {"client_token": "%s"}}`, r.URL.Path)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client, err := vaultapi.NewClient(&vaultapi.Config{Address: server.URL})
+	if err != nil {
+		tt.Fatal(err)
+	}
+	client.SetToken("stale")
+
+	opened := 0
+	browser := func(u string) error {
+		opened++
+		if _, err := url.Parse(u); err != nil {
+			return err
+		}
+		go func() {
+			if resp, err := http.Get(u); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		return nil
+	}
+	cached := &OIDC{CallbackAddr: "127.0.0.1:0", Open: browser}
+
+	var (
+		success bool
+		tests   = []struct {
+			description string
+			login       *OIDC
+			normOutput  string
+			opened      int
+			isSuccess   bool
+		}{
+			{"Default mount", cached, "/v1/auth/oidc/oidc/callback", 1, true},
+			{"Token reused", cached, "/v1/auth/oidc/oidc/callback", 1, true},
+			{"Custom mount", &OIDC{Mount: "sso", Role: "ops", CallbackAddr: "127.0.0.1:0", Open: browser}, "/v1/auth/sso/oidc/callback", 2, true},
+			{"Denied", &OIDC{Role: "denied", CallbackAddr: "127.0.0.1:0", Open: browser}, "", 3, false},
+		}
+	)
+	for _, test := range tests {
+		token, err := test.login.Login(client)
+		success = (err == nil)
+		if success == test.isSuccess && token == test.normOutput && opened == test.opened && client.Token() == "stale" {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t, %v", test.description, test.isSuccess, success, err)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' opened %d got '%s' opened %d", test.description, test.normOutput, test.opened, token, opened)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PolicyPath is a path to dump split into its mount and the path below it
+type PolicyPath struct {
+	Mount string
+	V2    bool
+	Sub   string
+}
+
+// PolicyPaths resolves the mount of each path for DumpPolicy
+func (vc *Config) PolicyPaths(paths []string) ([]PolicyPath, error) {
+	resolved := []PolicyPath{}
+	for _, p := range paths {
+		p = NormalizePath(p)
+		if p == "" {
+			continue
+		}
+		mountPath, v2, err := vc.kvMount(p)
+		if err != nil {
+			return nil, err
+		}
+		mount := EnsureNoTrailingSlash(mountPath)
+		if mount == "" {
+			// not a KV mount, the path is listed and read as it is
+			mount = p
+		}
+		sub := strings.Trim(strings.TrimPrefix(p, mount), "/")
+		if parts := strings.SplitN(sub, "/", 2); v2 && (parts[0] == "data" || parts[0] == "metadata") {
+			// secret/data/foo and secret/metadata/foo select secret/foo
+			sub = ""
+			if len(parts) == 2 {
+				sub = parts[1]
+			}
+		}
+		resolved = append(resolved, PolicyPath{Mount: mount, V2: v2, Sub: sub})
+	}
+	return resolved, nil
+}
+
+// DumpPolicy returns the HCL of the least privileged policy able to dump
+// paths, metadata grants reading KV version 2 metadata as needed to dump the
+// previous version of deleted secrets
+func DumpPolicy(paths []PolicyPath, metadata bool) string {
+	var b strings.Builder
+	b.WriteString("# Policy for vault-dump, generated for:\n")
+	for _, p := range paths {
+		fmt.Fprintf(&b, "#   %s\n", strings.Trim(p.Mount+"/"+p.Sub, "/"))
+	}
+
+	rule := func(path string, capabilities ...string) {
+		fmt.Fprintf(&b, "\npath %q {\n  capabilities = [\"%s\"]\n}\n", path, strings.Join(capabilities, `", "`))
+	}
+	join := func(parts ...string) string {
+		nonEmpty := []string{}
+		for _, p := range parts {
+			if p != "" {
+				nonEmpty = append(nonEmpty, p)
+			}
+		}
+		return strings.Join(nonEmpty, "/")
+	}
+
+	seen := map[PolicyPath]bool{}
+	for _, p := range paths {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+
+		if !p.V2 {
+			if p.Sub != "" {
+				rule(join(p.Mount, p.Sub), "read")
+			}
+			rule(join(p.Mount, p.Sub, "*"), "list", "read")
+			continue
+		}
+
+		if metadata {
+			if p.Sub != "" {
+				rule(join(p.Mount, "metadata", p.Sub), "read")
+			}
+			rule(join(p.Mount, "metadata", p.Sub, "*"), "list", "read")
+		} else {
+			rule(join(p.Mount, "metadata", p.Sub, "*"), "list")
+		}
+		if p.Sub != "" {
+			rule(join(p.Mount, "data", p.Sub), "read")
+		}
+		rule(join(p.Mount, "data", p.Sub, "*"), "read")
+	}
+	return b.String()
+}
@@ -0,0 +1,37 @@
+package vault
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSuiteDumpPolicy(tt *testing.T) {
+	rule := regexp.MustCompile(`path "([^"]+)" \{\n  capabilities = \[([^\]]+)\]`)
+	var (
+		tests = []struct {
+			description string
+			paths       []PolicyPath
+			metadata    bool
+			normOutput  string
+		}{
+			{"KV v2 mount", []PolicyPath{{Mount: "secret", V2: true}}, false, `secret/metadata/* "list"; secret/data/* "read"`},
+			{"KV v2 path", []PolicyPath{{Mount: "secret", V2: true, Sub: "team/app"}}, false, `secret/metadata/team/app/* "list"; secret/data/team/app "read"; secret/data/team/app/* "read"`},
+			{"KV v2 metadata", []PolicyPath{{Mount: "secret", V2: true, Sub: "app"}}, true, `secret/metadata/app "read"; secret/metadata/app/* "list", "read"; secret/data/app "read"; secret/data/app/* "read"`},
+			{"KV v1 path", []PolicyPath{{Mount: "kv", Sub: "app"}}, false, `kv/app "read"; kv/app/* "list", "read"`},
+			{"KV v1 mount", []PolicyPath{{Mount: "kv"}}, true, `kv/* "list", "read"`},
+			{"Duplicates", []PolicyPath{{Mount: "kv"}, {Mount: "kv"}}, false, `kv/* "list", "read"`},
+		}
+	)
+	for _, test := range tests {
+		norm := []string{}
+		for _, m := range rule.FindAllStringSubmatch(DumpPolicy(test.paths, test.metadata), -1) {
+			norm = append(norm, m[1]+" "+m[2])
+		}
+		if strings.Join(norm, "; ") != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, strings.Join(norm, "; "))
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
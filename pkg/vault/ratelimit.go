@@ -0,0 +1,80 @@
+package vault
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimitTransport wraps next so that at most rate requests per second are
+// sent, every attempt of a retried request included. Requests wait for a
+// token of a bucket holding up to rate tokens, rounded up, so short bursts
+// pass the way they would with the rate limit quotas of Vault.
+func RateLimitTransport(next http.RoundTripper, rate float64) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &rateLimit{next: next, bucket: newBucket(rate, time.Now)}
+}
+
+type rateLimit struct {
+	next   http.RoundTripper
+	bucket *bucket
+}
+
+func (t *rateLimit) RoundTrip(req *http.Request) (*http.Response, error) {
+	if wait := t.bucket.take(); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			t.bucket.giveBack()
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, req.Context().Err()
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// bucket is a token bucket refilled at rate tokens per second. Tokens are
+// taken ahead of time, so waiting requests are sent in the order they came.
+type bucket struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, now func() time.Time) *bucket {
+	burst := math.Max(1, math.Ceil(rate))
+	return &bucket{rate: rate, burst: burst, now: now, tokens: burst, last: now()}
+}
+
+// take takes a token and returns how long to wait until it is available
+func (b *bucket) take() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// giveBack returns a token taken by a request that was not sent
+func (b *bucket) giveBack() {
+	b.mu.Lock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+	b.mu.Unlock()
+}
@@ -0,0 +1,87 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSuiteBucket(tt *testing.T) {
+	type step struct {
+		advance time.Duration
+		wait    time.Duration
+	}
+	var (
+		tests = []struct {
+			description string
+			rate        float64
+			steps       []step
+		}{
+			{"Burst", 2, []step{{0, 0}, {0, 0}, {0, 500 * time.Millisecond}, {0, time.Second}}},
+			{"Refilled", 2, []step{{0, 0}, {0, 0}, {time.Second, 0}, {0, 0}, {0, 500 * time.Millisecond}}},
+			{"Burst capped", 2, []step{{time.Hour, 0}, {0, 0}, {0, 500 * time.Millisecond}}},
+			{"Waiting taken into account", 2, []step{{0, 0}, {0, 0}, {0, 500 * time.Millisecond}, {time.Second, 0}, {0, 500 * time.Millisecond}}},
+			{"Below one per second", 0.5, []step{{0, 0}, {0, 2 * time.Second}, {time.Second, 3 * time.Second}}},
+			{"Fraction rounded up", 1.5, []step{{0, 0}, {0, 0}, {0, 666666666 * time.Nanosecond}}},
+		}
+	)
+	for _, test := range tests {
+		now := time.Unix(0, 0)
+		b := newBucket(test.rate, func() time.Time { return now })
+		failed := false
+		for i, s := range test.steps {
+			now = now.Add(s.advance)
+			if wait := b.take(); wait != s.wait {
+				tt.Errorf("FAIL %s: expected '%s' got '%s' at step %d", test.description, s.wait, wait, i)
+				failed = true
+				break
+			}
+		}
+		if !failed {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteRateLimitTransport(tt *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	transport := RateLimitTransport(nil, 1).(*rateLimit)
+	client := &http.Client{Transport: transport}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	var (
+		tests = []struct {
+			description string
+			ctx         context.Context
+			isSuccess   bool
+		}{
+			{"First request", context.Background(), true},
+			{"Cancelled while waiting", cancelled, false},
+			{"Cancelled again", cancelled, false},
+		}
+	)
+	for _, test := range tests {
+		req, _ := http.NewRequestWithContext(test.ctx, http.MethodGet, server.URL+"/v1/secret/data/a", nil)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if success := err == nil; success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t, %v", test.description, test.isSuccess, success, err)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+	// the cancelled requests gave their tokens back
+	if wait := transport.bucket.take(); wait <= 0 || wait > time.Second {
+		tt.Errorf("FAIL Tokens given back: expected a wait of at most 1s got '%s'", wait)
+	} else {
+		tt.Logf("PASS Tokens given back")
+	}
+}
@@ -0,0 +1,73 @@
+package vault
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// ReadOnlyTransport wraps next so that any request that could change Vault
+// fails, only GET, HEAD and LIST requests, capability lookups, logins and
+// token renewals are sent. Refused requests are answered with a 405 so they
+// are not retried.
+func ReadOnlyTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return readOnly{next}
+}
+
+type readOnly struct {
+	next http.RoundTripper
+}
+
+func (t readOnly) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, "LIST":
+		return t.next.RoundTrip(req)
+	}
+	if (req.Method == http.MethodPost || req.Method == http.MethodPut) && lookupOrLogin(req.URL.Path) {
+		return t.next.RoundTrip(req)
+	}
+
+	msg := fmt.Sprintf("read-only: refused %s %s, this command must never write to Vault", req.Method, req.URL.Path)
+	log.Println("ERROR:", msg)
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return &http.Response{
+		Status:     "405 Method Not Allowed",
+		StatusCode: http.StatusMethodNotAllowed,
+		Proto:      req.Proto,
+		ProtoMajor: req.ProtoMajor,
+		ProtoMinor: req.ProtoMinor,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(fmt.Sprintf(`{"errors":[%q]}`, msg))),
+		Request:    req,
+	}, nil
+}
+
+// lookupOrLogin reports whether a write to path only looks things up, logs
+// in or renews the token, which never changes secrets. Logins are those of
+// auth methods mounted at a single path segment, auth/<mount>/login, and the
+// OIDC login URL, auth/<mount>/oidc/auth_url.
+func lookupOrLogin(path string) bool {
+	switch path {
+	case "/v1/sys/capabilities-self", "/v1/sys/wrapping/lookup", "/v1/sys/wrapping/unwrap", "/v1/auth/token/renew-self":
+		return true
+	}
+	if !strings.HasPrefix(path, "/v1/auth/") {
+		return false
+	}
+	parts := strings.Split(strings.TrimPrefix(path, "/v1/auth/"), "/")
+	if parts[0] == "" || parts[0] == "token" {
+		return false
+	}
+	switch strings.Join(parts[1:], "/") {
+	case "login", "oidc/auth_url":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package vault
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSuiteReadOnly(tt *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: ReadOnlyTransport(nil)}
+	var (
+		tests = []struct {
+			description string
+			method      string
+			path        string
+			normOutput  int
+		}{
+			{"Read", http.MethodGet, "/v1/secret/data/a", http.StatusOK},
+			{"List", "LIST", "/v1/secret/metadata/", http.StatusOK},
+			{"Write", http.MethodPut, "/v1/secret/data/a", http.StatusMethodNotAllowed},
+			{"Create", http.MethodPost, "/v1/secret/data/a", http.StatusMethodNotAllowed},
+			{"Delete", http.MethodDelete, "/v1/secret/data/a", http.StatusMethodNotAllowed},
+			{"Patch", http.MethodPatch, "/v1/secret/data/a", http.StatusMethodNotAllowed},
+			{"Capabilities", http.MethodPost, "/v1/sys/capabilities-self", http.StatusOK},
+			{"Token renewal", http.MethodPut, "/v1/auth/token/renew-self", http.StatusOK},
+			{"Token creation", http.MethodPost, "/v1/auth/token/create", http.StatusMethodNotAllowed},
+			{"AppRole login", http.MethodPut, "/v1/auth/approle/login", http.StatusOK},
+			{"OIDC login URL", http.MethodPut, "/v1/auth/oidc/oidc/auth_url", http.StatusOK},
+			{"Nested path ending in login", http.MethodPut, "/v1/auth/approle/role/login", http.StatusMethodNotAllowed},
+			{"Login below a login", http.MethodPut, "/v1/auth/approle/login/login", http.StatusMethodNotAllowed},
+			{"Token login", http.MethodPut, "/v1/auth/token/login", http.StatusMethodNotAllowed},
+			{"Unwrap", http.MethodPut, "/v1/sys/wrapping/unwrap", http.StatusOK},
+			{"Wrap", http.MethodPut, "/v1/sys/wrapping/wrap", http.StatusMethodNotAllowed},
+			{"Secret ID creation", http.MethodPut, "/v1/auth/approle/role/dump/secret-id", http.StatusMethodNotAllowed},
+		}
+	)
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, server.URL+test.path, strings.NewReader("{}"))
+		resp, err := client.Do(req)
+		if err != nil {
+			tt.Errorf("FAIL %s: %s", test.description, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%d' got '%d'", test.description, test.normOutput, resp.StatusCode)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
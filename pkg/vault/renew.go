@@ -0,0 +1,84 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// renewAfter is how far into its TTL the token is renewed
+var renewAfter = func(ttl time.Duration) time.Duration {
+	return ttl * 2 / 3
+}
+
+// keepAlive renews the client's token before it expires until stop is
+// closed, logging in again with Auth when the token can not be renewed any
+// further. Path tokens are not renewed.
+func (vc *Config) keepAlive(stop <-chan struct{}) {
+	for {
+		info, err := vc.LookupSelf()
+		if err != nil {
+			log.Printf("WARNING: token renewal stopped, failed to look up the token: %s", err)
+			return
+		}
+		// tokens without a TTL never expire
+		if info.TTL <= 0 {
+			return
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(renewAfter(info.TTL)):
+		}
+		if err := vc.refreshToken(info); err != nil {
+			log.Printf("WARNING: token renewal stopped, %s", err)
+			return
+		}
+	}
+}
+
+// refreshToken renews the token described by info, or logs in again when it
+// is not renewable or its renewal is capped by its max TTL
+func (vc *Config) refreshToken(info *TokenInfo) error {
+	var reason error
+	if info.Renewable {
+		secret, err := vc.Client.Auth().Token().RenewSelf(0)
+		if err == nil {
+			ttl, err := secret.TokenTTL()
+			if err != nil {
+				return err
+			}
+			if ttl >= info.TTL {
+				return nil
+			}
+			reason = fmt.Errorf("the token reaches its max TTL in %s", ttl)
+		} else {
+			reason = fmt.Errorf("failed to renew the token: %w", err)
+		}
+	} else {
+		reason = errors.New("the token is not renewable")
+	}
+
+	if vc.Auth == nil {
+		return fmt.Errorf("%s and there are no credentials to log in again", reason)
+	}
+	token, err := vc.Auth.Login(vc.Client)
+	if err != nil {
+		return fmt.Errorf("%s and logging in again failed: %w", reason, err)
+	}
+	if token == vc.Client.Token() {
+		return fmt.Errorf("%s and logging in again returned the same token", reason)
+	}
+	vc.Client.SetToken(token)
+	log.Printf("Logged in to Vault again, %s", reason)
+	return nil
+}
+
+// Close stops renewing the token of a client created with Renew
+func (vc *Config) Close() {
+	if vc.stop != nil {
+		close(vc.stop)
+		vc.stop = nil
+	}
+}
@@ -0,0 +1,106 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+type loginFunc func(client *vaultapi.Client) (string, error)
+
+func (f loginFunc) Login(client *vaultapi.Client) (string, error) {
+	return f(client)
+}
+
+func TestSuiteRefreshToken(tt *testing.T) {
+	var renewTTL int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/token/renew-self" || renewTTL == 0 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, `{"auth": {"client_token": "%s", "lease_duration": %d, "renewable": true}}`, r.Header.Get("X-Vault-Token"), renewTTL)
+	}))
+	defer server.Close()
+
+	login := func(token string, err error) Login {
+		return loginFunc(func(*vaultapi.Client) (string, error) { return token, err })
+	}
+	var (
+		success bool
+		tests   = []struct {
+			description string
+			info        TokenInfo
+			renewTTL    int
+			auth        Login
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Renewed", TokenInfo{TTL: time.Minute, Renewable: true}, 3600, nil, "old", true},
+			{"Renewal capped", TokenInfo{TTL: time.Minute, Renewable: true}, 10, nil, "", false},
+			{"Renewal capped, logged in again", TokenInfo{TTL: time.Minute, Renewable: true}, 10, login("new", nil), "new", true},
+			{"Renewal failed, logged in again", TokenInfo{TTL: time.Minute, Renewable: true}, 0, login("new", nil), "new", true},
+			{"Not renewable", TokenInfo{TTL: time.Minute}, 3600, nil, "", false},
+			{"Not renewable, logged in again", TokenInfo{TTL: time.Minute}, 3600, login("new", nil), "new", true},
+			{"Login failed", TokenInfo{TTL: time.Minute}, 3600, login("", errors.New("denied")), "", false},
+			{"Login returned the same token", TokenInfo{TTL: time.Minute}, 3600, login("old", nil), "", false},
+		}
+	)
+	for _, test := range tests {
+		client, err := vaultapi.NewClient(&vaultapi.Config{Address: server.URL})
+		if err != nil {
+			tt.Fatal(err)
+		}
+		client.SetToken("old")
+		renewTTL = test.renewTTL
+		vc := &Config{Client: client, Auth: test.auth}
+
+		err = vc.refreshToken(&test.info)
+		success = (err == nil)
+		if success == test.isSuccess && (!success || client.Token() == test.normOutput) {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t, %v", test.description, test.isSuccess, success, err)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, client.Token())
+		}
+	}
+}
+
+func TestSuiteKeepAlive(tt *testing.T) {
+	var renewals int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			fmt.Fprint(w, `{"data": {"accessor": "a", "policies": ["dump"], "ttl": 60, "renewable": true}}`)
+		case "/v1/auth/token/renew-self":
+			atomic.AddInt32(&renewals, 1)
+			fmt.Fprint(w, `{"auth": {"client_token": "old", "lease_duration": 60, "renewable": true}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	defer func(f func(time.Duration) time.Duration) { renewAfter = f }(renewAfter)
+	renewAfter = func(ttl time.Duration) time.Duration { return ttl / 6000 }
+
+	vc, err := NewClient(&Config{Address: server.URL, Token: "old", Renew: true, ReadOnly: true})
+	if err != nil {
+		tt.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&renewals) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	vc.Close()
+	if n := atomic.LoadInt32(&renewals); n < 2 {
+		tt.Errorf("FAIL expected the token to be renewed at least twice, renewed %d times", n)
+	}
+}
@@ -0,0 +1,91 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRetryBackoff = time.Second
+	defaultMaxRetryWait = 30 * time.Second
+)
+
+// backoff returns how long to wait before retry number attempt, counted from
+// 0, of a request that got resp: base doubled for every retry, plus up to half
+// of it as jitter, at most max. The Retry-After of a 429 or 503 response is
+// waited for instead, also at most max.
+func backoff(base, max time.Duration) func(_, _ time.Duration, attempt int, resp *http.Response) time.Duration {
+	if base <= 0 {
+		base = defaultRetryBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxRetryWait
+	}
+	return func(_, _ time.Duration, attempt int, resp *http.Response) time.Duration {
+		if wait, ok := retryAfter(resp); ok {
+			if wait > max {
+				return max
+			}
+			return wait
+		}
+		wait := base
+		for i := 0; i < attempt && wait < max; i++ {
+			wait *= 2
+		}
+		if wait < max {
+			wait += time.Duration(rand.Int63n(int64(wait)/2 + 1))
+		}
+		if wait > max {
+			return max
+		}
+		return wait
+	}
+}
+
+// retryAfter returns the wait a 429 or 503 response asks for, in seconds or
+// as a date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(t); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// retryPolicy retries connection errors, 5xx responses but 501 and 429
+// responses, until ctx is done or the request was cancelled, see
+// ContextTransport
+func retryPolicy(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	if errors.Is(err, context.Canceled) {
+		return false, err
+	}
+	if err != nil {
+		return true, err
+	}
+	switch {
+	case resp.StatusCode == 0, resp.StatusCode == http.StatusTooManyRequests:
+		return true, nil
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,86 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSuiteBackoff(tt *testing.T) {
+	response := func(status int, retryAfter string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		if retryAfter != "" {
+			resp.Header.Set("Retry-After", retryAfter)
+		}
+		return resp
+	}
+	wait := backoff(time.Second, 10*time.Second)
+	var (
+		tests = []struct {
+			description string
+			attempt     int
+			resp        *http.Response
+			min         time.Duration
+			max         time.Duration
+		}{
+			{"First retry", 0, response(500, ""), time.Second, 1500 * time.Millisecond},
+			{"Doubled", 2, response(500, ""), 4 * time.Second, 6 * time.Second},
+			{"Capped", 10, response(500, ""), 10 * time.Second, 10 * time.Second},
+			{"Connection error", 1, nil, 2 * time.Second, 3 * time.Second},
+			{"Retry-After seconds", 0, response(429, "3"), 3 * time.Second, 3 * time.Second},
+			{"Retry-After capped", 0, response(429, "120"), 10 * time.Second, 10 * time.Second},
+			{"Retry-After of 503", 3, response(503, "0"), 0, 0},
+			{"Retry-After date", 0, response(429, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)), 10 * time.Second, 10 * time.Second},
+			{"Invalid Retry-After", 0, response(429, "soon"), time.Second, 1500 * time.Millisecond},
+			{"Retry-After of 500 ignored", 0, response(500, "5"), time.Second, 1500 * time.Millisecond},
+		}
+	)
+	for _, test := range tests {
+		got := wait(0, 0, test.attempt, test.resp)
+		if got < test.min || got > test.max {
+			tt.Errorf("FAIL %s: expected %s to %s got %s", test.description, test.min, test.max, got)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteRetryPolicy(tt *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	var (
+		tests = []struct {
+			description string
+			ctx         context.Context
+			status      int
+			err         error
+			normOutput  string
+		}{
+			{"Success", context.Background(), 200, nil, "false"},
+			{"Not found", context.Background(), 404, nil, "false"},
+			{"Too many requests", context.Background(), 429, nil, "true"},
+			{"Server error", context.Background(), 500, nil, "true"},
+			{"Unavailable", context.Background(), 503, nil, "true"},
+			{"Not implemented", context.Background(), 501, nil, "false"},
+			{"Connection error", context.Background(), 0, errors.New("connection refused"), "true"},
+			{"Cancelled", cancelled, 500, nil, "false"},
+			{"Request cancelled", context.Background(), 0, &url.Error{Op: "Get", URL: "/v1/secret/a", Err: context.Canceled}, "false"},
+		}
+	)
+	for _, test := range tests {
+		var resp *http.Response
+		if test.err == nil {
+			resp = &http.Response{StatusCode: test.status}
+		}
+		retry, _ := retryPolicy(test.ctx, resp, test.err)
+		if norm := fmt.Sprint(retry); norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package vault
+
+import (
+	"fmt"
+	"io"
+)
+
+// RaftSnapshot writes a snapshot of the integrated storage of the cluster to
+// w as Vault streams it and returns its size, the token needs read on
+// sys/storage/raft/snapshot
+func (vc *Config) RaftSnapshot(w io.Writer) (int64, error) {
+	resp, err := vc.Client.RawRequest(vc.Client.NewRequest("GET", "/v1/sys/storage/raft/snapshot"))
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to take a raft snapshot: %w", err)
+	}
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return n, fmt.Errorf("failed to download the raft snapshot: %w", err)
+	}
+	return n, nil
+}
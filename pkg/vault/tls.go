@@ -0,0 +1,50 @@
+package vault
+
+import (
+	"os"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// TLS configures how the client verifies Vault and authenticates to it with
+// a certificate. Fields left empty keep what the VAULT_CACERT, VAULT_CAPATH,
+// VAULT_CLIENT_CERT, VAULT_CLIENT_KEY, VAULT_TLS_SERVER_NAME and
+// VAULT_SKIP_VERIFY environment variables configure.
+type TLS struct {
+	// CACert is a PEM file of the CAs to trust, CAPath a directory of them,
+	// either replaces the CAs of the environment
+	CACert string
+	CAPath string
+	// ClientCert and ClientKey are the PEM files of the client certificate
+	ClientCert string
+	ClientKey  string
+	// ServerName is the name to verify the certificate of Vault against
+	ServerName string
+	SkipVerify bool
+}
+
+// configure applies t to the transport of config, which must not be wrapped
+// yet
+func (t *TLS) configure(config *vaultapi.Config) error {
+	if t == nil || *t == (TLS{}) {
+		return nil
+	}
+	c := &vaultapi.TLSConfig{
+		CACert:        t.CACert,
+		CAPath:        t.CAPath,
+		ClientCert:    t.ClientCert,
+		ClientKey:     t.ClientKey,
+		TLSServerName: t.ServerName,
+		Insecure:      t.SkipVerify,
+	}
+	// the half of a client certificate not given comes from the environment
+	if c.ClientCert != "" || c.ClientKey != "" {
+		if c.ClientCert == "" {
+			c.ClientCert = os.Getenv(vaultapi.EnvVaultClientCert)
+		}
+		if c.ClientKey == "" {
+			c.ClientKey = os.Getenv(vaultapi.EnvVaultClientKey)
+		}
+	}
+	return config.ConfigureTLS(c)
+}
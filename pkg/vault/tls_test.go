@@ -0,0 +1,65 @@
+package vault
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+func TestSuiteTLS(tt *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caCert := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600); err != nil {
+		tt.Fatal(err)
+	}
+	for _, env := range []string{vaultapi.EnvVaultCACert, vaultapi.EnvVaultCAPath, vaultapi.EnvVaultClientCert, vaultapi.EnvVaultClientKey, vaultapi.EnvVaultTLSServerName, vaultapi.EnvVaultSkipVerify} {
+		tt.Setenv(env, "")
+	}
+
+	var (
+		success bool
+		tests   = []struct {
+			description string
+			tls         *TLS
+			isSuccess   bool
+		}{
+			{"Untrusted CA", nil, false},
+			{"CA cert", &TLS{CACert: caCert}, true},
+			{"CA path", &TLS{CAPath: dir}, true},
+			{"Skip verify", &TLS{SkipVerify: true}, true},
+			{"Server name", &TLS{CACert: caCert, ServerName: "example.com"}, true},
+			{"Wrong server name", &TLS{CACert: caCert, ServerName: "vault.example.org"}, false},
+			{"Client cert without key", &TLS{CACert: caCert, ClientCert: caCert}, false},
+			{"Missing CA cert", &TLS{CACert: filepath.Join(dir, "missing.pem")}, false},
+		}
+	)
+	for _, test := range tests {
+		config := vaultapi.DefaultConfig()
+		err := test.tls.configure(config)
+		if err == nil {
+			var resp *http.Response
+			if resp, err = config.HttpClient.Get(server.URL); err == nil {
+				resp.Body.Close()
+			}
+		}
+		success = (err == nil)
+		if success == test.isSuccess {
+			tt.Logf("PASS %s", test.description)
+		} else {
+			tt.Errorf("FAIL %s: expected %t got %t, %v", test.description, test.isSuccess, success, err)
+		}
+	}
+}
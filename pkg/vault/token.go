@@ -0,0 +1,94 @@
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TokenInfo describes the token of the client, it never holds the token
+type TokenInfo struct {
+	Accessor    string
+	DisplayName string
+	Policies    []string
+	TTL         time.Duration
+	ExpireTime  string
+	Renewable   bool
+}
+
+// LookupSelf returns the accessor, policies and TTL of the client's token
+func (vc *Config) LookupSelf() (*TokenInfo, error) {
+	secret, err := vc.Client.Auth().Token().LookupSelf()
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("empty token lookup response")
+	}
+
+	info := &TokenInfo{}
+	if info.Accessor, err = secret.TokenAccessor(); err != nil {
+		return nil, err
+	}
+	if info.Policies, err = secret.TokenPolicies(); err != nil {
+		return nil, err
+	}
+	if info.TTL, err = secret.TokenTTL(); err != nil {
+		return nil, err
+	}
+	if info.Renewable, err = secret.TokenIsRenewable(); err != nil {
+		return nil, err
+	}
+	info.DisplayName, _ = secret.Data["display_name"].(string)
+	info.ExpireTime, _ = secret.Data["expire_time"].(string)
+	sort.Strings(info.Policies)
+	return info, nil
+}
+
+// Capability is what the token may do on one of the endpoints a dump of
+// Path uses
+type Capability struct {
+	Path         string
+	Endpoint     string
+	Needed       string
+	Capabilities []string
+}
+
+// Allowed reports whether the token has the capability the dump needs
+func (c Capability) Allowed() bool {
+	for _, capability := range c.Capabilities {
+		if capability == c.Needed || capability == "root" {
+			return true
+		}
+	}
+	return false
+}
+
+// DumpCapabilities returns the capabilities of the token on the endpoints
+// listed and read to dump paths, reads are checked on the path itself, for
+// a single secret, and below it
+func (vc *Config) DumpCapabilities(paths []PolicyPath) ([]Capability, error) {
+	capabilities := []Capability{}
+	for _, p := range paths {
+		name := strings.Trim(p.Mount+"/"+p.Sub, "/")
+		listPath, readPath := name, name
+		if p.V2 {
+			listPath = strings.Trim(p.Mount+"/metadata/"+p.Sub, "/")
+			readPath = strings.Trim(p.Mount+"/data/"+p.Sub, "/")
+		}
+		for _, c := range []Capability{
+			{Path: name, Endpoint: listPath + "/", Needed: "list"},
+			{Path: name, Endpoint: readPath, Needed: "read"},
+			{Path: name, Endpoint: readPath + "/", Needed: "read"},
+		} {
+			caps, err := vc.Client.Sys().CapabilitiesSelf(c.Endpoint)
+			if err != nil {
+				return nil, err
+			}
+			c.Capabilities = caps
+			capabilities = append(capabilities, c)
+		}
+	}
+	return capabilities, nil
+}
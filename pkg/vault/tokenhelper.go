@@ -0,0 +1,97 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+)
+
+// cliConfig is the part of the vault CLI's config file read here
+type cliConfig struct {
+	TokenHelper string `hcl:"token_helper"`
+}
+
+// DefaultToken returns the token the vault CLI uses when none is given,
+// VAULT_TOKEN or else the token of the token helper configured in the CLI's
+// config file, by default the one `vault login` saved in ~/.vault-token. An
+// empty token is returned when there is none.
+func DefaultToken() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", nil
+	}
+
+	configPath := os.Getenv("VAULT_CONFIG_PATH")
+	if configPath == "" {
+		configPath = filepath.Join(home, ".vault")
+	}
+	config, err := readCLIConfig(expandHome(configPath, home))
+	if err != nil {
+		return "", err
+	}
+	if config.TokenHelper != "" {
+		return helperToken(expandHome(config.TokenHelper, home))
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(home, ".vault-token"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// readCLIConfig reads the vault CLI's config file, a missing file is an
+// empty config
+func readCLIConfig(path string) (cliConfig, error) {
+	var config cliConfig
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	if err := hcl.Decode(&config, string(data)); err != nil {
+		return config, fmt.Errorf("invalid vault config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// helperToken runs the token helper with get, which prints the token, looked
+// up in PATH unless it is a path
+func helperToken(helper string) (string, error) {
+	path := helper
+	if !filepath.IsAbs(path) {
+		var err error
+		if path, err = exec.LookPath(helper); err != nil {
+			return "", fmt.Errorf("token helper %s: %w", helper, err)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	c := exec.Command(path, "get")
+	c.Stdout, c.Stderr = &stdout, &stderr
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("token helper %s: %w: %s", helper, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// expandHome replaces a leading ~ of path with home
+func expandHome(path, home string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return filepath.Join(home, path[1:])
+	}
+	return path
+}
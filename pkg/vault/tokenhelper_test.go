@@ -0,0 +1,63 @@
+package vault
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSuiteDefaultToken(tt *testing.T) {
+	dir, err := ioutil.TempDir("", "tokenhelper")
+	if err != nil {
+		tt.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string, mode os.FileMode) string {
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, []byte(content), mode); err != nil {
+			tt.Fatal(err)
+		}
+		return p
+	}
+	write("helper", "#!/bin/sh\n[ \"$1\" = get ] && echo helper-token\n", 0700)
+	write("failing", "#!/bin/sh\necho denied >&2\nexit 1\n", 0700)
+	write("helper.hcl", `token_helper = "~/helper"`, 0600)
+	write("failing.hcl", `token_helper = "`+filepath.Join(dir, "failing")+`"`, 0600)
+	write("invalid.hcl", `token_helper = "unterminated`, 0600)
+
+	var (
+		success bool
+		tests   = []struct {
+			description string
+			home        string
+			envToken    string
+			configPath  string
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"VAULT_TOKEN", dir, "env-token", filepath.Join(dir, "helper.hcl"), "env-token", true},
+			{"Token file", dir, "", "", "file-token", true},
+			{"No token", filepath.Join(dir, "missing"), "", "", "", true},
+			{"Token helper", dir, "", filepath.Join(dir, "helper.hcl"), "helper-token", true},
+			{"Failing token helper", dir, "", filepath.Join(dir, "failing.hcl"), "", false},
+			{"Invalid config", dir, "", filepath.Join(dir, "invalid.hcl"), "", false},
+		}
+	)
+	write(".vault-token", "file-token\n", 0600)
+	for _, test := range tests {
+		tt.Setenv("HOME", test.home)
+		tt.Setenv("VAULT_TOKEN", test.envToken)
+		tt.Setenv("VAULT_CONFIG_PATH", test.configPath)
+
+		token, err := DefaultToken()
+		success = (err == nil)
+		if success == test.isSuccess && token == test.normOutput {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t, %v", test.description, test.isSuccess, success, err)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, token)
+		}
+	}
+}
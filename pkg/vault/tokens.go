@@ -0,0 +1,107 @@
+package vault
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// PathToken selects the token of the requests below Prefix, for trees no
+// single token may read
+type PathToken struct {
+	Prefix string
+	Token  string
+}
+
+// ParsePathTokens reads specs of the form prefix=token
+func ParsePathTokens(specs []string) ([]PathToken, error) {
+	tokens := []PathToken{}
+	for _, spec := range specs {
+		kv := strings.SplitN(spec, "=", 2)
+		if len(kv) != 2 || NormalizePath(kv[0]) == "" || strings.TrimSpace(kv[1]) == "" {
+			return nil, fmt.Errorf("invalid path token %q, expected prefix=token", redact(spec))
+		}
+		tokens = append(tokens, PathToken{Prefix: NormalizePath(kv[0]), Token: strings.TrimSpace(kv[1])})
+	}
+	return tokens, nil
+}
+
+// redact hides the token of an invalid spec in error messages
+func redact(spec string) string {
+	if i := strings.Index(spec, "="); i >= 0 {
+		return spec[:i+1] + "..."
+	}
+	return spec
+}
+
+// kvEndpoints are the API paths below a KV version 2 mount
+var kvEndpoints = []string{"data", "metadata", "delete", "undelete", "destroy"}
+
+// tokenRoutes returns the token of every API path prefix of tokens. The
+// mount of each prefix is looked up with its own token, and on KV version 2
+// mounts the prefix is routed below every API path of the mount.
+func tokenRoutes(client *vaultapi.Client, tokens []PathToken) (map[string]string, error) {
+	routes := make(map[string]string)
+	for _, t := range tokens {
+		clone, err := client.Clone()
+		if err != nil {
+			return nil, err
+		}
+		clone.SetToken(t.Token)
+		vc := &Config{Client: clone, memo: new(sync.Map)}
+		paths, err := vc.PolicyPaths([]string{t.Prefix})
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up the mount of %s: %w", t.Prefix, err)
+		}
+
+		routes[t.Prefix] = t.Token
+		for _, p := range paths {
+			if !p.V2 {
+				routes[strings.Trim(p.Mount+"/"+p.Sub, "/")] = t.Token
+				continue
+			}
+			for _, endpoint := range kvEndpoints {
+				routes[strings.Trim(p.Mount+"/"+endpoint+"/"+p.Sub, "/")] = t.Token
+			}
+		}
+	}
+	return routes, nil
+}
+
+// TokenTransport wraps next so that requests below a prefix of routes are
+// sent with its token instead of the client's, the longest prefix wins.
+// Mount lookups of a path are routed like the path itself.
+func TokenTransport(next http.RoundTripper, routes map[string]string) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return tokenRouter{next, routes}
+}
+
+type tokenRouter struct {
+	next   http.RoundTripper
+	routes map[string]string
+}
+
+func (t tokenRouter) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := strings.TrimPrefix(req.URL.Path, "/v1/")
+	path = strings.TrimPrefix(path, "sys/internal/ui/mounts/")
+
+	match, token := "", ""
+	for prefix, tok := range t.routes {
+		if (path == prefix || strings.HasPrefix(path, prefix+"/")) && len(prefix) > len(match) {
+			match, token = prefix, tok
+		}
+	}
+	if match == "" {
+		return t.next.RoundTrip(req)
+	}
+
+	// a RoundTripper must not modify the request it was given
+	routed := req.Clone(req.Context())
+	routed.Header.Set("X-Vault-Token", token)
+	return t.next.RoundTrip(routed)
+}
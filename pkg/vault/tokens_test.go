@@ -0,0 +1,94 @@
+package vault
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSuiteTokenTransport(tt *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Vault-Token")))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: TokenTransport(nil, map[string]string{
+		"secret/team-a":          "a",
+		"secret/data/team-a":     "a",
+		"secret/metadata/team-a": "a",
+		"secret/data/team-a/ops": "ops",
+		"kv/team-b":              "b",
+	})}
+	var (
+		tests = []struct {
+			description string
+			method      string
+			path        string
+			normOutput  string
+		}{
+			{"Read", http.MethodGet, "/v1/secret/data/team-a/db", "a"},
+			{"List", "LIST", "/v1/secret/metadata/team-a/", "a"},
+			{"Prefix itself", http.MethodGet, "/v1/secret/data/team-a", "a"},
+			{"Longest prefix", http.MethodGet, "/v1/secret/data/team-a/ops/key", "ops"},
+			{"Mount lookup", http.MethodGet, "/v1/sys/internal/ui/mounts/secret/team-a/db", "a"},
+			{"KV v1", http.MethodGet, "/v1/kv/team-b/db", "b"},
+			{"Sibling", http.MethodGet, "/v1/secret/data/team-ab/db", "default"},
+			{"Parent", "LIST", "/v1/secret/metadata/", "default"},
+			{"Other mount", http.MethodGet, "/v1/sys/health", "default"},
+		}
+	)
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, server.URL+test.path, nil)
+		req.Header.Set("X-Vault-Token", "default")
+		resp, err := client.Do(req)
+		if err != nil {
+			tt.Errorf("FAIL %s: %s", test.description, err)
+			continue
+		}
+		body := make([]byte, 64)
+		n, _ := resp.Body.Read(body)
+		resp.Body.Close()
+		if string(body[:n]) != test.normOutput || req.Header.Get("X-Vault-Token") != "default" {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, body[:n])
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+func TestSuiteParsePathTokens(tt *testing.T) {
+	var (
+		success bool
+		tests   = []struct {
+			description string
+			specs       []string
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"None", nil, "", true},
+			{"Prefix", []string{"/secret/team-a/=s.abc"}, "secret/team-a", true},
+			{"Token with equals", []string{"secret/a=s.a=b"}, "secret/a", true},
+			{"No token", []string{"secret/a="}, "", false},
+			{"No prefix", []string{"=s.abc"}, "", false},
+			{"No separator", []string{"secret/a"}, "", false},
+		}
+	)
+	for _, test := range tests {
+		tokens, err := ParsePathTokens(test.specs)
+		success = (err == nil)
+		norm := ""
+		if len(tokens) > 0 {
+			norm = tokens[0].Prefix
+		}
+		if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t", test.description, test.isSuccess, success)
+		} else if success && norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else if !success && len(test.specs) > 0 && strings.Contains(err.Error(), "s.abc") {
+			tt.Errorf("FAIL %s: token in error '%s'", test.description, err)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package vault
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TraceTransport wraps next so that every request is logged to w with its
+// method, URL path, status, latency and retry count. Bodies, query strings
+// and headers are never logged, so neither secrets nor tokens reach the trace.
+func TraceTransport(next http.RoundTripper, w io.Writer) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &trace{
+		next:    next,
+		log:     log.New(w, "", log.LstdFlags|log.Lmicroseconds),
+		retries: map[string]retry{},
+	}
+}
+
+// retryWindow is how long a failed attempt counts towards the retries of the
+// next attempt of the same request
+const retryWindow = time.Minute
+
+type trace struct {
+	next http.RoundTripper
+	log  *log.Logger
+
+	mu sync.Mutex
+	// retries holds the failed attempts of requests that are being retried,
+	// by method and URL, as the Vault client sends each attempt as a new
+	// request
+	retries map[string]retry
+}
+
+type retry struct {
+	count int
+	last  time.Time
+}
+
+func (t *trace) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + req.URL.Host + req.URL.Path
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	latency := time.Since(start)
+
+	status := "error"
+	if err == nil {
+		status = fmt.Sprint(resp.StatusCode)
+	}
+	// same as the retry policy of the Vault client
+	failed := err != nil || resp.StatusCode == 0 || (resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented)
+
+	t.mu.Lock()
+	r := t.retries[key]
+	if time.Since(r.last) > retryWindow {
+		r = retry{}
+	}
+	if failed {
+		t.retries[key] = retry{count: r.count + 1, last: time.Now()}
+	} else {
+		delete(t.retries, key)
+	}
+	t.mu.Unlock()
+
+	t.log.Printf("%s %s%s status=%s latency=%s retry=%d\n", req.Method, req.URL.Host, req.URL.Path, status, latency.Round(time.Microsecond), r.count)
+	return resp, err
+}
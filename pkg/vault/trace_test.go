@@ -0,0 +1,67 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSuiteTrace(tt *testing.T) {
+	var statuses []int
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		status := statuses[0]
+		statuses = statuses[1:]
+		if status == 0 {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(`{"data":{"password":"hunter2"}}`)), Request: req}, nil
+	})
+
+	var (
+		tests = []struct {
+			description string
+			statuses    []int
+			normOutput  string
+		}{
+			{"Success", []int{200}, "GET vault:8200/v1/secret/data/a status=200 retry=0"},
+			{"Not found", []int{404}, "GET vault:8200/v1/secret/data/a status=404 retry=0"},
+			{"Retried", []int{500, 502, 200}, "GET vault:8200/v1/secret/data/a status=500 retry=0|GET vault:8200/v1/secret/data/a status=502 retry=1|GET vault:8200/v1/secret/data/a status=200 retry=2"},
+			{"Transport error", []int{0, 200}, "GET vault:8200/v1/secret/data/a status=error retry=0|GET vault:8200/v1/secret/data/a status=200 retry=1"},
+			{"Not implemented", []int{501, 200}, "GET vault:8200/v1/secret/data/a status=501 retry=0|GET vault:8200/v1/secret/data/a status=200 retry=0"},
+		}
+	)
+	latency := regexp.MustCompile(`^\S+ \S+ | latency=\S+`)
+	for _, test := range tests {
+		var out bytes.Buffer
+		client := &http.Client{Transport: TraceTransport(next, &out)}
+		statuses = test.statuses
+		for range test.statuses {
+			req, _ := http.NewRequest(http.MethodGet, "http://vault:8200/v1/secret/data/a?version=2", strings.NewReader(`{"password":"hunter2"}`))
+			req.Header.Set("X-Vault-Token", "s.token")
+			if resp, err := client.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+
+		lines := []string{}
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			lines = append(lines, latency.ReplaceAllString(line, ""))
+		}
+		norm := strings.Join(lines, "|")
+		if norm != test.normOutput || strings.Contains(out.String(), "hunter2") || strings.Contains(out.String(), "s.token") || strings.Contains(out.String(), "version") {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, out.String())
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
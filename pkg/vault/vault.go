@@ -4,13 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
+	"os"
 	"runtime"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/dathan/go-vault-dump/pkg/fault"
+	"github.com/dathan/go-vault-dump/pkg/usage"
 	vaultapi "github.com/hashicorp/vault/api"
 	"golang.org/x/sync/syncmap"
 )
@@ -28,9 +32,51 @@ type Config struct {
 	Address string
 	Token   string
 	Client  *vaultapi.Client
+	// Retries is how often a failed request, and then a failed write, is
+	// retried, 0 retries neither
 	Retries int
-	Ignore  *Ignore
-	memo    *sync.Map
+	// Brute retries failed writes until they succeed instead
+	Brute  bool
+	Ignore *Ignore
+	// RetryBackoff is the wait before the first retry of a failed request,
+	// doubled for every retry up to MaxRetryWait, see backoff
+	RetryBackoff time.Duration
+	MaxRetryWait time.Duration
+	// Timeout limits each request to Vault, retries are timed separately
+	Timeout time.Duration
+	// RateLimit is the most requests per second sent to Vault, unlimited
+	// when 0, see RateLimitTransport
+	RateLimit float64
+	// Namespace is the Vault Enterprise namespace requests are sent to, the
+	// root namespace when empty
+	Namespace string
+	// Faults injects failures into requests to Vault, see fault.Config
+	Faults *fault.Config
+	// ReadOnly refuses every request that could write to Vault
+	ReadOnly bool
+	// Trace logs every request to Vault, see TraceTransport
+	Trace io.Writer
+	// Usage counts every request to Vault
+	Usage *usage.Counter
+	// TLS configures certificates, see TLS
+	TLS *TLS
+	// PathTokens replace Token below their prefixes, see TokenTransport
+	PathTokens []PathToken
+	// Auth logs in to replace Token when set, see AppRole, AWS and Kubernetes
+	Auth Login
+	// Context fails every request to Vault once it is done, those in flight
+	// included, see ContextTransport
+	Context context.Context
+	// Renew keeps the token alive until Close, see keepAlive
+	Renew bool
+	memo  *sync.Map
+	stop  chan struct{}
+}
+
+// Login logs in to Vault and returns the token to use instead of
+// Config.Token
+type Login interface {
+	Login(client *vaultapi.Client) (string, error)
 }
 
 // Ignore
@@ -49,14 +95,67 @@ type PurgeContext struct {
 
 // NewClient
 func NewClient(vc *Config) (*Config, error) {
-	vaultClient, err := vaultapi.NewClient(vaultapi.DefaultConfig())
+	config := vaultapi.DefaultConfig()
+	if err := vc.TLS.configure(config); err != nil {
+		return &Config{}, errors.New("failed vault client TLS config: " + err.Error())
+	}
+	config.Backoff = backoff(vc.RetryBackoff, vc.MaxRetryWait)
+	config.CheckRetry = retryPolicy
+	config.MaxRetries = vc.Retries
+	if vc.Timeout > 0 {
+		config.HttpClient.Timeout = vc.Timeout
+		config.Timeout = 0
+	}
+	if vc.RateLimit > 0 {
+		config.HttpClient.Transport = RateLimitTransport(config.HttpClient.Transport, vc.RateLimit)
+	}
+	if vc.Faults != nil {
+		config.HttpClient.Transport = vc.Faults.Transport(config.HttpClient.Transport)
+	}
+	if vc.ReadOnly {
+		config.HttpClient.Transport = ReadOnlyTransport(config.HttpClient.Transport)
+	}
+	if vc.Trace != nil {
+		config.HttpClient.Transport = TraceTransport(config.HttpClient.Transport, vc.Trace)
+	}
+	if vc.Usage != nil {
+		config.HttpClient.Transport = vc.Usage.Transport(config.HttpClient.Transport)
+	}
+	if vc.Context != nil {
+		config.HttpClient.Transport = ContextTransport(config.HttpClient.Transport, vc.Context)
+	}
+	vaultClient, err := vaultapi.NewClient(config)
 	if err != nil {
 		return &Config{}, errors.New("failed vault client init: " + err.Error())
 	}
 	vaultClient.SetAddress(vc.Address)
 	vaultClient.SetToken(vc.Token)
+	if vc.Namespace == "" {
+		vc.Namespace = os.Getenv(vaultapi.EnvVaultNamespace)
+	}
+	if vc.Namespace != "" {
+		vaultClient.SetNamespace(vc.Namespace)
+	}
+	if vc.Auth != nil {
+		token, err := vc.Auth.Login(vaultClient)
+		if err != nil {
+			return &Config{}, err
+		}
+		vaultClient.SetToken(token)
+	}
+	if len(vc.PathTokens) > 0 {
+		routes, err := tokenRoutes(vaultClient, vc.PathTokens)
+		if err != nil {
+			return &Config{}, err
+		}
+		config.HttpClient.Transport = TokenTransport(config.HttpClient.Transport, routes)
+	}
 	vc.Client = vaultClient
 	vc.memo = new(syncmap.Map)
+	if vc.Renew {
+		vc.stop = make(chan struct{})
+		go vc.keepAlive(vc.stop)
+	}
 
 	return vc, nil
 }
@@ -87,9 +186,12 @@ func (vc *Config) OverwriteSecret(path string, secret map[string]interface{}) er
 		if retries > 0 {
 			log.Printf("failed, try number %v with error %v\n", retries+1, err.Error())
 		}
+		if vc.cancelled() {
+			return err
+		}
 		time.Sleep(time.Duration(rand.Int31n(1000)) * time.Millisecond)
 		retries++
-		if vc.Retries == 0 {
+		if vc.Brute {
 			continue
 		}
 		if retries > vc.Retries {
@@ -112,9 +214,12 @@ func (vc *Config) OverwriteSecret(path string, secret map[string]interface{}) er
 			}
 		}
 
+		if vc.cancelled() {
+			return err
+		}
 		time.Sleep(time.Duration(rand.Int31n(1000)) * time.Millisecond)
 		retries++
-		if vc.Retries == 0 {
+		if vc.Brute {
 			continue
 		}
 		if retries > vc.Retries {
@@ -142,9 +247,12 @@ func (vc *Config) OverwritePolicy(name string, rules string) error {
 		if retries > 0 {
 			log.Printf("failed, try number %v with error %v\n", retries+1, err.Error())
 		}
+		if vc.cancelled() {
+			return err
+		}
 		time.Sleep(time.Duration(rand.Int31n(1000)) * time.Millisecond)
 		retries++
-		if vc.Retries == 0 {
+		if vc.Brute {
 			continue
 		}
 		if retries > vc.Retries {
@@ -250,16 +358,17 @@ func (vc *Config) PurgePaths(paths []string) error {
 // IsDatabaseConfig
 func IsDatabaseConfig(key string) bool {
 	for _, prefix := range VaultDatabaseConfigPrefix {
-		if strings.HasPrefix(key, EnsureNoTrailingSlash(prefix)) {
+		if strings.HasPrefix(NormalizePath(key), NormalizePath(prefix)) {
 			return true
 		}
 	}
 	return false
 }
+
 // IsPolicy
 func IsPolicy(key string) bool {
 	for _, prefix := range VaultPolicyPrefix {
-		if strings.HasPrefix(key, EnsureNoTrailingSlash(prefix)) {
+		if strings.HasPrefix(NormalizePath(key), NormalizePath(prefix)) {
 			return true
 		}
 	}
@@ -268,9 +377,9 @@ func IsPolicy(key string) bool {
 
 // IsPolicyRoot
 func IsPolicyRoot(key string) bool {
-	key = EnsureNoTrailingSlash(key)
+	key = NormalizePath(key)
 	for _, prefix := range VaultPolicyPrefix {
-		if key == EnsureNoTrailingSlash(prefix) {
+		if key == NormalizePath(prefix) {
 			return true
 		}
 	}
@@ -291,7 +400,7 @@ func purgeKey(key string, cxt *PurgeContext) error {
 			}
 		} else {
 			for _, kk := range VaultPolicyProtected {
-				if key == kk {
+				if NormalizePath(key) == NormalizePath(kk) {
 					return nil
 				}
 			}
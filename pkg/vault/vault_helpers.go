@@ -1,40 +1,143 @@
 package vault
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
 )
 
-// updateIfKVv2 updates the path and secret if the KV engine is version 2
-// uses memoization to reduce number of calls to Vault
-// this function expects the path to have already been sanitized
-func (vc *Config) updateIfKVv2(path string, secret map[string]interface{}) (string, map[string]interface{}, error) {
-	var (
-		err       error
-		mountPath string
-		v2        bool
-	)
+// Cluster identifies a Vault cluster
+type Cluster struct {
+	ID   string `json:"cluster_id"`
+	Name string `json:"cluster_name"`
+}
+
+// String returns the name and ID of the cluster
+func (c Cluster) String() string {
+	return fmt.Sprintf("%s (%s)", c.Name, c.ID)
+}
+
+// Same reports whether both identify the same cluster, by ID when both
+// have one and by name otherwise
+func (c Cluster) Same(other Cluster) bool {
+	if c.ID != "" && other.ID != "" {
+		return c.ID == other.ID
+	}
+	return c.Name == other.Name
+}
+
+// RotateRoot rotates the root credentials of the database connection
+// configured at path, e.g. database/config/mydb
+func (vc *Config) RotateRoot(path string) error {
+	path = NormalizePath(path)
+	i := strings.LastIndex(path, "/config/")
+	if i < 0 {
+		return fmt.Errorf("%s is not a database connection", path)
+	}
+	_, err := vc.Client.Logical().Write(path[:i]+"/rotate-root/"+path[i+len("/config/"):], nil)
+	return err
+}
+
+// Fingerprint returns the identity of the cluster the client talks to
+func (vc *Config) Fingerprint() (Cluster, error) {
+	health, err := vc.Client.Sys().Health()
+	if err != nil {
+		return Cluster{}, err
+	}
+	return Cluster{ID: health.ClusterID, Name: health.ClusterName}, nil
+}
 
+// VersionState is the deletion state of a KV version 2 secret version
+type VersionState struct {
+	Version      int    `json:"version"`
+	DeletionTime string `json:"deletion_time,omitempty"`
+	Destroyed    bool   `json:"destroyed,omitempty"`
+}
+
+// kvMount returns the mount of the path and whether it is a KV version 2
+// mount, uses memoization to reduce number of calls to Vault
+func (vc *Config) kvMount(path string) (string, bool, error) {
 	p := strings.Split(path, "/")
 	mount := p[0]
 
-	mp, ok := vc.memo.Load(mount)
-	if !ok {
-		var version bool
-		mountPath, version, err = IsKVv2(path, vc.Client)
-		if err != nil {
-			return path, secret, err
+	if mp, ok := vc.memo.Load(mount); ok { // use values stored in memo
+		return mount + "/", mp.(bool), nil
+	}
+
+	mountPath, v2, err := IsKVv2(path, vc.Client)
+	if err != nil {
+		return "", false, err
+	}
+	vc.memo.Store(mount, v2)
+	return mountPath, v2, nil
+}
+
+// hasKVv2Prefix reports whether a path below a KV version 2 mount already
+// addresses one of its API endpoints, e.g. secret/data/foo
+func hasKVv2Prefix(path, mountPath string) bool {
+	rest := strings.TrimPrefix(path, mountPath)
+	for _, prefix := range []string{"data", "metadata", "delete", "undelete", "destroy"} {
+		if rest == prefix || strings.HasPrefix(rest, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveMountPath normalizes a path and, on KV version 2 mounts, inserts
+// apiPrefix after the mount, so secret/foo, secret/data/foo and
+// secret/metadata/foo all resolve to the same location
+func (vc *Config) ResolveMountPath(path, apiPrefix string) (string, error) {
+	path = NormalizePath(path)
+	mountPath, v2, err := vc.kvMount(path)
+	if err != nil || !v2 {
+		return path, err
+	}
+	if hasKVv2Prefix(path, mountPath) {
+		parts := strings.SplitN(strings.TrimPrefix(path, mountPath), "/", 2)
+		if parts[0] != "data" && parts[0] != "metadata" {
+			return path, nil
 		}
-		vc.memo.Store(mount, version)
-		v2 = version
+		parts[0] = apiPrefix
+		return mountPath + strings.Join(parts, "/"), nil
+	}
+	return AddPrefixToVKVPath(path, mountPath, apiPrefix), nil
+}
+
+// ResolveSecretPath returns the data path of the secret a request path
+// changes, on KV version 2 mounts the data, metadata, delete, undelete and
+// destroy paths of secret/foo all resolve to secret/data/foo
+func (vc *Config) ResolveSecretPath(path string) (string, error) {
+	path = NormalizePath(path)
+	mountPath, v2, err := vc.kvMount(path)
+	if err != nil || !v2 {
+		return path, err
 	}
+	if !hasKVv2Prefix(path, mountPath) {
+		return AddPrefixToVKVPath(path, mountPath, "data"), nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(path, mountPath), "/", 2)
+	parts[0] = "data"
+	return mountPath + strings.Join(parts, "/"), nil
+}
 
-	if mp != nil { // use values stored in memo
-		v2 = mp.(bool)
-		mountPath = mount + "/"
+// updateIfKVv2 updates the path and secret if the KV engine is version 2
+// this function expects the path to have already been sanitized
+func (vc *Config) updateIfKVv2(path string, secret map[string]interface{}) (string, map[string]interface{}, error) {
+	_, v2, err := vc.kvMount(path)
+	if err != nil {
+		return path, secret, err
 	}
 
 	if v2 {
-		path = AddPrefixToVKVPath(path, mountPath, "data")
+		path, err = vc.ResolveMountPath(path, "data")
+		if err != nil {
+			return path, secret, err
+		}
 		// https://github.com/hashicorp/vault/blob/31ddb809c8e46b2796654f5083cc2ac8b1b3b188/command/kv_put.go#L131
 		secret = map[string]interface{}{
 			"data":    secret,
@@ -44,3 +147,334 @@ func (vc *Config) updateIfKVv2(path string, secret map[string]interface{}) (stri
 
 	return path, secret, nil
 }
+
+// DeletedVersion returns the state of the version a KV version 2 read
+// returned without data because it was deleted or destroyed
+func DeletedVersion(secret *api.Secret) (VersionState, bool) {
+	if secret == nil {
+		return VersionState{}, false
+	}
+	if data, ok := secret.Data["data"]; !ok || data != nil {
+		return VersionState{}, false
+	}
+	metadata, ok := secret.Data["metadata"].(map[string]interface{})
+	if !ok {
+		return VersionState{}, false
+	}
+	state := versionState(metadata)
+	return state, state.Destroyed || state.DeletionTime != ""
+}
+
+// SecretVersion returns the version of a KV version 2 read, or 0 for secrets
+// without versions
+func SecretVersion(secret *api.Secret) int {
+	if secret == nil {
+		return 0
+	}
+	metadata, ok := secret.Data["metadata"].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	return versionState(metadata).Version
+}
+
+// SecretUpdated returns when the version of a KV version 2 secret that was
+// read was written, the zero time for other secrets
+func SecretUpdated(secret *api.Secret) time.Time {
+	if secret == nil {
+		return time.Time{}
+	}
+	metadata, ok := secret.Data["metadata"].(map[string]interface{})
+	if !ok {
+		return time.Time{}
+	}
+	created, _ := metadata["created_time"].(string)
+	t, err := time.Parse(time.RFC3339Nano, created)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// versionState reads the state of a version from its metadata, numbers are
+// decoded as json.Number by the API client
+func versionState(metadata map[string]interface{}) VersionState {
+	state := VersionState{}
+	state.Version, _ = strconv.Atoi(fmt.Sprint(metadata["version"]))
+	state.DeletionTime, _ = metadata["deletion_time"].(string)
+	state.Destroyed, _ = metadata["destroyed"].(bool)
+	return state
+}
+
+// ReadSecret reads the data of the secret at path, a path as written in
+// dumps or without the KV version 2 data prefix, and its version. The data is
+// nil for secrets that do not exist or whose latest version is deleted.
+func (vc *Config) ReadSecret(path string) (map[string]interface{}, int, error) {
+	resolved, err := vc.ResolveMountPath(path, "data")
+	if err != nil {
+		return nil, 0, err
+	}
+	secret, err := vc.Client.Logical().Read(resolved)
+	if err != nil || secret == nil {
+		return nil, 0, err
+	}
+	if _, deleted := DeletedVersion(secret); deleted {
+		return nil, SecretVersion(secret), nil
+	}
+	// secret engine v2 has a different response body
+	data, ok := secret.Data["data"].(map[string]interface{})
+	if !ok {
+		data = secret.Data
+	}
+	return data, SecretVersion(secret), nil
+}
+
+// ReadLatestUndeleted reads the latest version of a KV version 2 secret that
+// is neither deleted nor destroyed, it returns a nil secret without one
+func (vc *Config) ReadLatestUndeleted(path string) (*api.Secret, int, error) {
+	metadataPath, err := vc.ResolveMountPath(path, "metadata")
+	if err != nil {
+		return nil, 0, err
+	}
+	metadata, err := vc.Client.Logical().Read(metadataPath)
+	if err != nil || metadata == nil {
+		return nil, 0, err
+	}
+	versions, _ := metadata.Data["versions"].(map[string]interface{})
+
+	candidates := []int{}
+	for v, raw := range versions {
+		n, err := strconv.Atoi(v)
+		state, ok := raw.(map[string]interface{})
+		if err != nil || !ok {
+			continue
+		}
+		if s := versionState(state); !s.Destroyed && s.DeletionTime == "" {
+			candidates = append(candidates, n)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, 0, nil
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(candidates)))
+
+	secret, err := vc.Client.Logical().ReadWithData(path, map[string][]string{
+		"version": {strconv.Itoa(candidates[0])},
+	})
+	return secret, candidates[0], err
+}
+
+// ReadVersion reads version of the KV version 2 secret at path, a data path,
+// the data is nil for a version that is deleted or destroyed
+func (vc *Config) ReadVersion(path string, version int) (map[string]interface{}, error) {
+	secret, err := vc.Client.Logical().ReadWithData(path, map[string][]string{
+		"version": {strconv.Itoa(version)},
+	})
+	if err != nil || secret == nil {
+		return nil, err
+	}
+	data, _ := secret.Data["data"].(map[string]interface{})
+	return data, nil
+}
+
+// SecretMetadata is what KV version 2 records about a secret besides its
+// values
+type SecretMetadata struct {
+	CurrentVersion int                        `json:"current_version"`
+	OldestVersion  int                        `json:"oldest_version,omitempty"`
+	CreatedTime    string                     `json:"created_time,omitempty"`
+	UpdatedTime    string                     `json:"updated_time,omitempty"`
+	CustomMetadata map[string]string          `json:"custom_metadata,omitempty"`
+	Versions       map[string]VersionMetadata `json:"versions,omitempty"`
+	// VersionCount is the number of versions retained, deleted and
+	// destroyed ones included
+	VersionCount int `json:"version_count,omitempty"`
+	// MaxVersions, CasRequired and DeleteVersionAfter are the settings of
+	// the secret, zero when the mount defaults apply
+	MaxVersions        int    `json:"max_versions,omitempty"`
+	CasRequired        bool   `json:"cas_required,omitempty"`
+	DeleteVersionAfter string `json:"delete_version_after,omitempty"`
+}
+
+// VersionMetadata is the state of a version of a KV version 2 secret
+type VersionMetadata struct {
+	CreatedTime  string `json:"created_time"`
+	DeletionTime string `json:"deletion_time,omitempty"`
+	Destroyed    bool   `json:"destroyed,omitempty"`
+}
+
+// ReadMetadata reads the metadata of the secret at path, a path as written in
+// dumps or with the KV version 2 data prefix. Secrets of other mounts have no
+// metadata and v2 is false, the metadata is nil for secrets that do not exist.
+func (vc *Config) ReadMetadata(path string) (metadata *SecretMetadata, v2 bool, err error) {
+	if _, v2, err = vc.kvMount(NormalizePath(path)); err != nil || !v2 {
+		return nil, false, err
+	}
+	metadataPath, err := vc.ResolveMountPath(path, "metadata")
+	if err != nil {
+		return nil, true, err
+	}
+	secret, err := vc.Client.Logical().Read(metadataPath)
+	if err != nil || secret == nil {
+		return nil, true, err
+	}
+	return parseMetadata(secret.Data), true, nil
+}
+
+// ReadSubkeys reads the key names of the KV version 2 secret at path, a path
+// as written in dumps or with the data prefix, from its subkeys endpoint,
+// returned as a read of its data endpoint with nil in place of every value
+// but nested objects. It returns nil for secrets of other mounts, secrets
+// that do not exist or whose latest version is deleted, and on Vault before
+// 1.10, which has no subkeys endpoint.
+func (vc *Config) ReadSubkeys(path string) (*api.Secret, error) {
+	if _, v2, err := vc.kvMount(NormalizePath(path)); err != nil || !v2 {
+		return nil, err
+	}
+	subkeysPath, err := vc.ResolveMountPath(path, "subkeys")
+	if err != nil {
+		return nil, err
+	}
+	secret, err := vc.Client.Logical().Read(subkeysPath)
+	if err != nil || secret == nil {
+		return nil, err
+	}
+	subkeys, ok := secret.Data["subkeys"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	secret.Data = map[string]interface{}{"data": subkeys, "metadata": secret.Data["metadata"]}
+	return secret, nil
+}
+
+// parseMetadata reads the metadata of a secret as returned by Vault, numbers
+// are decoded as json.Number by the API client
+func parseMetadata(data map[string]interface{}) *SecretMetadata {
+	number := func(v interface{}) int {
+		n, _ := strconv.Atoi(fmt.Sprint(v))
+		return n
+	}
+	m := &SecretMetadata{
+		CurrentVersion: number(data["current_version"]),
+		OldestVersion:  number(data["oldest_version"]),
+	}
+	m.CreatedTime, _ = data["created_time"].(string)
+	m.UpdatedTime, _ = data["updated_time"].(string)
+	m.MaxVersions = number(data["max_versions"])
+	m.CasRequired, _ = data["cas_required"].(bool)
+	if after, _ := data["delete_version_after"].(string); after != "0s" {
+		m.DeleteVersionAfter = after
+	}
+	if custom, ok := data["custom_metadata"].(map[string]interface{}); ok && len(custom) > 0 {
+		m.CustomMetadata = make(map[string]string, len(custom))
+		for k, v := range custom {
+			m.CustomMetadata[k] = fmt.Sprint(v)
+		}
+	}
+	if versions, ok := data["versions"].(map[string]interface{}); ok && len(versions) > 0 {
+		m.Versions = make(map[string]VersionMetadata, len(versions))
+		for v, raw := range versions {
+			state, _ := raw.(map[string]interface{})
+			vm := VersionMetadata{}
+			vm.CreatedTime, _ = state["created_time"].(string)
+			vm.DeletionTime, _ = state["deletion_time"].(string)
+			vm.Destroyed, _ = state["destroyed"].(bool)
+			m.Versions[v] = vm
+		}
+		m.VersionCount = len(m.Versions)
+	}
+	return m
+}
+
+// ApplyVersionState deletes or destroys the current version of a KV version
+// 2 secret to match state, paths without a current version are left as they are
+func (vc *Config) ApplyVersionState(path string, state VersionState) error {
+	if !state.Destroyed && state.DeletionTime == "" {
+		return nil
+	}
+	_, v2, err := vc.kvMount(NormalizePath(path))
+	if err != nil || !v2 {
+		return err
+	}
+	metadataPath, err := vc.ResolveMountPath(path, "metadata")
+	if err != nil {
+		return err
+	}
+	metadata, err := vc.Client.Logical().Read(metadataPath)
+	if err != nil {
+		return err
+	}
+	if metadata == nil {
+		return nil
+	}
+	current, _ := strconv.Atoi(fmt.Sprint(metadata.Data["current_version"]))
+	if current == 0 {
+		return nil
+	}
+
+	action := "delete"
+	if state.Destroyed {
+		action = "destroy"
+	}
+	actionPath, err := vc.ResolveMountPath(path, action)
+	if err != nil {
+		return err
+	}
+	_, err = vc.Client.Logical().Write(actionPath, map[string]interface{}{
+		"versions": []int{current},
+	})
+	return err
+}
+
+// WriteMetadata replaces the custom metadata and settings of the KV version
+// 2 secret at path with those of m. Secrets of other mounts have no metadata,
+// they are left as they are and false is returned.
+func (vc *Config) WriteMetadata(path string, m *SecretMetadata) (bool, error) {
+	_, v2, err := vc.kvMount(NormalizePath(path))
+	if err != nil || !v2 {
+		return false, err
+	}
+	metadataPath, err := vc.ResolveMountPath(path, "metadata")
+	if err != nil {
+		return true, err
+	}
+	custom := m.CustomMetadata
+	if custom == nil {
+		custom = map[string]string{}
+	}
+	after := m.DeleteVersionAfter
+	if after == "" {
+		after = "0s"
+	}
+	_, err = vc.Client.Logical().Write(metadataPath, map[string]interface{}{
+		"max_versions":         m.MaxVersions,
+		"cas_required":         m.CasRequired,
+		"delete_version_after": after,
+		"custom_metadata":      custom,
+	})
+	return true, err
+}
+
+// WriteSecretCAS writes a secret only if its current version is version, 0
+// when it must not exist. Check-and-set needs KV version 2, on other mounts
+// the secret is written as is and false is returned.
+func (vc *Config) WriteSecretCAS(path string, data map[string]interface{}, version int) (bool, error) {
+	path = NormalizePath(path)
+	_, v2, err := vc.kvMount(path)
+	if err != nil {
+		return false, err
+	}
+	if !v2 {
+		_, err := vc.Client.Logical().Write(path, data)
+		return false, err
+	}
+	resolved, err := vc.ResolveMountPath(path, "data")
+	if err != nil {
+		return true, err
+	}
+	_, err = vc.Client.Logical().Write(resolved, map[string]interface{}{
+		"data":    data,
+		"options": map[string]interface{}{"cas": version},
+	})
+	return true, err
+}
@@ -0,0 +1,53 @@
+package vault
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSuiteParseMetadata(tt *testing.T) {
+	var (
+		tests = []struct {
+			description string
+			inputs      map[string]interface{}
+			normOutput  string
+		}{
+			{"Empty", map[string]interface{}{}, `{"current_version":0}`},
+			{"Versions", map[string]interface{}{
+				"current_version": json.Number("2"),
+				"oldest_version":  json.Number("1"),
+				"created_time":    "2021-01-01T00:00:00Z",
+				"updated_time":    "2021-02-01T00:00:00Z",
+				"custom_metadata": nil,
+				"versions": map[string]interface{}{
+					"1": map[string]interface{}{"created_time": "2021-01-01T00:00:00Z", "deletion_time": "", "destroyed": true},
+					"2": map[string]interface{}{"created_time": "2021-02-01T00:00:00Z", "deletion_time": "2021-03-01T00:00:00Z", "destroyed": false},
+				},
+			}, `{"current_version":2,"oldest_version":1,"created_time":"2021-01-01T00:00:00Z","updated_time":"2021-02-01T00:00:00Z","versions":{"1":{"created_time":"2021-01-01T00:00:00Z","destroyed":true},"2":{"created_time":"2021-02-01T00:00:00Z","deletion_time":"2021-03-01T00:00:00Z"}},"version_count":2}`},
+			{"Custom metadata", map[string]interface{}{
+				"current_version": json.Number("1"),
+				"custom_metadata": map[string]interface{}{"owner": "team-a", "tier": "1"},
+			}, `{"current_version":1,"custom_metadata":{"owner":"team-a","tier":"1"}}`},
+			{"Settings", map[string]interface{}{
+				"current_version":      json.Number("1"),
+				"max_versions":         json.Number("5"),
+				"cas_required":         true,
+				"delete_version_after": "720h0m0s",
+			}, `{"current_version":1,"max_versions":5,"cas_required":true,"delete_version_after":"720h0m0s"}`},
+			{"Default settings", map[string]interface{}{
+				"current_version":      json.Number("1"),
+				"max_versions":         json.Number("0"),
+				"cas_required":         false,
+				"delete_version_after": "0s",
+			}, `{"current_version":1}`},
+		}
+	)
+	for _, test := range tests {
+		b, _ := json.Marshal(parseMetadata(test.inputs))
+		if norm := string(b); norm != test.normOutput {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, norm)
+		} else {
+			tt.Logf("PASS %s", test.description)
+		}
+	}
+}
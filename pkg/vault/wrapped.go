@@ -0,0 +1,66 @@
+package vault
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// WrappedToken logs in with the token wrapped in a response wrapping token,
+// as handed out for secure introduction instead of the token itself
+type WrappedToken struct {
+	Token string
+
+	mu        sync.Mutex
+	unwrapped string
+}
+
+// Login unwraps the token on the first login, a wrapping token can only be
+// unwrapped once so later logins return the same token
+func (w *WrappedToken) Login(client *vaultapi.Client) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.unwrapped != "" {
+		return w.unwrapped, nil
+	}
+	if w.Token == "" {
+		return "", fmt.Errorf("a wrapping token is required to unwrap")
+	}
+
+	// the wrapping token is its own authentication, no other token may be
+	// sent along
+	clone, err := client.Clone()
+	if err != nil {
+		return "", err
+	}
+	clone.ClearToken()
+
+	lookup, err := clone.Logical().Write("sys/wrapping/lookup", map[string]interface{}{"token": w.Token})
+	if err != nil {
+		return "", fmt.Errorf("invalid wrapping token, it may have expired or been unwrapped already: %w", err)
+	}
+	if lookup == nil {
+		return "", fmt.Errorf("invalid wrapping token")
+	}
+	// tokens are only created below auth/, a token wrapping anything else
+	// was not made for us
+	path, _ := lookup.Data["creation_path"].(string)
+	if !strings.HasPrefix(path, "auth/") {
+		return "", fmt.Errorf("wrapping token was created by %q, which does not create tokens", path)
+	}
+
+	secret, err := clone.Logical().Unwrap(w.Token)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap the token: %w", err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return "", fmt.Errorf("failed to unwrap the token: no token in the response")
+	}
+	log.Println("Unwrapped the token")
+	w.unwrapped, w.Token = secret.Auth.ClientToken, ""
+	return w.unwrapped, nil
+}
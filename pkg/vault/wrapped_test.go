@@ -0,0 +1,81 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+func TestSuiteWrappedTokenLogin(tt *testing.T) {
+	// wrapping tokens by the path that created them, each unwraps once
+	created := map[string]string{
+		"w-token":  "auth/token/create",
+		"w-login":  "auth/approle/login",
+		"w-secret": "secret/data/app",
+	}
+	unwrapped := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// unwrap sends the wrapping token as the token of the request
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["token"] == "" {
+			body = map[string]string{"token": r.Header.Get("X-Vault-Token")}
+		}
+		path, ok := created[body["token"]]
+		if !ok || unwrapped[body["token"]] {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errors": ["wrapping token is not valid or does not exist"]}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/sys/wrapping/lookup":
+			fmt.Fprintf(w, `{"data": {"creation_path": "%s"}}`, path)
+		case "/v1/sys/wrapping/unwrap":
+			unwrapped[body["token"]] = true
+			fmt.Fprintf(w, `{"auth": {"client_token": "inner-%s"}}`, body["token"])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, err := vaultapi.NewClient(&vaultapi.Config{Address: server.URL})
+	if err != nil {
+		tt.Fatal(err)
+	}
+	client.SetToken("stale")
+
+	again := &WrappedToken{Token: "w-token"}
+	var (
+		success bool
+		tests   = []struct {
+			description string
+			login       *WrappedToken
+			normOutput  string
+			isSuccess   bool
+		}{
+			{"Token", again, "inner-w-token", true},
+			{"Second login", again, "inner-w-token", true},
+			{"Token of a login", &WrappedToken{Token: "w-login"}, "inner-w-login", true},
+			{"Wrapped secret", &WrappedToken{Token: "w-secret"}, "", false},
+			{"Already unwrapped", &WrappedToken{Token: "w-token"}, "", false},
+			{"Unknown token", &WrappedToken{Token: "random"}, "", false},
+			{"No token", &WrappedToken{}, "", false},
+		}
+	)
+	for _, test := range tests {
+		token, err := test.login.Login(client)
+		success = (err == nil)
+		if success == test.isSuccess && token == test.normOutput && client.Token() == "stale" {
+			tt.Logf("PASS %s", test.description)
+		} else if success != test.isSuccess {
+			tt.Errorf("FAIL %s: expected %t got %t, %v", test.description, test.isSuccess, success, err)
+		} else {
+			tt.Errorf("FAIL %s: expected '%s' got '%s'", test.description, test.normOutput, token)
+		}
+	}
+}